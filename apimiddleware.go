@@ -28,7 +28,10 @@ const (
 	ctxTxInOutIndex
 	ctxSearch
 	ctxN
+	ctxM
+	ctxStakeDiffWindow
 	ctxStakeVersionLatest
+	ctxAgendaID
 )
 
 // CacheControl creates a new middleware to set the HTTP response header with
@@ -109,11 +112,58 @@ func NPathCtx(next http.Handler) http.Handler {
 			http.NotFound(w, r)
 			return
 		}
+		if N < 0 {
+			apiLog.Infof("Negative numeric value: %d", N)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
 		ctx := context.WithValue(r.Context(), ctxN, N)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// MPathCtx returns a http.HandlerFunc that embeds the value at the url part
+// {M} into the request context
+func MPathCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pathMStr := chi.URLParam(r, "M")
+		M, err := strconv.Atoi(pathMStr)
+		if err != nil {
+			apiLog.Infof("No/invalid numeric value (uint64): %v", err)
+			http.NotFound(w, r)
+			return
+		}
+		if M < 0 {
+			apiLog.Infof("Negative numeric value: %d", M)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		ctx := context.WithValue(r.Context(), ctxM, M)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// StakeDiffWindowPathCtx returns a http.HandlerFunc that embeds the value at
+// the url part {window} into the request context
+func StakeDiffWindowPathCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pathWindowStr := chi.URLParam(r, "window")
+		window, err := strconv.Atoi(pathWindowStr)
+		if err != nil {
+			apiLog.Infof("No/invalid window value (int64): %v", err)
+			http.NotFound(w, r)
+			return
+		}
+		if window < 0 {
+			apiLog.Infof("Negative window value: %d", window)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		ctx := context.WithValue(r.Context(), ctxStakeDiffWindow, window)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func (c *appContext) StakeVersionLatestCtx(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ver := -1
@@ -219,6 +269,16 @@ func AddressPathCtx(next http.Handler) http.Handler {
 	})
 }
 
+// AgendaIDPathCtx returns a http.HandlerFunc that embeds the value at the url
+// part {agendaid} into the request context
+func AgendaIDPathCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agendaID := chi.URLParam(r, "agendaid")
+		ctx := context.WithValue(r.Context(), ctxAgendaID, agendaID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 // apiDocs generates a middleware with a "docs" in the context containing a
 // map of the routers handlers, etc.
 func apiDocs(mux *chi.Mux) func(next http.Handler) http.Handler {
@@ -44,6 +44,7 @@ func newAPIRouter(app *appContext, userRealIP bool) apiMux {
 			rd.Get("/size", app.getBlockSize)
 			rd.With((middleware.Compress(1))).Get("/verbose", app.getBlockVerbose)
 			rd.Get("/pos", app.getBlockStakeInfoExtended)
+			rd.Get("/missed", app.getBlockMissedTickets)
 			rd.Route("/tx", func(rt chi.Router) {
 				rt.Get("/", app.getBlockTransactions)
 				rt.Get("/count", app.getBlockTransactionsCount)
@@ -58,6 +59,7 @@ func newAPIRouter(app *appContext, userRealIP bool) apiMux {
 			rd.Get("/size", app.getBlockSize)
 			rd.With((middleware.Compress(1))).Get("/verbose", app.getBlockVerbose)
 			rd.Get("/pos", app.getBlockStakeInfoExtended)
+			rd.Get("/missed", app.getBlockMissedTickets)
 			rd.Route("/tx", func(rt chi.Router) {
 				rt.Get("/", app.getBlockTransactions)
 				rt.Get("/count", app.getBlockTransactionsCount)
@@ -72,6 +74,7 @@ func newAPIRouter(app *appContext, userRealIP bool) apiMux {
 			rd.Get("/size", app.getBlockSize)
 			rd.With((middleware.Compress(1))).Get("/verbose", app.getBlockVerbose)
 			rd.Get("/pos", app.getBlockStakeInfoExtended)
+			rd.Get("/missed", app.getBlockMissedTickets)
 			rd.Route("/tx", func(rt chi.Router) {
 				rt.Get("/", app.getBlockTransactions)
 				rt.Get("/count", app.getBlockTransactionsCount)
@@ -104,6 +107,7 @@ func newAPIRouter(app *appContext, userRealIP bool) apiMux {
 			rd.With(app.BlockIndexLatestCtx).Get("/", app.getTicketPoolInfo)
 			rd.With(BlockIndexPathCtx).Get("/b/{idx}", app.getTicketPoolInfo)
 			rd.With(BlockIndex0PathCtx, BlockIndexPathCtx).Get("/r/{idx0}/{idx}", app.getTicketPoolInfoRange)
+			rd.Get("/distribution", app.getTicketPoolDistribution)
 		})
 		r.Route("/diff", func(rd chi.Router) {
 			rd.Get("/", app.getStakeDiffSummary)
@@ -111,7 +115,24 @@ func newAPIRouter(app *appContext, userRealIP bool) apiMux {
 			rd.Get("/estimates", app.getStakeDiffEstimates)
 			rd.With(BlockIndexPathCtx).Get("/b/{idx}", app.getStakeDiff)
 			rd.With(BlockIndex0PathCtx, BlockIndexPathCtx).Get("/r/{idx0}/{idx}", app.getStakeDiffRange)
+			rd.Get("/windows", app.getStakeDiffWindows)
+			rd.With(NPathCtx).Get("/windows/count/{N}", app.getStakeDiffWindows)
+			rd.With(StakeDiffWindowPathCtx).Get("/windows/{window}", app.getStakeDiffWindow)
 		})
+		r.Route("/versions", func(rd chi.Router) {
+			rd.Get("/", app.getStakeVersions)
+			rd.Get("/intervals", app.getStakeVersionIntervals)
+			rd.With(NPathCtx).Get("/count/{N}", app.getLatestVersionWindow)
+			rd.With(BlockIndex0PathCtx, BlockIndexPathCtx).Get("/r/{idx0}/{idx}", app.getVersionWindow)
+		})
+		r.With(TransactionHashCtx).Get("/ticket/{txid}", app.getTicketInfo)
+	})
+
+	mux.Get("/agendas", app.getAgendas)
+	mux.Route("/agenda/{agendaid}", func(r chi.Router) {
+		r.Use(AgendaIDPathCtx)
+		r.Get("/", app.getAgenda)
+		r.With(BlockIndex0PathCtx, BlockIndexPathCtx).Get("/r/{idx0}/{idx}", app.getAgendaBlocks)
 	})
 
 	mux.Route("/tx", func(r chi.Router) {
@@ -120,7 +141,11 @@ func newAPIRouter(app *appContext, userRealIP bool) apiMux {
 			rd.Get("/", app.getTransaction)
 			rd.Route("/out", func(ro chi.Router) {
 				ro.Get("/", app.getTransactionOutputs)
-				ro.With(TransactionIOIndexCtx).Get("/{txinoutindex}", app.getTransactionOutput)
+				ro.Route("/{txinoutindex}", func(rn chi.Router) {
+					rn.Use(TransactionIOIndexCtx)
+					rn.Get("/", app.getTransactionOutput)
+					rn.Get("/spender", app.getTransactionOutputSpender)
+				})
 			})
 			rd.Route("/in", func(ri chi.Router) {
 				ri.Get("/", app.getTransactionInputs)
@@ -135,16 +160,33 @@ func newAPIRouter(app *appContext, userRealIP bool) apiMux {
 			rd.Use(AddressPathCtx)
 			rd.Get("/", app.getAddressTransactions)
 			rd.With((middleware.Compress(1))).Get("/raw", app.getAddressTransactionsRaw)
+			rd.Get("/balance", app.getAddressBalance)
+			rd.Get("/totals", app.getAddressTotals)
+			rd.With((middleware.Compress(1))).Get("/utxo", app.getAddressUTXO)
 			rd.Route("/count/{N}", func(ri chi.Router) {
 				ri.Use(NPathCtx)
 				ri.Get("/", app.getAddressTransactions)
 				ri.With((middleware.Compress(1))).Get("/raw", app.getAddressTransactionsRaw)
+				ri.Route("/skip/{M}", func(rs chi.Router) {
+					rs.Use(MPathCtx)
+					rs.Get("/", app.getAddressTransactions)
+					rs.With((middleware.Compress(1))).Get("/raw", app.getAddressTransactionsRaw)
+				})
 			})
 		})
 	})
 
 	mux.Route("/mempool", func(r chi.Router) {
-		r.Get("/", http.NotFound /*app.getMempoolOverview*/)
+		r.Get("/", app.getMempoolSummary)
+		r.Get("/txs", app.getMempoolTxs)
+		r.Route("/votes", func(rd chi.Router) {
+			rd.Get("/", app.getMempoolVotes)
+			rd.Get("/blocks", app.getMempoolBlockVotes)
+			rd.Get("/tip", app.getMempoolTipVotes)
+		})
+		r.Get("/feeestimate", app.getMempoolFeeEstimate)
+		r.Get("/conflicts", app.getMempoolConflicts)
+		r.Get("/history", app.getMempoolHistory)
 		// ticket purchases
 		r.Route("/sstx", func(rd chi.Router) {
 			rd.Get("/", app.getSSTxSummary)
@@ -152,6 +194,7 @@ func newAPIRouter(app *appContext, userRealIP bool) apiMux {
 			rd.With(NPathCtx).Get("/fees/{N}", app.getSSTxFees)
 			rd.Get("/details", app.getSSTxDetails)
 			rd.With(NPathCtx).Get("/details/{N}", app.getSSTxDetails)
+			rd.Get("/inclusion", app.getTicketInclusion)
 		})
 	})
 
@@ -7,8 +7,10 @@ import (
 	"net/http"
 	"strconv"
 	"sync"
+	"time"
 
 	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/dcrdata/dcrdata/mempool"
 	"github.com/decred/dcrd/dcrjson"
 	"github.com/decred/dcrd/rpcclient"
 )
@@ -31,6 +33,15 @@ type APIDataSource interface {
 	GetStakeVersionsLatest() (*dcrjson.StakeVersions, error)
 	GetAllTxIn(txid string) []*apitypes.TxIn
 	GetAllTxOut(txid string) []*apitypes.TxOut
+	GetTxOutSpender(txid string, vout uint32) *apitypes.TxOutSpender
+	GetTicketInfo(txid string) *apitypes.TicketInfo
+	GetAgendas() []*apitypes.Agenda
+	GetAgenda(agendaID string) *apitypes.Agenda
+	GetAgendaBlocks(agendaID string, idx0, idx int64) *apitypes.Agenda
+	GetStakeVersionsSummary() *apitypes.StakeVersionsSummary
+	GetStakeVersionIntervals() []*apitypes.VersionWindow
+	GetVersionWindow(idx0, idx int64) *apitypes.VersionWindow
+	GetLatestVersionWindow(N int64) *apitypes.VersionWindow
 	GetTransactionsForBlock(idx int64) *apitypes.BlockTransactions
 	GetTransactionsForBlockByHash(hash string) *apitypes.BlockTransactions
 	GetFeeInfo(idx int) *dcrjson.FeeInfoBlock
@@ -38,6 +49,9 @@ type APIDataSource interface {
 	GetStakeInfoExtended(idx int) *apitypes.StakeInfoExtended
 	//needs db update: GetStakeInfoExtendedByHash(hash string) *apitypes.StakeInfoExtended
 	GetStakeDiffEstimates() *apitypes.StakeDiff
+	EstimateStakeDiff(tickets *uint32) (*dcrjson.EstimateStakeDiffResult, error)
+	GetStakeDiffWindows(w0, w1 int64) []*apitypes.StakeDiffWindow
+	GetLatestStakeDiffWindows(count int64) []*apitypes.StakeDiffWindow
 	//GetBestBlock() *blockdata.BlockData
 	GetSummary(idx int) *apitypes.BlockDataBasic
 	GetSummaryByHash(hash string) *apitypes.BlockDataBasic
@@ -47,14 +61,27 @@ type APIDataSource interface {
 	GetPoolInfo(idx int) *apitypes.TicketPoolInfo
 	GetPoolInfoByHash(hash string) *apitypes.TicketPoolInfo
 	GetPoolInfoRange(idx0, idx1 int) []apitypes.TicketPoolInfo
+	GetMissedTickets(idx int) []string
+	GetPoolDistribution(priceWidth float64, ageWidth int64) *apitypes.TicketPoolDistribution
 	GetPoolValAndSizeRange(idx0, idx1 int) ([]float64, []float64)
 	GetSDiff(idx int) float64
 	GetSDiffRange(idx0, idx1 int) []float64
 	GetMempoolSSTxSummary() *apitypes.MempoolTicketFeeInfo
 	GetMempoolSSTxFeeRates(N int) *apitypes.MempoolTicketFees
 	GetMempoolSSTxDetails(N int) *apitypes.MempoolTicketDetails
-	GetAddressTransactions(addr string, count int) *apitypes.Address
-	GetAddressTransactionsRaw(addr string, count int) []*apitypes.AddressTxRaw
+	GetMempoolSummary() *apitypes.MempoolSummary
+	GetMempoolTxs(txType string) *apitypes.MempoolTxs
+	GetMempoolFeeEstimate(blocks int) (*apitypes.MempoolFeeEstimate, error)
+	GetMempoolBlockVotes() []*apitypes.MempoolBlockVotes
+	GetMempoolTipVotes() *apitypes.MempoolTipVotes
+	GetMempoolConflicts() *apitypes.MempoolConflicts
+	GetMempoolHistory(from, to, step int64) (*apitypes.MempoolHistory, error)
+	GetTicketInclusion() []*apitypes.TicketInclusionWindow
+	GetAddressTransactions(addr string, count, skip int) *apitypes.Address
+	GetAddressTransactionsRaw(addr string, count, skip int) []*apitypes.AddressTxRaw
+	GetAddressBalance(addr string) *apitypes.AddressBalance
+	GetAddressTotals(addr string) *apitypes.AddressTotals
+	GetAddressUTXO(addr string) []*apitypes.AddressUTXO
 }
 
 // dcrdata application context used by all route handlers
@@ -220,6 +247,16 @@ func (c *appContext) getBlockHeightCtx(r *http.Request) int64 {
 	return idx
 }
 
+// blockNotFound reports whether the block at the height idx given by
+// getBlockHeightCtx does not exist, either because its hash is unknown or
+// because it is above the best block.
+func (c *appContext) blockNotFound(r *http.Request, idx int64) bool {
+	if idx < 0 {
+		return getBlockHashOnlyCtx(r) != ""
+	}
+	return idx > int64(c.BlockData.GetHeight())
+}
+
 func getTxIDCtx(r *http.Request) string {
 	hash, ok := r.Context().Value(ctxTxHash).(string)
 	if !ok {
@@ -247,6 +284,15 @@ func getAddressCtx(r *http.Request) string {
 	return address
 }
 
+func getAgendaIDCtx(r *http.Request) string {
+	agendaID, ok := r.Context().Value(ctxAgendaID).(string)
+	if !ok {
+		apiLog.Trace("agendaid not set")
+		return ""
+	}
+	return agendaID
+}
+
 func getNCtx(r *http.Request) int {
 	N, ok := r.Context().Value(ctxN).(int)
 	if !ok {
@@ -256,6 +302,26 @@ func getNCtx(r *http.Request) int {
 	return N
 }
 
+// getMCtx returns the number of items to skip, which is 0 when there is no
+// {M} path part.
+func getMCtx(r *http.Request) int {
+	M, ok := r.Context().Value(ctxM).(int)
+	if !ok {
+		apiLog.Trace("M not set")
+		return 0
+	}
+	return M
+}
+
+func getStakeDiffWindowCtx(r *http.Request) int {
+	window, ok := r.Context().Value(ctxStakeDiffWindow).(int)
+	if !ok {
+		apiLog.Error("stake difficulty window not set")
+		return -1
+	}
+	return window
+}
+
 func getStatusCtx(r *http.Request) *apitypes.Status {
 	status, ok := r.Context().Value(ctxAPIStatus).(*apitypes.Status)
 	if !ok {
@@ -593,6 +659,78 @@ func (c *appContext) getTransactionOutput(w http.ResponseWriter, r *http.Request
 	writeJSON(w, *allTxOut[index], c.getIndentQuery(r))
 }
 
+// getTransactionOutputSpender serves the TxOutSpender for TxOut[i]
+func (c *appContext) getTransactionOutputSpender(w http.ResponseWriter, r *http.Request) {
+	txid := getTxIDCtx(r)
+	if txid == "" {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	index := getTxIOIndexCtx(r)
+	if index < 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	spender := c.BlockData.GetTxOutSpender(txid, uint32(index))
+	if spender == nil {
+		apiLog.Debugf("Output %s:%d not found", txid, index)
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON(w, spender, c.getIndentQuery(r))
+}
+
+// defaultStakeDiffWindowCount is the number of recent stake difficulty
+// windows returned by the windows endpoint when no count is given.
+const defaultStakeDiffWindowCount = 10
+
+// getStakeDiffWindows serves the statistics of the most recent stake
+// difficulty windows, newest first. The number of windows is given by the
+// optional path part {N}.
+func (c *appContext) getStakeDiffWindows(w http.ResponseWriter, r *http.Request) {
+	N := getNCtx(r)
+	if N < 0 {
+		N = defaultStakeDiffWindowCount
+	}
+	if N == 0 {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	windows := c.BlockData.GetLatestStakeDiffWindows(int64(N))
+	if windows == nil {
+		apiLog.Errorf("Unable to get stake difficulty windows")
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	writeJSON(w, windows, c.getIndentQuery(r))
+}
+
+// getStakeDiffWindow serves the statistics of the stake difficulty window
+// with the number given by the path part {window}.
+func (c *appContext) getStakeDiffWindow(w http.ResponseWriter, r *http.Request) {
+	window := getStakeDiffWindowCtx(r)
+	if window < 0 {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	windows := c.BlockData.GetStakeDiffWindows(int64(window), int64(window))
+	if windows == nil {
+		apiLog.Errorf("Unable to get stake difficulty window %d", window)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	if len(windows) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, windows[0], c.getIndentQuery(r))
+}
+
 func (c *appContext) getBlockFeeInfo(w http.ResponseWriter, r *http.Request) {
 	idx := c.getBlockHeightCtx(r)
 	if idx < 0 {
@@ -610,8 +748,35 @@ func (c *appContext) getBlockFeeInfo(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, blockFeeInfo, c.getIndentQuery(r))
 }
 
+// getBlockMissedTickets serves the tickets called to vote on the block that
+// did not vote
+func (c *appContext) getBlockMissedTickets(w http.ResponseWriter, r *http.Request) {
+	idx := c.getBlockHeightCtx(r)
+	if c.blockNotFound(r, idx) {
+		http.NotFound(w, r)
+		return
+	}
+	if idx < 0 {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	missed := c.BlockData.GetMissedTickets(int(idx))
+	if missed == nil {
+		apiLog.Errorf("Unable to get block %d missed tickets", idx)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	writeJSON(w, missed, c.getIndentQuery(r))
+}
+
 func (c *appContext) getBlockStakeInfoExtended(w http.ResponseWriter, r *http.Request) {
 	idx := c.getBlockHeightCtx(r)
+	if c.blockNotFound(r, idx) {
+		http.NotFound(w, r)
+		return
+	}
 	if idx < 0 {
 		http.Error(w, http.StatusText(422), 422)
 		return
@@ -654,15 +819,182 @@ func (c *appContext) getStakeDiffCurrent(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, stakeDiffCurrent, c.getIndentQuery(r))
 }
 
+// getStakeDiffEstimates serves the estimates of the next stake difficulty. If
+// the tickets url query is set, the estimate assuming that many more ticket
+// purchases in the current window is included as the user estimate.
 func (c *appContext) getStakeDiffEstimates(w http.ResponseWriter, r *http.Request) {
-	stakeDiff := c.BlockData.GetStakeDiffEstimates()
-	if stakeDiff == nil {
-		apiLog.Errorf("Unable to get stake diff info")
+	var tickets *uint32
+	if t := r.URL.Query().Get("tickets"); t != "" {
+		n, err := strconv.ParseUint(t, 10, 32)
+		if err != nil {
+			http.Error(w, "invalid tickets", http.StatusUnprocessableEntity)
+			return
+		}
+		numTickets := uint32(n)
+		tickets = &numTickets
+	}
+
+	estimates, err := c.BlockData.EstimateStakeDiff(tickets)
+	if err != nil {
+		apiLog.Errorf("Unable to estimate stake diff: %v", err)
 		http.Error(w, http.StatusText(422), 422)
 		return
 	}
 
-	writeJSON(w, stakeDiff.Estimates, c.getIndentQuery(r))
+	writeJSON(w, estimates, c.getIndentQuery(r))
+}
+
+// getMempoolSummary serves the number, size and fees of the transactions of
+// each type in mempool.
+func (c *appContext) getMempoolSummary(w http.ResponseWriter, r *http.Request) {
+	summary := c.BlockData.GetMempoolSummary()
+	if summary == nil {
+		apiLog.Errorf("Unable to get mempool summary")
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	writeJSON(w, summary, c.getIndentQuery(r))
+}
+
+// getMempoolTxs serves the transactions in mempool, optionally only those of
+// the type given by the URL query "type" (regular, ticket, vote or
+// revocation).
+func (c *appContext) getMempoolTxs(w http.ResponseWriter, r *http.Request) {
+	txType := r.URL.Query().Get("type")
+	if txType != "" && !mempool.ValidTxType(txType) {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	c.writeMempoolTxs(w, r, txType)
+}
+
+// getMempoolVotes serves the votes in mempool.
+func (c *appContext) getMempoolVotes(w http.ResponseWriter, r *http.Request) {
+	c.writeMempoolTxs(w, r, "vote")
+}
+
+// getMempoolBlockVotes serves the votes in mempool grouped by the block they
+// vote on.
+func (c *appContext) getMempoolBlockVotes(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, c.BlockData.GetMempoolBlockVotes(), c.getIndentQuery(r))
+}
+
+// getMempoolTipVotes serves the votes in mempool on the best block, and which
+// of its winning tickets have voted.
+func (c *appContext) getMempoolTipVotes(w http.ResponseWriter, r *http.Request) {
+	tipVotes := c.BlockData.GetMempoolTipVotes()
+	if tipVotes == nil {
+		apiLog.Errorf("Unable to get mempool votes on the best block")
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	writeJSON(w, tipVotes, c.getIndentQuery(r))
+}
+
+// getMempoolConflicts serves the transactions in mempool that spend the same
+// outpoint as another, and those recently invalidated by blocks.
+func (c *appContext) getMempoolConflicts(w http.ResponseWriter, r *http.Request) {
+	conflicts := c.BlockData.GetMempoolConflicts()
+	if conflicts == nil {
+		apiLog.Errorf("Unable to get mempool conflicts")
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	writeJSON(w, conflicts, c.getIndentQuery(r))
+}
+
+// getTicketInclusion serves the statistics of the time the tickets mined in
+// the recent stake difficulty windows spent in mempool, by fee rate. The
+// statistics are not persisted, and cover only the tickets mined since startup.
+func (c *appContext) getTicketInclusion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, c.BlockData.GetTicketInclusion(), c.getIndentQuery(r))
+}
+
+const (
+	// defaultMempoolHistorySpan is the default time span in seconds of the
+	// mempool history endpoint.
+	defaultMempoolHistorySpan = 24 * 60 * 60
+	// maxMempoolHistoryPoints is the largest number of time steps served by
+	// the mempool history endpoint.
+	maxMempoolHistoryPoints = 2000
+)
+
+// getMempoolHistory serves the mempool data collections from the UNIX time
+// given by the from url query to that given by to, averaged in steps of step
+// seconds. The time span defaults to the last day, and the step to the
+// smallest giving at most maxMempoolHistoryPoints steps.
+func (c *appContext) getMempoolHistory(w http.ResponseWriter, r *http.Request) {
+	to := time.Now().Unix()
+	var err error
+	if t := r.URL.Query().Get("to"); t != "" {
+		to, err = strconv.ParseInt(t, 10, 64)
+		if err != nil || to < 0 {
+			http.Error(w, "invalid to", http.StatusUnprocessableEntity)
+			return
+		}
+	}
+	from := to - defaultMempoolHistorySpan
+	if f := r.URL.Query().Get("from"); f != "" {
+		from, err = strconv.ParseInt(f, 10, 64)
+		if err != nil || from < 0 || from > to {
+			http.Error(w, "invalid from", http.StatusUnprocessableEntity)
+			return
+		}
+	}
+	step := (to-from)/maxMempoolHistoryPoints + 1
+	if s := r.URL.Query().Get("step"); s != "" {
+		step, err = strconv.ParseInt(s, 10, 64)
+		if err != nil || step <= 0 || (to-from)/step >= maxMempoolHistoryPoints {
+			http.Error(w, "invalid step", http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	history, err := c.BlockData.GetMempoolHistory(from, to, step)
+	if err != nil {
+		apiLog.Errorf("Unable to get mempool history: %v", err)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	writeJSON(w, history, c.getIndentQuery(r))
+}
+
+// getMempoolFeeEstimate serves an estimate of the fee rate for a regular
+// transaction to be mined within the number of blocks given by the URL query
+// "blocks", which defaults to 1.
+func (c *appContext) getMempoolFeeEstimate(w http.ResponseWriter, r *http.Request) {
+	blocks := 1
+	if blocksStr := r.URL.Query().Get("blocks"); blocksStr != "" {
+		var err error
+		blocks, err = strconv.Atoi(blocksStr)
+		if err != nil || blocks < 1 || blocks > mempool.MaxFeeEstimateBlocks {
+			http.Error(w, http.StatusText(422), 422)
+			return
+		}
+	}
+
+	estimate, err := c.BlockData.GetMempoolFeeEstimate(blocks)
+	if err != nil {
+		apiLog.Errorf("Unable to estimate fee rate: %v", err)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	writeJSON(w, estimate, c.getIndentQuery(r))
+}
+
+func (c *appContext) writeMempoolTxs(w http.ResponseWriter, r *http.Request, txType string) {
+	txs := c.BlockData.GetMempoolTxs(txType)
+	if txs == nil {
+		apiLog.Errorf("Unable to get mempool transactions")
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	writeJSON(w, txs, c.getIndentQuery(r))
 }
 
 func (c *appContext) getSSTxSummary(w http.ResponseWriter, r *http.Request) {
@@ -866,8 +1198,31 @@ func (c *appContext) getBlockRangeSteppedSummary(w http.ResponseWriter, r *http.
 	fmt.Fprintf(w, "]")
 }
 
+// getTicketInfo serves the lifecycle status of the ticket with the given
+// purchase transaction hash
+func (c *appContext) getTicketInfo(w http.ResponseWriter, r *http.Request) {
+	txid := getTxIDCtx(r)
+	if txid == "" {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	ticket := c.BlockData.GetTicketInfo(txid)
+	if ticket == nil {
+		apiLog.Debugf("Ticket %s not found", txid)
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON(w, ticket, c.getIndentQuery(r))
+}
+
 func (c *appContext) getTicketPoolInfo(w http.ResponseWriter, r *http.Request) {
 	idx := c.getBlockHeightCtx(r)
+	if c.blockNotFound(r, idx) {
+		http.NotFound(w, r)
+		return
+	}
 	if idx < 0 {
 		http.Error(w, http.StatusText(422), 422)
 		return
@@ -877,6 +1232,45 @@ func (c *appContext) getTicketPoolInfo(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, tpi, c.getIndentQuery(r))
 }
 
+const (
+	// defaultPriceBucketWidth is the default width in DCR of the ticket price
+	// buckets of the pool distribution endpoint.
+	defaultPriceBucketWidth = 1.0
+	// defaultAgeBucketWidth is the default width in blocks of the ticket age
+	// buckets of the pool distribution endpoint.
+	defaultAgeBucketWidth = 288
+)
+
+// getTicketPoolDistribution serves the distribution of the live tickets by
+// price and age, with the bucket widths set by the pricewidth (DCR) and
+// agewidth (blocks) url queries.
+func (c *appContext) getTicketPoolDistribution(w http.ResponseWriter, r *http.Request) {
+	priceWidth, ageWidth := defaultPriceBucketWidth, int64(defaultAgeBucketWidth)
+	var err error
+	if pw := r.URL.Query().Get("pricewidth"); pw != "" {
+		priceWidth, err = strconv.ParseFloat(pw, 64)
+		if err != nil || priceWidth <= 0 {
+			http.Error(w, "invalid pricewidth", http.StatusUnprocessableEntity)
+			return
+		}
+	}
+	if aw := r.URL.Query().Get("agewidth"); aw != "" {
+		ageWidth, err = strconv.ParseInt(aw, 10, 64)
+		if err != nil || ageWidth <= 0 {
+			http.Error(w, "invalid agewidth", http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	dist := c.BlockData.GetPoolDistribution(priceWidth, ageWidth)
+	if dist == nil {
+		apiLog.Errorf("Unable to get ticket pool distribution")
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	writeJSON(w, dist, c.getIndentQuery(r))
+}
+
 func (c *appContext) getTicketPoolInfoRange(w http.ResponseWriter, r *http.Request) {
 	idx0 := getBlockIndex0Ctx(r)
 	if idx0 < 0 {
@@ -943,6 +1337,134 @@ func (c *appContext) getStakeDiff(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, []float64{sdiff}, c.getIndentQuery(r))
 }
 
+func (c *appContext) getAgendas(w http.ResponseWriter, r *http.Request) {
+	agendas := c.BlockData.GetAgendas()
+	if agendas == nil {
+		apiLog.Errorf("Unable to get agendas")
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	writeJSON(w, agendas, c.getIndentQuery(r))
+}
+
+func (c *appContext) getAgenda(w http.ResponseWriter, r *http.Request) {
+	agendaID := getAgendaIDCtx(r)
+	if agendaID == "" {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	agenda := c.BlockData.GetAgenda(agendaID)
+	if agenda == nil {
+		apiLog.Debugf("Agenda %s not found", agendaID)
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON(w, agenda, c.getIndentQuery(r))
+}
+
+func (c *appContext) getAgendaBlocks(w http.ResponseWriter, r *http.Request) {
+	agendaID := getAgendaIDCtx(r)
+	if agendaID == "" {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	idx0 := getBlockIndex0Ctx(r)
+	if idx0 < 0 {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	idx := getBlockIndexCtx(r)
+	if idx < idx0 {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	agenda := c.BlockData.GetAgendaBlocks(agendaID, int64(idx0), int64(idx))
+	if agenda == nil {
+		apiLog.Debugf("Agenda %s not found", agendaID)
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON(w, agenda, c.getIndentQuery(r))
+}
+
+// getStakeVersions serves the block, stake and vote versions in the blocks
+// checked by dcrd for block version upgrades, and in the current and previous
+// stake version intervals.
+func (c *appContext) getStakeVersions(w http.ResponseWriter, r *http.Request) {
+	summary := c.BlockData.GetStakeVersionsSummary()
+	if summary == nil {
+		apiLog.Errorf("Unable to get stake versions")
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	writeJSON(w, summary, c.getIndentQuery(r))
+}
+
+// getStakeVersionIntervals serves the block, stake and vote versions in every
+// stake version interval.
+func (c *appContext) getStakeVersionIntervals(w http.ResponseWriter, r *http.Request) {
+	intervals := c.BlockData.GetStakeVersionIntervals()
+	if intervals == nil {
+		apiLog.Errorf("Unable to get stake version intervals")
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	writeJSON(w, intervals, c.getIndentQuery(r))
+}
+
+// getLatestVersionWindow serves the block, stake and vote versions in the N
+// most recent blocks, where N is given by the path part {N}.
+func (c *appContext) getLatestVersionWindow(w http.ResponseWriter, r *http.Request) {
+	N := getNCtx(r)
+	if N < 1 {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	window := c.BlockData.GetLatestVersionWindow(int64(N))
+	if window == nil {
+		apiLog.Errorf("Unable to get versions of the last %d blocks", N)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	writeJSON(w, window, c.getIndentQuery(r))
+}
+
+// getVersionWindow serves the block, stake and vote versions in the blocks
+// from height {idx0} to {idx}.
+func (c *appContext) getVersionWindow(w http.ResponseWriter, r *http.Request) {
+	idx0 := getBlockIndex0Ctx(r)
+	if idx0 < 0 {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	idx := getBlockIndexCtx(r)
+	if idx < idx0 {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	window := c.BlockData.GetVersionWindow(int64(idx0), int64(idx))
+	if window == nil {
+		apiLog.Errorf("Unable to get versions for blocks %d to %d", idx0, idx)
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+
+	writeJSON(w, window, c.getIndentQuery(r))
+}
+
 func (c *appContext) getStakeDiffRange(w http.ResponseWriter, r *http.Request) {
 	idx0 := getBlockIndex0Ctx(r)
 	if idx0 < 0 {
@@ -972,7 +1494,8 @@ func (c *appContext) getAddressTransactions(w http.ResponseWriter, r *http.Reque
 	} else if count > 2000 {
 		count = 2000
 	}
-	txs := c.BlockData.GetAddressTransactions(address, count)
+	skip := getMCtx(r)
+	txs := c.BlockData.GetAddressTransactions(address, count, skip)
 	if txs == nil {
 		http.Error(w, http.StatusText(422), 422)
 		return
@@ -992,10 +1515,53 @@ func (c *appContext) getAddressTransactionsRaw(w http.ResponseWriter, r *http.Re
 	} else if count > 2000 {
 		count = 2000
 	}
-	txs := c.BlockData.GetAddressTransactionsRaw(address, count)
+	skip := getMCtx(r)
+	txs := c.BlockData.GetAddressTransactionsRaw(address, count, skip)
 	if txs == nil {
 		http.Error(w, http.StatusText(422), 422)
 		return
 	}
 	writeJSON(w, txs, c.getIndentQuery(r))
 }
+
+func (c *appContext) getAddressBalance(w http.ResponseWriter, r *http.Request) {
+	address := getAddressCtx(r)
+	if address == "" {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	balance := c.BlockData.GetAddressBalance(address)
+	if balance == nil {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	writeJSON(w, balance, c.getIndentQuery(r))
+}
+
+func (c *appContext) getAddressTotals(w http.ResponseWriter, r *http.Request) {
+	address := getAddressCtx(r)
+	if address == "" {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	totals := c.BlockData.GetAddressTotals(address)
+	if totals == nil {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	writeJSON(w, totals, c.getIndentQuery(r))
+}
+
+func (c *appContext) getAddressUTXO(w http.ResponseWriter, r *http.Request) {
+	address := getAddressCtx(r)
+	if address == "" {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	utxos := c.BlockData.GetAddressUTXO(address)
+	if utxos == nil {
+		http.Error(w, http.StatusText(422), 422)
+		return
+	}
+	writeJSON(w, utxos, c.getIndentQuery(r))
+}
@@ -21,18 +21,20 @@ type ReorgData struct {
 
 // for getblock, ticketfeeinfo, estimatestakediff, etc.
 type chainMonitor struct {
-	collector       *Collector
-	dataSavers      []BlockDataSaver
-	reorgDataSavers []BlockDataSaver
-	quit            chan struct{}
-	wg              *sync.WaitGroup
-	watchaddrs      map[string]txhelpers.TxAction
-	blockChan       chan *chainhash.Hash
-	recvTxBlockChan chan *txhelpers.BlockWatchedTx
-	reorgChan       chan *ReorgData
-	ConnectingLock  chan struct{}
-	DoneConnecting  chan struct{}
-	syncConnect     sync.Mutex
+	collector        *Collector
+	dataSavers       []BlockDataSaver
+	reorgDataSavers  []BlockDataSaver
+	quit             chan struct{}
+	wg               *sync.WaitGroup
+	watchaddrs       func() map[string]txhelpers.TxAction
+	outpointAddrs    txhelpers.OutpointAddressGetter
+	blockChan        chan *chainhash.Hash
+	recvTxBlockChan  chan *txhelpers.BlockWatchedTx
+	spendTxBlockChan chan *txhelpers.BlockWatchedTx
+	reorgChan        chan *ReorgData
+	ConnectingLock   chan struct{}
+	DoneConnecting   chan struct{}
+	syncConnect      sync.Mutex
 
 	// reorg handling
 	reorgLock    sync.Mutex
@@ -41,25 +43,32 @@ type chainMonitor struct {
 	reorganizing bool
 }
 
-// NewChainMonitor creates a new chainMonitor
+// NewChainMonitor creates a new chainMonitor. The addresses watched in each
+// new block are given by addrs, which may be nil if none are watched. The
+// transactions paying to them are sent on recvTxBlockChan, and those spending
+// from them on spendTxBlockChan. The addresses of the outputs spent in a block
+// are looked up with outpointAddrs, which must index the blocks before it.
 func NewChainMonitor(collector *Collector,
 	savers []BlockDataSaver, reorgSavers []BlockDataSaver,
 	quit chan struct{}, wg *sync.WaitGroup,
-	addrs map[string]txhelpers.TxAction, blockChan chan *chainhash.Hash,
-	recvTxBlockChan chan *txhelpers.BlockWatchedTx,
+	addrs func() map[string]txhelpers.TxAction,
+	outpointAddrs txhelpers.OutpointAddressGetter, blockChan chan *chainhash.Hash,
+	recvTxBlockChan, spendTxBlockChan chan *txhelpers.BlockWatchedTx,
 	reorgChan chan *ReorgData) *chainMonitor {
 	return &chainMonitor{
-		collector:       collector,
-		dataSavers:      savers,
-		reorgDataSavers: reorgSavers,
-		quit:            quit,
-		wg:              wg,
-		watchaddrs:      addrs,
-		blockChan:       blockChan,
-		recvTxBlockChan: recvTxBlockChan,
-		reorgChan:       reorgChan,
-		ConnectingLock:  make(chan struct{}, 1),
-		DoneConnecting:  make(chan struct{}),
+		collector:        collector,
+		dataSavers:       savers,
+		reorgDataSavers:  reorgSavers,
+		quit:             quit,
+		wg:               wg,
+		watchaddrs:       addrs,
+		outpointAddrs:    outpointAddrs,
+		blockChan:        blockChan,
+		recvTxBlockChan:  recvTxBlockChan,
+		spendTxBlockChan: spendTxBlockChan,
+		reorgChan:        reorgChan,
+		ConnectingLock:   make(chan struct{}, 1),
+		DoneConnecting:   make(chan struct{}),
 	}
 }
 
@@ -130,17 +139,28 @@ out:
 			height := block.Height()
 			log.Infof("Block height %v connected. Collecting data...", height)
 
-			if len(p.watchaddrs) > 0 {
-				// txsForOutpoints := blockConsumesOutpointWithAddresses(block, p.watchaddrs,
-				// 	p.collector.dcrdChainSvr)
-				// if len(txsForOutpoints) > 0 {
-				// 	p.spendTxBlockChan <- &BlockWatchedTx{height, txsForOutpoints}
-				// }
+			var watchaddrs map[string]txhelpers.TxAction
+			if p.watchaddrs != nil {
+				watchaddrs = p.watchaddrs()
+			}
+			if len(watchaddrs) > 0 {
+				txsForOutpoints, err := txhelpers.BlockConsumesOutpointWithAddresses(block,
+					watchaddrs, p.outpointAddrs, p.collector.netParams)
+				if err != nil {
+					log.Errorf("Unable to check block %v for spends from watched "+
+						"addresses: %v", hash, err)
+				} else if len(txsForOutpoints) > 0 {
+					p.spendTxBlockChan <- &txhelpers.BlockWatchedTx{
+						BlockHash:     *hash,
+						BlockHeight:   height,
+						TxsForAddress: txsForOutpoints}
+				}
 
 				txsForAddrs := txhelpers.BlockReceivesToAddresses(block,
-					p.watchaddrs, p.collector.netParams)
+					watchaddrs, p.collector.netParams)
 				if len(txsForAddrs) > 0 {
 					p.recvTxBlockChan <- &txhelpers.BlockWatchedTx{
+						BlockHash:     *hash,
 						BlockHeight:   height,
 						TxsForAddress: txsForAddrs}
 				}
@@ -0,0 +1,248 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package chaindb
+
+import (
+	"fmt"
+
+	"github.com/dcrdata/dcrdata/dbtypes"
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/wire"
+)
+
+// StoreBlockAddresses inserts a row into the address table for each address
+// paid to by an output of the block's transactions, and marks as spent the
+// outputs consumed by the block's inputs. This is done in a single SQL
+// transaction so that a block is either fully indexed or not at all.
+func (t *Tables) StoreBlockAddresses(msgBlock *wire.MsgBlock, params *chaincfg.Params) error {
+	b := &BlockIndex{
+		height:    int64(msgBlock.Header.Height),
+		blockTime: msgBlock.Header.Timestamp.Unix(),
+	}
+	b.outs, b.spends = dbtypes.ExtractBlockAddresses(msgBlock, params)
+	return t.storeIndex(b, t.storeAddresses, &t.addressHeight)
+}
+
+// storeAddresses stores the address rows of the block with the statements of
+// a SQL transaction.
+func (t *Tables) storeAddresses(s *txStmts, b *BlockIndex) error {
+	insertStmt, err := s.prepare(t.queries.InsertAddress)
+	if err != nil {
+		return err
+	}
+
+	// All outputs are inserted before any spends are recorded since a
+	// transaction may spend an output created earlier in the same block.
+	for _, o := range b.outs {
+		_, err = insertStmt.Exec(o.Address, o.TxHash, o.TxIndex, o.TxTree,
+			o.Value, o.BlockHeight, o.BlockTime)
+		if err != nil {
+			return fmt.Errorf("unable to insert address row: %v", err)
+		}
+	}
+
+	spendStmt, err := s.prepare(t.queries.SetAddressSpending)
+	if err != nil {
+		return err
+	}
+
+	for _, sp := range b.spends {
+		_, err = spendStmt.Exec(sp.TxHash, sp.TxIndex, b.height, b.blockTime,
+			sp.PrevTxHash, sp.PrevTxIndex)
+		if err != nil {
+			return fmt.Errorf("unable to set spending info: %v", err)
+		}
+	}
+	return nil
+}
+
+// DeleteAddressesAboveHeight rolls back the address table to the given height
+// by removing outputs created in later blocks and clearing the spending info
+// for inputs in later blocks. This is used when handling a reorganization.
+func (t *Tables) DeleteAddressesAboveHeight(height int64) error {
+	dbtx, err := t.db.Begin()
+	if err != nil {
+		return fmt.Errorf("unable to begin database transaction: %v", err)
+	}
+
+	res, err := dbtx.Exec(t.queries.DeleteAddressesAbove, height)
+	if err != nil {
+		_ = dbtx.Rollback()
+		return err
+	}
+	if err = logDBResult(res); err != nil {
+		_ = dbtx.Rollback()
+		return err
+	}
+
+	res, err = dbtx.Exec(t.queries.UnsetAddressSpendingAbove, height)
+	if err != nil {
+		_ = dbtx.Rollback()
+		return err
+	}
+	if err = logDBResult(res); err != nil {
+		_ = dbtx.Rollback()
+		return err
+	}
+
+	if err = dbtx.Commit(); err != nil {
+		return err
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.addressHeight > height {
+		t.addressHeight = height
+	}
+	return nil
+}
+
+// GetAddressHeight returns the largest block height for which the address
+// table has been updated.
+func (t *Tables) GetAddressHeight() int64 {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.addressHeight < 0 {
+		height, err := t.RetrieveAddressHeight()
+		if err != nil {
+			log.Errorf("RetrieveAddressHeight failed: %v", err)
+			return -1
+		}
+		t.addressHeight = height
+	}
+	return t.addressHeight
+}
+
+// RetrieveAddressHeight returns the height of the most recent block with
+// outputs in the address table, or -1 if the table is empty.
+func (t *Tables) RetrieveAddressHeight() (int64, error) {
+	var height int64
+	err := t.db.QueryRow(t.queries.GetAddressHeight).Scan(&height)
+	return height, err
+}
+
+// RetrieveAddressTxns returns up to N transactions involving the address,
+// newest first, after skipping the newest offset transactions.
+func (t *Tables) RetrieveAddressTxns(address string, N, offset int64) ([]*dbtypes.AddressTxn, error) {
+	if N < 0 || offset < 0 {
+		return nil, fmt.Errorf("invalid count (%d) or offset (%d)", N, offset)
+	}
+
+	stmt, err := t.db.Prepare(t.queries.GetAddressTxns)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(address, N, offset)
+	if err != nil {
+		log.Errorf("Query failed: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	txns := make([]*dbtypes.AddressTxn, 0, N)
+	for rows.Next() {
+		txn := new(dbtypes.AddressTxn)
+		if err = rows.Scan(&txn.TxHash, &txn.BlockHeight, &txn.BlockTime,
+			&txn.Received, &txn.Sent); err != nil {
+			log.Errorf("Unable to scan for AddressTxn fields: %v", err)
+			continue
+		}
+		txns = append(txns, txn)
+	}
+	if err = rows.Err(); err != nil {
+		log.Error(err)
+	}
+
+	return txns, nil
+}
+
+// RetrieveAddressTotals returns the number of transactions, and the total
+// amounts received, sent and unspent for the address.
+func (t *Tables) RetrieveAddressTotals(address string) (*dbtypes.AddressTotals, error) {
+	totals := new(dbtypes.AddressTotals)
+	err := t.db.QueryRow(t.queries.GetAddressTotals, address).Scan(&totals.Received,
+		&totals.Sent)
+	if err != nil {
+		return nil, err
+	}
+	totals.Unspent = totals.Received - totals.Sent
+
+	err = t.db.QueryRow(t.queries.GetAddressTxnCount, address).Scan(&totals.NumTxns)
+	if err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+// RetrieveOutpointAddresses returns the addresses paid to by the given
+// transaction output, and the output's value in atoms.
+func (t *Tables) RetrieveOutpointAddresses(txHash string, index uint32) ([]string, int64, error) {
+	stmt, err := t.db.Prepare(t.queries.GetOutpointAddresses)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(txHash, index)
+	if err != nil {
+		log.Errorf("Query failed: %v", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var addresses []string
+	var value int64
+	for rows.Next() {
+		var addr string
+		if err = rows.Scan(&addr, &value); err != nil {
+			log.Errorf("Unable to scan for outpoint address fields: %v", err)
+			continue
+		}
+		addresses = append(addresses, addr)
+	}
+	if err = rows.Err(); err != nil {
+		log.Error(err)
+	}
+
+	return addresses, value, nil
+}
+
+// RetrieveAddressUTXOs returns the unspent outputs paying to the address,
+// oldest first.
+func (t *Tables) RetrieveAddressUTXOs(address string) ([]*dbtypes.AddressOutpoint, error) {
+	stmt, err := t.db.Prepare(t.queries.GetAddressUTXOs)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(address)
+	if err != nil {
+		log.Errorf("Query failed: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var utxos []*dbtypes.AddressOutpoint
+	for rows.Next() {
+		o := &dbtypes.AddressOutpoint{
+			Address:         address,
+			SpendingTxIndex: -1,
+			SpendingHeight:  -1,
+		}
+		if err = rows.Scan(&o.TxHash, &o.TxIndex, &o.TxTree, &o.Value,
+			&o.BlockHeight, &o.BlockTime); err != nil {
+			log.Errorf("Unable to scan for AddressOutpoint fields: %v", err)
+			continue
+		}
+		utxos = append(utxos, o)
+	}
+	if err = rows.Err(); err != nil {
+		log.Error(err)
+	}
+
+	return utxos, nil
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package chaindb
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/dcrdata/dcrdata/dbtypes"
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/wire"
+)
+
+// BlockIndex holds the rows of a block for the address, transaction, votes,
+// block versions and tickets tables. Use NewBlockIndex to extract them from a
+// block, and StoreIndexBatch to store a batch of blocks together.
+type BlockIndex struct {
+	height    int64
+	blockTime int64
+
+	outs   []*dbtypes.AddressOutpoint
+	spends []*dbtypes.OutpointSpend
+
+	txs   []*dbtypes.Tx
+	vins  []*dbtypes.Vin
+	vouts []*dbtypes.Vout
+
+	votes    []*dbtypes.Vote
+	versions *dbtypes.BlockVersions
+
+	// The tickets table is only updated if the ticket status changes are
+	// known, i.e. SetTicketUpdates was called.
+	tickets     []dbtypes.TicketUpdate
+	haveTickets bool
+}
+
+// NewBlockIndex extracts the address, transaction, vote and block versions
+// rows of the block.
+func NewBlockIndex(msgBlock *wire.MsgBlock, params *chaincfg.Params) *BlockIndex {
+	b := &BlockIndex{
+		height:    int64(msgBlock.Header.Height),
+		blockTime: msgBlock.Header.Timestamp.Unix(),
+		votes:     dbtypes.ExtractBlockVotes(msgBlock),
+		versions: &dbtypes.BlockVersions{
+			Height:       int64(msgBlock.Header.Height),
+			Hash:         msgBlock.BlockHash().String(),
+			BlockVersion: msgBlock.Header.Version,
+			StakeVersion: msgBlock.Header.StakeVersion,
+		},
+	}
+	b.outs, b.spends = dbtypes.ExtractBlockAddresses(msgBlock, params)
+	b.txs, b.vins, b.vouts = dbtypes.ExtractBlockTransactions(msgBlock, params)
+	return b
+}
+
+// SetTicketUpdates sets the ticket status changes made by the block, which are
+// otherwise not stored in the tickets table.
+func (b *BlockIndex) SetTicketUpdates(updates []dbtypes.TicketUpdate) {
+	b.tickets = updates
+	b.haveTickets = true
+}
+
+// Height returns the height of the block.
+func (b *BlockIndex) Height() int64 {
+	return b.height
+}
+
+// txStmts prepares the statements used in a SQL transaction on demand, so that
+// each is prepared once however many rows it stores.
+type txStmts struct {
+	dbtx  *sql.Tx
+	stmts map[string]*sql.Stmt
+}
+
+func newTxStmts(dbtx *sql.Tx) *txStmts {
+	return &txStmts{
+		dbtx:  dbtx,
+		stmts: make(map[string]*sql.Stmt),
+	}
+}
+
+// prepare returns the prepared statement for the query.
+func (s *txStmts) prepare(query string) (*sql.Stmt, error) {
+	if stmt, ok := s.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := s.dbtx.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	s.stmts[query] = stmt
+	return stmt, nil
+}
+
+// close closes the prepared statements.
+func (s *txStmts) close() {
+	for _, stmt := range s.stmts {
+		stmt.Close()
+	}
+}
+
+// indexHeights are the best heights of the tables stored by StoreIndexBatch.
+type indexHeights struct {
+	address, tx, ticket, vote, versions int64
+}
+
+// StoreIndexBatch stores a batch of blocks in the address, transaction, votes,
+// block versions and tickets tables within the SQL transaction dbtx, in order
+// of height. Tables already at or above the height of a block are skipped for
+// that block. Since the rows are not stored until dbtx is committed, the
+// returned function must be called after a successful commit to update the
+// cached table heights. On error, dbtx should be rolled back.
+func (t *Tables) StoreIndexBatch(dbtx *sql.Tx, blocks []*BlockIndex) (func(), error) {
+	// The cached heights are used since the tables may not be queried outside
+	// of dbtx while it is open, e.g. with a single connection.
+	t.mtx.RLock()
+	prev := indexHeights{
+		address:  t.addressHeight,
+		tx:       t.txHeight,
+		ticket:   t.ticketHeight,
+		vote:     t.voteHeight,
+		versions: t.versionsHeight,
+	}
+	t.mtx.RUnlock()
+	stored := indexHeights{-1, -1, -1, -1, -1}
+
+	s := newTxStmts(dbtx)
+	defer s.close()
+
+	for _, b := range blocks {
+		if b.height > prev.address {
+			if err := t.storeAddresses(s, b); err != nil {
+				return nil, err
+			}
+			stored.address = b.height
+		}
+		if b.height > prev.tx {
+			if err := t.storeTransactions(s, b); err != nil {
+				return nil, err
+			}
+			stored.tx = b.height
+		}
+		if b.height > prev.vote {
+			if err := t.storeVotes(s, b); err != nil {
+				return nil, err
+			}
+			if len(b.votes) > 0 {
+				stored.vote = b.height
+			}
+		}
+		if b.height > prev.versions {
+			if err := t.storeVersions(s, b); err != nil {
+				return nil, err
+			}
+			stored.versions = b.height
+		}
+		if b.haveTickets && b.height > prev.ticket {
+			if err := t.storeTicketUpdates(s, b); err != nil {
+				return nil, err
+			}
+			stored.ticket = b.height
+		}
+	}
+
+	return func() {
+		t.mtx.Lock()
+		defer t.mtx.Unlock()
+		setMax(&t.addressHeight, stored.address)
+		setMax(&t.txHeight, stored.tx)
+		setMax(&t.ticketHeight, stored.ticket)
+		setMax(&t.voteHeight, stored.vote)
+		setMax(&t.versionsHeight, stored.versions)
+	}, nil
+}
+
+// storeIndex stores the rows of the block with store in a new SQL transaction,
+// and then raises the cached table height at height, if not nil, to that of
+// the block.
+func (t *Tables) storeIndex(b *BlockIndex, store func(*txStmts, *BlockIndex) error,
+	height *int64) error {
+	dbtx, err := t.db.Begin()
+	if err != nil {
+		return fmt.Errorf("unable to begin database transaction: %v", err)
+	}
+
+	s := newTxStmts(dbtx)
+	if err = store(s, b); err != nil {
+		s.close()
+		_ = dbtx.Rollback()
+		return err
+	}
+	s.close()
+
+	if err = dbtx.Commit(); err != nil {
+		return err
+	}
+
+	if height != nil {
+		t.mtx.Lock()
+		defer t.mtx.Unlock()
+		setMax(height, b.height)
+	}
+	return nil
+}
+
+// setMax sets the height to h if h is greater.
+func setMax(height *int64, h int64) {
+	if h > *height {
+		*height = h
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+// Package chaindb stores and retrieves the address, transaction, ticket,
+// vote, block version and mempool history tables shared by the dcrsqlite and
+// dcrpg backends. The scanning, tallying and assembly of the results is done
+// here once, while each backend supplies the SQL for its own dialect in a
+// Queries.
+package chaindb
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btclog"
+)
+
+// Queries holds the SQL statements used by Tables, written for the dialect of
+// a particular database backend. The parameters of each statement are given
+// in the order documented for it, and statements that use a parameter more
+// than once must refer to it by its number (e.g. ?1 or $1).
+type Queries struct {
+	// Address table. GetAddressTxns takes (address, limit, offset) and
+	// GetAddressTxnCount takes (address).
+	InsertAddress, SetAddressSpending     string
+	DeleteAddressesAbove                  string
+	UnsetAddressSpendingAbove             string
+	GetAddressHeight, GetAddressTxns      string
+	GetAddressTxnCount, GetAddressTotals  string
+	GetOutpointAddresses, GetAddressUTXOs string
+
+	// Transaction, vin and vout tables. GetTicketsMaturing takes (first
+	// purchase height, last purchase height), and GetTicketsLeaving takes
+	// (maturity+expiry, first purchase height, last height, first height) and
+	// uses the pool exit heights in the tickets table.
+	InsertTx, InsertVin, InsertVout         string
+	SetVoutSpending, UnsetVoutSpendingAbove string
+	DeleteTxsAbove, DeleteVinsAbove         string
+	DeleteVoutsAbove, GetTxHeight           string
+	GetTx, GetTxVins, GetTxVouts            string
+	GetVoutSpender                          string
+	GetTicketsMaturing, GetTicketsLeaving   string
+
+	// Tickets table. GetTicketExitCounts takes (first height, last height).
+	InsertTicket, SetTicketStatus         string
+	SetTicketSpend, DeleteTicketsAbove    string
+	UnsetTicketStatusAbove                string
+	UnsetTicketSpendAbove                 string
+	GetTicketHeight, GetTicket            string
+	GetMissedTickets, GetTicketExitCounts string
+
+	// Votes table. GetVoteBitsCounts takes (first height, bin size, mask,
+	// vote version, last height).
+	InsertVote, DeleteVotesAbove     string
+	GetVoteHeight, GetVoteBitsCounts string
+
+	// Block versions table. The version counts take (first height, bin size,
+	// last height).
+	InsertVersions, DeleteVersionsAbove     string
+	GetVersionsHeight, GetVoteVersionCounts string
+	GetBlockVersionCounts                   string
+	GetStakeVersionCounts                   string
+
+	// Mempool history table. GetMempoolHistory takes (from, step, to), and
+	// DeleteMempoolHistoryBefore takes (time).
+	InsertMempoolSnapshot, GetMempoolHistory string
+	DeleteMempoolHistoryBefore               string
+}
+
+// Tables stores and retrieves the chain data tables of a database using the
+// SQL statements in its Queries. It caches the best height of each table.
+// Use NewTables to get a new instance.
+type Tables struct {
+	db             *sql.DB
+	queries        *Queries
+	mtx            sync.RWMutex
+	addressHeight  int64
+	txHeight       int64
+	ticketHeight   int64
+	voteHeight     int64
+	versionsHeight int64
+
+	// mempoolRetention is how long the mempool history is kept, or 0 to keep
+	// all of it.
+	mempoolRetention time.Duration
+
+	// exitTotals[h] are the numbers of tickets missed and expired from genesis
+	// to height h, up to the tickets table height.
+	exitMtx    sync.Mutex
+	exitTotals []exitTotal
+}
+
+// NewTables creates a Tables for the existing tables of db, which are
+// accessed with the given queries.
+func NewTables(db *sql.DB, queries *Queries) *Tables {
+	t := &Tables{
+		db:               db,
+		queries:          queries,
+		addressHeight:    -1,
+		txHeight:         -1,
+		ticketHeight:     -1,
+		voteHeight:       -1,
+		versionsHeight:   -1,
+		mempoolRetention: DefaultMempoolRetention,
+	}
+
+	t.GetAddressHeight()
+	t.GetTransactionHeight()
+	t.GetTicketHeight()
+	t.GetVoteHeight()
+	t.GetBlockVersionsHeight()
+
+	return t
+}
+
+// logDBResult logs the number of rows affected by a statement. LastInsertId is
+// not used since the postgres driver does not support it.
+func logDBResult(res sql.Result) error {
+	if log.Level() > btclog.LevelTrace {
+		return nil
+	}
+
+	rowCnt, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	log.Tracef("affected = %d", rowCnt)
+
+	return nil
+}
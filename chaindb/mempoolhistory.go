@@ -0,0 +1,75 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package chaindb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dcrdata/dcrdata/dbtypes"
+)
+
+// DefaultMempoolRetention is how long the mempool history is kept by default.
+const DefaultMempoolRetention = 30 * 24 * time.Hour
+
+// SetMempoolRetention sets how long the mempool history is kept, or keeps all
+// of it if retention is 0.
+func (t *Tables) SetMempoolRetention(retention time.Duration) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.mempoolRetention = retention
+}
+
+// StoreMempoolSnapshot inserts a mempool data collection into the mempool
+// history table, and deletes the collections older than the retention period
+// before it.
+func (t *Tables) StoreMempoolSnapshot(snap *dbtypes.MempoolSnapshot) error {
+	_, err := t.db.Exec(t.queries.InsertMempoolSnapshot, snap.Time, snap.Height,
+		snap.NumTickets, snap.FeeMin, snap.FeeMean, snap.FeeMedian,
+		snap.FeeMax, snap.NumRegular, snap.RegularSize)
+	if err != nil {
+		return fmt.Errorf("unable to insert mempool history row: %v", err)
+	}
+
+	t.mtx.RLock()
+	retention := int64(t.mempoolRetention / time.Second)
+	t.mtx.RUnlock()
+	if retention <= 0 {
+		return nil
+	}
+	res, err := t.db.Exec(t.queries.DeleteMempoolHistoryBefore, snap.Time-retention)
+	if err != nil {
+		return fmt.Errorf("unable to delete old mempool history rows: %v", err)
+	}
+	return logDBResult(res)
+}
+
+// RetrieveMempoolHistory averages the mempool snapshots from UNIX time from to
+// to, in bins of step seconds starting at from, so that bin i is for the
+// snapshots from from+i*step. Bins without snapshots are omitted. The bins are
+// in order of time.
+func (t *Tables) RetrieveMempoolHistory(from, to, step int64) ([]dbtypes.MempoolHistoryBin, error) {
+	if to < from || step < 1 {
+		return nil, fmt.Errorf("invalid time range [%d,%d] or step %d",
+			from, to, step)
+	}
+
+	rows, err := t.db.Query(t.queries.GetMempoolHistory, from, step, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bins []dbtypes.MempoolHistoryBin
+	for rows.Next() {
+		var b dbtypes.MempoolHistoryBin
+		if err = rows.Scan(&b.Bin, &b.NumSamples, &b.Height, &b.NumTickets,
+			&b.FeeMin, &b.FeeMean, &b.FeeMedian, &b.FeeMax, &b.NumRegular,
+			&b.RegularSize); err != nil {
+			return nil, err
+		}
+		bins = append(bins, b)
+	}
+	return bins, rows.Err()
+}
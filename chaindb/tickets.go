@@ -0,0 +1,247 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package chaindb
+
+import (
+	"fmt"
+
+	"github.com/dcrdata/dcrdata/dbtypes"
+)
+
+// StoreTicketUpdates applies the ticket status changes made by the block at
+// the given height to the tickets table. Tickets that matured in the block are
+// inserted as live, tickets that left the pool have their status and exit
+// height set, and voted or revoked tickets have their spend height set. This
+// is done in a single SQL transaction.
+func (t *Tables) StoreTicketUpdates(height int64, updates []dbtypes.TicketUpdate) error {
+	b := &BlockIndex{height: height}
+	b.SetTicketUpdates(updates)
+	return t.storeIndex(b, t.storeTicketUpdates, &t.ticketHeight)
+}
+
+// storeTicketUpdates applies the ticket status changes of the block with the
+// statements of a SQL transaction.
+func (t *Tables) storeTicketUpdates(s *txStmts, b *BlockIndex) error {
+	insertStmt, err := s.prepare(t.queries.InsertTicket)
+	if err != nil {
+		return err
+	}
+
+	statusStmt, err := s.prepare(t.queries.SetTicketStatus)
+	if err != nil {
+		return err
+	}
+
+	spendStmt, err := s.prepare(t.queries.SetTicketSpend)
+	if err != nil {
+		return err
+	}
+
+	for _, u := range b.tickets {
+		switch {
+		case u.Revoked:
+			_, err = spendStmt.Exec(true, b.height, u.TicketHash)
+		case u.Status == dbtypes.PoolStatusLive:
+			_, err = insertStmt.Exec(u.TicketHash, u.PurchaseHeight, b.height)
+		default:
+			_, err = statusStmt.Exec(string(u.Status), b.height, u.TicketHash)
+			if err == nil && u.Status == dbtypes.PoolStatusVoted {
+				_, err = spendStmt.Exec(false, b.height, u.TicketHash)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("unable to update ticket %s: %v", u.TicketHash, err)
+		}
+	}
+	return nil
+}
+
+// DeleteTicketsAboveHeight rolls back the tickets table to the given height,
+// removing tickets that matured in later blocks and returning to the live
+// pool the tickets that left it in later blocks. This is used when handling a
+// reorganization.
+func (t *Tables) DeleteTicketsAboveHeight(height int64) error {
+	dbtx, err := t.db.Begin()
+	if err != nil {
+		return fmt.Errorf("unable to begin database transaction: %v", err)
+	}
+
+	for _, stmt := range []string{t.queries.DeleteTicketsAbove,
+		t.queries.UnsetTicketStatusAbove, t.queries.UnsetTicketSpendAbove} {
+		res, err := dbtx.Exec(stmt, height)
+		if err != nil {
+			_ = dbtx.Rollback()
+			return err
+		}
+		if err = logDBResult(res); err != nil {
+			_ = dbtx.Rollback()
+			return err
+		}
+	}
+
+	if err = dbtx.Commit(); err != nil {
+		return err
+	}
+
+	t.exitMtx.Lock()
+	defer t.exitMtx.Unlock()
+	if int64(len(t.exitTotals)) > height+1 {
+		t.exitTotals = t.exitTotals[:height+1]
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.ticketHeight > height {
+		t.ticketHeight = height
+	}
+	return nil
+}
+
+// GetTicketHeight returns the largest block height for which the tickets
+// table has been updated.
+func (t *Tables) GetTicketHeight() int64 {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.ticketHeight < 0 {
+		height, err := t.RetrieveTicketHeight()
+		if err != nil {
+			log.Errorf("RetrieveTicketHeight failed: %v", err)
+			return -1
+		}
+		t.ticketHeight = height
+	}
+	return t.ticketHeight
+}
+
+// RetrieveTicketHeight returns the height of the most recent ticket status
+// change in the tickets table, or -1 if the table is empty.
+func (t *Tables) RetrieveTicketHeight() (int64, error) {
+	var height int64
+	err := t.db.QueryRow(t.queries.GetTicketHeight).Scan(&height)
+	return height, err
+}
+
+// RetrieveTicket returns the stored status of the ticket with the given hash,
+// or sql.ErrNoRows if it is not in the tickets table, as is the case for
+// immature tickets.
+func (t *Tables) RetrieveTicket(txHash string) (*dbtypes.Ticket, error) {
+	ticket := new(dbtypes.Ticket)
+	var status string
+	err := t.db.QueryRow(t.queries.GetTicket, txHash).Scan(&ticket.TxHash,
+		&ticket.PurchaseHeight, &ticket.MaturityHeight, &status,
+		&ticket.PoolExitHeight, &ticket.Revoked, &ticket.SpendHeight)
+	if err != nil {
+		return nil, err
+	}
+	ticket.PoolStatus = dbtypes.TicketPoolStatus(status)
+	return ticket, nil
+}
+
+// RetrieveMissedTickets returns the hashes of the tickets that were called to
+// vote on the block at the given height but did not.
+func (t *Tables) RetrieveMissedTickets(height int64) ([]string, error) {
+	rows, err := t.db.Query(t.queries.GetMissedTickets, height)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	missed := make([]string, 0)
+	for rows.Next() {
+		var hash string
+		if err = rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		missed = append(missed, hash)
+	}
+	return missed, rows.Err()
+}
+
+// exitTotal is the number of tickets missed and expired from genesis to a
+// block.
+type exitTotal struct {
+	missed, expired uint32
+}
+
+// RetrieveTicketExitCounts returns, for each block from height ind0 to ind1,
+// the total number of tickets missed and expired from genesis to that block.
+// The totals are cached up to the tickets table height, so only the tickets
+// that left the pool since the last call are counted.
+func (t *Tables) RetrieveTicketExitCounts(ind0, ind1 int64) ([]uint32, []uint32, error) {
+	if ind1 < ind0 || ind0 < 0 {
+		return nil, nil, fmt.Errorf("invalid block range [%d,%d]", ind0, ind1)
+	}
+
+	t.exitMtx.Lock()
+	defer t.exitMtx.Unlock()
+
+	// Totals above the tickets table height may still change, so the cache is
+	// only extended to that height.
+	cacheTo := t.GetTicketHeight()
+	if ind1 < cacheTo {
+		cacheTo = ind1
+	}
+
+	if from := int64(len(t.exitTotals)); from <= cacheTo {
+		totals, err := t.retrieveExitTotals(from, cacheTo)
+		if err != nil {
+			return nil, nil, err
+		}
+		t.exitTotals = append(t.exitTotals, totals...)
+	}
+
+	// Blocks above the cached totals have no exits in the tickets table.
+	var last exitTotal
+	if n := len(t.exitTotals); n > 0 {
+		last = t.exitTotals[n-1]
+	}
+	N := ind1 - ind0 + 1
+	missed, expired := make([]uint32, N), make([]uint32, N)
+	for i := ind0; i <= ind1; i++ {
+		total := last
+		if i < int64(len(t.exitTotals)) {
+			total = t.exitTotals[i]
+		}
+		missed[i-ind0], expired[i-ind0] = total.missed, total.expired
+	}
+	return missed, expired, nil
+}
+
+// retrieveExitTotals returns the totals for each block from height ind0 to
+// ind1 by adding the tickets that left the pool in that range to the cached
+// total at height ind0-1. The caller must hold exitMtx.
+func (t *Tables) retrieveExitTotals(ind0, ind1 int64) ([]exitTotal, error) {
+	rows, err := t.db.Query(t.queries.GetTicketExitCounts, ind0, ind1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var total exitTotal
+	if ind0 > 0 {
+		total = t.exitTotals[ind0-1]
+	}
+	totals := make([]exitTotal, ind1-ind0+1)
+	next := ind0
+	for rows.Next() {
+		var height int64
+		var numMissed, numExpired uint32
+		if err = rows.Scan(&height, &numMissed, &numExpired); err != nil {
+			return nil, err
+		}
+		// Fill in the totals for the blocks before this one.
+		for ; next < height && next <= ind1; next++ {
+			totals[next-ind0] = total
+		}
+		total.missed += numMissed
+		total.expired += numExpired
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	for ; next <= ind1; next++ {
+		totals[next-ind0] = total
+	}
+	return totals, nil
+}
@@ -0,0 +1,306 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package chaindb
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/dcrdata/dcrdata/dbtypes"
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/wire"
+)
+
+// StoreBlockTransactions inserts the transactions of both trees of the block
+// into the transactions table, with their inputs and outputs in the vins and
+// vouts tables, and marks as spent the outputs consumed by the block's inputs.
+// This is done in a single SQL transaction so that a block is either fully
+// stored or not at all.
+func (t *Tables) StoreBlockTransactions(msgBlock *wire.MsgBlock, params *chaincfg.Params) error {
+	b := &BlockIndex{height: int64(msgBlock.Header.Height)}
+	b.txs, b.vins, b.vouts = dbtypes.ExtractBlockTransactions(msgBlock, params)
+	return t.storeIndex(b, t.storeTransactions, &t.txHeight)
+}
+
+// storeTransactions stores the transaction, vin and vout rows of the block
+// with the statements of a SQL transaction.
+func (t *Tables) storeTransactions(s *txStmts, b *BlockIndex) error {
+	txStmt, err := s.prepare(t.queries.InsertTx)
+	if err != nil {
+		return err
+	}
+
+	for _, tx := range b.txs {
+		_, err = txStmt.Exec(tx.TxHash, tx.BlockHash, tx.BlockHeight, tx.BlockTime,
+			tx.BlockIndex, tx.Tree, tx.TxType, tx.Version, tx.Locktime, tx.Expiry,
+			tx.Size, tx.NumVin, tx.NumVout)
+		if err != nil {
+			return fmt.Errorf("unable to insert transaction row: %v", err)
+		}
+	}
+
+	voutStmt, err := s.prepare(t.queries.InsertVout)
+	if err != nil {
+		return err
+	}
+
+	// All outputs are inserted before any spends are recorded since a
+	// transaction may spend an output created earlier in the same block.
+	for _, o := range b.vouts {
+		_, err = voutStmt.Exec(o.TxHash, o.TxIndex, o.TxTree, o.BlockHeight,
+			o.Value, o.Version, o.PkScript, o.ScriptClass, o.ReqSigs,
+			strings.Join(o.Addresses, ","), o.CommitAmt)
+		if err != nil {
+			return fmt.Errorf("unable to insert vout row: %v", err)
+		}
+	}
+
+	vinStmt, err := s.prepare(t.queries.InsertVin)
+	if err != nil {
+		return err
+	}
+
+	spendStmt, err := s.prepare(t.queries.SetVoutSpending)
+	if err != nil {
+		return err
+	}
+
+	for _, in := range b.vins {
+		_, err = vinStmt.Exec(in.TxHash, in.TxIndex, in.TxTree, in.BlockHeight,
+			in.PrevTxHash, in.PrevTxIndex, in.PrevTxTree, in.Sequence,
+			in.ValueIn, in.ProofHeight, in.ProofIndex, in.SigScript)
+		if err != nil {
+			return fmt.Errorf("unable to insert vin row: %v", err)
+		}
+
+		// coinbase and stakebase inputs do not spend anything
+		if dbtypes.IsZeroHashStr(in.PrevTxHash) {
+			continue
+		}
+		_, err = spendStmt.Exec(in.TxHash, in.TxIndex, b.height,
+			in.PrevTxHash, in.PrevTxIndex)
+		if err != nil {
+			return fmt.Errorf("unable to set vout spending info: %v", err)
+		}
+	}
+	return nil
+}
+
+// DeleteTransactionsAboveHeight rolls back the transactions, vins and vouts
+// tables to the given height, and clears the spending info for outputs spent
+// in later blocks. This is used when handling a reorganization.
+func (t *Tables) DeleteTransactionsAboveHeight(height int64) error {
+	dbtx, err := t.db.Begin()
+	if err != nil {
+		return fmt.Errorf("unable to begin database transaction: %v", err)
+	}
+
+	for _, stmt := range []string{t.queries.DeleteTxsAbove, t.queries.DeleteVinsAbove,
+		t.queries.DeleteVoutsAbove, t.queries.UnsetVoutSpendingAbove} {
+		res, err := dbtx.Exec(stmt, height)
+		if err != nil {
+			_ = dbtx.Rollback()
+			return err
+		}
+		if err = logDBResult(res); err != nil {
+			_ = dbtx.Rollback()
+			return err
+		}
+	}
+
+	if err = dbtx.Commit(); err != nil {
+		return err
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.txHeight > height {
+		t.txHeight = height
+	}
+	return nil
+}
+
+// GetTransactionHeight returns the largest block height for which the
+// transactions table has been updated.
+func (t *Tables) GetTransactionHeight() int64 {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.txHeight < 0 {
+		height, err := t.RetrieveTransactionHeight()
+		if err != nil {
+			log.Errorf("RetrieveTransactionHeight failed: %v", err)
+			return -1
+		}
+		t.txHeight = height
+	}
+	return t.txHeight
+}
+
+// RetrieveTransactionHeight returns the height of the most recent block in
+// the transactions table, or -1 if the table is empty.
+func (t *Tables) RetrieveTransactionHeight() (int64, error) {
+	var height int64
+	err := t.db.QueryRow(t.queries.GetTxHeight).Scan(&height)
+	return height, err
+}
+
+// RetrieveTransaction returns the stored transaction with the given hash, or
+// sql.ErrNoRows if it is not in the transactions table.
+func (t *Tables) RetrieveTransaction(txHash string) (*dbtypes.Tx, error) {
+	tx := new(dbtypes.Tx)
+	err := t.db.QueryRow(t.queries.GetTx, txHash).Scan(&tx.TxHash, &tx.BlockHash,
+		&tx.BlockHeight, &tx.BlockTime, &tx.BlockIndex, &tx.Tree, &tx.TxType,
+		&tx.Version, &tx.Locktime, &tx.Expiry, &tx.Size, &tx.NumVin, &tx.NumVout)
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// RetrieveTxVins returns the stored inputs of the transaction, in order.
+func (t *Tables) RetrieveTxVins(txHash string) ([]*dbtypes.Vin, error) {
+	stmt, err := t.db.Prepare(t.queries.GetTxVins)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(txHash)
+	if err != nil {
+		log.Errorf("Query failed: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vins []*dbtypes.Vin
+	for rows.Next() {
+		in := new(dbtypes.Vin)
+		if err = rows.Scan(&in.TxHash, &in.TxIndex, &in.TxTree, &in.BlockHeight,
+			&in.PrevTxHash, &in.PrevTxIndex, &in.PrevTxTree, &in.Sequence,
+			&in.ValueIn, &in.ProofHeight, &in.ProofIndex, &in.SigScript); err != nil {
+			log.Errorf("Unable to scan for Vin fields: %v", err)
+			continue
+		}
+		vins = append(vins, in)
+	}
+	if err = rows.Err(); err != nil {
+		log.Error(err)
+	}
+
+	return vins, nil
+}
+
+// RetrieveTxVouts returns the stored outputs of the transaction, in order.
+func (t *Tables) RetrieveTxVouts(txHash string) ([]*dbtypes.Vout, error) {
+	stmt, err := t.db.Prepare(t.queries.GetTxVouts)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(txHash)
+	if err != nil {
+		log.Errorf("Query failed: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vouts []*dbtypes.Vout
+	for rows.Next() {
+		o := new(dbtypes.Vout)
+		var addresses string
+		if err = rows.Scan(&o.TxHash, &o.TxIndex, &o.TxTree, &o.BlockHeight,
+			&o.Value, &o.Version, &o.PkScript, &o.ScriptClass, &o.ReqSigs,
+			&addresses, &o.CommitAmt, &o.SpendingTxHash, &o.SpendingTxIndex,
+			&o.SpendingHeight); err != nil {
+			log.Errorf("Unable to scan for Vout fields: %v", err)
+			continue
+		}
+		if addresses != "" {
+			o.Addresses = strings.Split(addresses, ",")
+		}
+		vouts = append(vouts, o)
+	}
+	if err = rows.Err(); err != nil {
+		log.Error(err)
+	}
+
+	return vouts, nil
+}
+
+// RetrieveSpendingTx returns the hash, input index and block height of the
+// mined transaction spending the given output. The hash is empty and the
+// height is -1 if the output is unspent, and sql.ErrNoRows is returned if the
+// output is not in the vouts table.
+func (t *Tables) RetrieveSpendingTx(txHash string, index uint32) (string, uint32, int64, error) {
+	var spendingTxHash string
+	var spendingIndex, spendingHeight int64
+	err := t.db.QueryRow(t.queries.GetVoutSpender, txHash, index).Scan(&spendingTxHash,
+		&spendingIndex, &spendingHeight)
+	if err != nil {
+		return "", 0, -1, err
+	}
+	if spendingHeight < 0 {
+		return "", 0, -1, nil
+	}
+	return spendingTxHash, uint32(spendingIndex), spendingHeight, nil
+}
+
+// RetrieveTicketPoolDeltas returns the net changes in ticket pool size and
+// value, in atoms, at each height from ind0 to ind1, computed from the ticket
+// purchases and their spends in the vouts table. Tickets join the pool when
+// they mature and leave at the pool exit height in the tickets table. Tickets
+// not in the tickets table leave when spent by a vote or revocation, or when
+// they expire, so a missed ticket without a row is counted as live until it
+// is revoked.
+func (t *Tables) RetrieveTicketPoolDeltas(ind0, ind1, maturity, expiry int64) ([]int64, []int64, error) {
+	if ind1 < ind0 {
+		return nil, nil, fmt.Errorf("Cannot retrieve ticket pool deltas range (%d<%d)",
+			ind1, ind0)
+	}
+	sizes := make([]int64, ind1-ind0+1)
+	values := make([]int64, ind1-ind0+1)
+
+	// accumulate adds the per-height counts and values scanned from rows,
+	// with the given sign, at the row height plus offset.
+	accumulate := func(rows *sql.Rows, offset, sign int64) error {
+		defer rows.Close()
+		for rows.Next() {
+			var height, count, value int64
+			if err := rows.Scan(&height, &count, &value); err != nil {
+				log.Errorf("Unable to scan for ticket pool delta fields: %v", err)
+				continue
+			}
+			i := height + offset - ind0
+			if i < 0 || i >= int64(len(sizes)) {
+				continue
+			}
+			sizes[i] += sign * count
+			values[i] += sign * value
+		}
+		return rows.Err()
+	}
+
+	rows, err := t.db.Query(t.queries.GetTicketsMaturing, ind0-maturity, ind1-maturity)
+	if err != nil {
+		log.Errorf("Query failed: %v", err)
+		return nil, nil, err
+	}
+	if err = accumulate(rows, maturity, 1); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err = t.db.Query(t.queries.GetTicketsLeaving, maturity+expiry,
+		ind0-maturity-expiry, ind1, ind0)
+	if err != nil {
+		log.Errorf("Query failed: %v", err)
+		return nil, nil, err
+	}
+	if err = accumulate(rows, 0, -1); err != nil {
+		return nil, nil, err
+	}
+
+	return sizes, values, nil
+}
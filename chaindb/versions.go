@@ -0,0 +1,120 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package chaindb
+
+import (
+	"fmt"
+
+	"github.com/dcrdata/dcrdata/dbtypes"
+)
+
+// StoreBlockVersions inserts the block and stake versions of a block header
+// into the block versions table.
+func (t *Tables) StoreBlockVersions(bv *dbtypes.BlockVersions) error {
+	b := &BlockIndex{
+		height:   bv.Height,
+		versions: bv,
+	}
+	return t.storeIndex(b, t.storeVersions, &t.versionsHeight)
+}
+
+// storeVersions stores the block versions row of the block with the statements
+// of a SQL transaction.
+func (t *Tables) storeVersions(s *txStmts, b *BlockIndex) error {
+	stmt, err := s.prepare(t.queries.InsertVersions)
+	if err != nil {
+		return err
+	}
+
+	bv := b.versions
+	_, err = stmt.Exec(bv.Height, bv.Hash, bv.BlockVersion, bv.StakeVersion)
+	if err != nil {
+		return fmt.Errorf("unable to insert block versions row: %v", err)
+	}
+	return nil
+}
+
+// DeleteBlockVersionsAboveHeight rolls back the block versions table to the
+// given height. This is used when handling a reorganization.
+func (t *Tables) DeleteBlockVersionsAboveHeight(height int64) error {
+	res, err := t.db.Exec(t.queries.DeleteVersionsAbove, height)
+	if err != nil {
+		return err
+	}
+	if err = logDBResult(res); err != nil {
+		return err
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.versionsHeight > height {
+		t.versionsHeight = height
+	}
+	return nil
+}
+
+// GetBlockVersionsHeight returns the largest block height for which the block
+// versions table has been updated.
+func (t *Tables) GetBlockVersionsHeight() int64 {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.versionsHeight < 0 {
+		height, err := t.RetrieveBlockVersionsHeight()
+		if err != nil {
+			log.Errorf("RetrieveBlockVersionsHeight failed: %v", err)
+			return -1
+		}
+		t.versionsHeight = height
+	}
+	return t.versionsHeight
+}
+
+// RetrieveBlockVersionsHeight returns the height of the most recent block in
+// the block versions table, or -1 if the table is empty.
+func (t *Tables) RetrieveBlockVersionsHeight() (int64, error) {
+	var height int64
+	err := t.db.QueryRow(t.queries.GetVersionsHeight).Scan(&height)
+	return height, err
+}
+
+// RetrieveVersionCounts counts the blocks from height ind0 to ind1 by their
+// block or stake version, or the votes in them by vote version, depending on
+// vt. The blocks are grouped into bins of binSize blocks starting at ind0, so
+// that the counts for bin i are for the blocks from ind0+i*binSize. The counts
+// are in order of bin and then version.
+func (t *Tables) RetrieveVersionCounts(vt dbtypes.VersionType, ind0, ind1,
+	binSize int64) ([]dbtypes.VersionCount, error) {
+	if ind1 < ind0 || ind0 < 0 || binSize < 1 {
+		return nil, fmt.Errorf("invalid block range [%d,%d] or bin size %d",
+			ind0, ind1, binSize)
+	}
+
+	var query string
+	switch vt {
+	case dbtypes.BlockVersion:
+		query = t.queries.GetBlockVersionCounts
+	case dbtypes.StakeVersion:
+		query = t.queries.GetStakeVersionCounts
+	case dbtypes.VoteVersion:
+		query = t.queries.GetVoteVersionCounts
+	default:
+		return nil, fmt.Errorf("unknown version type %d", vt)
+	}
+
+	rows, err := t.db.Query(query, ind0, binSize, ind1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []dbtypes.VersionCount
+	for rows.Next() {
+		var c dbtypes.VersionCount
+		if err = rows.Scan(&c.Bin, &c.Version, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
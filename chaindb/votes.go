@@ -0,0 +1,118 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package chaindb
+
+import (
+	"fmt"
+
+	"github.com/dcrdata/dcrdata/dbtypes"
+	"github.com/decred/dcrd/wire"
+)
+
+// StoreBlockVotes inserts the votes in the block's stake tree into the votes
+// table in a single SQL transaction.
+func (t *Tables) StoreBlockVotes(msgBlock *wire.MsgBlock) error {
+	b := &BlockIndex{
+		height: int64(msgBlock.Header.Height),
+		votes:  dbtypes.ExtractBlockVotes(msgBlock),
+	}
+	// The votes table height is that of the last block with votes.
+	var height *int64
+	if len(b.votes) > 0 {
+		height = &t.voteHeight
+	}
+	return t.storeIndex(b, t.storeVotes, height)
+}
+
+// storeVotes stores the vote rows of the block with the statements of a SQL
+// transaction.
+func (t *Tables) storeVotes(s *txStmts, b *BlockIndex) error {
+	stmt, err := s.prepare(t.queries.InsertVote)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range b.votes {
+		_, err = stmt.Exec(v.TxHash, v.BlockHeight, v.TicketHash,
+			v.VoteVersion, v.VoteBits)
+		if err != nil {
+			return fmt.Errorf("unable to insert vote row: %v", err)
+		}
+	}
+	return nil
+}
+
+// DeleteVotesAboveHeight rolls back the votes table to the given height. This
+// is used when handling a reorganization.
+func (t *Tables) DeleteVotesAboveHeight(height int64) error {
+	res, err := t.db.Exec(t.queries.DeleteVotesAbove, height)
+	if err != nil {
+		return err
+	}
+	if err = logDBResult(res); err != nil {
+		return err
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.voteHeight > height {
+		t.voteHeight = height
+	}
+	return nil
+}
+
+// GetVoteHeight returns the largest block height for which the votes table
+// has been updated. Blocks before stake validation height have no votes, so
+// the table is empty until then.
+func (t *Tables) GetVoteHeight() int64 {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.voteHeight < 0 {
+		height, err := t.RetrieveVoteHeight()
+		if err != nil {
+			log.Errorf("RetrieveVoteHeight failed: %v", err)
+			return -1
+		}
+		t.voteHeight = height
+	}
+	return t.voteHeight
+}
+
+// RetrieveVoteHeight returns the height of the most recent block in the votes
+// table, or -1 if the table is empty.
+func (t *Tables) RetrieveVoteHeight() (int64, error) {
+	var height int64
+	err := t.db.QueryRow(t.queries.GetVoteHeight).Scan(&height)
+	return height, err
+}
+
+// RetrieveVoteBitsCounts counts the votes of the given vote version in the
+// blocks from height ind0 to ind1 by their vote bits masked with mask. The
+// blocks are grouped into bins of binSize blocks starting at ind0, so that
+// the counts for bin i are for the blocks from ind0+i*binSize. The counts are
+// in order of bin, and bins with no votes are omitted.
+func (t *Tables) RetrieveVoteBitsCounts(version uint32, mask uint16, ind0, ind1,
+	binSize int64) ([]dbtypes.VoteBitsCount, error) {
+	if ind1 < ind0 || ind0 < 0 || binSize < 1 {
+		return nil, fmt.Errorf("invalid block range [%d,%d] or bin size %d",
+			ind0, ind1, binSize)
+	}
+
+	rows, err := t.db.Query(t.queries.GetVoteBitsCounts, ind0, binSize, mask,
+		version, ind1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []dbtypes.VoteBitsCount
+	for rows.Next() {
+		var c dbtypes.VoteBitsCount
+		if err = rows.Scan(&c.Bin, &c.Bits, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
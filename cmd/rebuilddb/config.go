@@ -37,6 +37,8 @@ var (
 	defaultDBPass      = "bananas"
 	defaultDBTableName = "dcrdata"
 	defaultDBFileName  = "dcrdata.sqlt.db"
+
+	defaultSyncBatchSize = 500
 )
 
 type config struct {
@@ -49,6 +51,9 @@ type config struct {
 	Quiet       bool   `short:"q" long:"quiet" description:"Easy way to set debuglevel to error"`
 	LogDir      string `long:"logdir" description:"Directory to log output"`
 	CPUProfile  string `long:"cpuprofile" description:"File for CPU profiling."`
+	Check       bool   `long:"check" description:"Check the database against the node's main chain and the stake DB, report problems, and exit"`
+	Repair      bool   `long:"repair" description:"Check the database, rewrite the rows at heights with problems, and exit"`
+	RebuildPool bool   `long:"rebuildpool" description:"Recompute the ticket pool values of all stored blocks from the transactions table and stake DB checkpoints, and exit"`
 
 	// DB
 	DBFileName string `long:"dbfile" description:"DB file name"`
@@ -57,6 +62,8 @@ type config struct {
 	DBPass     string `long:"dbpass" description:"DB pass"`
 	DBTable    string `long:"dbtable" description:"DB table name"`
 
+	SyncBatchSize int `long:"syncbatchsize" description:"Number of blocks fetched and stored together (default is 500)"`
+
 	// RPC client options
 	DcrdUser         string `long:"dcrduser" description:"Daemon RPC user name"`
 	DcrdPass         string `long:"dcrdpass" description:"Daemon RPC password"`
@@ -81,6 +88,8 @@ var (
 		DBPass:     defaultDBPass,
 		DBTable:    defaultDBTableName,
 		DcrdCert:   defaultDaemonRPCCertFile,
+
+		SyncBatchSize: defaultSyncBatchSize,
 	}
 )
 
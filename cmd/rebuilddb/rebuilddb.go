@@ -81,6 +81,7 @@ func mainCore() int {
 	}
 	log.Infof("SQLite DB successfully opened: %s", cfg.DBFileName)
 	defer sqliteDB.Close()
+	sqliteDB.SetSyncBatchSize(cfg.SyncBatchSize)
 
 	// Ctrl-C to shut down.
 	// Nothing should be sent the quit channel.  It should only be closed.
@@ -98,6 +99,45 @@ func mainCore() int {
 		close(quit)
 	}()
 
+	// Check or repair db instead of resyncing
+	if cfg.Check || cfg.Repair {
+		report, err := sqliteDB.CheckDB(cfg.Repair, quit)
+		if report != nil {
+			log.Infof("Database check summary:\n%v", report)
+		}
+		if err != nil {
+			log.Errorf("Database check failed: %v", err)
+			return 1
+		}
+		if !cfg.Repair && report.NumProblems() > 0 {
+			log.Warnf("Problems found at %d heights. Run with --repair to fix them.",
+				report.NumProblems())
+			return 3
+		}
+		if len(report.Unrepaired) > 0 {
+			log.Warnf("Unable to repair %d heights with unknown ticket pool "+
+				"values. Run with --rebuildpool to recompute them.",
+				len(report.Unrepaired))
+			return 3
+		}
+		log.Print("Done!")
+		return 0
+	}
+
+	// Recompute pool values instead of resyncing
+	if cfg.RebuildPool {
+		height := sqliteDB.GetBlockSummaryHeight()
+		if txHeight := sqliteDB.GetTransactionHeight(); txHeight < height {
+			height = txHeight
+		}
+		if err = sqliteDB.RebuildPoolInfo(0, height, quit); err != nil {
+			log.Errorf("Pool info rebuild failed: %v", err)
+			return 1
+		}
+		log.Print("Done!")
+		return 0
+	}
+
 	// Resync db
 	var waitSync sync.WaitGroup
 	waitSync.Add(1)
@@ -7,6 +7,7 @@ package main
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -50,8 +51,16 @@ var (
 	defaultMempoolMinInterval = 2
 	defaultMempoolMaxInterval = 120
 	defaultMPTriggerTickets   = 1
+	defaultMPHistoryDays      = 30
 
 	defaultDBFileName = "dcrdata.sqlt.db"
+	defaultDBBackend  = "sqlite"
+	defaultPGHost     = "127.0.0.1:5432"
+	defaultPGPort     = "5432"
+	defaultPGUser     = "dcrdata"
+	defaultPGDBName   = "dcrdata"
+
+	defaultSyncBatchSize = 500
 )
 
 type config struct {
@@ -82,7 +91,14 @@ type config struct {
 	MempoolMaxInterval int    `long:"mp-max-interval" description:"The maximum time in seconds between mempool reports (within a couple seconds), regarless of number of new tickets seen."`
 	MPTriggerTickets   int    `long:"mp-ticket-trigger" description:"The number minimum number of new tickets that must be seen to trigger a new mempool report."`
 	DumpAllMPTix       bool   `long:"dumpallmptix" description:"Dump to file the fees of all the tickets in mempool."`
+	MPHistoryDays      int    `long:"mp-history-days" description:"The number of days of mempool history kept in the database, or 0 to keep all of it (default is 30)."`
 	DBFileName         string `long:"dbfile" description:"SQLite DB file name (default is dcrdata.sqlt.db)."`
+	DBBackend          string `long:"dbbackend" description:"Database backend {sqlite, postgresql} (default is sqlite)."`
+	PGHost             string `long:"pghost" description:"PostgreSQL server host[:port] (default is 127.0.0.1:5432)."`
+	PGUser             string `long:"pguser" description:"PostgreSQL user name (default is dcrdata)."`
+	PGPass             string `long:"pgpass" description:"PostgreSQL password."`
+	PGDBName           string `long:"pgdbname" description:"PostgreSQL database name (default is dcrdata)."`
+	SyncBatchSize      int    `long:"syncbatchsize" description:"Number of blocks fetched and stored together when resyncing the database (default is 500)."`
 
 	//WatchAddresses []string `short:"w" long:"watchaddress" description:"Watched address (receiving). One per line."`
 	//WatchOutpoints []string `short:"o" long:"watchout" description:"Watched outpoint (sending). One per line."`
@@ -117,7 +133,13 @@ var (
 		MempoolMinInterval: defaultMempoolMinInterval,
 		MempoolMaxInterval: defaultMempoolMaxInterval,
 		MPTriggerTickets:   defaultMPTriggerTickets,
+		MPHistoryDays:      defaultMPHistoryDays,
 		DBFileName:         defaultDBFileName,
+		DBBackend:          defaultDBBackend,
+		PGHost:             defaultPGHost,
+		PGUser:             defaultPGUser,
+		PGDBName:           defaultPGDBName,
+		SyncBatchSize:      defaultSyncBatchSize,
 		//EmailSubject:       defaultEmailSubject,
 	}
 )
@@ -140,6 +162,16 @@ func cleanAndExpandPath(path string) string {
 	return filepath.Clean(os.ExpandEnv(path))
 }
 
+// normalizeAddress returns addr with the default port appended if it does not
+// already specify a port.
+func normalizeAddress(addr, defaultPort string) string {
+	_, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return net.JoinHostPort(addr, defaultPort)
+	}
+	return addr
+}
+
 // validLogLevel returns whether or not logLevel is a valid debug log level.
 func validLogLevel(logLevel string) bool {
 	_, ok := btclog.LevelFromString(logLevel)
@@ -311,6 +343,36 @@ func loadConfig() (*config, error) {
 		cfg.DcrdServ = defaultHost + ":" + activeNet.JSONRPCClientPort
 	}
 
+	// Only the sqlite and postgresql database backends are supported.
+	switch cfg.DBBackend {
+	case "sqlite", "postgresql":
+	default:
+		str := "%s: Invalid dbbackend %q -- choose sqlite or postgresql"
+		err := fmt.Errorf(str, "loadConfig", cfg.DBBackend)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return loadConfigError(err)
+	}
+
+	// A PostgreSQL host given without a port uses the default port.
+	cfg.PGHost = normalizeAddress(cfg.PGHost, defaultPGPort)
+
+	if cfg.SyncBatchSize < 1 {
+		str := "%s: syncbatchsize must be at least 1"
+		err := fmt.Errorf(str, "loadConfig")
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return loadConfigError(err)
+	}
+
+	if cfg.MPHistoryDays < 0 {
+		str := "%s: mp-history-days may not be negative"
+		err := fmt.Errorf(str, "loadConfig")
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return loadConfigError(err)
+	}
+
 	// Put comma-separated comamnd line aguments into slice of strings
 	//cfg.CmdArgs = strings.Split(cfg.CmdArgs[0], ",")
 
@@ -0,0 +1,412 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+// Package dbtypes defines the data types and block data extraction functions
+// shared by the dcrdata database backends.
+package dbtypes
+
+import (
+	"encoding/hex"
+
+	"github.com/decred/dcrd/blockchain/stake"
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/dcrd/txscript"
+	"github.com/decred/dcrd/wire"
+)
+
+// AddressOutpoint models a transaction output paying to an address, and the
+// transaction input that spends it (if any) as stored in the address table.
+type AddressOutpoint struct {
+	Address     string
+	TxHash      string
+	TxIndex     uint32
+	TxTree      int8
+	Value       int64
+	BlockHeight int64
+	BlockTime   int64
+	// Spending info. SpendingHeight is -1 for unspent outputs.
+	SpendingTxHash  string
+	SpendingTxIndex int64
+	SpendingHeight  int64
+	SpendingTime    int64
+}
+
+// AddressTxn models a transaction that involves an address, either by funding
+// it or spending from it. Received and Sent are in atoms.
+type AddressTxn struct {
+	TxHash      string
+	BlockHeight int64
+	BlockTime   int64
+	Received    int64
+	Sent        int64
+}
+
+// AddressTotals contains the aggregate amounts for an address, in atoms.
+type AddressTotals struct {
+	NumTxns  int64
+	Received int64
+	Sent     int64
+	Unspent  int64
+}
+
+// OutpointSpend identifies a previous outpoint spent by input TxIndex of
+// transaction TxHash.
+type OutpointSpend struct {
+	PrevTxHash  string
+	PrevTxIndex uint32
+	TxHash      string
+	TxIndex     uint32
+}
+
+// Tx models a mined transaction as stored in the transactions table.
+type Tx struct {
+	TxHash      string
+	BlockHash   string
+	BlockHeight int64
+	BlockTime   int64
+	BlockIndex  uint32
+	Tree        int8
+	TxType      int
+	Version     int32
+	Locktime    uint32
+	Expiry      uint32
+	Size        int32
+	NumVin      uint32
+	NumVout     uint32
+}
+
+// Vin models a transaction input as stored in the vins table. ProofHeight and
+// ProofIndex are the input's fraud proof, while BlockHeight is the height of
+// the block containing the spending transaction.
+type Vin struct {
+	TxHash      string
+	TxIndex     uint32
+	TxTree      int8
+	BlockHeight int64
+	PrevTxHash  string
+	PrevTxIndex uint32
+	PrevTxTree  int8
+	Sequence    uint32
+	ValueIn     int64
+	ProofHeight uint32
+	ProofIndex  uint32
+	SigScript   string
+}
+
+// Vout models a transaction output as stored in the vouts table. PkScript is
+// hex encoded. CommitAmt is only meaningful for the "sstxcommitment" outputs of
+// ticket purchases.
+type Vout struct {
+	TxHash      string
+	TxIndex     uint32
+	TxTree      int8
+	BlockHeight int64
+	Value       int64
+	Version     uint16
+	PkScript    string
+	ScriptClass string
+	ReqSigs     int32
+	Addresses   []string
+	CommitAmt   int64
+	// Spending info. SpendingHeight is -1 for unspent outputs.
+	SpendingTxHash  string
+	SpendingTxIndex int64
+	SpendingHeight  int64
+}
+
+// TicketPoolStatus describes a ticket's place in the ticket pool.
+type TicketPoolStatus string
+
+// Ticket pool statuses. A ticket is immature until TicketMaturity blocks after
+// its purchase, when it becomes live. A live ticket leaves the pool when it is
+// called to vote, and votes or is missed, or when it expires.
+const (
+	PoolStatusImmature TicketPoolStatus = "immature"
+	PoolStatusLive     TicketPoolStatus = "live"
+	PoolStatusVoted    TicketPoolStatus = "voted"
+	PoolStatusMissed   TicketPoolStatus = "missed"
+	PoolStatusExpired  TicketPoolStatus = "expired"
+)
+
+// TicketUpdate is a change in the status of a ticket in a block. Either the
+// ticket's pool status changed to Status, or the ticket was revoked.
+type TicketUpdate struct {
+	TicketHash     string
+	PurchaseHeight int64
+	Status         TicketPoolStatus
+	Revoked        bool
+}
+
+// Ticket models the lifecycle of a mature ticket as stored in the tickets
+// table. PoolExitHeight is the height at which the ticket voted, was missed
+// or expired, and SpendHeight is the height of the vote or revocation. Both
+// are -1 if not applicable.
+type Ticket struct {
+	TxHash         string
+	PurchaseHeight int64
+	MaturityHeight int64
+	PoolStatus     TicketPoolStatus
+	PoolExitHeight int64
+	Revoked        bool
+	SpendHeight    int64
+}
+
+// Vote models a vote (ssgen) as stored in the votes table. VoteBits are the
+// vote bits of the vote's version, including the block validity bit.
+type Vote struct {
+	TxHash      string
+	BlockHeight int64
+	TicketHash  string
+	VoteVersion uint32
+	VoteBits    uint16
+}
+
+// VoteBitsCount is the number of votes with the same masked vote bits in a bin
+// of consecutive blocks.
+type VoteBitsCount struct {
+	Bin   int64
+	Bits  uint16
+	Count uint32
+}
+
+// BlockVersions models the versions in a block header, as stored in the
+// versions table.
+type BlockVersions struct {
+	Height       int64
+	Hash         string
+	BlockVersion int32
+	StakeVersion uint32
+}
+
+// VersionType identifies the kind of version counted by a version count.
+type VersionType int
+
+// The versions that are counted. Block and stake versions are counted per
+// block header, while vote versions are counted per vote.
+const (
+	BlockVersion VersionType = iota
+	StakeVersion
+	VoteVersion
+)
+
+// VersionCount is the number of blocks or votes with the same version in a bin
+// of consecutive blocks.
+type VersionCount struct {
+	Bin     int64
+	Version uint32
+	Count   uint32
+}
+
+// MempoolSnapshot models a mempool data collection, as stored in the mempool
+// history table. The ticket fee rates are in DCR/kB, and Time is the UNIX time
+// of the collection.
+type MempoolSnapshot struct {
+	Time        int64
+	Height      int64
+	NumTickets  uint32
+	FeeMin      float64
+	FeeMean     float64
+	FeeMedian   float64
+	FeeMax      float64
+	NumRegular  uint32
+	RegularSize int64
+}
+
+// MempoolHistoryBin is the average of the mempool snapshots in a bin of
+// consecutive seconds.
+type MempoolHistoryBin struct {
+	Bin         int64
+	NumSamples  uint32
+	Height      int64
+	NumTickets  float64
+	FeeMin      float64
+	FeeMean     float64
+	FeeMedian   float64
+	FeeMax      float64
+	NumRegular  float64
+	RegularSize float64
+}
+
+// SStxCommitmentClass is the script class reported for the commitment outputs
+// of a ticket purchase, matching dcrd's getrawtransaction.
+const SStxCommitmentClass = "sstxcommitment"
+
+// zeroHash is the previous outpoint hash of coinbase and stakebase inputs.
+var zeroHash chainhash.Hash
+
+// ExtractBlockAddresses extracts the address outputs and the spent previous
+// outpoints of all transactions in both trees of the block.
+func ExtractBlockAddresses(msgBlock *wire.MsgBlock, params *chaincfg.Params) ([]*AddressOutpoint, []*OutpointSpend) {
+	height := int64(msgBlock.Header.Height)
+	blockTime := msgBlock.Header.Timestamp.Unix()
+
+	var outs []*AddressOutpoint
+	var spends []*OutpointSpend
+
+	processTxns := func(txns []*wire.MsgTx, tree int8) {
+		for _, tx := range txns {
+			txHash := tx.TxHash().String()
+			for i, txOut := range tx.TxOut {
+				_, txAddrs, _, err := txscript.ExtractPkScriptAddrs(
+					txOut.Version, txOut.PkScript, params)
+				if err != nil {
+					continue
+				}
+				for _, txAddr := range txAddrs {
+					outs = append(outs, &AddressOutpoint{
+						Address:     txAddr.EncodeAddress(),
+						TxHash:      txHash,
+						TxIndex:     uint32(i),
+						TxTree:      tree,
+						Value:       txOut.Value,
+						BlockHeight: height,
+						BlockTime:   blockTime,
+					})
+				}
+			}
+			for i, txIn := range tx.TxIn {
+				prevOut := &txIn.PreviousOutPoint
+				// coinbase and stakebase inputs do not spend anything
+				if prevOut.Hash == zeroHash {
+					continue
+				}
+				spends = append(spends, &OutpointSpend{
+					PrevTxHash:  prevOut.Hash.String(),
+					PrevTxIndex: prevOut.Index,
+					TxHash:      txHash,
+					TxIndex:     uint32(i),
+				})
+			}
+		}
+	}
+
+	processTxns(msgBlock.Transactions, wire.TxTreeRegular)
+	processTxns(msgBlock.STransactions, wire.TxTreeStake)
+
+	return outs, spends
+}
+
+// ExtractBlockTransactions extracts the transactions, inputs and outputs of
+// both trees of the block for storage in the transactions, vins and vouts
+// tables.
+func ExtractBlockTransactions(msgBlock *wire.MsgBlock, params *chaincfg.Params) ([]*Tx, []*Vin, []*Vout) {
+	height := int64(msgBlock.Header.Height)
+	blockTime := msgBlock.Header.Timestamp.Unix()
+	blockHash := msgBlock.BlockHash().String()
+
+	var txs []*Tx
+	var vins []*Vin
+	var vouts []*Vout
+
+	processTxns := func(txns []*wire.MsgTx, tree int8) {
+		for blockIndex, tx := range txns {
+			txHash := tx.TxHash().String()
+			txType := stake.DetermineTxType(tx)
+			txs = append(txs, &Tx{
+				TxHash:      txHash,
+				BlockHash:   blockHash,
+				BlockHeight: height,
+				BlockTime:   blockTime,
+				BlockIndex:  uint32(blockIndex),
+				Tree:        tree,
+				TxType:      int(txType),
+				Version:     int32(tx.Version),
+				Locktime:    tx.LockTime,
+				Expiry:      tx.Expiry,
+				Size:        int32(tx.SerializeSize()),
+				NumVin:      uint32(len(tx.TxIn)),
+				NumVout:     uint32(len(tx.TxOut)),
+			})
+
+			for i, txIn := range tx.TxIn {
+				prevOut := &txIn.PreviousOutPoint
+				vins = append(vins, &Vin{
+					TxHash:      txHash,
+					TxIndex:     uint32(i),
+					TxTree:      tree,
+					BlockHeight: height,
+					PrevTxHash:  prevOut.Hash.String(),
+					PrevTxIndex: prevOut.Index,
+					PrevTxTree:  prevOut.Tree,
+					Sequence:    txIn.Sequence,
+					ValueIn:     txIn.ValueIn,
+					ProofHeight: txIn.BlockHeight,
+					ProofIndex:  txIn.BlockIndex,
+					SigScript:   hex.EncodeToString(txIn.SignatureScript),
+				})
+			}
+
+			for i, txOut := range tx.TxOut {
+				vout := &Vout{
+					TxHash:          txHash,
+					TxIndex:         uint32(i),
+					TxTree:          tree,
+					BlockHeight:     height,
+					Value:           txOut.Value,
+					Version:         txOut.Version,
+					PkScript:        hex.EncodeToString(txOut.PkScript),
+					SpendingTxIndex: -1,
+					SpendingHeight:  -1,
+				}
+				// The odd outputs of a ticket purchase are commitments that
+				// encode an address and amount rather than a standard script.
+				var addrs []dcrutil.Address
+				if txType == stake.TxTypeSStx && i%2 != 0 {
+					vout.ScriptClass = SStxCommitmentClass
+					vout.ReqSigs = 1
+					if addr, err := stake.AddrFromSStxPkScrCommitment(txOut.PkScript,
+						params); err == nil {
+						addrs = []dcrutil.Address{addr}
+					}
+					if amt, err := stake.AmountFromSStxPkScrCommitment(txOut.PkScript); err == nil {
+						vout.CommitAmt = int64(amt)
+					}
+				} else {
+					class, txAddrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(
+						txOut.Version, txOut.PkScript, params)
+					vout.ScriptClass = class.String()
+					vout.ReqSigs = int32(reqSigs)
+					addrs = txAddrs
+				}
+				for _, addr := range addrs {
+					vout.Addresses = append(vout.Addresses, addr.EncodeAddress())
+				}
+				vouts = append(vouts, vout)
+			}
+		}
+	}
+
+	processTxns(msgBlock.Transactions, wire.TxTreeRegular)
+	processTxns(msgBlock.STransactions, wire.TxTreeStake)
+
+	return txs, vins, vouts
+}
+
+// ExtractBlockVotes extracts the votes in the block's stake tree for storage
+// in the votes table.
+func ExtractBlockVotes(msgBlock *wire.MsgBlock) []*Vote {
+	height := int64(msgBlock.Header.Height)
+	var votes []*Vote
+	for _, stx := range msgBlock.STransactions {
+		if isVote, _ := stake.IsSSGen(stx); !isVote {
+			continue
+		}
+		votes = append(votes, &Vote{
+			TxHash:      stx.TxHash().String(),
+			BlockHeight: height,
+			// The ticket is spent by the second input, after the stakebase.
+			TicketHash:  stx.TxIn[1].PreviousOutPoint.Hash.String(),
+			VoteVersion: stake.SSGenVersion(stx),
+			VoteBits:    stake.SSGenVoteBits(stx),
+		})
+	}
+	return votes
+}
+
+// IsZeroHashStr reports whether the hex encoded hash is the all-zero hash of
+// a coinbase or stakebase input's previous outpoint.
+func IsZeroHashStr(hash string) bool {
+	return hash == zeroHash.String()
+}
@@ -4,6 +4,8 @@
 package dcrdataapi
 
 import (
+	"encoding/json"
+
 	"github.com/dcrdata/dcrdata/txhelpers"
 	"github.com/decred/dcrd/dcrjson"
 	"github.com/decred/dcrd/dcrutil"
@@ -114,10 +116,27 @@ type ScriptPubKey struct {
 
 // TxOut defines a decred transaction output.
 type TxOut struct {
-	Value     float64  `json:"value"`
-	Version   uint16   `json:"version"`
-	PkScript  string   `json:"pkscript"`
-	Addresses []string `json:"addresses,omitempty"`
+	Value     float64    `json:"value"`
+	Version   uint16     `json:"version"`
+	PkScript  string     `json:"pkscript"`
+	Addresses []string   `json:"addresses,omitempty"`
+	Spender   *TxSpender `json:"spender,omitempty"`
+}
+
+// TxSpender identifies the mined transaction input that spends an output.
+type TxSpender struct {
+	TxID        string `json:"txid"`
+	Vin         uint32 `json:"vin"`
+	BlockHeight int64  `json:"blockheight"`
+}
+
+// TxOutSpender models the spent status of the transaction output TxID:Vout,
+// and the input spending it, if any.
+type TxOutSpender struct {
+	TxID    string     `json:"txid"`
+	Vout    uint32     `json:"vout"`
+	Spent   bool       `json:"spent"`
+	Spender *TxSpender `json:"spender,omitempty"`
 }
 
 // TxIn defines a decred transaction input.
@@ -171,6 +190,35 @@ type AddressTxShort struct {
 	Confirmations int64   `json:"confirmations"`
 }
 
+// AddressBalance models the confirmed balance of an address, and the net
+// change in balance from transactions in mempool
+type AddressBalance struct {
+	Address     string  `json:"address"`
+	Confirmed   float64 `json:"confirmed"`
+	Unconfirmed float64 `json:"unconfirmed"`
+	NumUnconfTx int     `json:"num_unconfirmed_txns"`
+}
+
+// AddressTotals models the number of transactions and the total amounts
+// received and sent by an address in mined transactions
+type AddressTotals struct {
+	Address  string  `json:"address"`
+	NumTxns  int64   `json:"num_txns"`
+	Received float64 `json:"received"`
+	Sent     float64 `json:"sent"`
+	Unspent  float64 `json:"unspent"`
+}
+
+// AddressUTXO models an unspent transaction output paying to an address
+type AddressUTXO struct {
+	TxID          string  `json:"txid"`
+	Vout          uint32  `json:"vout"`
+	Tree          int8    `json:"tree"`
+	Amount        float64 `json:"amount"`
+	Height        int64   `json:"height"`
+	Confirmations int64   `json:"confirmations"`
+}
+
 // BlockDataWithTxType adds an array of TxRawWithTxType to
 // dcrjson.GetBlockVerboseResult to include the stake transaction type
 type BlockDataWithTxType struct {
@@ -234,11 +282,146 @@ type Status struct {
 	DcrdataVersion  string `json:"dcrdata_version"`
 }
 
-// TicketPoolInfo models data about ticket pool
+// TicketPoolInfo models data about ticket pool. Missed and Expired are the
+// running totals of tickets that left the pool by missing their vote or by
+// expiring, and are only set by the ticket pool endpoints.
 type TicketPoolInfo struct {
-	Size   uint32  `json:"size"`
-	Value  float64 `json:"value"`
-	ValAvg float64 `json:"valavg"`
+	Size    uint32  `json:"size"`
+	Value   float64 `json:"value"`
+	ValAvg  float64 `json:"valavg"`
+	Missed  uint32  `json:"missed,omitempty"`
+	Expired uint32  `json:"expired,omitempty"`
+}
+
+// TicketInfo models the lifecycle of a ticket. Status is one of immature,
+// live, voted, missed, expired or revoked, while PoolStatus omits revocation,
+// which happens after a missed or expired ticket has left the pool. Heights
+// that do not yet apply are omitted.
+type TicketInfo struct {
+	TxID             string `json:"txid"`
+	Status           string `json:"status"`
+	PoolStatus       string `json:"pool_status"`
+	PurchaseHeight   int64  `json:"purchase_height"`
+	MaturityHeight   int64  `json:"maturity_height"`
+	ExpirationHeight int64  `json:"expiration_height"`
+	PoolExitHeight   int64  `json:"pool_exit_height,omitempty"`
+	SpendHeight      int64  `json:"spend_height,omitempty"`
+	SpendingTxID     string `json:"spending_txid,omitempty"`
+}
+
+// TicketPoolDistribution models the distribution of the live tickets at a
+// block by purchase price, in buckets of PriceBucketWidth DCR, and by age in
+// blocks since maturity, in buckets of AgeBucketWidth blocks. UnknownAge counts
+// tickets for which the purchase height could not be determined, and
+// Unavailable the tickets that could not be fetched from the node, which are in
+// neither distribution.
+type TicketPoolDistribution struct {
+	Height           uint32             `json:"height"`
+	PoolSize         uint32             `json:"pool_size"`
+	PriceBucketWidth float64            `json:"price_bucket_width"`
+	AgeBucketWidth   int64              `json:"age_bucket_width"`
+	Price            []TicketPoolBucket `json:"price"`
+	Age              []TicketPoolBucket `json:"age"`
+	UnknownAge       uint32             `json:"unknown_age,omitempty"`
+	Unavailable      uint32             `json:"unavailable,omitempty"`
+}
+
+// TicketPoolBucket models the number and total value of the tickets in a
+// bucket of a TicketPoolDistribution, with Lower the bucket's lower bound.
+type TicketPoolBucket struct {
+	Lower float64 `json:"lower"`
+	Count uint32  `json:"count"`
+	Value float64 `json:"value"`
+}
+
+// StakeDiffWindow models the ticket purchases in a stake difficulty window.
+// The fees are in DCR/kB, and PoolSizeChange is the change in ticket pool size
+// from the end of the previous window. Complete is false for the current
+// window, which has not yet reached EndHeight.
+type StakeDiffWindow struct {
+	Window         int64   `json:"window"`
+	StartHeight    int64   `json:"start_height"`
+	EndHeight      int64   `json:"end_height"`
+	Complete       bool    `json:"complete"`
+	StakeDiff      float64 `json:"stakediff"`
+	NumTickets     uint32  `json:"num_tickets"`
+	FeeMin         float64 `json:"fee_min"`
+	FeeMean        float64 `json:"fee_mean"`
+	FeeMax         float64 `json:"fee_max"`
+	PoolSizeChange int64   `json:"pool_size_change"`
+}
+
+// AgendaTally models the number of votes for each choice of an agenda in the
+// blocks from StartHeight to EndHeight. Yes counts the votes for any choice
+// that is neither abstain nor no.
+type AgendaTally struct {
+	StartHeight int64  `json:"start_height"`
+	EndHeight   int64  `json:"end_height"`
+	Yes         uint32 `json:"yes"`
+	No          uint32 `json:"no"`
+	Abstain     uint32 `json:"abstain"`
+}
+
+// AgendaInterval models the votes on an agenda in a rule change interval, and
+// the progress toward the quorum of non-abstaining votes and the threshold
+// fraction of yes votes required for the agenda to pass. Complete is false for
+// the current interval, which has not yet reached EndHeight.
+type AgendaInterval struct {
+	Interval int64 `json:"interval"`
+	Complete bool  `json:"complete"`
+	AgendaTally
+	QuorumProgress float64 `json:"quorum_progress"`
+	YesRatio       float64 `json:"yes_ratio"`
+	QuorumMet      bool    `json:"quorum_met"`
+	ThresholdMet   bool    `json:"threshold_met"`
+}
+
+// Agenda models a consensus rule change agenda and the votes on it.
+type Agenda struct {
+	ID              string           `json:"id"`
+	Description     string           `json:"description"`
+	VoteVersion     uint32           `json:"vote_version"`
+	Mask            uint16           `json:"mask"`
+	Choices         []string         `json:"choices"`
+	StartTime       uint64           `json:"start_time"`
+	ExpireTime      uint64           `json:"expire_time"`
+	Quorum          uint32           `json:"quorum"`
+	Threshold       float64          `json:"threshold"`
+	CurrentInterval *AgendaInterval  `json:"current_interval,omitempty"`
+	Intervals       []AgendaInterval `json:"intervals,omitempty"`
+	Blocks          []AgendaTally    `json:"blocks,omitempty"`
+}
+
+// VersionCount models the number of blocks or votes with a version, and their
+// percentage of all the blocks or votes counted in a VersionWindow.
+type VersionCount struct {
+	Version uint32  `json:"version"`
+	Count   uint32  `json:"count"`
+	Percent float64 `json:"percent"`
+}
+
+// VersionWindow models the block and stake versions of the block headers from
+// StartHeight to EndHeight, and the versions of the votes in those blocks.
+// Blocks and Votes are the numbers of blocks and votes counted.
+type VersionWindow struct {
+	StartHeight   int64          `json:"start_height"`
+	EndHeight     int64          `json:"end_height"`
+	Blocks        uint32         `json:"blocks"`
+	Votes         uint32         `json:"votes"`
+	BlockVersions []VersionCount `json:"block_versions"`
+	StakeVersions []VersionCount `json:"stake_versions"`
+	VoteVersions  []VersionCount `json:"vote_versions"`
+}
+
+// StakeVersionsSummary models the upgrade progress of the block, stake and vote
+// versions as of the block at Height. LastBlocks is the window of blocks that
+// dcrd checks for block version upgrades. The current stake version interval
+// is incomplete, and there are no intervals before stake validation height.
+type StakeVersionsSummary struct {
+	Height           int64          `json:"height"`
+	LastBlocks       *VersionWindow `json:"last_blocks"`
+	CurrentInterval  *VersionWindow `json:"current_interval,omitempty"`
+	PreviousInterval *VersionWindow `json:"previous_interval,omitempty"`
 }
 
 // TicketPoolValsAndSizes models two arrays, one each for ticket values and
@@ -345,6 +528,241 @@ type MempoolTicketDetails struct {
 	Tickets TicketsDetails `json:"tickets"`
 }
 
+// MempoolTx models a transaction in mempool. The fee is in DCR, and the fee
+// rate in DCR/kB.
+type MempoolTx struct {
+	Hash    string  `json:"hash"`
+	Type    string  `json:"type"`
+	Size    int32   `json:"size"`
+	Fee     float64 `json:"fee"`
+	FeeRate float64 `json:"fee_rate"`
+	Time    int64   `json:"time"`
+	Height  int64   `json:"height_received"`
+}
+
+// MempoolTxs models a list of the transactions in mempool, newest first
+type MempoolTxs struct {
+	Height uint32       `json:"height"`
+	Time   int64        `json:"time"`
+	Count  uint32       `json:"count"`
+	Txs    []*MempoolTx `json:"txs"`
+}
+
+// MempoolTypeTotals models the number, total size, and total fees in DCR of
+// the transactions of a type in mempool
+type MempoolTypeTotals struct {
+	Count uint32  `json:"count"`
+	Size  int64   `json:"size"`
+	Fees  float64 `json:"fees"`
+}
+
+// MempoolSummary models the transactions in mempool by type
+type MempoolSummary struct {
+	Height      uint32            `json:"height"`
+	Time        int64             `json:"time"`
+	Total       MempoolTypeTotals `json:"total"`
+	Regular     MempoolTypeTotals `json:"regular"`
+	Tickets     MempoolTypeTotals `json:"tickets"`
+	Votes       MempoolTypeTotals `json:"votes"`
+	Revocations MempoolTypeTotals `json:"revocations"`
+}
+
+// MempoolFeeEstimate models an estimate of the fee rate in DCR/kB for a regular
+// transaction to be mined within Blocks blocks. BlockCapacity is the estimated
+// space in bytes for regular transactions in a block, and MempoolDepth is the
+// size of the regular transactions in mempool with fee rates at or above the
+// estimate. RecentMinFeeRate is the median of the lowest fee rates of the
+// regular transactions in the NumBlocks most recent blocks, and RelayFeeRate
+// the node's minimum relay fee rate, below which the estimate never goes.
+type MempoolFeeEstimate struct {
+	Height           uint32  `json:"height"`
+	Time             int64   `json:"time"`
+	Blocks           int     `json:"blocks"`
+	FeeRate          float64 `json:"fee_rate"`
+	BlockCapacity    int64   `json:"block_capacity"`
+	MempoolDepth     int64   `json:"mempool_depth"`
+	RecentMinFeeRate float64 `json:"recent_min_fee_rate"`
+	RelayFeeRate     float64 `json:"relay_fee_rate"`
+	NumBlocks        int     `json:"num_blocks"`
+}
+
+// MempoolVote models a vote in mempool on the block with hash BlockHash, cast
+// by the ticket with hash Ticket
+type MempoolVote struct {
+	Hash        string `json:"hash"`
+	Ticket      string `json:"ticket"`
+	BlockHash   string `json:"block_hash"`
+	BlockHeight int64  `json:"block_height"`
+	Approve     bool   `json:"approve"`
+	VoteBits    uint16 `json:"vote_bits"`
+	Time        int64  `json:"time"`
+}
+
+// MempoolBlockVotes models the votes in mempool on a block, and how many of
+// them approve or reject the block
+type MempoolBlockVotes struct {
+	BlockHash   string         `json:"block_hash"`
+	BlockHeight int64          `json:"block_height"`
+	Count       uint16         `json:"count"`
+	Approve     uint16         `json:"approve"`
+	Reject      uint16         `json:"reject"`
+	Votes       []*MempoolVote `json:"votes"`
+}
+
+// MempoolWinner models a ticket selected to vote on the best block, and its
+// vote in mempool if it has voted
+type MempoolWinner struct {
+	Ticket  string `json:"ticket"`
+	Voted   bool   `json:"voted"`
+	Vote    string `json:"vote,omitempty"`
+	Approve bool   `json:"approve"`
+}
+
+// MempoolTipVotes models the votes in mempool on the best block. Required is
+// the number of tickets selected to vote on each block, and Approved is true if
+// a majority of the votes so far approve the block.
+type MempoolTipVotes struct {
+	Height   uint32          `json:"height"`
+	Time     int64           `json:"time"`
+	Required uint16          `json:"required"`
+	Winners  []MempoolWinner `json:"winners"`
+	Approved bool            `json:"approved"`
+	MempoolBlockVotes
+}
+
+// MempoolConflict models the transactions in mempool that spend the same
+// outpoint
+type MempoolConflict struct {
+	Outpoint string       `json:"outpoint"`
+	Txs      []*MempoolTx `json:"txs"`
+}
+
+// MempoolInvalidatedTx models a transaction evicted from mempool because it
+// was invalidated by the block with hash BlockHash. Reason is "doublespend" if
+// ConflictingTx in the block spends the same Outpoint, or "invalidinput" if
+// Outpoint is an output of ConflictingTx, an invalidated transaction.
+type MempoolInvalidatedTx struct {
+	*MempoolTx
+	Reason        string `json:"reason"`
+	ConflictingTx string `json:"conflicting_tx"`
+	Outpoint      string `json:"outpoint"`
+	BlockHash     string `json:"block_hash"`
+	BlockHeight   int64  `json:"block_height"`
+	EvictedTime   int64  `json:"time_evicted"`
+}
+
+// MempoolConflicts models the conflicting transactions in mempool, and the
+// transactions recently invalidated by blocks, newest first
+type MempoolConflicts struct {
+	Height      uint32                  `json:"height"`
+	Time        int64                   `json:"time"`
+	Conflicts   []*MempoolConflict      `json:"conflicts"`
+	Invalidated []*MempoolInvalidatedTx `json:"invalidated"`
+}
+
+// MempoolHistoryPoint models the averages of the mempool data collections in a
+// time step starting at Time. The ticket fee rates are in DCR/kB, and
+// RegularSize is the total size in bytes of the regular transactions.
+type MempoolHistoryPoint struct {
+	Time        int64   `json:"time"`
+	NumSamples  uint32  `json:"num_samples"`
+	Height      int64   `json:"height"`
+	NumTickets  float64 `json:"num_tickets"`
+	FeeMin      float64 `json:"fee_min"`
+	FeeMean     float64 `json:"fee_mean"`
+	FeeMedian   float64 `json:"fee_median"`
+	FeeMax      float64 `json:"fee_max"`
+	NumRegular  float64 `json:"num_regular"`
+	RegularSize float64 `json:"regular_size"`
+}
+
+// MempoolHistory models the mempool data collections from UNIX time From to
+// To, averaged in steps of Step seconds
+type MempoolHistory struct {
+	From   int64                 `json:"from"`
+	To     int64                 `json:"to"`
+	Step   int64                 `json:"step"`
+	Points []MempoolHistoryPoint `json:"points"`
+}
+
+// TicketInclusionStats models the time tickets spent in mempool before being
+// mined. Delays are in seconds from when the ticket was first seen to the time
+// stamp of the block including it, and Blocks counts the blocks from entry to
+// inclusion. FirstBlock is the number of tickets included in the first block
+// after entering mempool. Fee rates are in DCR/kB.
+type TicketInclusionStats struct {
+	Count           uint32  `json:"count"`
+	FirstBlock      uint32  `json:"first_block"`
+	FirstBlockRatio float64 `json:"first_block_ratio"`
+	MeanDelay       float64 `json:"mean_delay"`
+	MedianDelay     float64 `json:"median_delay"`
+	MeanBlocks      float64 `json:"mean_blocks"`
+	MinFeeRate      float64 `json:"min_fee_rate"`
+	MaxFeeRate      float64 `json:"max_fee_rate"`
+}
+
+// TicketInclusionWindow models the inclusion of the tickets mined in a stake
+// difficulty window, overall and by fee rate quartile, lowest first. The
+// statistics are kept in memory by dcrdata and reset when it restarts, so a
+// window includes only the tickets mined since dcrdata was started.
+type TicketInclusionWindow struct {
+	Window      int64                  `json:"window"`
+	StartHeight int64                  `json:"start_height"`
+	EndHeight   int64                  `json:"end_height"`
+	Tickets     TicketInclusionStats   `json:"tickets"`
+	ByFeeRate   []TicketInclusionStats `json:"by_fee_rate"`
+}
+
+// WebSocketVersion is the version of the websocket message protocol, given in
+// every WebSocketEnvelope.
+const WebSocketVersion = 1
+
+// Types of the websocket messages. Clients send subscribe, unsubscribe and
+// ping requests, each answered by a response with the same ID. The server sends
+// an event for each update on a subscribed topic, and a periodic ping.
+const (
+	WSTypeSubscribe   = "subscribe"
+	WSTypeUnsubscribe = "unsubscribe"
+	WSTypePing        = "ping"
+	WSTypeResponse    = "response"
+	WSTypeEvent       = "event"
+)
+
+// Websocket subscription topics. The address and tx topics are followed by a
+// colon and the address or transaction hash, e.g. tx:<txid>.
+const (
+	WSTopicBlocks     = "blocks"
+	WSTopicMempool    = "mempool"
+	WSTopicTicketPool = "ticketpool"
+	WSTopicAddress    = "address"
+	WSTopicTx         = "tx"
+)
+
+// WebSocketEnvelope is the message exchanged with websocket clients in either
+// direction. Topic is the subscription topic of a request, response or event,
+// and Event names the kind of event. Error is set on the response to a failed
+// request. Payload is the JSON data of an event or response.
+type WebSocketEnvelope struct {
+	Version int             `json:"version"`
+	Type    string          `json:"type"`
+	ID      uint64          `json:"id,omitempty"`
+	Topic   string          `json:"topic,omitempty"`
+	Event   string          `json:"event,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// WebSocketSubscriptions is the payload of the response to a subscribe or
+// unsubscribe request, listing the client's topics after the request
+type WebSocketSubscriptions struct {
+	Topics []string `json:"topics"`
+}
+
+// WebSocketPing is the payload of a ping, with the number of connected clients
+type WebSocketPing struct {
+	Clients int `json:"clients"`
+}
+
 // TicketsDetails is an array of pointers of TicketDetails used in
 // MempoolTicketDetails
 type TicketsDetails []*TicketDetails
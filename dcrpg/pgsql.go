@@ -0,0 +1,1058 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+// Package dcrpg provides a PostgreSQL backend for dcrdata that stores the same
+// block summary, extended stake info, address and transaction data as
+// dcrsqlite, so that dcrdata may be run against a shared Postgres server.
+package dcrpg
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/btcsuite/btclog"
+	"github.com/dcrdata/dcrdata/chaindb"
+	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	_ "github.com/lib/pq" // register postgres driver with database/sql
+)
+
+// DBInfo contains the PostgreSQL connection parameters
+type DBInfo struct {
+	Host, Port, User, Pass, DBName string
+}
+
+const (
+	// TableNameSummaries is name of the table used to store block summary data
+	TableNameSummaries = "dcrdata_block_summary"
+	// TableNameStakeInfo is name of the table used to store extended stake info
+	TableNameStakeInfo = "dcrdata_stakeinfo_extended"
+	// TableNameAddresses is name of the table used to store address outpoints
+	TableNameAddresses = "dcrdata_addresses"
+	// TableNameTransactions is name of the table used to store transactions
+	TableNameTransactions = "dcrdata_transactions"
+	// TableNameVins is name of the table used to store transaction inputs
+	TableNameVins = "dcrdata_vins"
+	// TableNameVouts is name of the table used to store transaction outputs
+	TableNameVouts = "dcrdata_vouts"
+	// TableNameTickets is name of the table used to store ticket status
+	TableNameTickets = "dcrdata_tickets"
+	// TableNameVotes is name of the table used to store votes
+	TableNameVotes = "dcrdata_votes"
+	// TableNameVersions is name of the table used to store block header
+	// versions
+	TableNameVersions = "dcrdata_block_versions"
+	// TableNameMempoolHistory is name of the table used to store mempool data
+	// collections
+	TableNameMempoolHistory = "dcrdata_mempool_history"
+)
+
+// DB is a wrapper around sql.DB that adds methods for storing and retrieving
+// chain data from a PostgreSQL database. Use InitDB to get a new instance.
+type DB struct {
+	*sql.DB
+	*chaindb.Tables
+	sync.RWMutex
+	dbSummaryHeight                                     int64
+	dbStakeInfoHeight                                   int64
+	getPoolSQL, getPoolRangeSQL                         string
+	getPoolByHashSQL                                    string
+	getSDiffSQL, getSDiffRangeSQL                       string
+	getLatestBlockSQL                                   string
+	getBlockSQL, insertBlockSQL                         string
+	getBlockByHashSQL                                   string
+	getBlockHashSQL, getBlockHeightSQL                  string
+	getBlockSizeRangeSQL                                string
+	getBlockTimeRangeSQL                                string
+	getBestBlockHashSQL, getBestBlockHeightSQL          string
+	getLatestStakeInfoExtendedSQL                       string
+	getStakeInfoExtendedSQL, insertStakeInfoExtendedSQL string
+	getStakeInfoExtendedRangeSQL                        string
+}
+
+// Columns of the block summary and stake info tables, in the order they are
+// scanned by the Retrieve methods.
+const (
+	blockSummaryColumns = `height, size, hash, diff, sdiff, time, poolsize,
+        poolval, poolavg`
+	stakeInfoColumns = `height, num_tickets, fee_min, fee_max, fee_mean,
+        fee_med, fee_std, sdiff, window_num, window_ind, pool_size, pool_val,
+        pool_valavg`
+)
+
+// NewDB creates a new DB instance with pre-generated sql statements from an
+// existing sql.DB. Use InitDB to create a new DB without having a sql.DB.
+func NewDB(db *sql.DB) *DB {
+	d := DB{
+		DB:                db,
+		dbSummaryHeight:   -1,
+		dbStakeInfoHeight: -1,
+	}
+
+	// Ticket pool queries
+	d.getPoolSQL = fmt.Sprintf(`SELECT poolsize, poolval, poolavg FROM %s WHERE height = $1`,
+		TableNameSummaries)
+	d.getPoolByHashSQL = fmt.Sprintf(`SELECT poolsize, poolval, poolavg FROM %s WHERE hash = $1`,
+		TableNameSummaries)
+	d.getPoolRangeSQL = fmt.Sprintf(`SELECT poolsize, poolval, poolavg FROM %s
+        WHERE height BETWEEN $1 AND $2 ORDER BY height`, TableNameSummaries)
+
+	d.getSDiffSQL = fmt.Sprintf(`SELECT sdiff FROM %s WHERE height = $1`,
+		TableNameSummaries)
+	d.getSDiffRangeSQL = fmt.Sprintf(`SELECT sdiff FROM %s
+        WHERE height BETWEEN $1 AND $2 ORDER BY height`, TableNameSummaries)
+
+	// Block queries
+	d.getBlockSQL = fmt.Sprintf(`SELECT %s FROM %s WHERE height = $1`,
+		blockSummaryColumns, TableNameSummaries)
+	d.getBlockByHashSQL = fmt.Sprintf(`SELECT %s FROM %s WHERE hash = $1`,
+		blockSummaryColumns, TableNameSummaries)
+	d.getLatestBlockSQL = fmt.Sprintf(`SELECT %s FROM %s ORDER BY height DESC LIMIT 1`,
+		blockSummaryColumns, TableNameSummaries)
+	d.insertBlockSQL = fmt.Sprintf(`
+        INSERT INTO %s(
+            height, size, hash, diff, sdiff, time, poolsize, poolval, poolavg
+        ) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        ON CONFLICT (height) DO UPDATE SET
+            size = EXCLUDED.size, hash = EXCLUDED.hash, diff = EXCLUDED.diff,
+            sdiff = EXCLUDED.sdiff, time = EXCLUDED.time,
+            poolsize = EXCLUDED.poolsize, poolval = EXCLUDED.poolval,
+            poolavg = EXCLUDED.poolavg
+        `, TableNameSummaries)
+
+	d.getBlockSizeRangeSQL = fmt.Sprintf(`SELECT size FROM %s
+        WHERE height BETWEEN $1 AND $2 ORDER BY height`, TableNameSummaries)
+	d.getBlockTimeRangeSQL = fmt.Sprintf(`SELECT time FROM %s
+        WHERE height BETWEEN $1 AND $2 ORDER BY height`, TableNameSummaries)
+
+	d.getBestBlockHashSQL = fmt.Sprintf(`SELECT hash FROM %s ORDER BY height DESC LIMIT 1`, TableNameSummaries)
+	d.getBestBlockHeightSQL = fmt.Sprintf(`SELECT height FROM %s ORDER BY height DESC LIMIT 1`, TableNameSummaries)
+
+	d.getBlockHashSQL = fmt.Sprintf(`SELECT hash FROM %s WHERE height = $1`, TableNameSummaries)
+	d.getBlockHeightSQL = fmt.Sprintf(`SELECT height FROM %s WHERE hash = $1`, TableNameSummaries)
+
+	// Stake info queries
+	d.getStakeInfoExtendedSQL = fmt.Sprintf(`SELECT %s FROM %s WHERE height = $1`,
+		stakeInfoColumns, TableNameStakeInfo)
+	d.getStakeInfoExtendedRangeSQL = fmt.Sprintf(`SELECT %s FROM %s
+        WHERE height BETWEEN $1 AND $2 ORDER BY height`, stakeInfoColumns,
+		TableNameStakeInfo)
+	d.getLatestStakeInfoExtendedSQL = fmt.Sprintf(
+		`SELECT %s FROM %s ORDER BY height DESC LIMIT 1`, stakeInfoColumns,
+		TableNameStakeInfo)
+	d.insertStakeInfoExtendedSQL = fmt.Sprintf(`
+        INSERT INTO %s(
+            height, num_tickets, fee_min, fee_max, fee_mean, fee_med, fee_std,
+            sdiff, window_num, window_ind, pool_size, pool_val, pool_valavg
+        ) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+        ON CONFLICT (height) DO UPDATE SET
+            num_tickets = EXCLUDED.num_tickets, fee_min = EXCLUDED.fee_min,
+            fee_max = EXCLUDED.fee_max, fee_mean = EXCLUDED.fee_mean,
+            fee_med = EXCLUDED.fee_med, fee_std = EXCLUDED.fee_std,
+            sdiff = EXCLUDED.sdiff, window_num = EXCLUDED.window_num,
+            window_ind = EXCLUDED.window_ind, pool_size = EXCLUDED.pool_size,
+            pool_val = EXCLUDED.pool_val, pool_valavg = EXCLUDED.pool_valavg
+        `, TableNameStakeInfo)
+
+	// Address, transaction, ticket, vote, block version and mempool history
+	// queries, used by the chain data tables.
+	var q chaindb.Queries
+
+	// Address queries
+	q.InsertAddress = fmt.Sprintf(`
+        INSERT INTO %s(
+            address, tx_hash, tx_index, tx_tree, value, block_height, block_time
+        ) VALUES($1, $2, $3, $4, $5, $6, $7)
+        ON CONFLICT (address, tx_hash, tx_index) DO NOTHING
+        `, TableNameAddresses)
+	q.SetAddressSpending = fmt.Sprintf(`UPDATE %s SET spending_tx_hash = $1,
+        spending_tx_index = $2, spending_height = $3, spending_time = $4
+        WHERE tx_hash = $5 AND tx_index = $6`, TableNameAddresses)
+	q.DeleteAddressesAbove = fmt.Sprintf(`DELETE FROM %s WHERE block_height > $1`,
+		TableNameAddresses)
+	q.UnsetAddressSpendingAbove = fmt.Sprintf(`UPDATE %s SET spending_tx_hash = '',
+        spending_tx_index = -1, spending_height = -1, spending_time = 0
+        WHERE spending_height > $1`, TableNameAddresses)
+	q.GetAddressHeight = fmt.Sprintf(`SELECT COALESCE(MAX(block_height), -1) FROM %s`,
+		TableNameAddresses)
+	// Transactions funding or spending from an address, with the amounts
+	// received and sent by each.
+	addressTxnsSubquery := fmt.Sprintf(`
+            SELECT tx_hash, block_height, block_time, value AS received, 0 AS sent
+            FROM %[1]s WHERE address = $1
+            UNION ALL
+            SELECT spending_tx_hash, spending_height, spending_time, 0, value
+            FROM %[1]s WHERE address = $1 AND spending_height >= 0`,
+		TableNameAddresses)
+	q.GetAddressTxns = fmt.Sprintf(`
+        SELECT tx_hash, block_height, block_time, SUM(received)::INT8, SUM(sent)::INT8
+        FROM (%s) AS txns
+        GROUP BY tx_hash, block_height, block_time
+        ORDER BY block_height DESC, tx_hash
+        LIMIT $2 OFFSET $3`, addressTxnsSubquery)
+	q.GetAddressTxnCount = fmt.Sprintf(`
+        SELECT COUNT(DISTINCT tx_hash) FROM (%s) AS txns`, addressTxnsSubquery)
+	q.GetAddressTotals = fmt.Sprintf(`SELECT COALESCE(SUM(value), 0)::INT8,
+        COALESCE(SUM(CASE WHEN spending_height >= 0 THEN value ELSE 0 END), 0)::INT8
+        FROM %s WHERE address = $1`, TableNameAddresses)
+	q.GetOutpointAddresses = fmt.Sprintf(`SELECT address, value FROM %s
+        WHERE tx_hash = $1 AND tx_index = $2`, TableNameAddresses)
+	q.GetAddressUTXOs = fmt.Sprintf(`SELECT tx_hash, tx_index, tx_tree, value,
+        block_height, block_time FROM %s WHERE address = $1 AND spending_height < 0
+        ORDER BY block_height, tx_hash, tx_index`, TableNameAddresses)
+
+	// Transaction queries
+	q.InsertTx = fmt.Sprintf(`
+        INSERT INTO %s(
+            tx_hash, block_hash, block_height, block_time, block_index, tree,
+            tx_type, version, locktime, expiry, size, num_vin, num_vout
+        ) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+        ON CONFLICT (tx_hash) DO UPDATE SET
+            block_hash = EXCLUDED.block_hash,
+            block_height = EXCLUDED.block_height,
+            block_time = EXCLUDED.block_time,
+            block_index = EXCLUDED.block_index
+        `, TableNameTransactions)
+	q.InsertVin = fmt.Sprintf(`
+        INSERT INTO %s(
+            tx_hash, tx_index, tx_tree, block_height, prev_tx_hash,
+            prev_tx_index, prev_tx_tree, sequence, value_in, proof_height,
+            proof_index, sig_script
+        ) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+        ON CONFLICT (tx_hash, tx_index) DO UPDATE SET
+            block_height = EXCLUDED.block_height
+        `, TableNameVins)
+	q.InsertVout = fmt.Sprintf(`
+        INSERT INTO %s(
+            tx_hash, tx_index, tx_tree, block_height, value, version, pkscript,
+            script_class, req_sigs, addresses, commit_amt
+        ) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+        ON CONFLICT (tx_hash, tx_index) DO UPDATE SET
+            block_height = EXCLUDED.block_height
+        `, TableNameVouts)
+	q.SetVoutSpending = fmt.Sprintf(`UPDATE %s SET spending_tx_hash = $1,
+        spending_tx_index = $2, spending_height = $3
+        WHERE tx_hash = $4 AND tx_index = $5`, TableNameVouts)
+	q.UnsetVoutSpendingAbove = fmt.Sprintf(`UPDATE %s SET spending_tx_hash = '',
+        spending_tx_index = -1, spending_height = -1
+        WHERE spending_height > $1`, TableNameVouts)
+	q.DeleteTxsAbove = fmt.Sprintf(`DELETE FROM %s WHERE block_height > $1`,
+		TableNameTransactions)
+	q.DeleteVinsAbove = fmt.Sprintf(`DELETE FROM %s WHERE block_height > $1`,
+		TableNameVins)
+	q.DeleteVoutsAbove = fmt.Sprintf(`DELETE FROM %s WHERE block_height > $1`,
+		TableNameVouts)
+	q.GetTxHeight = fmt.Sprintf(`SELECT COALESCE(MAX(block_height), -1) FROM %s`,
+		TableNameTransactions)
+	q.GetTx = fmt.Sprintf(`SELECT tx_hash, block_hash, block_height,
+        block_time, block_index, tree, tx_type, version, locktime, expiry, size,
+        num_vin, num_vout FROM %s WHERE tx_hash = $1`, TableNameTransactions)
+	q.GetTxVins = fmt.Sprintf(`SELECT tx_hash, tx_index, tx_tree,
+        block_height, prev_tx_hash, prev_tx_index, prev_tx_tree, sequence,
+        value_in, proof_height, proof_index, sig_script
+        FROM %s WHERE tx_hash = $1 ORDER BY tx_index`, TableNameVins)
+	q.GetTxVouts = fmt.Sprintf(`SELECT tx_hash, tx_index, tx_tree,
+        block_height, value, version, pkscript, script_class, req_sigs,
+        addresses, commit_amt, spending_tx_hash, spending_tx_index,
+        spending_height FROM %s WHERE tx_hash = $1 ORDER BY tx_index`,
+		TableNameVouts)
+	q.GetVoutSpender = fmt.Sprintf(`SELECT spending_tx_hash, spending_tx_index,
+        spending_height FROM %s WHERE tx_hash = $1 AND tx_index = $2`, TableNameVouts)
+
+	// Ticket pool queries. A ticket (the stakesubmission output of a ticket
+	// purchase) joins the live pool TicketMaturity blocks after it is mined,
+	// and leaves when spent by a vote or revocation, or when it expires.
+	q.GetTicketsMaturing = fmt.Sprintf(`SELECT block_height, COUNT(*), SUM(value)
+        FROM %s WHERE script_class = 'stakesubmission' AND block_height BETWEEN $1 AND $2
+        GROUP BY block_height`, TableNameVouts)
+	q.GetTicketsLeaving = fmt.Sprintf(`SELECT leave_height, COUNT(*), SUM(value)
+        FROM (SELECT CASE
+                WHEN t.pool_exit_height >= 0 THEN t.pool_exit_height
+                WHEN v.spending_height >= 0 AND v.spending_height < v.block_height + $1
+                THEN v.spending_height ELSE v.block_height + $1 END AS leave_height,
+                v.value
+            FROM %s AS v LEFT JOIN %s AS t ON t.tx_hash = v.tx_hash
+            WHERE v.script_class = 'stakesubmission'
+                AND v.block_height BETWEEN $2 AND $3) AS tickets
+        WHERE leave_height BETWEEN $4 AND $3
+        GROUP BY leave_height`, TableNameVouts, TableNameTickets)
+
+	// Ticket status queries
+	q.InsertTicket = fmt.Sprintf(`
+        INSERT INTO %s(
+            tx_hash, purchase_height, maturity_height, pool_status,
+            pool_exit_height, revoked, spend_height
+        ) VALUES($1, $2, $3, 'live', -1, FALSE, -1)
+        ON CONFLICT (tx_hash) DO UPDATE SET
+            purchase_height = EXCLUDED.purchase_height,
+            maturity_height = EXCLUDED.maturity_height,
+            pool_status = 'live', pool_exit_height = -1,
+            revoked = FALSE, spend_height = -1
+        `, TableNameTickets)
+	q.SetTicketStatus = fmt.Sprintf(`UPDATE %s SET pool_status = $1,
+        pool_exit_height = $2 WHERE tx_hash = $3`, TableNameTickets)
+	q.SetTicketSpend = fmt.Sprintf(`UPDATE %s SET revoked = $1,
+        spend_height = $2 WHERE tx_hash = $3`, TableNameTickets)
+	q.DeleteTicketsAbove = fmt.Sprintf(`DELETE FROM %s WHERE maturity_height > $1`,
+		TableNameTickets)
+	q.UnsetTicketStatusAbove = fmt.Sprintf(`UPDATE %s SET pool_status = 'live',
+        pool_exit_height = -1 WHERE pool_exit_height > $1`, TableNameTickets)
+	q.UnsetTicketSpendAbove = fmt.Sprintf(`UPDATE %s SET revoked = FALSE,
+        spend_height = -1 WHERE spend_height > $1`, TableNameTickets)
+	q.GetTicketHeight = fmt.Sprintf(`SELECT COALESCE(MAX(GREATEST(maturity_height,
+        pool_exit_height, spend_height)), -1) FROM %s`, TableNameTickets)
+	q.GetTicket = fmt.Sprintf(`SELECT tx_hash, purchase_height, maturity_height,
+        pool_status, pool_exit_height, revoked, spend_height
+        FROM %s WHERE tx_hash = $1`, TableNameTickets)
+	q.GetMissedTickets = fmt.Sprintf(`SELECT tx_hash FROM %s
+        WHERE pool_status = 'missed' AND pool_exit_height = $1
+        ORDER BY tx_hash`, TableNameTickets)
+	q.GetTicketExitCounts = fmt.Sprintf(`SELECT pool_exit_height,
+        SUM(CASE WHEN pool_status = 'missed' THEN 1 ELSE 0 END),
+        SUM(CASE WHEN pool_status = 'expired' THEN 1 ELSE 0 END)
+        FROM %s WHERE pool_status IN ('missed', 'expired')
+            AND pool_exit_height BETWEEN $1 AND $2
+        GROUP BY pool_exit_height ORDER BY pool_exit_height`, TableNameTickets)
+
+	// Vote queries. The vote bits counts are grouped into bins of $2 blocks
+	// starting at height $1.
+	q.InsertVote = fmt.Sprintf(`
+        INSERT INTO %s(
+            tx_hash, block_height, ticket_hash, vote_version, vote_bits
+        ) VALUES($1, $2, $3, $4, $5)
+        ON CONFLICT (tx_hash) DO UPDATE SET
+            block_height = EXCLUDED.block_height,
+            ticket_hash = EXCLUDED.ticket_hash,
+            vote_version = EXCLUDED.vote_version,
+            vote_bits = EXCLUDED.vote_bits
+        `, TableNameVotes)
+	q.DeleteVotesAbove = fmt.Sprintf(`DELETE FROM %s WHERE block_height > $1`,
+		TableNameVotes)
+	q.GetVoteHeight = fmt.Sprintf(`SELECT COALESCE(MAX(block_height), -1) FROM %s`,
+		TableNameVotes)
+	q.GetVoteBitsCounts = fmt.Sprintf(`SELECT (block_height - $1) / $2 AS bin,
+        vote_bits & $3 AS bits, COUNT(*)
+        FROM %s WHERE vote_version = $4 AND block_height BETWEEN $1 AND $5
+        GROUP BY bin, bits ORDER BY bin, bits`, TableNameVotes)
+
+	// Block version queries. The version counts are grouped into bins of $2
+	// blocks starting at height $1. Vote versions are counted from the votes
+	// table.
+	q.InsertVersions = fmt.Sprintf(`
+        INSERT INTO %s(
+            height, hash, block_version, stake_version
+        ) VALUES($1, $2, $3, $4)
+        ON CONFLICT (height) DO UPDATE SET
+            hash = EXCLUDED.hash,
+            block_version = EXCLUDED.block_version,
+            stake_version = EXCLUDED.stake_version
+        `, TableNameVersions)
+	q.DeleteVersionsAbove = fmt.Sprintf(`DELETE FROM %s WHERE height > $1`,
+		TableNameVersions)
+	q.GetVersionsHeight = fmt.Sprintf(`SELECT COALESCE(MAX(height), -1) FROM %s`,
+		TableNameVersions)
+	q.GetBlockVersionCounts = fmt.Sprintf(`SELECT (height - $1) / $2 AS bin,
+        block_version, COUNT(*)
+        FROM %s WHERE height BETWEEN $1 AND $3
+        GROUP BY bin, block_version ORDER BY bin, block_version`, TableNameVersions)
+	q.GetStakeVersionCounts = fmt.Sprintf(`SELECT (height - $1) / $2 AS bin,
+        stake_version, COUNT(*)
+        FROM %s WHERE height BETWEEN $1 AND $3
+        GROUP BY bin, stake_version ORDER BY bin, stake_version`, TableNameVersions)
+	q.GetVoteVersionCounts = fmt.Sprintf(`SELECT (block_height - $1) / $2 AS bin,
+        vote_version, COUNT(*)
+        FROM %s WHERE block_height BETWEEN $1 AND $3
+        GROUP BY bin, vote_version ORDER BY bin, vote_version`, TableNameVotes)
+
+	// Mempool history queries. The snapshots are averaged in bins of $2
+	// seconds starting at time $1.
+	q.InsertMempoolSnapshot = fmt.Sprintf(`
+        INSERT INTO %s(
+            time, height, num_tickets, fee_min, fee_mean, fee_median, fee_max,
+            num_regular, regular_size
+        ) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        ON CONFLICT (time) DO UPDATE SET
+            height = EXCLUDED.height,
+            num_tickets = EXCLUDED.num_tickets,
+            fee_min = EXCLUDED.fee_min,
+            fee_mean = EXCLUDED.fee_mean,
+            fee_median = EXCLUDED.fee_median,
+            fee_max = EXCLUDED.fee_max,
+            num_regular = EXCLUDED.num_regular,
+            regular_size = EXCLUDED.regular_size
+        `, TableNameMempoolHistory)
+	q.GetMempoolHistory = fmt.Sprintf(`SELECT (time - $1) / $2 AS bin,
+        COUNT(*), MAX(height), AVG(num_tickets), AVG(fee_min), AVG(fee_mean),
+        AVG(fee_median), AVG(fee_max), AVG(num_regular), AVG(regular_size)
+        FROM %s WHERE time BETWEEN $1 AND $3
+        GROUP BY bin ORDER BY bin`, TableNameMempoolHistory)
+	q.DeleteMempoolHistoryBefore = fmt.Sprintf(`DELETE FROM %s WHERE time < $1`,
+		TableNameMempoolHistory)
+
+	d.Tables = chaindb.NewTables(db, &q)
+
+	d.dbSummaryHeight = d.GetBlockSummaryHeight()
+	d.dbStakeInfoHeight = d.GetStakeInfoHeight()
+
+	return &d
+}
+
+// createTableStmts creates the dcrdata tables and their indexes if they do
+// not already exist.
+var createTableStmts = []string{
+	fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+            height INT8 PRIMARY KEY,
+            size INT8,
+            hash TEXT,
+            diff FLOAT8,
+            sdiff FLOAT8,
+            time INT8,
+            poolsize INT8,
+            poolval FLOAT8,
+            poolavg FLOAT8
+        )`, TableNameSummaries),
+	fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[1]s_hash_idx ON %[1]s(hash)`,
+		TableNameSummaries),
+	fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+            height INT8 PRIMARY KEY,
+            num_tickets INT8,
+            fee_min FLOAT8, fee_max FLOAT8, fee_mean FLOAT8,
+            fee_med FLOAT8, fee_std FLOAT8,
+            sdiff FLOAT8, window_num INT8, window_ind INT8,
+            pool_size INT8, pool_val FLOAT8, pool_valavg FLOAT8
+        )`, TableNameStakeInfo),
+	fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+            address TEXT,
+            tx_hash TEXT, tx_index INT8, tx_tree INT2,
+            value INT8,
+            block_height INT8, block_time INT8,
+            spending_tx_hash TEXT DEFAULT '',
+            spending_tx_index INT8 DEFAULT -1,
+            spending_height INT8 DEFAULT -1,
+            spending_time INT8 DEFAULT 0,
+            PRIMARY KEY (address, tx_hash, tx_index)
+        )`, TableNameAddresses),
+	fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[1]s_outpoint_idx ON %[1]s(tx_hash, tx_index)`,
+		TableNameAddresses),
+	fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[1]s_height_idx ON %[1]s(block_height)`,
+		TableNameAddresses),
+	fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[1]s_spending_idx ON %[1]s(spending_height)`,
+		TableNameAddresses),
+	fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+            tx_hash TEXT PRIMARY KEY,
+            block_hash TEXT, block_height INT8, block_time INT8,
+            block_index INT8, tree INT2, tx_type INT4,
+            version INT4, locktime INT8, expiry INT8, size INT4,
+            num_vin INT8, num_vout INT8
+        )`, TableNameTransactions),
+	fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[1]s_height_idx ON %[1]s(block_height)`,
+		TableNameTransactions),
+	fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+            tx_hash TEXT, tx_index INT8, tx_tree INT2,
+            block_height INT8,
+            prev_tx_hash TEXT, prev_tx_index INT8, prev_tx_tree INT2,
+            sequence INT8, value_in INT8,
+            proof_height INT8, proof_index INT8,
+            sig_script TEXT,
+            PRIMARY KEY (tx_hash, tx_index)
+        )`, TableNameVins),
+	fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[1]s_prevout_idx ON %[1]s(prev_tx_hash, prev_tx_index)`,
+		TableNameVins),
+	fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[1]s_height_idx ON %[1]s(block_height)`,
+		TableNameVins),
+	fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+            tx_hash TEXT, tx_index INT8, tx_tree INT2,
+            block_height INT8,
+            value INT8, version INT4, pkscript TEXT,
+            script_class TEXT, req_sigs INT4, addresses TEXT,
+            commit_amt INT8,
+            spending_tx_hash TEXT DEFAULT '',
+            spending_tx_index INT8 DEFAULT -1,
+            spending_height INT8 DEFAULT -1,
+            PRIMARY KEY (tx_hash, tx_index)
+        )`, TableNameVouts),
+	fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[1]s_height_idx ON %[1]s(block_height)`,
+		TableNameVouts),
+	fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[1]s_spending_idx ON %[1]s(spending_height)`,
+		TableNameVouts),
+	fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+            tx_hash TEXT PRIMARY KEY,
+            purchase_height INT8, maturity_height INT8,
+            pool_status TEXT, pool_exit_height INT8 DEFAULT -1,
+            revoked BOOLEAN DEFAULT FALSE, spend_height INT8 DEFAULT -1
+        )`, TableNameTickets),
+	fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[1]s_maturity_idx ON %[1]s(maturity_height)`,
+		TableNameTickets),
+	fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[1]s_exit_idx ON %[1]s(pool_exit_height)`,
+		TableNameTickets),
+	fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[1]s_spend_idx ON %[1]s(spend_height)`,
+		TableNameTickets),
+	fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+            tx_hash TEXT PRIMARY KEY,
+            block_height INT8, ticket_hash TEXT,
+            vote_version INT8, vote_bits INT4
+        )`, TableNameVotes),
+	fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[1]s_height_idx ON %[1]s(block_height)`,
+		TableNameVotes),
+	fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[1]s_version_idx ON %[1]s(vote_version, block_height)`,
+		TableNameVotes),
+	fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+            height INT8 PRIMARY KEY, hash TEXT,
+            block_version INT4, stake_version INT8
+        )`, TableNameVersions),
+	fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+            time INT8 PRIMARY KEY, height INT8, num_tickets INT8,
+            fee_min FLOAT8, fee_mean FLOAT8, fee_median FLOAT8, fee_max FLOAT8,
+            num_regular INT8, regular_size INT8
+        )`, TableNameMempoolHistory),
+}
+
+// connString builds a lib/pq connection string from the DBInfo.
+func (dbi *DBInfo) connString() string {
+	quote := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	conn := fmt.Sprintf("host='%s' user='%s' dbname='%s' sslmode=disable",
+		quote.Replace(dbi.Host), quote.Replace(dbi.User), quote.Replace(dbi.DBName))
+	if dbi.Port != "" {
+		conn += fmt.Sprintf(" port='%s'", quote.Replace(dbi.Port))
+	}
+	if dbi.Pass != "" {
+		conn += fmt.Sprintf(" password='%s'", quote.Replace(dbi.Pass))
+	}
+	return conn
+}
+
+// InitDB connects to the PostgreSQL server described by dbInfo, creates the
+// tables if necessary, and returns a new DB instance.
+func InitDB(dbInfo *DBInfo) (*DB, error) {
+	db, err := sql.Open("postgres", dbInfo.connString())
+	if err != nil || db == nil {
+		return nil, err
+	}
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	for _, stmt := range createTableStmts {
+		if _, err = db.Exec(stmt); err != nil {
+			log.Errorf("%q: %s\n", err, stmt)
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return NewDB(db), nil
+}
+
+// StoreBlockSummary attemps to stores the block data in the database and
+// returns an error on failure
+func (db *DB) StoreBlockSummary(bd *apitypes.BlockDataBasic) error {
+	stmt, err := db.Prepare(db.insertBlockSQL)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(&bd.Height, &bd.Size, &bd.Hash,
+		&bd.Difficulty, &bd.StakeDiff, &bd.Time,
+		&bd.PoolInfo.Size, &bd.PoolInfo.Value, &bd.PoolInfo.ValAvg)
+	if err != nil {
+		return err
+	}
+
+	db.Lock()
+	defer db.Unlock()
+	if err = logDBResult(res); err == nil {
+		height := int64(bd.Height)
+		if height > db.dbSummaryHeight {
+			db.dbSummaryHeight = height
+		}
+	}
+
+	return err
+}
+
+// GetBestBlockHash returns the hash of the best block
+func (db *DB) GetBestBlockHash() string {
+	hash, err := db.RetrieveBestBlockHash()
+	if err != nil {
+		log.Errorf("RetrieveBestBlockHash failed: %v", err)
+		return ""
+	}
+	return hash
+}
+
+// GetBestBlockHeight returns the height of the best block
+func (db *DB) GetBestBlockHeight() int64 {
+	return db.GetBlockSummaryHeight()
+}
+
+// GetBlockSummaryHeight returns the largest block height for which the database
+// can provide a block summary
+func (db *DB) GetBlockSummaryHeight() int64 {
+	db.RLock()
+	defer db.RUnlock()
+	if db.dbSummaryHeight < 0 {
+		height, err := db.RetrieveBestBlockHeight()
+		if err != nil {
+			if err != sql.ErrNoRows {
+				log.Errorf("RetrieveBestBlockHeight failed: %v", err)
+			}
+			return -1
+		}
+		db.dbSummaryHeight = height
+	}
+	return db.dbSummaryHeight
+}
+
+// GetStakeInfoHeight returns the largest block height for which the database
+// can provide a stake info
+func (db *DB) GetStakeInfoHeight() int64 {
+	db.RLock()
+	defer db.RUnlock()
+	if db.dbStakeInfoHeight < 0 {
+		si, err := db.RetrieveLatestStakeInfoExtended()
+		if err != nil || si == nil {
+			if err != sql.ErrNoRows {
+				log.Errorf("RetrieveLatestStakeInfoExtended failed: %v", err)
+			}
+			return -1
+		}
+		db.dbStakeInfoHeight = int64(si.Feeinfo.Height)
+	}
+	return db.dbStakeInfoHeight
+}
+
+// checkSummaryRange returns an error if the block range [ind0, ind1] is not
+// available from the block summary table.
+func (db *DB) checkSummaryRange(what string, ind0, ind1 int64) error {
+	if ind1 < ind0 {
+		return fmt.Errorf("Cannot retrieve %s range (%d<%d)", what, ind1, ind0)
+	}
+	db.RLock()
+	defer db.RUnlock()
+	if ind1 > db.dbSummaryHeight || ind0 < 0 {
+		return fmt.Errorf("Cannot retrieve %s range [%d,%d], have height %d",
+			what, ind0, ind1, db.dbSummaryHeight)
+	}
+	return nil
+}
+
+// RetrievePoolInfoRange returns an array of apitypes.TicketPoolInfo for block
+// range ind0 to ind1 and a non-nil error on success
+func (db *DB) RetrievePoolInfoRange(ind0, ind1 int64) ([]apitypes.TicketPoolInfo, error) {
+	N := ind1 - ind0 + 1
+	if N == 0 {
+		return []apitypes.TicketPoolInfo{}, nil
+	}
+	if err := db.checkSummaryRange("pool info", ind0, ind1); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(db.getPoolRangeSQL, ind0, ind1)
+	if err != nil {
+		log.Errorf("Query failed: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	tpis := make([]apitypes.TicketPoolInfo, 0, N)
+	for rows.Next() {
+		var tpi apitypes.TicketPoolInfo
+		if err = rows.Scan(&tpi.Size, &tpi.Value, &tpi.ValAvg); err != nil {
+			log.Errorf("Unable to scan for TicketPoolInfo fields: %v", err)
+		}
+		tpis = append(tpis, tpi)
+	}
+	if err = rows.Err(); err != nil {
+		log.Error(err)
+	}
+
+	return tpis, nil
+}
+
+// RetrievePoolInfo returns ticket pool info for block height ind
+func (db *DB) RetrievePoolInfo(ind int64) (*apitypes.TicketPoolInfo, error) {
+	tpi := new(apitypes.TicketPoolInfo)
+	err := db.QueryRow(db.getPoolSQL, ind).Scan(&tpi.Size, &tpi.Value, &tpi.ValAvg)
+	return tpi, err
+}
+
+// RetrievePoolInfoByHash returns ticket pool info for blockhash hash
+func (db *DB) RetrievePoolInfoByHash(hash string) (*apitypes.TicketPoolInfo, error) {
+	tpi := new(apitypes.TicketPoolInfo)
+	err := db.QueryRow(db.getPoolByHashSQL, hash).Scan(&tpi.Size, &tpi.Value, &tpi.ValAvg)
+	return tpi, err
+}
+
+// RetrievePoolValAndSizeRange retuns an array each of the pool values and sizes
+// for block range ind0 to ind1
+func (db *DB) RetrievePoolValAndSizeRange(ind0, ind1 int64) ([]float64, []float64, error) {
+	N := ind1 - ind0 + 1
+	if N == 0 {
+		return []float64{}, []float64{}, nil
+	}
+	if err := db.checkSummaryRange("pool val and size", ind0, ind1); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := db.Query(db.getPoolRangeSQL, ind0, ind1)
+	if err != nil {
+		log.Errorf("Query failed: %v", err)
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	poolvals := make([]float64, 0, N)
+	poolsizes := make([]float64, 0, N)
+	for rows.Next() {
+		var pval, psize, pavg float64
+		if err = rows.Scan(&psize, &pval, &pavg); err != nil {
+			log.Errorf("Unable to scan for TicketPoolInfo fields: %v", err)
+		}
+		poolvals = append(poolvals, pval)
+		poolsizes = append(poolsizes, psize)
+	}
+	if err = rows.Err(); err != nil {
+		log.Error(err)
+	}
+
+	if len(poolsizes) != int(N) {
+		log.Warnf("Retrieved pool values (%d) not expected number (%d)", len(poolsizes), N)
+	}
+
+	return poolvals, poolsizes, nil
+}
+
+// RetrieveSDiffRange returns an array of stake difficulties for block range
+// ind0 to ind1
+func (db *DB) RetrieveSDiffRange(ind0, ind1 int64) ([]float64, error) {
+	N := ind1 - ind0 + 1
+	if N == 0 {
+		return []float64{}, nil
+	}
+	if err := db.checkSummaryRange("sdiff", ind0, ind1); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(db.getSDiffRangeSQL, ind0, ind1)
+	if err != nil {
+		log.Errorf("Query failed: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	sdiffs := make([]float64, 0, N)
+	for rows.Next() {
+		var sdiff float64
+		if err = rows.Scan(&sdiff); err != nil {
+			log.Errorf("Unable to scan for sdiff fields: %v", err)
+		}
+		sdiffs = append(sdiffs, sdiff)
+	}
+	if err = rows.Err(); err != nil {
+		log.Error(err)
+	}
+
+	return sdiffs, nil
+}
+
+// RetrieveSDiff returns the stake difficulty for block ind
+func (db *DB) RetrieveSDiff(ind int64) (float64, error) {
+	var sdiff float64
+	err := db.QueryRow(db.getSDiffSQL, ind).Scan(&sdiff)
+	return sdiff, err
+}
+
+// scanBlockSummary scans a row of the block summary table.
+func scanBlockSummary(row *sql.Row) (*apitypes.BlockDataBasic, error) {
+	bd := new(apitypes.BlockDataBasic)
+	err := row.Scan(&bd.Height, &bd.Size, &bd.Hash,
+		&bd.Difficulty, &bd.StakeDiff, &bd.Time,
+		&bd.PoolInfo.Size, &bd.PoolInfo.Value, &bd.PoolInfo.ValAvg)
+	if err != nil {
+		return nil, err
+	}
+	return bd, nil
+}
+
+// RetrieveLatestBlockSummary returns the block summary for the best block
+func (db *DB) RetrieveLatestBlockSummary() (*apitypes.BlockDataBasic, error) {
+	return scanBlockSummary(db.QueryRow(db.getLatestBlockSQL))
+}
+
+// RetrieveBlockHash returns the block hash for block ind
+func (db *DB) RetrieveBlockHash(ind int64) (string, error) {
+	var blockHash string
+	err := db.QueryRow(db.getBlockHashSQL, ind).Scan(&blockHash)
+	return blockHash, err
+}
+
+// RetrieveBlockHeight returns the block height for blockhash hash
+func (db *DB) RetrieveBlockHeight(hash string) (int64, error) {
+	var blockHeight int64
+	err := db.QueryRow(db.getBlockHeightSQL, hash).Scan(&blockHeight)
+	return blockHeight, err
+}
+
+// RetrieveBestBlockHash returns the block hash for the best block
+func (db *DB) RetrieveBestBlockHash() (string, error) {
+	var blockHash string
+	err := db.QueryRow(db.getBestBlockHashSQL).Scan(&blockHash)
+	return blockHash, err
+}
+
+// RetrieveBestBlockHeight returns the block height for the best block
+func (db *DB) RetrieveBestBlockHeight() (int64, error) {
+	var blockHeight int64
+	err := db.QueryRow(db.getBestBlockHeightSQL).Scan(&blockHeight)
+	return blockHeight, err
+}
+
+// RetrieveBlockSummaryByHash returns basic block data for a block given its hash
+func (db *DB) RetrieveBlockSummaryByHash(hash string) (*apitypes.BlockDataBasic, error) {
+	return scanBlockSummary(db.QueryRow(db.getBlockByHashSQL, hash))
+}
+
+// RetrieveBlockSummary returns basic block data for block ind
+func (db *DB) RetrieveBlockSummary(ind int64) (*apitypes.BlockDataBasic, error) {
+	return scanBlockSummary(db.QueryRow(db.getBlockSQL, ind))
+}
+
+// RetrieveBlockSizeRange returns an array of block sizes for block range ind0 to ind1
+func (db *DB) RetrieveBlockSizeRange(ind0, ind1 int64) ([]int32, error) {
+	N := ind1 - ind0 + 1
+	if N == 0 {
+		return []int32{}, nil
+	}
+	if err := db.checkSummaryRange("block size", ind0, ind1); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(db.getBlockSizeRangeSQL, ind0, ind1)
+	if err != nil {
+		log.Errorf("Query failed: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	blockSizes := make([]int32, 0, N)
+	for rows.Next() {
+		var blockSize int32
+		if err = rows.Scan(&blockSize); err != nil {
+			log.Errorf("Unable to scan for block size fields: %v", err)
+		}
+		blockSizes = append(blockSizes, blockSize)
+	}
+	if err = rows.Err(); err != nil {
+		log.Error(err)
+	}
+
+	return blockSizes, nil
+}
+
+// RetrieveBlockTimeRange returns an array of block timestamps for block range
+// ind0 to ind1
+func (db *DB) RetrieveBlockTimeRange(ind0, ind1 int64) ([]int64, error) {
+	N := ind1 - ind0 + 1
+	if N == 0 {
+		return []int64{}, nil
+	}
+	if err := db.checkSummaryRange("block time", ind0, ind1); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(db.getBlockTimeRangeSQL, ind0, ind1)
+	if err != nil {
+		log.Errorf("Query failed: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	times := make([]int64, 0, N)
+	for rows.Next() {
+		var t int64
+		if err = rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		times = append(times, t)
+	}
+	return times, rows.Err()
+}
+
+// StoreStakeInfoExtended stores the extended stake info in the database
+func (db *DB) StoreStakeInfoExtended(si *apitypes.StakeInfoExtended) error {
+	stmt, err := db.Prepare(db.insertStakeInfoExtendedSQL)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(&si.Feeinfo.Height,
+		&si.Feeinfo.Number, &si.Feeinfo.Min, &si.Feeinfo.Max, &si.Feeinfo.Mean,
+		&si.Feeinfo.Median, &si.Feeinfo.StdDev,
+		&si.StakeDiff, // no next or estimates
+		&si.PriceWindowNum, &si.IdxBlockInWindow, &si.PoolInfo.Size,
+		&si.PoolInfo.Value, &si.PoolInfo.ValAvg)
+	if err != nil {
+		return err
+	}
+
+	db.Lock()
+	defer db.Unlock()
+	if err = logDBResult(res); err == nil {
+		height := int64(si.Feeinfo.Height)
+		if height > db.dbStakeInfoHeight {
+			db.dbStakeInfoHeight = height
+		}
+	}
+	return err
+}
+
+// StoreBlockBatch stores the block summaries and extended stake info for a
+// batch of blocks, and the blocks in the chain data tables, in a single SQL
+// transaction, which is much faster than storing each row separately during a
+// resync.
+func (db *DB) StoreBlockBatch(bds []*apitypes.BlockDataBasic, sis []*apitypes.StakeInfoExtended,
+	blocks []*chaindb.BlockIndex) error {
+	dbtx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("unable to begin database transaction: %v", err)
+	}
+
+	blockStmt, err := dbtx.Prepare(db.insertBlockSQL)
+	if err != nil {
+		_ = dbtx.Rollback()
+		return err
+	}
+	defer blockStmt.Close()
+
+	var summaryHeight int64 = -1
+	for _, bd := range bds {
+		_, err = blockStmt.Exec(&bd.Height, &bd.Size, &bd.Hash,
+			&bd.Difficulty, &bd.StakeDiff, &bd.Time,
+			&bd.PoolInfo.Size, &bd.PoolInfo.Value, &bd.PoolInfo.ValAvg)
+		if err != nil {
+			_ = dbtx.Rollback()
+			return fmt.Errorf("unable to insert block summary row: %v", err)
+		}
+		if int64(bd.Height) > summaryHeight {
+			summaryHeight = int64(bd.Height)
+		}
+	}
+
+	stakeStmt, err := dbtx.Prepare(db.insertStakeInfoExtendedSQL)
+	if err != nil {
+		_ = dbtx.Rollback()
+		return err
+	}
+	defer stakeStmt.Close()
+
+	var stakeHeight int64 = -1
+	for _, si := range sis {
+		_, err = stakeStmt.Exec(&si.Feeinfo.Height,
+			&si.Feeinfo.Number, &si.Feeinfo.Min, &si.Feeinfo.Max, &si.Feeinfo.Mean,
+			&si.Feeinfo.Median, &si.Feeinfo.StdDev,
+			&si.StakeDiff, // no next or estimates
+			&si.PriceWindowNum, &si.IdxBlockInWindow, &si.PoolInfo.Size,
+			&si.PoolInfo.Value, &si.PoolInfo.ValAvg)
+		if err != nil {
+			_ = dbtx.Rollback()
+			return fmt.Errorf("unable to insert stake info row: %v", err)
+		}
+		if int64(si.Feeinfo.Height) > stakeHeight {
+			stakeHeight = int64(si.Feeinfo.Height)
+		}
+	}
+
+	updateIndexHeights, err := db.StoreIndexBatch(dbtx, blocks)
+	if err != nil {
+		_ = dbtx.Rollback()
+		return err
+	}
+
+	if err = dbtx.Commit(); err != nil {
+		return err
+	}
+
+	updateIndexHeights()
+
+	db.Lock()
+	defer db.Unlock()
+	if summaryHeight > db.dbSummaryHeight {
+		db.dbSummaryHeight = summaryHeight
+	}
+	if stakeHeight > db.dbStakeInfoHeight {
+		db.dbStakeInfoHeight = stakeHeight
+	}
+	return nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanStakeInfoExtended scans a row of the extended stake info table.
+func scanStakeInfoExtended(row rowScanner) (*apitypes.StakeInfoExtended, error) {
+	si := new(apitypes.StakeInfoExtended)
+	err := row.Scan(&si.Feeinfo.Height,
+		&si.Feeinfo.Number, &si.Feeinfo.Min, &si.Feeinfo.Max, &si.Feeinfo.Mean,
+		&si.Feeinfo.Median, &si.Feeinfo.StdDev,
+		&si.StakeDiff, // no next or estimates
+		&si.PriceWindowNum, &si.IdxBlockInWindow, &si.PoolInfo.Size,
+		&si.PoolInfo.Value, &si.PoolInfo.ValAvg)
+	if err != nil {
+		return nil, err
+	}
+	return si, nil
+}
+
+// RetrieveLatestStakeInfoExtended returns the extended stake info for the best block
+func (db *DB) RetrieveLatestStakeInfoExtended() (*apitypes.StakeInfoExtended, error) {
+	return scanStakeInfoExtended(db.QueryRow(db.getLatestStakeInfoExtendedSQL))
+}
+
+// RetrieveStakeInfoExtended returns the extended stake info for block ind
+func (db *DB) RetrieveStakeInfoExtended(ind int64) (*apitypes.StakeInfoExtended, error) {
+	return scanStakeInfoExtended(db.QueryRow(db.getStakeInfoExtendedSQL, ind))
+}
+
+// RetrieveStakeInfoExtendedRange returns the extended stake info for the
+// blocks from height ind0 to ind1, in order of height.
+func (db *DB) RetrieveStakeInfoExtendedRange(ind0, ind1 int64) ([]*apitypes.StakeInfoExtended, error) {
+	rows, err := db.Query(db.getStakeInfoExtendedRangeSQL, ind0, ind1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sis []*apitypes.StakeInfoExtended
+	for rows.Next() {
+		si, err := scanStakeInfoExtended(rows)
+		if err != nil {
+			return nil, err
+		}
+		sis = append(sis, si)
+	}
+	return sis, rows.Err()
+}
+
+// logDBResult logs the number of rows affected by a statement. Unlike sqlite,
+// the postgres driver does not support LastInsertId.
+func logDBResult(res sql.Result) error {
+	if log.Level() > btclog.LevelTrace {
+		return nil
+	}
+
+	rowCnt, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	log.Tracef("affected = %d", rowCnt)
+
+	return nil
+}
@@ -0,0 +1,346 @@
+//go:build pgonline
+// +build pgonline
+
+// These tests require a running PostgreSQL server with a "dcrdata" role that
+// owns a "dcrdata_test" database. Run with: go test -tags pgonline
+
+package dcrpg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dcrdata/dcrdata/dbtypes"
+	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/chaincfg/chainec"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/dcrd/txscript"
+	"github.com/decred/dcrd/wire"
+)
+
+var testDBInfo = DBInfo{
+	Host:   "127.0.0.1",
+	Port:   "5432",
+	User:   "dcrdata",
+	DBName: "dcrdata_test",
+}
+
+func openTestDB(t *testing.T) *DB {
+	db, err := InitDB(&testDBInfo)
+	if err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	for _, table := range []string{TableNameSummaries, TableNameStakeInfo,
+		TableNameAddresses, TableNameTransactions, TableNameVins, TableNameVouts,
+		TableNameMempoolHistory} {
+		if _, err = db.Exec("DELETE FROM " + table); err != nil {
+			t.Fatalf("Unable to clear table %s: %v", table, err)
+		}
+	}
+	// Start over with fresh cached heights.
+	return NewDB(db.DB)
+}
+
+func TestBlockSummary(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	for i := uint32(0); i < 3; i++ {
+		bd := &apitypes.BlockDataBasic{
+			Height:     i,
+			Size:       1000 + i,
+			Hash:       chainhash.HashH([]byte{byte(i)}).String(),
+			Difficulty: 1.5,
+			StakeDiff:  2,
+			Time:       int64(1500000000 + i),
+			PoolInfo:   apitypes.TicketPoolInfo{Size: 40960, Value: 123.4, ValAvg: 0.5},
+		}
+		if err := db.StoreBlockSummary(bd); err != nil {
+			t.Fatalf("StoreBlockSummary failed: %v", err)
+		}
+	}
+
+	// Storing an existing height must replace the row.
+	bd := &apitypes.BlockDataBasic{Height: 2, Size: 2222,
+		Hash: chainhash.HashH([]byte{2}).String()}
+	if err := db.StoreBlockSummary(bd); err != nil {
+		t.Fatalf("StoreBlockSummary failed: %v", err)
+	}
+
+	if h := db.GetBestBlockHeight(); h != 2 {
+		t.Errorf("GetBestBlockHeight: got %d, expected 2", h)
+	}
+	if hash := db.GetBestBlockHash(); hash != bd.Hash {
+		t.Errorf("GetBestBlockHash: got %s, expected %s", hash, bd.Hash)
+	}
+
+	sizes, err := db.RetrieveBlockSizeRange(0, 2)
+	if err != nil {
+		t.Fatalf("RetrieveBlockSizeRange failed: %v", err)
+	}
+	expected := []int32{1000, 1001, 2222}
+	if len(sizes) != len(expected) {
+		t.Fatalf("RetrieveBlockSizeRange: got %v, expected %v", sizes, expected)
+	}
+	for i := range sizes {
+		if sizes[i] != expected[i] {
+			t.Errorf("RetrieveBlockSizeRange: got %v, expected %v", sizes, expected)
+		}
+	}
+
+	summary, err := db.RetrieveBlockSummaryByHash(chainhash.HashH([]byte{1}).String())
+	if err != nil {
+		t.Fatalf("RetrieveBlockSummaryByHash failed: %v", err)
+	}
+	if summary.Height != 1 || summary.PoolInfo.Size != 40960 {
+		t.Errorf("RetrieveBlockSummaryByHash: unexpected summary %v", summary)
+	}
+
+	// A batch updates both tables and the cached heights.
+	bds := []*apitypes.BlockDataBasic{{Height: 3}, {Height: 4}}
+	sis := []*apitypes.StakeInfoExtended{{}, {}}
+	sis[0].Feeinfo.Height, sis[1].Feeinfo.Height = 3, 4
+	if err = db.StoreBlockBatch(bds, sis, nil); err != nil {
+		t.Fatalf("StoreBlockBatch failed: %v", err)
+	}
+	if h := db.GetBlockSummaryHeight(); h != 4 {
+		t.Errorf("GetBlockSummaryHeight: got %d, expected 4", h)
+	}
+	if h := db.GetStakeInfoHeight(); h != 4 {
+		t.Errorf("GetStakeInfoHeight: got %d, expected 4", h)
+	}
+}
+
+func testAddress(t *testing.T, b byte) (string, []byte) {
+	pkHash := make([]byte, 20)
+	pkHash[0] = b
+	addr, err := dcrutil.NewAddressPubKeyHash(pkHash, &chaincfg.SimNetParams,
+		chainec.ECTypeSecp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr.EncodeAddress(), script
+}
+
+func TestAddresses(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	addr1, script1 := testAddress(t, 1)
+	addr2, script2 := testAddress(t, 2)
+
+	// Block 1 pays 10 atoms to addr1.
+	fund := wire.NewMsgTx()
+	fund.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+		wire.MaxPrevOutIndex, wire.TxTreeRegular), nil))
+	fund.AddTxOut(wire.NewTxOut(10, script1))
+	block1 := &wire.MsgBlock{
+		Header:       wire.BlockHeader{Height: 1, Timestamp: time.Unix(1500000000, 0)},
+		Transactions: []*wire.MsgTx{fund},
+	}
+
+	// Block 2 spends it, paying 7 atoms to addr2.
+	fundHash := fund.TxHash()
+	spend := wire.NewMsgTx()
+	spend.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&fundHash, 0, wire.TxTreeRegular), nil))
+	spend.AddTxOut(wire.NewTxOut(7, script2))
+	block2 := &wire.MsgBlock{
+		Header:       wire.BlockHeader{Height: 2, Timestamp: time.Unix(1500000300, 0)},
+		Transactions: []*wire.MsgTx{spend},
+	}
+
+	for _, b := range []*wire.MsgBlock{block1, block2} {
+		if err := db.StoreBlockAddresses(b, &chaincfg.SimNetParams); err != nil {
+			t.Fatalf("StoreBlockAddresses failed: %v", err)
+		}
+	}
+	if h := db.GetAddressHeight(); h != 2 {
+		t.Errorf("GetAddressHeight: got %d, expected 2", h)
+	}
+
+	checkTotals := func(addr string, expected dbtypes.AddressTotals) {
+		totals, err := db.RetrieveAddressTotals(addr)
+		if err != nil {
+			t.Fatalf("RetrieveAddressTotals failed: %v", err)
+		}
+		if *totals != expected {
+			t.Errorf("RetrieveAddressTotals(%s): got %v, expected %v", addr,
+				*totals, expected)
+		}
+	}
+	checkTotals(addr1, dbtypes.AddressTotals{NumTxns: 2, Received: 10, Sent: 10})
+	checkTotals(addr2, dbtypes.AddressTotals{NumTxns: 1, Received: 7, Unspent: 7})
+
+	txns, err := db.RetrieveAddressTxns(addr1, 10, 0)
+	if err != nil {
+		t.Fatalf("RetrieveAddressTxns failed: %v", err)
+	}
+	if len(txns) != 2 || txns[0].TxHash != spend.TxHash().String() ||
+		txns[0].Sent != 10 || txns[1].Received != 10 {
+		t.Errorf("RetrieveAddressTxns: unexpected history %v", txns)
+	}
+
+	// Roll back block 2. The output paying addr1 is unspent again.
+	if err = db.DeleteAddressesAboveHeight(1); err != nil {
+		t.Fatalf("DeleteAddressesAboveHeight failed: %v", err)
+	}
+	checkTotals(addr1, dbtypes.AddressTotals{NumTxns: 1, Received: 10, Unspent: 10})
+	checkTotals(addr2, dbtypes.AddressTotals{})
+
+	utxos, err := db.RetrieveAddressUTXOs(addr1)
+	if err != nil {
+		t.Fatalf("RetrieveAddressUTXOs failed: %v", err)
+	}
+	if len(utxos) != 1 || utxos[0].TxHash != fundHash.String() || utxos[0].Value != 10 {
+		t.Errorf("RetrieveAddressUTXOs: unexpected outputs %v", utxos)
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	addr1, script1 := testAddress(t, 1)
+
+	fund := wire.NewMsgTx()
+	fund.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+		wire.MaxPrevOutIndex, wire.TxTreeRegular), []byte{0x51}))
+	fund.AddTxOut(wire.NewTxOut(10, script1))
+	fund.AddTxOut(wire.NewTxOut(20, script1))
+	block1 := &wire.MsgBlock{
+		Header:       wire.BlockHeader{Height: 1, Timestamp: time.Unix(1500000000, 0)},
+		Transactions: []*wire.MsgTx{fund},
+	}
+
+	fundHash := fund.TxHash()
+	spend := wire.NewMsgTx()
+	spend.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&fundHash, 1, wire.TxTreeRegular), nil))
+	spend.AddTxOut(wire.NewTxOut(15, script1))
+	block2 := &wire.MsgBlock{
+		Header:       wire.BlockHeader{Height: 2, Timestamp: time.Unix(1500000300, 0)},
+		Transactions: []*wire.MsgTx{spend},
+	}
+
+	for _, b := range []*wire.MsgBlock{block1, block2} {
+		if err := db.StoreBlockTransactions(b, &chaincfg.SimNetParams); err != nil {
+			t.Fatalf("StoreBlockTransactions failed: %v", err)
+		}
+	}
+	if h := db.GetTransactionHeight(); h != 2 {
+		t.Errorf("GetTransactionHeight: got %d, expected 2", h)
+	}
+
+	tx, err := db.RetrieveTransaction(fundHash.String())
+	if err != nil {
+		t.Fatalf("RetrieveTransaction failed: %v", err)
+	}
+	if tx.BlockHeight != 1 || tx.BlockHash != block1.BlockHash().String() ||
+		tx.NumVin != 1 || tx.NumVout != 2 || tx.Size != int32(fund.SerializeSize()) {
+		t.Errorf("RetrieveTransaction: unexpected transaction %v", tx)
+	}
+
+	vins, err := db.RetrieveTxVins(fundHash.String())
+	if err != nil {
+		t.Fatalf("RetrieveTxVins failed: %v", err)
+	}
+	if len(vins) != 1 || vins[0].SigScript != "51" {
+		t.Errorf("RetrieveTxVins: unexpected inputs %v", vins)
+	}
+
+	vouts, err := db.RetrieveTxVouts(fundHash.String())
+	if err != nil {
+		t.Fatalf("RetrieveTxVouts failed: %v", err)
+	}
+	if len(vouts) != 2 {
+		t.Fatalf("RetrieveTxVouts: got %d outputs, expected 2", len(vouts))
+	}
+	if vouts[0].ScriptClass != "pubkeyhash" || len(vouts[0].Addresses) != 1 ||
+		vouts[0].Addresses[0] != addr1 || vouts[0].SpendingHeight != -1 {
+		t.Errorf("RetrieveTxVouts: unexpected output %v", vouts[0])
+	}
+	if vouts[1].SpendingTxHash != spend.TxHash().String() || vouts[1].SpendingHeight != 2 {
+		t.Errorf("RetrieveTxVouts: output 1 not spent by %v", spend.TxHash())
+	}
+
+	spender, vin, height, err := db.RetrieveSpendingTx(fundHash.String(), 1)
+	if err != nil {
+		t.Fatalf("RetrieveSpendingTx failed: %v", err)
+	}
+	if spender != spend.TxHash().String() || vin != 0 || height != 2 {
+		t.Errorf("RetrieveSpendingTx: got %s:%d at %d, expected %v:0 at 2",
+			spender, vin, height, spend.TxHash())
+	}
+
+	// Roll back block 2. The spent output is unspent again.
+	if err = db.DeleteTransactionsAboveHeight(1); err != nil {
+		t.Fatalf("DeleteTransactionsAboveHeight failed: %v", err)
+	}
+	if _, err = db.RetrieveTransaction(spend.TxHash().String()); err == nil {
+		t.Errorf("RetrieveTransaction: rolled back transaction still stored")
+	}
+	vouts, err = db.RetrieveTxVouts(fundHash.String())
+	if err != nil {
+		t.Fatalf("RetrieveTxVouts failed: %v", err)
+	}
+	if len(vouts) != 2 || vouts[1].SpendingHeight != -1 {
+		t.Errorf("RetrieveTxVouts: output 1 still spent after rollback")
+	}
+	if _, _, height, err = db.RetrieveSpendingTx(fundHash.String(), 1); err != nil || height != -1 {
+		t.Errorf("RetrieveSpendingTx: output 1 still spent after rollback")
+	}
+}
+
+func TestMempoolHistory(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	// Four collections, 30 seconds apart.
+	for i := int64(0); i < 4; i++ {
+		snap := &dbtypes.MempoolSnapshot{
+			Time:        1500000000 + 30*i,
+			Height:      100 + i/2,
+			NumTickets:  uint32(10 * (i + 1)),
+			FeeMin:      0.01,
+			FeeMean:     0.02 * float64(i+1),
+			FeeMedian:   0.02,
+			FeeMax:      0.1,
+			NumRegular:  uint32(i),
+			RegularSize: 250 * i,
+		}
+		if err := db.StoreMempoolSnapshot(snap); err != nil {
+			t.Fatalf("StoreMempoolSnapshot failed: %v", err)
+		}
+	}
+
+	// Bins of one minute hold two collections each.
+	bins, err := db.RetrieveMempoolHistory(1500000000, 1500000119, 60)
+	if err != nil {
+		t.Fatalf("RetrieveMempoolHistory failed: %v", err)
+	}
+	if len(bins) != 2 {
+		t.Fatalf("RetrieveMempoolHistory: got %d bins, expected 2", len(bins))
+	}
+	if b := bins[1]; b.Bin != 1 || b.NumSamples != 2 || b.Height != 101 ||
+		b.NumTickets != 35 || b.NumRegular != 2.5 || b.RegularSize != 625 {
+		t.Errorf("RetrieveMempoolHistory: unexpected bin %v", b)
+	}
+
+	// The last collection is outside the time range.
+	bins, err = db.RetrieveMempoolHistory(1500000000, 1500000089, 30)
+	if err != nil {
+		t.Fatalf("RetrieveMempoolHistory failed: %v", err)
+	}
+	if len(bins) != 3 || bins[2].Bin != 2 || bins[2].NumSamples != 1 {
+		t.Errorf("RetrieveMempoolHistory: unexpected bins %v", bins)
+	}
+
+	if _, err = db.RetrieveMempoolHistory(1500000000, 1500000089, 0); err == nil {
+		t.Errorf("RetrieveMempoolHistory: expected error for a zero step")
+	}
+}
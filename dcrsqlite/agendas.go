@@ -0,0 +1,374 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package dcrsqlite
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/dcrdata/dcrdata/explorer"
+	"github.com/decred/dcrd/chaincfg"
+)
+
+// agendaDeployment is a consensus deployment and the vote version of the votes
+// on its agenda.
+type agendaDeployment struct {
+	*chaincfg.ConsensusDeployment
+	VoteVersion uint32
+}
+
+// agendaDeployments returns the consensus deployments of the network, in order
+// of vote version.
+func (db *wiredDB) agendaDeployments() []agendaDeployment {
+	versions := make([]uint32, 0, len(db.params.Deployments))
+	for v := range db.params.Deployments {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i] < versions[j]
+	})
+
+	var deployments []agendaDeployment
+	for _, v := range versions {
+		for i := range db.params.Deployments[v] {
+			deployments = append(deployments, agendaDeployment{
+				&db.params.Deployments[v][i], v,
+			})
+		}
+	}
+	return deployments
+}
+
+// findAgendaDeployment returns the consensus deployment of the agenda with the
+// given ID, or nil if there is none.
+func (db *wiredDB) findAgendaDeployment(agendaID string) *agendaDeployment {
+	for _, d := range db.agendaDeployments() {
+		if d.Vote.Id == agendaID {
+			return &d
+		}
+	}
+	return nil
+}
+
+// tallyVoteBits adds count votes with the masked vote bits to the tally for
+// the matching choice of the agenda. Bits matching no choice are not counted.
+func tallyVoteBits(t *apitypes.AgendaTally, vote *chaincfg.Vote, bits uint16,
+	count uint32) {
+	for i := range vote.Choices {
+		choice := &vote.Choices[i]
+		if choice.Bits != bits {
+			continue
+		}
+		switch {
+		case choice.IsAbstain:
+			t.Abstain += count
+		case choice.IsNo:
+			t.No += count
+		default:
+			t.Yes += count
+		}
+		return
+	}
+}
+
+// medianTimeBlocks is the number of blocks whose timestamps are used to
+// compute the median time of a block, as in dcrd.
+const medianTimeBlocks = 11
+
+// medianTime returns the median timestamp of the block at the given height
+// and the blocks before it, which dcrd compares with an agenda's start and
+// expire times.
+func (db *wiredDB) medianTime(height int64) (int64, error) {
+	ind0 := height - medianTimeBlocks + 1
+	if ind0 < 0 {
+		ind0 = 0
+	}
+	times, err := db.RetrieveBlockTimeRange(ind0, height)
+	if err != nil {
+		return 0, fmt.Errorf("RetrieveBlockTimeRange failed: %v", err)
+	}
+	if len(times) == 0 {
+		return 0, fmt.Errorf("no block time for height %d", height)
+	}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i] < times[j]
+	})
+	return times[len(times)/2], nil
+}
+
+// agendaIntervalActive reports whether dcrd counts the votes on the agenda in
+// the rule change interval starting at height start. Voting starts with the
+// first interval after the median time reaches the agenda's start time, and
+// the votes in an interval are discarded if the median time at its last
+// block, or at the best block if it is not complete, is at or after the
+// expire time.
+func (db *wiredDB) agendaIntervalActive(d *agendaDeployment, start,
+	bestHeight int64) (bool, error) {
+	if start < 1 {
+		return false, nil
+	}
+	startTime, err := db.medianTime(start - 1)
+	if err != nil {
+		return false, err
+	}
+	if startTime < int64(d.StartTime) {
+		return false, nil
+	}
+
+	end := start + int64(db.params.RuleChangeActivationInterval) - 1
+	if end > bestHeight {
+		end = bestHeight
+	}
+	if h := db.GetBlockSummaryHeight(); end > h {
+		end = h
+	}
+	endTime, err := db.medianTime(end)
+	if err != nil {
+		return false, err
+	}
+	return endTime < int64(d.ExpireTime), nil
+}
+
+// agendaTallies counts the votes on the agenda in the blocks from height ind0
+// to ind1, in bins of binSize blocks starting at ind0. Only the votes in the
+// rule change intervals in which the agenda is active, given the best block
+// height, are counted. Bins with no counted votes are omitted.
+func (db *wiredDB) agendaTallies(d *agendaDeployment, ind0, ind1, binSize,
+	bestHeight int64) ([]apitypes.AgendaTally, error) {
+	counts, err := db.RetrieveVoteBitsCounts(d.VoteVersion, d.Vote.Mask, ind0,
+		ind1, binSize)
+	if err != nil {
+		return nil, fmt.Errorf("RetrieveVoteBitsCounts failed: %v", err)
+	}
+
+	// Whether the agenda is active in each rule change interval.
+	active := make(map[int64]bool)
+	var tallies []apitypes.AgendaTally
+	for _, c := range counts {
+		start := ind0 + c.Bin*binSize
+		n, intervalStart := db.ruleChangeInterval(start)
+		if n < 0 {
+			continue
+		}
+		isActive, ok := active[n]
+		if !ok {
+			isActive, err = db.agendaIntervalActive(d, intervalStart, bestHeight)
+			if err != nil {
+				return nil, err
+			}
+			active[n] = isActive
+		}
+		if !isActive {
+			continue
+		}
+
+		if len(tallies) == 0 || tallies[len(tallies)-1].StartHeight != start {
+			tallies = append(tallies, apitypes.AgendaTally{
+				StartHeight: start,
+				EndHeight:   start + binSize - 1,
+			})
+		}
+		tallyVoteBits(&tallies[len(tallies)-1], &d.Vote, c.Bits, c.Count)
+	}
+	return tallies, nil
+}
+
+// stakeInterval returns the interval of intervalSize blocks containing the
+// block at the given height, and the interval's first block height. The
+// intervals start at stake validation height, so the interval is negative for
+// earlier blocks.
+func (db *wiredDB) stakeInterval(height, intervalSize int64) (int64, int64) {
+	svh := db.params.StakeValidationHeight
+	if height < svh {
+		return -1, svh - intervalSize
+	}
+	n := (height - svh) / intervalSize
+	return n, svh + n*intervalSize
+}
+
+// ruleChangeInterval returns the rule change interval containing the block at
+// the given height, and the interval's first block height.
+func (db *wiredDB) ruleChangeInterval(height int64) (int64, int64) {
+	return db.stakeInterval(height, int64(db.params.RuleChangeActivationInterval))
+}
+
+// makeAgendaInterval computes the quorum and threshold progress of the votes
+// on an agenda in a rule change interval, given the best block height.
+func (db *wiredDB) makeAgendaInterval(t apitypes.AgendaTally,
+	bestHeight int64) apitypes.AgendaInterval {
+	n, _ := db.ruleChangeInterval(t.StartHeight)
+	ai := apitypes.AgendaInterval{
+		Interval:    n,
+		Complete:    t.EndHeight <= bestHeight,
+		AgendaTally: t,
+	}
+
+	quorum := db.params.RuleChangeActivationQuorum
+	nonAbstain := t.Yes + t.No
+	if quorum > 0 {
+		ai.QuorumProgress = float64(nonAbstain) / float64(quorum)
+	}
+	ai.QuorumMet = nonAbstain >= quorum
+	if nonAbstain > 0 {
+		ai.YesRatio = float64(t.Yes) / float64(nonAbstain)
+	}
+	ai.ThresholdMet = ai.QuorumMet &&
+		uint64(t.Yes)*uint64(db.params.RuleChangeActivationDivisor) >=
+			uint64(nonAbstain)*uint64(db.params.RuleChangeActivationMultiplier)
+	return ai
+}
+
+// makeAgenda describes the agenda, with the votes on it in the current rule
+// change interval.
+func (db *wiredDB) makeAgenda(d *agendaDeployment, bestHeight int64) (*apitypes.Agenda, error) {
+	agenda := &apitypes.Agenda{
+		ID:          d.Vote.Id,
+		Description: d.Vote.Description,
+		VoteVersion: d.VoteVersion,
+		Mask:        d.Vote.Mask,
+		StartTime:   d.StartTime,
+		ExpireTime:  d.ExpireTime,
+		Quorum:      db.params.RuleChangeActivationQuorum,
+		Threshold: float64(db.params.RuleChangeActivationMultiplier) /
+			float64(db.params.RuleChangeActivationDivisor),
+	}
+	for _, c := range d.Vote.Choices {
+		agenda.Choices = append(agenda.Choices, c.Id)
+	}
+
+	n, start := db.ruleChangeInterval(bestHeight)
+	if n < 0 {
+		return agenda, nil
+	}
+	intervalSize := int64(db.params.RuleChangeActivationInterval)
+	tallies, err := db.agendaTallies(d, start, bestHeight, intervalSize,
+		bestHeight)
+	if err != nil {
+		return nil, err
+	}
+	tally := apitypes.AgendaTally{
+		StartHeight: start,
+		EndHeight:   start + intervalSize - 1,
+	}
+	if len(tallies) > 0 {
+		tally = tallies[0]
+	}
+	ai := db.makeAgendaInterval(tally, bestHeight)
+	agenda.CurrentInterval = &ai
+	return agenda, nil
+}
+
+// GetAgendas returns the consensus rule change agendas of all vote versions,
+// with the votes on each in the current rule change interval, or nil on
+// error.
+func (db *wiredDB) GetAgendas() []*apitypes.Agenda {
+	bestHeight := db.GetVoteHeight()
+	deployments := db.agendaDeployments()
+	agendas := make([]*apitypes.Agenda, 0, len(deployments))
+	for i := range deployments {
+		agenda, err := db.makeAgenda(&deployments[i], bestHeight)
+		if err != nil {
+			log.Errorf("Unable to get agenda %s: %v", deployments[i].Vote.Id, err)
+			return nil
+		}
+		agendas = append(agendas, agenda)
+	}
+	return agendas
+}
+
+// GetAgenda returns the agenda with the given ID, with the votes on it in
+// each rule change interval that has any, or nil if there is no such agenda
+// or on error.
+func (db *wiredDB) GetAgenda(agendaID string) *apitypes.Agenda {
+	d := db.findAgendaDeployment(agendaID)
+	if d == nil {
+		return nil
+	}
+
+	bestHeight := db.GetVoteHeight()
+	agenda, err := db.makeAgenda(d, bestHeight)
+	if err != nil {
+		log.Errorf("Unable to get agenda %s: %v", agendaID, err)
+		return nil
+	}
+	if agenda.CurrentInterval == nil {
+		return agenda
+	}
+
+	tallies, err := db.agendaTallies(d, db.params.StakeValidationHeight,
+		bestHeight, int64(db.params.RuleChangeActivationInterval), bestHeight)
+	if err != nil {
+		log.Errorf("Unable to get agenda %s: %v", agendaID, err)
+		return nil
+	}
+	agenda.Intervals = make([]apitypes.AgendaInterval, 0, len(tallies))
+	for _, t := range tallies {
+		agenda.Intervals = append(agenda.Intervals,
+			db.makeAgendaInterval(t, bestHeight))
+	}
+	return agenda
+}
+
+// GetAgendaBlocks returns the agenda with the given ID, with the votes on it
+// in each block from height idx0 to idx that has any, or nil if there is no
+// such agenda or on error.
+func (db *wiredDB) GetAgendaBlocks(agendaID string, idx0, idx int64) *apitypes.Agenda {
+	d := db.findAgendaDeployment(agendaID)
+	if d == nil {
+		return nil
+	}
+
+	bestHeight := db.GetVoteHeight()
+	agenda, err := db.makeAgenda(d, bestHeight)
+	if err != nil {
+		log.Errorf("Unable to get agenda %s: %v", agendaID, err)
+		return nil
+	}
+
+	agenda.Blocks, err = db.agendaTallies(d, idx0, idx, 1, bestHeight)
+	if err != nil {
+		log.Errorf("Unable to get agenda %s: %v", agendaID, err)
+		return nil
+	}
+	return agenda
+}
+
+// GetExplorerAgendas returns the agendas with the progress of their votes in
+// the current rule change interval for the explorer's agendas page, or nil on
+// error.
+func (db *wiredDB) GetExplorerAgendas() []*explorer.AgendaInfo {
+	agendas := db.GetAgendas()
+	if agendas == nil {
+		return nil
+	}
+
+	infos := make([]*explorer.AgendaInfo, 0, len(agendas))
+	for _, a := range agendas {
+		info := &explorer.AgendaInfo{
+			ID:               a.ID,
+			Description:      a.Description,
+			VoteVersion:      a.VoteVersion,
+			Choices:          a.Choices,
+			StartTime:        time.Unix(int64(a.StartTime), 0).UTC().Format("2006-01-02"),
+			ExpireTime:       time.Unix(int64(a.ExpireTime), 0).UTC().Format("2006-01-02"),
+			Interval:         -1,
+			ThresholdPercent: 100 * a.Threshold,
+		}
+		if ai := a.CurrentInterval; ai != nil {
+			info.Interval = ai.Interval
+			info.IntervalStart, info.IntervalEnd = ai.StartHeight, ai.EndHeight
+			info.Yes, info.No, info.Abstain = ai.Yes, ai.No, ai.Abstain
+			info.QuorumPercent = 100 * ai.QuorumProgress
+			if info.QuorumPercent > 100 {
+				info.QuorumPercent = 100
+			}
+			info.YesPercent = 100 * ai.YesRatio
+			info.QuorumMet, info.ThresholdMet = ai.QuorumMet, ai.ThresholdMet
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
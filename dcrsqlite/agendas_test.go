@@ -0,0 +1,158 @@
+package dcrsqlite
+
+import (
+	"testing"
+
+	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/decred/dcrd/chaincfg"
+)
+
+func TestTallyVoteBits(t *testing.T) {
+	vote := &chaincfg.Vote{
+		Id:   "test",
+		Mask: 0x6,
+		Choices: []chaincfg.Choice{
+			{Id: "abstain", Bits: 0x0, IsAbstain: true},
+			{Id: "no", Bits: 0x2, IsNo: true},
+			{Id: "yes", Bits: 0x4},
+		},
+	}
+
+	var tally apitypes.AgendaTally
+	tallyVoteBits(&tally, vote, 0x0, 1)
+	tallyVoteBits(&tally, vote, 0x2, 2)
+	tallyVoteBits(&tally, vote, 0x4, 3)
+	tallyVoteBits(&tally, vote, 0x4, 4)
+	// Bits matching no choice are not counted.
+	tallyVoteBits(&tally, vote, 0x6, 5)
+
+	expected := apitypes.AgendaTally{Yes: 7, No: 2, Abstain: 1}
+	if tally != expected {
+		t.Errorf("tally %v, expected %v", tally, expected)
+	}
+}
+
+func TestMakeAgendaInterval(t *testing.T) {
+	db := newTestWiredDB(nil)
+	params := db.params
+	quorum := params.RuleChangeActivationQuorum
+	intervalSize := int64(params.RuleChangeActivationInterval)
+	start := params.StakeValidationHeight + 2*intervalSize
+
+	// yesAt returns the number of yes votes out of nonAbstain at exactly the
+	// threshold.
+	yesAt := func(nonAbstain uint32) uint32 {
+		return nonAbstain * params.RuleChangeActivationMultiplier /
+			params.RuleChangeActivationDivisor
+	}
+
+	tests := []struct {
+		name                    string
+		yes, no, abstain        uint32
+		bestHeight              int64
+		complete                bool
+		quorumMet, thresholdMet bool
+	}{
+		{"at threshold", yesAt(quorum), quorum - yesAt(quorum), 100,
+			start + intervalSize, true, true, true},
+		{"below threshold", yesAt(quorum) - 1, quorum - yesAt(quorum) + 1, 0,
+			start + intervalSize, true, true, false},
+		{"below quorum", quorum - 1, 0, 2 * quorum,
+			start + intervalSize/2, false, false, false},
+		{"no votes", 0, 0, 0, start, false, false, false},
+	}
+
+	for _, tt := range tests {
+		tally := apitypes.AgendaTally{
+			StartHeight: start,
+			EndHeight:   start + intervalSize - 1,
+			Yes:         tt.yes,
+			No:          tt.no,
+			Abstain:     tt.abstain,
+		}
+		ai := db.makeAgendaInterval(tally, tt.bestHeight)
+		if ai.Interval != 2 || ai.AgendaTally != tally {
+			t.Errorf("%s: interval %d with tally %v", tt.name, ai.Interval,
+				ai.AgendaTally)
+		}
+		if ai.Complete != tt.complete {
+			t.Errorf("%s: complete %v, expected %v", tt.name, ai.Complete, tt.complete)
+		}
+		if ai.QuorumMet != tt.quorumMet || ai.ThresholdMet != tt.thresholdMet {
+			t.Errorf("%s: quorum met %v and threshold met %v, expected %v and %v",
+				tt.name, ai.QuorumMet, ai.ThresholdMet, tt.quorumMet, tt.thresholdMet)
+		}
+
+		nonAbstain := tt.yes + tt.no
+		if progress := float64(nonAbstain) / float64(quorum); ai.QuorumProgress != progress {
+			t.Errorf("%s: quorum progress %v, expected %v", tt.name,
+				ai.QuorumProgress, progress)
+		}
+		var yesRatio float64
+		if nonAbstain > 0 {
+			yesRatio = float64(tt.yes) / float64(nonAbstain)
+		}
+		if ai.YesRatio != yesRatio {
+			t.Errorf("%s: yes ratio %v, expected %v", tt.name, ai.YesRatio, yesRatio)
+		}
+	}
+}
+
+func TestAgendaIntervalActive(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	wdb := newTestWiredDB(db)
+
+	// Rule change intervals of 4 blocks start at height 4. The median time of
+	// the block at height h >= 10 is the time of block h-5.
+	params := chaincfg.MainNetParams
+	params.StakeValidationHeight = 4
+	params.RuleChangeActivationInterval = 4
+	wdb.params = &params
+	for h := int64(0); h < 20; h++ {
+		err := db.StoreBlockSummary(&apitypes.BlockDataBasic{
+			Height: uint32(h),
+			Time:   1000 + 100*h,
+		})
+		if err != nil {
+			t.Fatalf("StoreBlockSummary(%d) failed: %v", h, err)
+		}
+	}
+
+	// The median time reaches the start time at height 11, so voting starts
+	// in the interval at 12.
+	d := &agendaDeployment{
+		ConsensusDeployment: &chaincfg.ConsensusDeployment{
+			StartTime:  1500,
+			ExpireTime: 2200,
+		},
+		VoteVersion: 5,
+	}
+
+	tests := []struct {
+		start, bestHeight int64
+		expireTime        uint64
+		active            bool
+	}{
+		{8, 19, 2200, false},
+		{12, 19, 2200, true},
+		// The median time at the end of the interval is past the expire time.
+		{16, 19, 2200, false},
+		{16, 19, 2300, false},
+		// Before the interval ends, the best block is checked instead.
+		{16, 17, 2200, false},
+		{16, 17, 2300, true},
+	}
+	for _, tt := range tests {
+		d.ExpireTime = tt.expireTime
+		active, err := wdb.agendaIntervalActive(d, tt.start, tt.bestHeight)
+		if err != nil {
+			t.Fatalf("agendaIntervalActive failed: %v", err)
+		}
+		if active != tt.active {
+			t.Errorf("interval at %d with best block %d and expire time %d: "+
+				"active %v, expected %v", tt.start, tt.bestHeight, tt.expireTime,
+				active, tt.active)
+		}
+	}
+}
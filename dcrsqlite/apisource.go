@@ -12,6 +12,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/dcrdata/dcrdata/dbtypes"
 	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
 	"github.com/dcrdata/dcrdata/explorer"
 	"github.com/dcrdata/dcrdata/mempool"
@@ -34,17 +35,25 @@ import (
 type wiredDB struct {
 	*DBDataSaver
 	MPC    *mempool.MempoolDataCache
+	MPT    *mempool.MempoolTxTracker
 	client *rpcclient.Client
 	params *chaincfg.Params
 	sDB    *stakedb.StakeDatabase
+
+	sdiffWindows  *StakeDiffWindowCache
+	syncBatchSize int64
 }
 
-func newWiredDB(DB *DB, statusC chan uint32, cl *rpcclient.Client, p *chaincfg.Params) (wiredDB, func() error) {
+func newWiredDB(store ChainDataStore, statusC chan uint32, cl *rpcclient.Client, p *chaincfg.Params) (wiredDB, func() error) {
 	wDB := wiredDB{
-		DBDataSaver: &DBDataSaver{DB, statusC},
+		DBDataSaver: &DBDataSaver{store, statusC},
 		MPC:         new(mempool.MempoolDataCache),
+		MPT:         mempool.NewMempoolTxTracker(p),
 		client:      cl,
 		params:      p,
+
+		sdiffWindows:  NewStakeDiffWindowCache(),
+		syncBatchSize: DefaultSyncBatchSize,
 	}
 
 	//err := wDB.openStakeDB()
@@ -54,7 +63,9 @@ func newWiredDB(DB *DB, statusC chan uint32, cl *rpcclient.Client, p *chaincfg.P
 		log.Errorf("Unable to create stake DB: %v", err)
 		return wDB, func() error { return nil }
 	}
-	return wDB, wDB.sDB.StakeDB.Close
+	// The stake DB may be reset by a resync, replacing StakeDB.
+	sDB := wDB.sDB
+	return wDB, func() error { return sDB.StakeDB.Close() }
 }
 
 // NewWiredDB creates a new wiredDB from a *sql.DB, a node client, network
@@ -64,6 +75,12 @@ func NewWiredDB(db *sql.DB, statusC chan uint32, cl *rpcclient.Client, p *chainc
 	return newWiredDB(NewDB(db), statusC, cl, p)
 }
 
+// NewWiredDBWithStore creates a new wiredDB backed by any ChainDataStore, such
+// as a dcrpg.DB. The other parameters are same as those for NewWiredDB.
+func NewWiredDBWithStore(store ChainDataStore, statusC chan uint32, cl *rpcclient.Client, p *chaincfg.Params) (wiredDB, func() error) {
+	return newWiredDB(store, statusC, cl, p)
+}
+
 // InitWiredDB creates a new wiredDB from a file containing the data for a
 // sql.DB. The other parameters are same as those for NewWiredDB.
 func InitWiredDB(dbInfo *DBInfo, statusC chan uint32, cl *rpcclient.Client, p *chaincfg.Params) (wiredDB, func() error, error) {
@@ -76,6 +93,14 @@ func InitWiredDB(dbInfo *DBInfo, statusC chan uint32, cl *rpcclient.Client, p *c
 	return wDB, cleanup, nil
 }
 
+// SetSyncBatchSize sets the number of blocks fetched and stored together when
+// resyncing the database. Values less than 1 are ignored.
+func (db *wiredDB) SetSyncBatchSize(n int) {
+	if n > 0 {
+		db.syncBatchSize = int64(n)
+	}
+}
+
 func (db *wiredDB) NewStakeDBChainMonitor(quit chan struct{}, wg *sync.WaitGroup,
 	blockChan chan *chainhash.Hash, reorgChan chan *stakedb.ReorgData) *stakedb.ChainMonitor {
 	return db.sDB.NewChainMonitor(quit, wg, blockChan, reorgChan)
@@ -194,7 +219,139 @@ func makeBlockTransactions(blockVerbose *dcrjson.GetBlockVerboseResult) *apitype
 	return blockTransactions
 }
 
+// retrieveDBTransaction retrieves the transaction with its inputs and outputs
+// from the transactions, vins and vouts tables. A nil *dbtypes.Tx is returned
+// if the transaction is not stored, e.g. if it is only in mempool.
+func (db *wiredDB) retrieveDBTransaction(txid string) (*dbtypes.Tx, []*dbtypes.Vin, []*dbtypes.Vout) {
+	t, err := db.RetrieveTransaction(txid)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Errorf("RetrieveTransaction failed for %s: %v", txid, err)
+		}
+		return nil, nil, nil
+	}
+	vins, err := db.RetrieveTxVins(txid)
+	if err != nil {
+		log.Errorf("RetrieveTxVins failed for %s: %v", txid, err)
+		return nil, nil, nil
+	}
+	vouts, err := db.RetrieveTxVouts(txid)
+	if err != nil {
+		log.Errorf("RetrieveTxVouts failed for %s: %v", txid, err)
+		return nil, nil, nil
+	}
+	return t, vins, vouts
+}
+
+// makeDcrjsonVin creates a dcrjson.Vin for a stored input of transaction t,
+// like those returned by getrawtransaction.
+func makeDcrjsonVin(t *dbtypes.Tx, in *dbtypes.Vin) dcrjson.Vin {
+	vin := dcrjson.Vin{
+		Sequence:    in.Sequence,
+		AmountIn:    dcrutil.Amount(in.ValueIn).ToCoin(),
+		BlockHeight: in.ProofHeight,
+		BlockIndex:  in.ProofIndex,
+	}
+	switch {
+	case t.Tree == wire.TxTreeRegular && t.BlockIndex == 0:
+		// The coinbase is always the first transaction of the regular tree.
+		vin.Coinbase = in.SigScript
+	case stake.TxType(t.TxType) == stake.TxTypeSSGen && in.TxIndex == 0:
+		vin.Stakebase = in.SigScript
+	default:
+		sigScript, _ := hex.DecodeString(in.SigScript)
+		asm, _ := txscript.DisasmString(sigScript)
+		vin.Txid = in.PrevTxHash
+		vin.Vout = in.PrevTxIndex
+		vin.Tree = in.PrevTxTree
+		vin.ScriptSig = &dcrjson.ScriptSig{
+			Asm: asm,
+			Hex: in.SigScript,
+		}
+	}
+	return vin
+}
+
+// makeAPITx creates an apitypes.Tx from a stored transaction and its inputs
+// and outputs.
+func makeAPITx(t *dbtypes.Tx, vins []*dbtypes.Vin, vouts []*dbtypes.Vout, bestHeight int64) *apitypes.Tx {
+	tx := new(apitypes.Tx)
+
+	// TxShort
+	tx.Size = t.Size
+	tx.TxID = t.TxHash
+	tx.Version = t.Version
+	tx.Locktime = t.Locktime
+	tx.Expiry = t.Expiry
+	tx.Vin = make([]dcrjson.Vin, len(vins))
+	for i := range vins {
+		tx.Vin[i] = makeDcrjsonVin(t, vins[i])
+	}
+	tx.Vout = make([]apitypes.Vout, len(vouts))
+	for i, vout := range vouts {
+		tx.Vout[i].Value = dcrutil.Amount(vout.Value).ToCoin()
+		tx.Vout[i].N = vout.TxIndex
+		tx.Vout[i].Version = vout.Version
+		spk := &tx.Vout[i].ScriptPubKeyDecoded
+		pkScript, _ := hex.DecodeString(vout.PkScript)
+		spk.Asm, _ = txscript.DisasmString(pkScript)
+		spk.ReqSigs = vout.ReqSigs
+		spk.Type = vout.ScriptClass
+		spk.Addresses = vout.Addresses
+		if vout.ScriptClass == dbtypes.SStxCommitmentClass {
+			commitAmt := dcrutil.Amount(vout.CommitAmt).ToCoin()
+			spk.CommitAmt = &commitAmt
+		}
+	}
+
+	tx.Confirmations = bestHeight - t.BlockHeight + 1
+
+	// BlockID
+	tx.Block = &apitypes.BlockID{
+		BlockHash:   t.BlockHash,
+		BlockHeight: t.BlockHeight,
+		BlockIndex:  t.BlockIndex,
+		Time:        t.BlockTime,
+		BlockTime:   t.BlockTime,
+	}
+
+	return tx
+}
+
+// totalVout returns the total value of the transaction outputs.
+func totalVout(vouts []apitypes.Vout) dcrutil.Amount {
+	var total dcrutil.Amount
+	for _, v := range vouts {
+		a, err := dcrutil.NewAmount(v.Value)
+		if err != nil {
+			continue
+		}
+		total += a
+	}
+	return total
+}
+
+// GetAllTxIn returns the inputs of the transaction from the vins table, or
+// from dcrd if the transaction is not stored (e.g. it is in mempool).
 func (db *wiredDB) GetAllTxIn(txid string) []*apitypes.TxIn {
+	if vins, err := db.RetrieveTxVins(txid); err == nil && len(vins) > 0 {
+		allTxIn := make([]*apitypes.TxIn, len(vins))
+		for i, in := range vins {
+			allTxIn[i] = &apitypes.TxIn{
+				PreviousOutPoint: apitypes.OutPoint{
+					Hash:  in.PrevTxHash,
+					Index: in.PrevTxIndex,
+					Tree:  in.PrevTxTree,
+				},
+				Sequence:        in.Sequence,
+				ValueIn:         dcrutil.Amount(in.ValueIn).ToCoin(),
+				BlockHeight:     in.ProofHeight,
+				BlockIndex:      in.ProofIndex,
+				SignatureScript: in.SigScript,
+			}
+		}
+		return allTxIn
+	}
 
 	txhash, err := chainhash.NewHashFromStr(txid)
 	if err != nil {
@@ -229,7 +386,28 @@ func (db *wiredDB) GetAllTxIn(txid string) []*apitypes.TxIn {
 	return allTxIn
 }
 
+// GetAllTxOut returns the outputs of the transaction from the vouts table, or
+// from dcrd if the transaction is not stored (e.g. it is in mempool).
 func (db *wiredDB) GetAllTxOut(txid string) []*apitypes.TxOut {
+	if vouts, err := db.RetrieveTxVouts(txid); err == nil && len(vouts) > 0 {
+		allTxOut := make([]*apitypes.TxOut, len(vouts))
+		for i, vout := range vouts {
+			allTxOut[i] = &apitypes.TxOut{
+				Value:     dcrutil.Amount(vout.Value).ToCoin(),
+				Version:   vout.Version,
+				PkScript:  vout.PkScript,
+				Addresses: vout.Addresses,
+			}
+			if vout.SpendingHeight >= 0 {
+				allTxOut[i].Spender = &apitypes.TxSpender{
+					TxID:        vout.SpendingTxHash,
+					Vin:         uint32(vout.SpendingTxIndex),
+					BlockHeight: vout.SpendingHeight,
+				}
+			}
+		}
+		return allTxOut
+	}
 
 	txhash, err := chainhash.NewHashFromStr(txid)
 	if err != nil {
@@ -271,11 +449,103 @@ func (db *wiredDB) GetAllTxOut(txid string) []*apitypes.TxOut {
 	return allTxOut
 }
 
+// GetTxOutSpender returns the spent status of the transaction output, and the
+// mined transaction input spending it, if any. nil is returned if the output
+// is not stored, e.g. if it was created by a mempool transaction.
+func (db *wiredDB) GetTxOutSpender(txid string, vout uint32) *apitypes.TxOutSpender {
+	spendingTxHash, vin, height, err := db.RetrieveSpendingTx(txid, vout)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Errorf("RetrieveSpendingTx failed for %s:%d: %v", txid, vout, err)
+		}
+		return nil
+	}
+
+	spender := &apitypes.TxOutSpender{
+		TxID: txid,
+		Vout: vout,
+	}
+	if height >= 0 {
+		spender.Spent = true
+		spender.Spender = &apitypes.TxSpender{
+			TxID:        spendingTxHash,
+			Vin:         vin,
+			BlockHeight: height,
+		}
+	}
+	return spender
+}
+
+// GetTicketInfo returns the lifecycle of the ticket purchased by the given
+// transaction, or nil if the transaction is not a ticket purchase or is not
+// in the database. Immature tickets are not in the tickets table, and are
+// identified from the transactions table.
+func (db *wiredDB) GetTicketInfo(txid string) *apitypes.TicketInfo {
+	maturity := int64(db.params.TicketMaturity)
+	expiry := int64(db.params.TicketExpiry)
+
+	ticket, err := db.RetrieveTicket(txid)
+	if err == sql.ErrNoRows {
+		tx, err := db.RetrieveTransaction(txid)
+		if err != nil {
+			if err != sql.ErrNoRows {
+				log.Errorf("RetrieveTransaction failed for %s: %v", txid, err)
+			}
+			return nil
+		}
+		if stake.TxType(tx.TxType) != stake.TxTypeSStx {
+			return nil
+		}
+		if tx.BlockHeight+maturity <= db.GetBestBlockHeight() {
+			log.Warnf("Mature ticket %s is not in the tickets table.", txid)
+			return nil
+		}
+		return &apitypes.TicketInfo{
+			TxID:             txid,
+			Status:           string(dbtypes.PoolStatusImmature),
+			PoolStatus:       string(dbtypes.PoolStatusImmature),
+			PurchaseHeight:   tx.BlockHeight,
+			MaturityHeight:   tx.BlockHeight + maturity,
+			ExpirationHeight: tx.BlockHeight + maturity + expiry,
+		}
+	}
+	if err != nil {
+		log.Errorf("RetrieveTicket failed for %s: %v", txid, err)
+		return nil
+	}
+
+	info := &apitypes.TicketInfo{
+		TxID:             txid,
+		Status:           string(ticket.PoolStatus),
+		PoolStatus:       string(ticket.PoolStatus),
+		PurchaseHeight:   ticket.PurchaseHeight,
+		MaturityHeight:   ticket.MaturityHeight,
+		ExpirationHeight: ticket.MaturityHeight + expiry,
+	}
+	if ticket.Revoked {
+		info.Status = "revoked"
+	}
+	if ticket.PoolExitHeight >= 0 {
+		info.PoolExitHeight = ticket.PoolExitHeight
+	}
+	if ticket.SpendHeight >= 0 {
+		info.SpendHeight = ticket.SpendHeight
+		// The vote or revocation spends the ticket's stake submission output.
+		spendingTxHash, _, _, err := db.RetrieveSpendingTx(txid, 0)
+		if err != nil {
+			log.Errorf("RetrieveSpendingTx failed for %s:0: %v", txid, err)
+		} else {
+			info.SpendingTxID = spendingTxHash
+		}
+	}
+	return info
+}
+
 // GetRawTransactionWithPrevOutAddresses looks up the previous outpoints for a
 // transaction and extracts a slice of addresses encoded by the pkScript for
 // each previous outpoint consumed by the transaction.
 func (db *wiredDB) GetRawTransactionWithPrevOutAddresses(txid string) (*apitypes.Tx, [][]string) {
-	tx, _ := db.getRawTransaction(txid)
+	tx := db.GetRawTransaction(txid)
 	if tx == nil {
 		return nil, nil
 	}
@@ -289,7 +559,13 @@ func (db *wiredDB) GetRawTransactionWithPrevOutAddresses(txid string) (*apitypes
 		if vin.IsCoinBase() || (vin.IsStakeBase() && i == 0) {
 			continue
 		}
-		var err error
+		// Use the address table, falling back to dcrd for outpoints that are
+		// not indexed, such as those created by mempool transactions.
+		addrs, _, err := db.RetrieveOutpointAddresses(vin.Txid, vin.Vout)
+		if err == nil && len(addrs) > 0 {
+			prevOutAddresses[i] = addrs
+			continue
+		}
 		prevOutAddresses[i], err = txhelpers.OutPointAddressesFromString(
 			vin.Txid, vin.Vout, vin.Tree, db.client, db.params)
 		if err != nil {
@@ -300,14 +576,17 @@ func (db *wiredDB) GetRawTransactionWithPrevOutAddresses(txid string) (*apitypes
 	return tx, prevOutAddresses
 }
 
+// GetRawTransaction returns the transaction from the transactions tables, or
+// from dcrd if the transaction is not stored (e.g. it is in mempool).
 func (db *wiredDB) GetRawTransaction(txid string) *apitypes.Tx {
+	if t, vins, vouts := db.retrieveDBTransaction(txid); t != nil {
+		return makeAPITx(t, vins, vouts, db.GetBestBlockHeight())
+	}
 	tx, _ := db.getRawTransaction(txid)
 	return tx
 }
 
 func (db *wiredDB) getRawTransaction(txid string) (*apitypes.Tx, string) {
-	tx := new(apitypes.Tx)
-
 	txhash, err := chainhash.NewHashFromStr(txid)
 	if err != nil {
 		log.Errorf("Invalid transaction hash %s", txid)
@@ -320,6 +599,14 @@ func (db *wiredDB) getRawTransaction(txid string) (*apitypes.Tx, string) {
 		return nil, ""
 	}
 
+	return makeAPITxFromRaw(txraw), txraw.Hex
+}
+
+// makeAPITxFromRaw creates an apitypes.Tx from a verbose transaction from
+// dcrd.
+func makeAPITxFromRaw(txraw *dcrjson.TxRawResult) *apitypes.Tx {
+	tx := new(apitypes.Tx)
+
 	// TxShort
 	tx.Size = int32(len(txraw.Hex) / 2)
 	tx.TxID = txraw.Txid
@@ -358,7 +645,7 @@ func (db *wiredDB) getRawTransaction(txid string) (*apitypes.Tx, string) {
 	tx.Block.Time = txraw.Time
 	tx.Block.BlockTime = txraw.Blocktime
 
-	return tx, txraw.Hex
+	return tx
 }
 
 // GetVoteVersionInfo requests stake version info from the dcrd RPC server
@@ -423,8 +710,25 @@ func (db *wiredDB) GetVoteInfo(txid string) (*apitypes.VoteInfo, error) {
 	return vinfo, nil
 }
 
+// GetStakeDiffEstimates returns the current and next stake difficulty from
+// dcrd, with the EstimateStakeDiff estimates of the next window's stake
+// difficulty.
 func (db *wiredDB) GetStakeDiffEstimates() *apitypes.StakeDiff {
-	sd := rpcutils.GetStakeDiffEstimates(db.client)
+	stakeDiff, err := db.client.GetStakeDifficulty()
+	if err != nil {
+		log.Errorf("GetStakeDifficulty failed: %v", err)
+		return nil
+	}
+	sd := &apitypes.StakeDiff{
+		GetStakeDifficultyResult: *stakeDiff,
+	}
+
+	est, err := db.EstimateStakeDiff(nil)
+	if err != nil {
+		log.Errorf("EstimateStakeDiff failed: %v", err)
+		return nil
+	}
+	sd.Estimates = *est
 
 	height := db.MPC.GetHeight()
 	winSize := uint32(db.params.StakeDiffWindowSize)
@@ -513,7 +817,9 @@ func (db *wiredDB) GetPoolInfo(idx int) *apitypes.TicketPoolInfo {
 		log.Errorf("Unable to retrieve ticket pool info: %v", err)
 		return nil
 	}
-	return ticketPoolInfo
+	tpis := []apitypes.TicketPoolInfo{*ticketPoolInfo}
+	db.setTicketExitCounts(int64(idx), tpis)
+	return &tpis[0]
 }
 
 func (db *wiredDB) GetPoolInfoByHash(hash string) *apitypes.TicketPoolInfo {
@@ -522,7 +828,14 @@ func (db *wiredDB) GetPoolInfoByHash(hash string) *apitypes.TicketPoolInfo {
 		log.Errorf("Unable to retrieve ticket pool info: %v", err)
 		return nil
 	}
-	return ticketPoolInfo
+	height, err := db.RetrieveBlockHeight(hash)
+	if err != nil {
+		log.Errorf("Unable to retrieve block height for %s: %v", hash, err)
+		return ticketPoolInfo
+	}
+	tpis := []apitypes.TicketPoolInfo{*ticketPoolInfo}
+	db.setTicketExitCounts(height, tpis)
+	return &tpis[0]
 }
 
 func (db *wiredDB) GetPoolInfoRange(idx0, idx1 int) []apitypes.TicketPoolInfo {
@@ -531,9 +844,55 @@ func (db *wiredDB) GetPoolInfoRange(idx0, idx1 int) []apitypes.TicketPoolInfo {
 		log.Errorf("Unable to retrieve ticket pool info range: %v", err)
 		return nil
 	}
+	db.setTicketExitCounts(int64(idx0), ticketPoolInfos)
 	return ticketPoolInfos
 }
 
+// setTicketExitCounts sets the running totals of missed and expired tickets
+// in the pool info for the consecutive blocks starting at height ind0.
+func (db *wiredDB) setTicketExitCounts(ind0 int64, tpis []apitypes.TicketPoolInfo) {
+	if len(tpis) == 0 {
+		return
+	}
+	missed, expired, err := db.RetrieveTicketExitCounts(ind0, ind0+int64(len(tpis))-1)
+	if err != nil {
+		log.Errorf("Unable to retrieve missed and expired ticket counts: %v", err)
+		return
+	}
+	for i := range tpis {
+		tpis[i].Missed, tpis[i].Expired = missed[i], expired[i]
+	}
+}
+
+// GetPoolDistribution returns the distribution of the current live tickets by
+// price, in buckets of priceWidth DCR, and by age, in buckets of ageWidth
+// blocks.
+func (db *wiredDB) GetPoolDistribution(priceWidth float64, ageWidth int64) *apitypes.TicketPoolDistribution {
+	if db.sDB == nil {
+		return nil
+	}
+	dist, err := db.sDB.PoolDistribution(priceWidth, ageWidth)
+	if err != nil {
+		log.Errorf("Unable to compute ticket pool distribution: %v", err)
+		return nil
+	}
+	return dist
+}
+
+// GetMissedTickets returns the hashes of the tickets called to vote on the
+// block at the given height that did not vote.
+func (db *wiredDB) GetMissedTickets(idx int) []string {
+	if int64(idx) > db.GetBestBlockHeight() {
+		return nil
+	}
+	missed, err := db.RetrieveMissedTickets(int64(idx))
+	if err != nil {
+		log.Errorf("Unable to retrieve missed tickets: %v", err)
+		return nil
+	}
+	return missed
+}
+
 func (db *wiredDB) GetPoolValAndSizeRange(idx0, idx1 int) ([]float64, []float64) {
 	poolvals, poolsizes, err := db.RetrievePoolValAndSizeRange(int64(idx0), int64(idx1))
 	if err != nil {
@@ -590,27 +949,175 @@ func (db *wiredDB) GetMempoolSSTxDetails(N int) *apitypes.MempoolTicketDetails {
 	return &mpTicketDetails
 }
 
-// GetAddressTransactions returns an apitypes.Address Object with at most the
-// last count transactions the address was in
-func (db *wiredDB) GetAddressTransactions(addr string, count int) *apitypes.Address {
-	address, err := dcrutil.DecodeAddress(addr)
+// GetMempoolSummary returns the number, size and fees of the transactions of
+// each type in mempool.
+func (db *wiredDB) GetMempoolSummary() *apitypes.MempoolSummary {
+	return db.MPT.GetSummary()
+}
+
+// GetMempoolTxs returns the transactions in mempool of the given type, or of
+// all types if txType is empty.
+func (db *wiredDB) GetMempoolTxs(txType string) *apitypes.MempoolTxs {
+	return db.MPT.GetTxs(txType)
+}
+
+// GetMempoolFeeEstimate estimates the fee rate in DCR/kB for a regular
+// transaction to be mined within the given number of blocks.
+func (db *wiredDB) GetMempoolFeeEstimate(blocks int) (*apitypes.MempoolFeeEstimate, error) {
+	return db.MPT.EstimateFee(blocks)
+}
+
+// StoreMPData stores the mempool data in the mempool cache, and records the
+// ticket fees and the regular transactions in mempool in the mempool history
+// table. StoreMPData satisfies the mempool.MempoolDataSaver interface.
+func (db *wiredDB) StoreMPData(data *mempool.MempoolData, timestamp time.Time) error {
+	if err := db.MPC.StoreMPData(data, timestamp); err != nil {
+		return err
+	}
+
+	fees := data.Ticketfees.FeeInfoMempool
+	regular := db.MPT.GetSummary().Regular
+	snap := &dbtypes.MempoolSnapshot{
+		Time:        timestamp.Unix(),
+		Height:      int64(data.Height),
+		NumTickets:  data.NumTickets,
+		FeeMin:      fees.Min,
+		FeeMean:     fees.Mean,
+		FeeMedian:   fees.Median,
+		FeeMax:      fees.Max,
+		NumRegular:  regular.Count,
+		RegularSize: regular.Size,
+	}
+	if err := db.StoreMempoolSnapshot(snap); err != nil {
+		log.Errorf("Unable to store mempool history: %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetMempoolHistory returns the mempool data collections from UNIX time from
+// to to, averaged in steps of step seconds.
+func (db *wiredDB) GetMempoolHistory(from, to, step int64) (*apitypes.MempoolHistory, error) {
+	bins, err := db.RetrieveMempoolHistory(from, to, step)
 	if err != nil {
+		return nil, err
+	}
+
+	history := &apitypes.MempoolHistory{
+		From:   from,
+		To:     to,
+		Step:   step,
+		Points: make([]apitypes.MempoolHistoryPoint, 0, len(bins)),
+	}
+	for _, b := range bins {
+		history.Points = append(history.Points, apitypes.MempoolHistoryPoint{
+			Time:        from + b.Bin*step,
+			NumSamples:  b.NumSamples,
+			Height:      b.Height,
+			NumTickets:  b.NumTickets,
+			FeeMin:      b.FeeMin,
+			FeeMean:     b.FeeMean,
+			FeeMedian:   b.FeeMedian,
+			FeeMax:      b.FeeMax,
+			NumRegular:  b.NumRegular,
+			RegularSize: b.RegularSize,
+		})
+	}
+	return history, nil
+}
+
+// GetTicketInclusion returns the statistics of the time the tickets mined in
+// the recent stake difficulty windows spent in mempool.
+func (db *wiredDB) GetTicketInclusion() []*apitypes.TicketInclusionWindow {
+	return db.MPT.GetTicketInclusion()
+}
+
+// GetMempoolBlockVotes returns the votes in mempool grouped by the block they
+// vote on.
+func (db *wiredDB) GetMempoolBlockVotes() []*apitypes.MempoolBlockVotes {
+	return db.MPT.GetBlockVotes()
+}
+
+// GetMempoolTipVotes returns the votes in mempool on the best block, or nil if
+// the winning tickets of the best block are not known.
+func (db *wiredDB) GetMempoolTipVotes() *apitypes.MempoolTipVotes {
+	return db.MPT.GetTipVotes()
+}
+
+// GetMempoolConflicts returns the transactions in mempool that spend the same
+// outpoint as another, and those recently invalidated by blocks.
+func (db *wiredDB) GetMempoolConflicts() *apitypes.MempoolConflicts {
+	return db.MPT.GetConflicts()
+}
+
+// addressTxnsWithTx gets up to count transactions involving the address from
+// the address table, skipping the newest skip transactions, and the
+// corresponding transactions from the transactions, vins and vouts tables.
+// Only the transactions that are not stored, as when the transactions tables
+// are behind the address table, are requested from the node.
+func (db *wiredDB) addressTxnsWithTx(addr string, count, skip int64) ([]*dbtypes.AddressTxn, []*apitypes.Tx, error) {
+	txns, err := db.RetrieveAddressTxns(addr, count, skip)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bestHeight := db.GetBestBlockHeight()
+	txs := make([]*apitypes.Tx, len(txns))
+	var missing []int
+	for i := range txns {
+		if t, vins, vouts := db.retrieveDBTransaction(txns[i].TxHash); t != nil {
+			txs[i] = makeAPITx(t, vins, vouts, bestHeight)
+			continue
+		}
+		missing = append(missing, i)
+	}
+	if len(missing) == 0 {
+		return txns, txs, nil
+	}
+
+	// Request all of the missing transactions before waiting for any of them
+	futures := make([]rpcclient.FutureGetRawTransactionVerboseResult, len(missing))
+	for j, i := range missing {
+		txhash, err := chainhash.NewHashFromStr(txns[i].TxHash)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid transaction hash %s", txns[i].TxHash)
+		}
+		futures[j] = db.client.GetRawTransactionVerboseAsync(txhash)
+	}
+
+	for j, i := range missing {
+		txraw, err := futures[j].Receive()
+		if err != nil {
+			return nil, nil, fmt.Errorf("GetRawTransactionVerbose failed for %s: %v",
+				txns[i].TxHash, err)
+		}
+		txs[i] = makeAPITxFromRaw(txraw)
+	}
+
+	return txns, txs, nil
+}
+
+// GetAddressTransactions returns an apitypes.Address Object with at most count
+// transactions the address was in, starting after the skip most recent
+func (db *wiredDB) GetAddressTransactions(addr string, count, skip int) *apitypes.Address {
+	if _, err := dcrutil.DecodeAddress(addr); err != nil {
 		log.Infof("Invalid address %s: %v", addr, err)
 		return nil
 	}
-	txs, err := db.client.SearchRawTransactionsVerbose(address, 0, count, false, true, nil)
+	txns, txs, err := db.addressTxnsWithTx(addr, int64(count), int64(skip))
 	if err != nil {
 		log.Warnf("GetAddressTransactions failed for address %s: %v", addr, err)
 		return nil
 	}
-	tx := make([]*apitypes.AddressTxShort, 0, len(txs))
-	for i := range txs {
+	bestHeight := db.GetBestBlockHeight()
+	tx := make([]*apitypes.AddressTxShort, 0, len(txns))
+	for i := range txns {
 		tx = append(tx, &apitypes.AddressTxShort{
-			TxID:          txs[i].Txid,
-			Time:          txs[i].Time,
-			Value:         txhelpers.TotalVout(txs[i].Vout).ToCoin(),
-			Confirmations: int64(txs[i].Confirmations),
-			Size:          int32(len(txs[i].Hex) / 2),
+			TxID:          txns[i].TxHash,
+			Time:          txns[i].BlockTime,
+			Value:         totalVout(txs[i].Vout).ToCoin(),
+			Confirmations: bestHeight - txns[i].BlockHeight + 1,
+			Size:          txs[i].Size,
 		})
 	}
 	return &apitypes.Address{
@@ -620,57 +1127,172 @@ func (db *wiredDB) GetAddressTransactions(addr string, count int) *apitypes.Addr
 
 }
 
-// GetAddressTransactions returns an array of apitypes.AddressTxRaw objects
-// representing the raw result of SearchRawTransactionsverbose
-func (db *wiredDB) GetAddressTransactionsRaw(addr string, count int) []*apitypes.AddressTxRaw {
-	address, err := dcrutil.DecodeAddress(addr)
-	if err != nil {
+// GetAddressTransactionsRaw returns an array of apitypes.AddressTxRaw objects
+// modeled after the result of SearchRawTransactionsVerbose, with the previous
+// outpoint info for each input obtained from the address table. As with
+// GetAddressTransactions, the skip most recent transactions are omitted.
+func (db *wiredDB) GetAddressTransactionsRaw(addr string, count, skip int) []*apitypes.AddressTxRaw {
+	if _, err := dcrutil.DecodeAddress(addr); err != nil {
 		log.Infof("Invalid address %s: %v", addr, err)
 		return nil
 	}
-	txs, err := db.client.SearchRawTransactionsVerbose(address, 0, count, true, true, nil)
+	txns, txs, err := db.addressTxnsWithTx(addr, int64(count), int64(skip))
 	if err != nil {
 		log.Warnf("GetAddressTransactionsRaw failed for address %s: %v", addr, err)
 		return nil
 	}
-	txarray := make([]*apitypes.AddressTxRaw, 0, len(txs))
-	for i := range txs {
+	bestHeight := db.GetBestBlockHeight()
+	txarray := make([]*apitypes.AddressTxRaw, 0, len(txns))
+	for i, t := range txs {
 		tx := new(apitypes.AddressTxRaw)
-		tx.Size = int32(len(txs[i].Hex) / 2)
-		tx.TxID = txs[i].Txid
-		tx.Version = txs[i].Version
-		tx.Locktime = txs[i].LockTime
-		tx.Vin = make([]dcrjson.VinPrevOut, len(txs[i].Vin))
-		copy(tx.Vin, txs[i].Vin)
-		tx.Confirmations = int64(txs[i].Confirmations)
-		tx.BlockHash = txs[i].BlockHash
-		tx.Blocktime = txs[i].Blocktime
-		tx.Time = txs[i].Time
-		tx.Vout = make([]apitypes.Vout, len(txs[i].Vout))
-		for j := range txs[i].Vout {
-			tx.Vout[j].Value = txs[i].Vout[j].Value
-			tx.Vout[j].N = txs[i].Vout[j].N
-			tx.Vout[j].Version = txs[i].Vout[j].Version
-			spk := &tx.Vout[j].ScriptPubKeyDecoded
-			spkRaw := &txs[i].Vout[j].ScriptPubKey
-			spk.Asm = spkRaw.Asm
-			spk.ReqSigs = spkRaw.ReqSigs
-			spk.Type = spkRaw.Type
-			spk.Addresses = make([]string, len(spkRaw.Addresses))
-			for k := range spkRaw.Addresses {
-				spk.Addresses[k] = spkRaw.Addresses[k]
-			}
-			if spkRaw.CommitAmt != nil {
-				spk.CommitAmt = new(float64)
-				*spk.CommitAmt = *spkRaw.CommitAmt
-			}
+		tx.Size = t.Size
+		tx.TxID = t.TxID
+		tx.Version = t.Version
+		tx.Locktime = t.Locktime
+		tx.Vin = make([]dcrjson.VinPrevOut, len(t.Vin))
+		for j := range t.Vin {
+			tx.Vin[j] = db.makeVinPrevOut(&t.Vin[j])
 		}
+		tx.Confirmations = bestHeight - txns[i].BlockHeight + 1
+		tx.BlockHash = t.Block.BlockHash
+		tx.Blocktime = t.Block.BlockTime
+		tx.Time = t.Block.Time
+		tx.Vout = t.Vout
 		txarray = append(txarray, tx)
 	}
 
 	return txarray
 }
 
+// makeVinPrevOut converts a dcrjson.Vin into a dcrjson.VinPrevOut, looking up
+// the addresses and value of the previous outpoint in the address table.
+func (db *wiredDB) makeVinPrevOut(vin *dcrjson.Vin) dcrjson.VinPrevOut {
+	amountIn := vin.AmountIn
+	blockHeight := vin.BlockHeight
+	blockIndex := vin.BlockIndex
+	vinPrevOut := dcrjson.VinPrevOut{
+		Coinbase:    vin.Coinbase,
+		Stakebase:   vin.Stakebase,
+		Txid:        vin.Txid,
+		Vout:        vin.Vout,
+		Tree:        vin.Tree,
+		AmountIn:    &amountIn,
+		BlockHeight: &blockHeight,
+		BlockIndex:  &blockIndex,
+		ScriptSig:   vin.ScriptSig,
+		Sequence:    vin.Sequence,
+	}
+	if vin.IsCoinBase() || vin.IsStakeBase() {
+		return vinPrevOut
+	}
+
+	addresses, value, err := db.RetrieveOutpointAddresses(vin.Txid, vin.Vout)
+	if err != nil {
+		log.Warnf("Unable to get addresses for outpoint %s:%d: %v",
+			vin.Txid, vin.Vout, err)
+		return vinPrevOut
+	}
+	vinPrevOut.PrevOut = &dcrjson.PrevOut{
+		Addresses: addresses,
+		Value:     dcrutil.Amount(value).ToCoin(),
+	}
+	return vinPrevOut
+}
+
+// mempoolAddressActivity finds the transactions in mempool paying to or
+// spending from the address using the mempool transaction tracker's address
+// index. The number of such transactions, and the net change in the address's
+// balance in atoms are returned. Mempool activity is only known when mempool
+// monitoring is enabled.
+func (db *wiredDB) mempoolAddressActivity(address string) (int, int64, error) {
+	utxos, err := db.RetrieveAddressUTXOs(address)
+	if err != nil {
+		return 0, 0, err
+	}
+	unspent := make(map[wire.OutPoint]int64, len(utxos))
+	for _, u := range utxos {
+		hash, err := chainhash.NewHashFromStr(u.TxHash)
+		if err != nil {
+			return 0, 0, err
+		}
+		unspent[wire.OutPoint{Hash: *hash, Index: u.TxIndex, Tree: u.TxTree}] = u.Value
+	}
+
+	numTxs, net := db.MPT.AddressActivity(address, unspent)
+	return numTxs, net, nil
+}
+
+// GetAddressBalance returns the confirmed balance of an address from the
+// address table, and the unconfirmed change in balance from mempool.
+func (db *wiredDB) GetAddressBalance(addr string) *apitypes.AddressBalance {
+	if _, err := dcrutil.DecodeAddress(addr); err != nil {
+		log.Infof("Invalid address %s: %v", addr, err)
+		return nil
+	}
+	totals, err := db.RetrieveAddressTotals(addr)
+	if err != nil {
+		log.Errorf("Unable to retrieve totals for address %s: %v", addr, err)
+		return nil
+	}
+	numUnconfirmed, unconfirmed, err := db.mempoolAddressActivity(addr)
+	if err != nil {
+		log.Warnf("Unable to get mempool activity for address %s: %v", addr, err)
+	}
+	return &apitypes.AddressBalance{
+		Address:     addr,
+		Confirmed:   dcrutil.Amount(totals.Unspent).ToCoin(),
+		Unconfirmed: dcrutil.Amount(unconfirmed).ToCoin(),
+		NumUnconfTx: numUnconfirmed,
+	}
+}
+
+// GetAddressTotals returns the number of transactions, and the total amounts
+// received, sent and unspent for an address.
+func (db *wiredDB) GetAddressTotals(addr string) *apitypes.AddressTotals {
+	if _, err := dcrutil.DecodeAddress(addr); err != nil {
+		log.Infof("Invalid address %s: %v", addr, err)
+		return nil
+	}
+	totals, err := db.RetrieveAddressTotals(addr)
+	if err != nil {
+		log.Errorf("Unable to retrieve totals for address %s: %v", addr, err)
+		return nil
+	}
+	return &apitypes.AddressTotals{
+		Address:  addr,
+		NumTxns:  totals.NumTxns,
+		Received: dcrutil.Amount(totals.Received).ToCoin(),
+		Sent:     dcrutil.Amount(totals.Sent).ToCoin(),
+		Unspent:  dcrutil.Amount(totals.Unspent).ToCoin(),
+	}
+}
+
+// GetAddressUTXO returns the unspent outputs paying to an address.
+func (db *wiredDB) GetAddressUTXO(addr string) []*apitypes.AddressUTXO {
+	if _, err := dcrutil.DecodeAddress(addr); err != nil {
+		log.Infof("Invalid address %s: %v", addr, err)
+		return nil
+	}
+	outs, err := db.RetrieveAddressUTXOs(addr)
+	if err != nil {
+		log.Errorf("Unable to retrieve UTXOs for address %s: %v", addr, err)
+		return nil
+	}
+	bestHeight := db.GetBestBlockHeight()
+	utxos := make([]*apitypes.AddressUTXO, 0, len(outs))
+	for _, o := range outs {
+		utxos = append(utxos, &apitypes.AddressUTXO{
+			TxID:          o.TxHash,
+			Vout:          o.TxIndex,
+			Tree:          o.TxTree,
+			Amount:        dcrutil.Amount(o.Value).ToCoin(),
+			Height:        o.BlockHeight,
+			Confirmations: bestHeight - o.BlockHeight + 1,
+		})
+	}
+	return utxos
+}
+
 func makeExplorerBlockBasic(data *dcrjson.GetBlockVerboseResult) *explorer.BlockBasic {
 	block := &explorer.BlockBasic{
 		Height:         data.Height,
@@ -723,30 +1345,17 @@ func makeExplorerTxBasic(data dcrjson.TxRawResult, msgTx *wire.MsgTx, params *ch
 	return tx
 }
 
-func makeExplorerAddressTx(data *dcrjson.SearchRawTransactionsResult, address string) *explorer.AddressTx {
+func makeExplorerAddressTx(txn *dbtypes.AddressTxn, data *apitypes.Tx, bestHeight int64) *explorer.AddressTx {
 	tx := new(explorer.AddressTx)
-	tx.TxID = data.Txid
-	tx.FormattedSize = humanize.Bytes(uint64(len(data.Hex) / 2))
-	tx.Total = txhelpers.TotalVout(data.Vout).ToCoin()
-	tx.Time = data.Time
+	tx.TxID = txn.TxHash
+	tx.FormattedSize = humanize.Bytes(uint64(data.Size))
+	tx.Total = totalVout(data.Vout).ToCoin()
+	tx.Time = txn.BlockTime
 	t := time.Unix(tx.Time, 0)
 	tx.FormattedTime = t.Format("1/_2/06 15:04:05")
-	tx.Confirmations = data.Confirmations
-
-	for i := range data.Vin {
-		if data.Vin[i].PrevOut != nil && len(data.Vin[i].PrevOut.Addresses) > 0 {
-			if data.Vin[i].PrevOut.Addresses[0] == address {
-				tx.SentTotal += *data.Vin[i].AmountIn
-			}
-		}
-	}
-	for i := range data.Vout {
-		if len(data.Vout[i].ScriptPubKey.Addresses) != 0 {
-			if data.Vout[i].ScriptPubKey.Addresses[0] == address {
-				tx.RecievedTotal += data.Vout[i].Value
-			}
-		}
-	}
+	tx.Confirmations = uint64(bestHeight - txn.BlockHeight + 1)
+	tx.RecievedTotal = dcrutil.Amount(txn.Received).ToCoin()
+	tx.SentTotal = dcrutil.Amount(txn.Sent).ToCoin()
 	return tx
 }
 
@@ -928,6 +1537,18 @@ func (db *wiredDB) GetExplorerTx(txid string) *explorer.TxInfo {
 			tx.Mature = "False"
 		}
 	}
+	if tx.Type == "Ticket" {
+		if ticket := db.GetTicketInfo(txid); ticket != nil {
+			tx.Ticket = &explorer.TicketInfo{
+				Status:           ticket.Status,
+				MaturityHeight:   ticket.MaturityHeight,
+				ExpirationHeight: ticket.ExpirationHeight,
+				PoolExitHeight:   ticket.PoolExitHeight,
+				SpendHeight:      ticket.SpendHeight,
+				SpendingTxID:     ticket.SpendingTxID,
+			}
+		}
+	}
 	if tx.Type == "Vote" {
 		if tx.Confirmations < int64(db.params.CoinbaseMaturity) {
 			tx.VoteFundsLocked = "True"
@@ -945,72 +1566,67 @@ func (db *wiredDB) GetExplorerTx(txid string) *explorer.TxInfo {
 		if strings.Contains(vout.ScriptPubKey.Asm, "OP_RETURN") {
 			opReturn = vout.ScriptPubKey.Asm
 		}
-		outputs = append(outputs, explorer.Vout{
+		output := explorer.Vout{
 			Addresses:       vout.ScriptPubKey.Addresses,
 			Amount:          vout.Value,
 			FormattedAmount: humanize.Commaf(vout.Value),
 			OP_RETURN:       opReturn,
 			Type:            vout.ScriptPubKey.Type,
 			Spent:           txout == nil,
-		})
+		}
+		// Link to the spending transaction if it is mined.
+		if output.Spent {
+			spendingTxHash, vin, height, err := db.RetrieveSpendingTx(txid, uint32(i))
+			if err == nil && height >= 0 {
+				output.SpendingTxID = spendingTxHash
+				output.SpendingVin = vin
+			}
+		}
+		outputs = append(outputs, output)
 	}
 	tx.Vout = outputs
 	return tx
 }
 
-func (db *wiredDB) GetExplorerAddress(address string, count int) *explorer.AddressInfo {
-	addr, err := dcrutil.DecodeAddress(address)
-	if err != nil {
+func (db *wiredDB) GetExplorerAddress(address string, count, offset int) *explorer.AddressInfo {
+	if _, err := dcrutil.DecodeAddress(address); err != nil {
 		log.Infof("Invalid address %s: %v", address, err)
 		return nil
 	}
 
-	txs, err := db.client.SearchRawTransactionsVerbose(addr, 0, count, true, true, nil)
+	txns, txs, err := db.addressTxnsWithTx(address, int64(count), int64(offset))
 	if err != nil {
-		log.Warnf("GetAddressTransactionsRaw failed for address %s: %v", addr, err)
+		log.Warnf("GetExplorerAddress failed for address %s: %v", address, err)
 		return nil
 	}
 
-	addressTxs := make([]*explorer.AddressTx, 0, len(txs))
-	for _, tx := range txs {
-		addressTxs = append(addressTxs, makeExplorerAddressTx(tx, address))
+	bestHeight := db.GetBestBlockHeight()
+	addressTxs := make([]*explorer.AddressTx, 0, len(txns))
+	for i := range txns {
+		addressTxs = append(addressTxs, makeExplorerAddressTx(txns[i], txs[i], bestHeight))
 	}
 
-	NumberOfTx := len(txs)
-	NoOfUnconfirmed := 0
-	var totalreceived, totalsent dcrutil.Amount
-	if NumberOfTx < explorer.AddressRows {
-		for _, tx := range txs {
-			if tx.Confirmations == 0 {
-				NoOfUnconfirmed++
-			}
-			for _, y := range tx.Vout {
-				if len(y.ScriptPubKey.Addresses) != 0 {
-					if address == y.ScriptPubKey.Addresses[0] {
-						t, _ := dcrutil.NewAmount(y.Value)
-						totalreceived += t
-					}
-				}
-			}
-			for _, u := range tx.Vin {
-				if u.PrevOut != nil && len(u.PrevOut.Addresses) != 0 {
-					if address == u.PrevOut.Addresses[0] {
-						t, _ := dcrutil.NewAmount(*u.AmountIn)
-						totalsent += t
-					}
-				}
-			}
-		}
+	totals, err := db.RetrieveAddressTotals(address)
+	if err != nil {
+		log.Warnf("Unable to get totals for address %s: %v", address, err)
+		return nil
 	}
-	unspentfunds := totalreceived - totalsent
+
+	numUnconfirmed, _, err := db.mempoolAddressActivity(address)
+	if err != nil {
+		log.Warnf("Unable to get mempool activity for address %s: %v", address, err)
+	}
+
 	return &explorer.AddressInfo{
 		Address:          address,
 		Transactions:     addressTxs,
-		NumTransactions:  NumberOfTx,
-		TotalUnconfirmed: NoOfUnconfirmed,
-		Received:         totalreceived,
+		NumTransactions:  int(totals.NumTxns),
+		TotalUnconfirmed: numUnconfirmed,
+		Received:         dcrutil.Amount(totals.Received),
 		AddressRow:       explorer.AddressRows,
-		TotalSent:        totalsent,
-		UnSpent:          unspentfunds,
+		Limit:            count,
+		Offset:           offset,
+		TotalSent:        dcrutil.Amount(totals.Sent),
+		UnSpent:          dcrutil.Amount(totals.Unspent),
 	}
 }
@@ -0,0 +1,80 @@
+package dcrsqlite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dcrdata/dcrdata/chaindb"
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/wire"
+)
+
+func TestAddressTransactionsFromDB(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	addr1, script1 := testAddress(t, 1)
+	_, script2 := testAddress(t, 2)
+	coinbase := func(script []byte) *wire.MsgTx {
+		tx := wire.NewMsgTx()
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex, wire.TxTreeRegular), nil))
+		tx.AddTxOut(wire.NewTxOut(10, script))
+		return tx
+	}
+
+	// Block 1 pays 10 atoms to addr1, and block 2 spends it.
+	fund := coinbase(script1)
+	fundHash := fund.TxHash()
+	spend := wire.NewMsgTx()
+	spend.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&fundHash, 0, wire.TxTreeRegular), nil))
+	spend.AddTxOut(wire.NewTxOut(7, script2))
+	block1 := &wire.MsgBlock{
+		Header:       wire.BlockHeader{Height: 1, Timestamp: time.Unix(1500000000, 0)},
+		Transactions: []*wire.MsgTx{fund},
+	}
+	block2 := &wire.MsgBlock{
+		Header:       wire.BlockHeader{Height: 2, Timestamp: time.Unix(1500000300, 0)},
+		Transactions: []*wire.MsgTx{coinbase(script2), spend},
+	}
+	blocks := []*chaindb.BlockIndex{
+		chaindb.NewBlockIndex(block1, &chaincfg.SimNetParams),
+		chaindb.NewBlockIndex(block2, &chaincfg.SimNetParams),
+	}
+	if err := db.StoreBlockBatch(nil, nil, blocks); err != nil {
+		t.Fatalf("StoreBlockBatch failed: %v", err)
+	}
+
+	// The wiredDB has no node, so the transactions must come from the DB.
+	wdb := newTestWiredDB(db)
+	wdb.params = &chaincfg.SimNetParams
+	txs := wdb.GetAddressTransactionsRaw(addr1, 10, 0)
+	if len(txs) != 2 {
+		t.Fatalf("Expected 2 transactions for %s, got %d", addr1, len(txs))
+	}
+	if txs[0].TxID != spend.TxHash().String() || txs[1].TxID != fundHash.String() {
+		t.Fatalf("Unexpected transactions %s, %s", txs[0].TxID, txs[1].TxID)
+	}
+	if len(txs[0].Vout) != 1 || txs[0].Vout[0].Value != 7e-8 {
+		t.Errorf("Unexpected outputs of the spending transaction: %v", txs[0].Vout)
+	}
+	if len(txs[0].Vin) != 1 || txs[0].Vin[0].PrevOut == nil {
+		t.Fatalf("Expected the previous outpoint of the spending input")
+	}
+	prevOut := txs[0].Vin[0].PrevOut
+	if len(prevOut.Addresses) != 1 || prevOut.Addresses[0] != addr1 || prevOut.Value != 1e-7 {
+		t.Errorf("Unexpected previous outpoint %v", *prevOut)
+	}
+	if txs[0].BlockHash != block2.BlockHash().String() {
+		t.Errorf("Unexpected block %s of the spending transaction", txs[0].BlockHash)
+	}
+
+	short := wdb.GetAddressTransactions(addr1, 10, 0)
+	if short == nil || len(short.Transactions) != 2 {
+		t.Fatalf("Expected 2 transactions for %s, got %v", addr1, short)
+	}
+	if short.Transactions[1].Value != 1e-7 || short.Transactions[1].Size != int32(fund.SerializeSize()) {
+		t.Errorf("Unexpected funding transaction %v", *short.Transactions[1])
+	}
+}
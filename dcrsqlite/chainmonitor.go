@@ -129,6 +129,12 @@ out:
 				p.reorgLock.Unlock()
 				log.Infof("Reorganization to block %v (height %d) complete",
 					p.reorgData.NewChainHead, p.reorgData.NewChainHeight)
+			} else {
+				// Regular block data is stored by the blockdata chain monitor,
+				// but the address and transaction tables are maintained here.
+				if err := p.db.indexBlockByHash(hash); err != nil {
+					log.Errorf("Failed to index block %v: %v", hash, err)
+				}
 			}
 			release()
 
@@ -151,28 +157,43 @@ func (p *ChainMonitor) switchToSideChain() (int32, *chainhash.Hash, error) {
 
 	// Update DBs, just overwrite
 
-	/* // Determine highest common ancestor of side chain and main chain
-	block, err := p.db.client.GetBlock(&p.sideChain[0])
+	// Determine highest common ancestor of side chain and main chain
+	header, err := p.db.client.GetBlockHeader(&p.sideChain[0])
 	if err != nil {
-		return 0, nil, fmt.Errorf("unable to get block at root of side chain")
+		return 0, nil, fmt.Errorf("unable to get block header at root of side chain")
 	}
-
-	prevBlock, err := p.db.client.GetBlock(&block.MsgBlock().Header.PrevBlock)
-	if err != nil {
-		return 0, nil, fmt.Errorf("unable to get common ancestor on side chain")
+	commonAncestorHeight := int64(header.Height) - 1
+
+	// The address and transaction tables are not simply overwritten since the
+	// old main chain's transactions, outputs and spends must be removed. Roll
+	// them back to the common ancestor, then index the side chain blocks.
+	log.Infof("Rolling back address table to height %d.", commonAncestorHeight)
+	if err = p.db.DeleteAddressesAboveHeight(commonAncestorHeight); err != nil {
+		return 0, nil, fmt.Errorf("unable to roll back address table: %v", err)
 	}
-
-	commonAncestorHeight := block.Height() - 1
-	if prevBlock.Height() != commonAncestorHeight {
-		panic("Failed to determine common ancestor.")
+	log.Infof("Rolling back transaction tables to height %d.", commonAncestorHeight)
+	if err = p.db.DeleteTransactionsAboveHeight(commonAncestorHeight); err != nil {
+		return 0, nil, fmt.Errorf("unable to roll back transaction tables: %v", err)
+	}
+	p.db.purgeStakeDiffWindows(commonAncestorHeight)
+	log.Infof("Rolling back tickets table to height %d.", commonAncestorHeight)
+	if err = p.db.DeleteTicketsAboveHeight(commonAncestorHeight); err != nil {
+		return 0, nil, fmt.Errorf("unable to roll back tickets table: %v", err)
+	}
+	log.Infof("Rolling back votes table to height %d.", commonAncestorHeight)
+	if err = p.db.DeleteVotesAboveHeight(commonAncestorHeight); err != nil {
+		return 0, nil, fmt.Errorf("unable to roll back votes table: %v", err)
+	}
+	log.Infof("Rolling back block versions table to height %d.", commonAncestorHeight)
+	if err = p.db.DeleteBlockVersionsAboveHeight(commonAncestorHeight); err != nil {
+		return 0, nil, fmt.Errorf("unable to roll back block versions table: %v", err)
+	}
+	for i := range p.sideChain {
+		if err = p.db.indexBlockByHash(&p.sideChain[i]); err != nil {
+			log.Errorf("Failed to index side chain block %v: %v",
+				p.sideChain[i], err)
+		}
 	}
-
-	mainTip := int64(p.db.GetHeight())
-	numOverwrittenBlocks := mainTip - commonAncestorHeight
-
-	// Disconnect blocks back to common ancestor
-	log.Debugf("Overwriting data for %d blocks from main chain.", numOverwrittenBlocks)
-	*/
 
 	// Save blocks from previous side chain that is now the main chain
 	log.Infof("Saving %d new blocks from previous side chain to sqlite", len(p.sideChain))
@@ -0,0 +1,284 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package dcrsqlite
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+
+	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/decred/dcrd/dcrutil"
+)
+
+// CheckReport summarizes the results of a check of the block summary and
+// extended stake info tables against the node's main chain and the stake DB.
+// Heights with problems are listed for each kind of problem found.
+type CheckReport struct {
+	BestHeight int64
+	Checked    int64
+	// MissingSummaries and MissingStakeInfo are heights with no row, i.e. gaps
+	// in the tables.
+	MissingSummaries []int64
+	MissingStakeInfo []int64
+	// HashMismatches are heights where the stored block hash is not that of
+	// the main chain block.
+	HashMismatches []int64
+	// PoolMismatches are heights where the stored ticket pool info does not
+	// match the block header pool size or the stake DB pool value.
+	PoolMismatches []int64
+	// PoolUnverified counts the heights for which the stake DB has no pool
+	// value, so that only the pool size was checked.
+	PoolUnverified int64
+	// Repaired counts the heights with rows rewritten in repair mode.
+	Repaired int64
+	// Unrepaired are heights with a problem that were left alone in repair
+	// mode because the pool value is unknown, being neither in the stake DB
+	// nor in a stored row for the right block. RebuildPoolInfo recomputes it.
+	Unrepaired []int64
+}
+
+// NumProblems returns the number of heights with a problem of any kind.
+func (r *CheckReport) NumProblems() int {
+	return len(r.mismatchedHeights())
+}
+
+// mismatchedHeights returns the set of heights with a problem of any kind.
+func (r *CheckReport) mismatchedHeights() map[int64]struct{} {
+	heights := make(map[int64]struct{})
+	for _, list := range [][]int64{r.MissingSummaries, r.MissingStakeInfo,
+		r.HashMismatches, r.PoolMismatches} {
+		for _, h := range list {
+			heights[h] = struct{}{}
+		}
+	}
+	return heights
+}
+
+func (r *CheckReport) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Checked %d blocks (best height %d).\n", r.Checked, r.BestHeight)
+	writeHeights := func(desc string, heights []int64) {
+		fmt.Fprintf(&b, "%s: %d", desc, len(heights))
+		if len(heights) > 0 {
+			fmt.Fprintf(&b, " %v", heights)
+		}
+		b.WriteString("\n")
+	}
+	writeHeights("Missing block summaries", r.MissingSummaries)
+	writeHeights("Missing stake info", r.MissingStakeInfo)
+	writeHeights("Block hash mismatches", r.HashMismatches)
+	writeHeights("Ticket pool mismatches", r.PoolMismatches)
+	fmt.Fprintf(&b, "Pool values not in stake DB (size checked only): %d\n",
+		r.PoolUnverified)
+	fmt.Fprintf(&b, "Repaired: %d\n", r.Repaired)
+	writeHeights("Not repaired, pool value unknown", r.Unrepaired)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// checkRows checks the stored block summary and extended stake info rows at
+// height against the main chain block hash and the expected ticket pool info,
+// recording any problem in the report. A nil row is missing. The pool value
+// is only compared if found, i.e. if the stake DB has it. The return value
+// indicates if there is a problem at this height.
+func (r *CheckReport) checkRows(height int64, hash string, tpi *apitypes.TicketPoolInfo,
+	found bool, summary *apitypes.BlockDataBasic, stakeInfo *apitypes.StakeInfoExtended) bool {
+	poolMismatch := func(pi *apitypes.TicketPoolInfo) bool {
+		return pi.Size != tpi.Size || (found && !poolInfoMatches(pi, tpi))
+	}
+
+	problem := false
+	switch {
+	case summary == nil:
+		r.MissingSummaries = append(r.MissingSummaries, height)
+		problem = true
+	case summary.Hash != hash:
+		r.HashMismatches = append(r.HashMismatches, height)
+		problem = true
+	case poolMismatch(&summary.PoolInfo):
+		r.PoolMismatches = append(r.PoolMismatches, height)
+		problem = true
+	}
+
+	switch {
+	case stakeInfo == nil:
+		r.MissingStakeInfo = append(r.MissingStakeInfo, height)
+		problem = true
+	case !problem && poolMismatch(&stakeInfo.PoolInfo):
+		r.PoolMismatches = append(r.PoolMismatches, height)
+		problem = true
+	}
+
+	return problem
+}
+
+// repairPoolInfo returns the ticket pool info with which to rewrite the rows
+// at a height with a problem, given the expected pool info and the stored
+// block summary, or false if the pool value is unknown. Without the value
+// from the stake DB (found), the stored value is used only if the summary is
+// for the right block and has the right pool size.
+func repairPoolInfo(hash string, tpi *apitypes.TicketPoolInfo, found bool,
+	summary *apitypes.BlockDataBasic) (*apitypes.TicketPoolInfo, bool) {
+	if found {
+		return tpi, true
+	}
+	if summary == nil || summary.Hash != hash || summary.PoolInfo.Size != tpi.Size {
+		return nil, false
+	}
+	return &summary.PoolInfo, true
+}
+
+// poolInfoMatches compares ticket pool info, allowing the rounding error of a
+// float64 coin amount.
+func poolInfoMatches(a, b *apitypes.TicketPoolInfo) bool {
+	const eps = 1e-8
+	return a.Size == b.Size && math.Abs(a.Value-b.Value) < eps &&
+		math.Abs(a.ValAvg-b.ValAvg) < eps
+}
+
+// CheckDB walks the block summary and extended stake info tables from genesis
+// to the best stored height, verifying that heights are contiguous, that the
+// block hashes are those of the node's main chain, and that ticket pool info
+// matches the block headers and the stake DB. The stake DB only has pool
+// values for blocks it connected in this session and for its best block, so
+// the pool value at other heights is not verified. In repair mode, the rows at
+// each height with a problem are rewritten from the node's block data, except
+// where the pool value is unknown, which are listed in the report's
+// Unrepaired heights.
+func (db *wiredDB) CheckDB(repair bool, quit chan struct{}) (*CheckReport, error) {
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	_, nodeHeight, err := db.client.GetBestBlock()
+	if err != nil {
+		return nil, fmt.Errorf("GetBestBlock failed: %v", err)
+	}
+
+	report := &CheckReport{
+		BestHeight: db.GetBlockSummaryHeight(),
+	}
+	if stakeHeight := db.GetStakeInfoHeight(); stakeHeight > report.BestHeight {
+		report.BestHeight = stakeHeight
+	}
+	if report.BestHeight > nodeHeight {
+		log.Warnf("Database height %d is beyond the node's best block %d. "+
+			"Only checking to height %d.", report.BestHeight, nodeHeight, nodeHeight)
+		report.BestHeight = nodeHeight
+	}
+
+	var sdbHeight int64 = -1
+	if db.sDB != nil && db.sDB.BestNode != nil {
+		sdbHeight = int64(db.sDB.Height())
+	}
+
+	log.Infof("Checking database from height 0 to %d...", report.BestHeight)
+
+	for i := int64(0); i <= report.BestHeight; i++ {
+		// check for quit signal
+		select {
+		case <-quit:
+			log.Infof("Check cancelled at height %d.", i)
+			return report, nil
+		default:
+		}
+
+		if i%rescanLogBlockChunk == 0 {
+			log.Infof("Checking blocks %d to %d...", i, i+rescanLogBlockChunk)
+		}
+
+		block, blockhash, err := db.getBlock(i)
+		if err != nil {
+			return report, err
+		}
+		report.Checked++
+
+		// Expected pool info. The value is only known if the stake DB has it.
+		var tpi *apitypes.TicketPoolInfo
+		var found bool
+		if db.sDB != nil {
+			tpi, found = db.sDB.PoolInfo(*blockhash)
+			if !found && i == sdbHeight {
+				poolInfo, _ := db.sDB.PoolInfoBest()
+				tpi, found = &poolInfo, true
+			}
+		}
+		if !found {
+			report.PoolUnverified++
+			tpi = &apitypes.TicketPoolInfo{
+				Size: block.MsgBlock().Header.PoolSize,
+			}
+		}
+
+		if err = db.checkBlock(report, block, tpi, found, repair); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// checkBlock checks the stored rows for the main chain block against the
+// expected ticket pool info, recording any problem in the report, and repairs
+// them in repair mode if the pool value is known.
+func (db *wiredDB) checkBlock(report *CheckReport, block *dcrutil.Block,
+	tpi *apitypes.TicketPoolInfo, found, repair bool) error {
+	height := block.Height()
+
+	summary, err := db.RetrieveBlockSummary(height)
+	if err == sql.ErrNoRows {
+		summary = nil
+	} else if err != nil {
+		return fmt.Errorf("RetrieveBlockSummary(%d) failed: %v", height, err)
+	}
+
+	stakeInfo, err := db.RetrieveStakeInfoExtended(height)
+	if err == sql.ErrNoRows {
+		stakeInfo = nil
+	} else if err != nil {
+		return fmt.Errorf("RetrieveStakeInfoExtended(%d) failed: %v", height, err)
+	}
+
+	hash := block.Hash().String()
+	if !report.checkRows(height, hash, tpi, found, summary, stakeInfo) || !repair {
+		return nil
+	}
+
+	// Rewriting the rows with a zero pool value would replace one problem with
+	// another, so heights without a known value are only reported.
+	if tpi, found = repairPoolInfo(hash, tpi, found, summary); !found {
+		log.Warnf("Unable to repair height %d: ticket pool value unknown.", height)
+		report.Unrepaired = append(report.Unrepaired, height)
+		return nil
+	}
+	if err = db.repairBlock(block, tpi); err != nil {
+		return err
+	}
+	report.Repaired++
+	return nil
+}
+
+// repairBlock rewrites the block summary and extended stake info rows for the
+// block with the given ticket pool info.
+func (db *wiredDB) repairBlock(block *dcrutil.Block, tpi *apitypes.TicketPoolInfo) error {
+	height := block.MsgBlock().Header.Height
+	log.Infof("Repairing block summary and stake info at height %d.", height)
+	db.purgeStakeDiffWindows(int64(height) - 1)
+
+	blockSummary := db.makeBlockSummary(block, tpi)
+	if err := db.StoreBlockSummary(blockSummary); err != nil {
+		return fmt.Errorf("Unable to store block summary in database: %v", err)
+	}
+
+	si, err := db.makeStakeInfo(block, tpi)
+	if err != nil {
+		return err
+	}
+	if err = db.StoreStakeInfoExtended(si); err != nil {
+		return fmt.Errorf("Unable to store stake info in database: %v", err)
+	}
+	return nil
+}
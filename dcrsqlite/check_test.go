@@ -0,0 +1,178 @@
+package dcrsqlite
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/dcrd/wire"
+)
+
+// openTestDB opens a new in-memory DB at the current schema version.
+func openTestDB(t *testing.T) *DB {
+	db := openMemDB(t)
+	if err := upgradeSchema(db); err != nil {
+		t.Fatalf("upgradeSchema failed: %v", err)
+	}
+	return NewDB(db)
+}
+
+// newTestWiredDB creates a wiredDB for the DB without a node or stake DB.
+func newTestWiredDB(db *DB) *wiredDB {
+	return &wiredDB{
+		DBDataSaver:   &DBDataSaver{db, nil},
+		params:        &chaincfg.MainNetParams,
+		sdiffWindows:  NewStakeDiffWindowCache(),
+		syncBatchSize: DefaultSyncBatchSize,
+	}
+}
+
+// testBlock creates an empty block at the given height and pool size.
+func testBlock(height int64, poolSize uint32) *dcrutil.Block {
+	return dcrutil.NewBlock(&wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Height:   uint32(height),
+			PoolSize: poolSize,
+			SBits:    2e8,
+		},
+	})
+}
+
+func TestCheckBlockRepair(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	wdb := newTestWiredDB(db)
+
+	// The stake DB has the pool value of heights 0 and 1 only.
+	const numBlocks = 4
+	found := []bool{true, true, false, false}
+	blocks := make([]*dcrutil.Block, numBlocks)
+	poolInfos := make([]*apitypes.TicketPoolInfo, numBlocks)
+	for i := range blocks {
+		size := uint32(10 + i)
+		blocks[i] = testBlock(int64(i), size)
+		poolInfos[i] = &apitypes.TicketPoolInfo{
+			Size:   size,
+			Value:  float64(100 * (i + 1)),
+			ValAvg: float64(100*(i+1)) / float64(size),
+		}
+		if err := wdb.repairBlock(blocks[i], poolInfos[i]); err != nil {
+			t.Fatalf("repairBlock(%d) failed: %v", i, err)
+		}
+	}
+
+	// expected returns the pool info the check is given for height i, which
+	// has only the size if the stake DB does not have the value.
+	expected := func(i int) *apitypes.TicketPoolInfo {
+		if found[i] {
+			return poolInfos[i]
+		}
+		return &apitypes.TicketPoolInfo{Size: poolInfos[i].Size}
+	}
+
+	check := func(repair bool) *CheckReport {
+		report := new(CheckReport)
+		for i, block := range blocks {
+			err := wdb.checkBlock(report, block, expected(i), found[i], repair)
+			if err != nil {
+				t.Fatalf("checkBlock(%d) failed: %v", i, err)
+			}
+		}
+		return report
+	}
+
+	if report := check(false); report.NumProblems() != 0 {
+		t.Fatalf("problems found in a good database:\n%v", report)
+	}
+
+	// Corrupt the pool value of a block the stake DB knows, the pool size of
+	// a block it does not, and the hash of another block it does not.
+	for _, stmt := range []string{
+		fmt.Sprintf(`UPDATE %s SET poolval = 0 WHERE height = 1`, TableNameSummaries),
+		fmt.Sprintf(`UPDATE %s SET pool_size = 0 WHERE height = 2`, TableNameStakeInfo),
+		fmt.Sprintf(`UPDATE %s SET hash = 'abc' WHERE height = 3`, TableNameSummaries),
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report := check(false)
+	if !reflect.DeepEqual(report.PoolMismatches, []int64{1, 2}) {
+		t.Errorf("pool mismatches %v, expected [1 2]", report.PoolMismatches)
+	}
+	if !reflect.DeepEqual(report.HashMismatches, []int64{3}) {
+		t.Errorf("hash mismatches %v, expected [3]", report.HashMismatches)
+	}
+	if report.Repaired != 0 || len(report.Unrepaired) != 0 {
+		t.Errorf("rows repaired in check mode:\n%v", report)
+	}
+
+	// The pool value of height 3 is unknown, so it is not repaired.
+	report = check(true)
+	if report.Repaired != 2 {
+		t.Errorf("%d heights repaired, expected 2", report.Repaired)
+	}
+	if !reflect.DeepEqual(report.Unrepaired, []int64{3}) {
+		t.Errorf("unrepaired heights %v, expected [3]", report.Unrepaired)
+	}
+
+	report = check(false)
+	if report.NumProblems() != 1 || !reflect.DeepEqual(report.HashMismatches, []int64{3}) {
+		t.Errorf("unexpected problems after repair:\n%v", report)
+	}
+
+	// The repaired rows have the stake DB's value, or the stored value.
+	for i := int64(1); i <= 2; i++ {
+		summary, err := db.RetrieveBlockSummary(i)
+		if err != nil {
+			t.Fatalf("RetrieveBlockSummary(%d) failed: %v", i, err)
+		}
+		stakeInfo, err := db.RetrieveStakeInfoExtended(i)
+		if err != nil {
+			t.Fatalf("RetrieveStakeInfoExtended(%d) failed: %v", i, err)
+		}
+		for _, pi := range []*apitypes.TicketPoolInfo{&summary.PoolInfo, &stakeInfo.PoolInfo} {
+			if !poolInfoMatches(pi, poolInfos[i]) {
+				t.Errorf("pool info at height %d is %v, expected %v", i, *pi, *poolInfos[i])
+			}
+		}
+	}
+}
+
+func TestRepairPoolInfo(t *testing.T) {
+	tpi := &apitypes.TicketPoolInfo{Size: 10}
+	stored := &apitypes.BlockDataBasic{
+		Hash:     "abc",
+		PoolInfo: apitypes.TicketPoolInfo{Size: 10, Value: 100, ValAvg: 10},
+	}
+
+	tests := []struct {
+		name    string
+		hash    string
+		found   bool
+		summary *apitypes.BlockDataBasic
+		want    *apitypes.TicketPoolInfo
+	}{
+		{"stake DB value", "abc", true, stored, tpi},
+		{"stored value", "abc", false, stored, &stored.PoolInfo},
+		{"missing summary", "abc", false, nil, nil},
+		{"wrong block", "def", false, stored, nil},
+		{"wrong size", "abc", false, &apitypes.BlockDataBasic{Hash: "abc",
+			PoolInfo: apitypes.TicketPoolInfo{Size: 9, Value: 90}}, nil},
+	}
+
+	for _, tt := range tests {
+		got, ok := repairPoolInfo(tt.hash, tpi, tt.found, tt.summary)
+		if ok != (tt.want != nil) {
+			t.Errorf("%s: ok = %v", tt.name, ok)
+			continue
+		}
+		if ok && got != tt.want {
+			t.Errorf("%s: pool info %v, expected %v", tt.name, *got, *tt.want)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package dcrsqlite
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// TableNameMeta is name of the table used to store database metadata such as
+// the schema version
+const TableNameMeta = "dcrdata_meta"
+
+// migration upgrades the database schema by one version. The upgrade is run
+// in a SQL transaction along with the update of the stored schema version.
+type migration struct {
+	description string
+	upgrade     func(tx *sql.Tx) error
+}
+
+// migrations is the registry of schema migrations. migrations[i] upgrades a
+// database from version i to version i+1, so the current schema version is
+// len(migrations). New migrations must only be appended.
+var migrations = []migration{
+	{"create block summary and extended stake info tables", createSummaryTables},
+	{"create address index table", createAddressesTable},
+	{"create transactions, vins and vouts tables", createTransactionTables},
+	{"create tickets table", createTicketsTable},
+	{"create votes table", createVotesTable},
+	{"create block versions table", createVersionsTable},
+	{"create mempool history table", createMempoolHistoryTable},
+}
+
+// schemaVersion is the version of the database schema used by this package.
+var schemaVersion = len(migrations)
+
+// execStmt executes a SQL statement in the transaction, logging the
+// statement on failure.
+func execStmt(tx *sql.Tx, stmt string) error {
+	_, err := tx.Exec(stmt)
+	if err != nil {
+		log.Errorf("%q: %s\n", err, stmt)
+	}
+	return err
+}
+
+// createSummaryTables creates the tables of the original schema.
+func createSummaryTables(tx *sql.Tx) error {
+	createBlockSummaryStmt := fmt.Sprintf(`
+        create table if not exists %s(
+            height INTEGER PRIMARY KEY,
+            size INTEGER,
+            hash TEXT,
+            diff FLOAT,
+            sdiff FLOAT,
+            time INTEGER,
+            poolsize INTEGER,
+            poolval FLOAT,
+            poolavg FLOAT
+        );
+        `, TableNameSummaries)
+
+	if err := execStmt(tx, createBlockSummaryStmt); err != nil {
+		return err
+	}
+
+	createStakeInfoExtendedStmt := fmt.Sprintf(`
+        create table if not exists %s(
+            height INTEGER PRIMARY KEY,
+            num_tickets INTEGER,
+            fee_min FLOAT, fee_max FLOAT, fee_mean FLOAT,
+            fee_med FLOAT, fee_std FLOAT,
+            sdiff FLOAT, window_num INTEGER, window_ind INTEGER,
+            pool_size INTEGER, pool_val FLOAT, pool_valavg FLOAT
+        );
+        `, TableNameStakeInfo)
+
+	return execStmt(tx, createStakeInfoExtendedStmt)
+}
+
+// createAddressesTable creates the address index table.
+func createAddressesTable(tx *sql.Tx) error {
+	createAddressesStmt := fmt.Sprintf(`
+        create table if not exists %[1]s(
+            address TEXT,
+            tx_hash TEXT, tx_index INTEGER, tx_tree INTEGER,
+            value INTEGER,
+            block_height INTEGER, block_time INTEGER,
+            spending_tx_hash TEXT DEFAULT '',
+            spending_tx_index INTEGER DEFAULT -1,
+            spending_height INTEGER DEFAULT -1,
+            spending_time INTEGER DEFAULT 0,
+            PRIMARY KEY (address, tx_hash, tx_index)
+        );
+        create index if not exists %[1]s_outpoint_idx on %[1]s(tx_hash, tx_index);
+        create index if not exists %[1]s_height_idx on %[1]s(block_height);
+        create index if not exists %[1]s_spending_idx on %[1]s(spending_height);
+        `, TableNameAddresses)
+
+	return execStmt(tx, createAddressesStmt)
+}
+
+// createTransactionTables creates the transactions, vins and vouts tables.
+func createTransactionTables(tx *sql.Tx) error {
+	createTransactionsStmt := fmt.Sprintf(`
+        create table if not exists %[1]s(
+            tx_hash TEXT PRIMARY KEY,
+            block_hash TEXT, block_height INTEGER, block_time INTEGER,
+            block_index INTEGER, tree INTEGER, tx_type INTEGER,
+            version INTEGER, locktime INTEGER, expiry INTEGER, size INTEGER,
+            num_vin INTEGER, num_vout INTEGER
+        );
+        create index if not exists %[1]s_height_idx on %[1]s(block_height);
+        create table if not exists %[2]s(
+            tx_hash TEXT, tx_index INTEGER, tx_tree INTEGER,
+            block_height INTEGER,
+            prev_tx_hash TEXT, prev_tx_index INTEGER, prev_tx_tree INTEGER,
+            sequence INTEGER, value_in INTEGER,
+            proof_height INTEGER, proof_index INTEGER,
+            sig_script TEXT,
+            PRIMARY KEY (tx_hash, tx_index)
+        );
+        create index if not exists %[2]s_prevout_idx on %[2]s(prev_tx_hash, prev_tx_index);
+        create index if not exists %[2]s_height_idx on %[2]s(block_height);
+        create table if not exists %[3]s(
+            tx_hash TEXT, tx_index INTEGER, tx_tree INTEGER,
+            block_height INTEGER,
+            value INTEGER, version INTEGER, pkscript TEXT,
+            script_class TEXT, req_sigs INTEGER, addresses TEXT,
+            commit_amt INTEGER,
+            spending_tx_hash TEXT DEFAULT '',
+            spending_tx_index INTEGER DEFAULT -1,
+            spending_height INTEGER DEFAULT -1,
+            PRIMARY KEY (tx_hash, tx_index)
+        );
+        create index if not exists %[3]s_height_idx on %[3]s(block_height);
+        create index if not exists %[3]s_spending_idx on %[3]s(spending_height);
+        `, TableNameTransactions, TableNameVins, TableNameVouts)
+
+	return execStmt(tx, createTransactionsStmt)
+}
+
+// createTicketsTable creates the tickets table.
+func createTicketsTable(tx *sql.Tx) error {
+	createTicketsStmt := fmt.Sprintf(`
+        create table if not exists %[1]s(
+            tx_hash TEXT PRIMARY KEY,
+            purchase_height INTEGER, maturity_height INTEGER,
+            pool_status TEXT, pool_exit_height INTEGER DEFAULT -1,
+            revoked INTEGER DEFAULT 0, spend_height INTEGER DEFAULT -1
+        );
+        create index if not exists %[1]s_maturity_idx on %[1]s(maturity_height);
+        create index if not exists %[1]s_exit_idx on %[1]s(pool_exit_height);
+        create index if not exists %[1]s_spend_idx on %[1]s(spend_height);
+        `, TableNameTickets)
+
+	return execStmt(tx, createTicketsStmt)
+}
+
+// createVotesTable creates the votes table.
+func createVotesTable(tx *sql.Tx) error {
+	createVotesStmt := fmt.Sprintf(`
+        create table if not exists %[1]s(
+            tx_hash TEXT PRIMARY KEY,
+            block_height INTEGER, ticket_hash TEXT,
+            vote_version INTEGER, vote_bits INTEGER
+        );
+        create index if not exists %[1]s_height_idx on %[1]s(block_height);
+        create index if not exists %[1]s_version_idx on %[1]s(vote_version, block_height);
+        `, TableNameVotes)
+
+	return execStmt(tx, createVotesStmt)
+}
+
+// createVersionsTable creates the block versions table.
+func createVersionsTable(tx *sql.Tx) error {
+	createVersionsStmt := fmt.Sprintf(`
+        create table if not exists %s(
+            height INTEGER PRIMARY KEY, hash TEXT,
+            block_version INTEGER, stake_version INTEGER
+        );
+        `, TableNameVersions)
+
+	return execStmt(tx, createVersionsStmt)
+}
+
+// createMempoolHistoryTable creates the table of mempool data collections.
+func createMempoolHistoryTable(tx *sql.Tx) error {
+	createMempoolHistoryStmt := fmt.Sprintf(`
+        create table if not exists %s(
+            time INTEGER PRIMARY KEY, height INTEGER, num_tickets INTEGER,
+            fee_min FLOAT, fee_mean FLOAT, fee_median FLOAT, fee_max FLOAT,
+            num_regular INTEGER, regular_size INTEGER
+        );
+        `, TableNameMempoolHistory)
+
+	return execStmt(tx, createMempoolHistoryStmt)
+}
+
+// tableExists checks if the named table exists in the database.
+func tableExists(db *sql.DB, tableName string) (bool, error) {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master
+        WHERE type = 'table' AND name = ?`, tableName).Scan(&n)
+	return n > 0, err
+}
+
+// retrieveSchemaVersion gets the schema version stored in the meta table,
+// creating the table if needed. A database created before the meta table was
+// introduced has no stored version. If it has the block summary table, it is
+// at version 1, the original schema. Otherwise it is a new database, at
+// version 0.
+func retrieveSchemaVersion(db *sql.DB) (int, error) {
+	_, err := db.Exec(fmt.Sprintf(`create table if not exists %s(
+            id INTEGER PRIMARY KEY CHECK (id = 0),
+            schema_version INTEGER
+        );`, TableNameMeta))
+	if err != nil {
+		return -1, err
+	}
+
+	var version int
+	err = db.QueryRow(fmt.Sprintf(`SELECT schema_version FROM %s WHERE id = 0`,
+		TableNameMeta)).Scan(&version)
+	if err == nil {
+		return version, nil
+	}
+	if err != sql.ErrNoRows {
+		return -1, err
+	}
+
+	legacy, err := tableExists(db, TableNameSummaries)
+	if err != nil {
+		return -1, err
+	}
+	if legacy {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// upgradeSchema brings the database schema up to schemaVersion by running
+// each of the registered migrations beyond the stored schema version. An
+// error is returned if the database was created by a newer dcrdata with a
+// schema this version does not understand.
+func upgradeSchema(db *sql.DB) error {
+	version, err := retrieveSchemaVersion(db)
+	if err != nil {
+		return fmt.Errorf("unable to retrieve database schema version: %v", err)
+	}
+
+	if version > schemaVersion {
+		return fmt.Errorf("database schema version %d is newer than the "+
+			"version %d supported by this dcrdata. Upgrade dcrdata or use a "+
+			"different database file", version, schemaVersion)
+	}
+
+	setVersionSQL := fmt.Sprintf(`INSERT OR REPLACE INTO %s(id, schema_version)
+        values(0, ?)`, TableNameMeta)
+
+	for v := version; v < schemaVersion; v++ {
+		m := migrations[v]
+		if version > 0 {
+			log.Infof("Upgrading database schema to version %d: %s", v+1,
+				m.description)
+		}
+
+		dbtx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("unable to begin database transaction: %v", err)
+		}
+		if err = m.upgrade(dbtx); err != nil {
+			_ = dbtx.Rollback()
+			return fmt.Errorf("schema upgrade to version %d failed: %v", v+1, err)
+		}
+		if _, err = dbtx.Exec(setVersionSQL, v+1); err != nil {
+			_ = dbtx.Rollback()
+			return fmt.Errorf("unable to store schema version %d: %v", v+1, err)
+		}
+		if err = dbtx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
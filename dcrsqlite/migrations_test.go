@@ -0,0 +1,185 @@
+package dcrsqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// tablesByVersion lists the tables created by each schema version.
+var tablesByVersion = [][]string{
+	{},
+	{TableNameSummaries, TableNameStakeInfo},
+	{TableNameAddresses},
+	{TableNameTransactions, TableNameVins, TableNameVouts},
+	{TableNameTickets},
+	{TableNameVotes},
+	{TableNameVersions},
+	{TableNameMempoolHistory},
+}
+
+// openMemDB opens a new in-memory SQLite database. The connection pool is
+// limited to one connection since each connection to ":memory:" opens a
+// separate database.
+func openMemDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Unable to open in-memory database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	return db
+}
+
+// migrateTo brings a new database to the given schema version.
+func migrateTo(t *testing.T, db *sql.DB, version int) {
+	if _, err := retrieveSchemaVersion(db); err != nil {
+		t.Fatalf("retrieveSchemaVersion failed: %v", err)
+	}
+	for v := 0; v < version; v++ {
+		dbtx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = migrations[v].upgrade(dbtx); err != nil {
+			t.Fatalf("migration to version %d failed: %v", v+1, err)
+		}
+		if err = dbtx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setSchemaVersion(t, db, version)
+}
+
+func setSchemaVersion(t *testing.T, db *sql.DB, version int) {
+	_, err := db.Exec(fmt.Sprintf(`INSERT OR REPLACE INTO %s(id, schema_version)
+        values(0, ?)`, TableNameMeta), version)
+	if err != nil {
+		t.Fatalf("Unable to set schema version %d: %v", version, err)
+	}
+}
+
+// checkSchema checks that the database is at the current schema version and
+// has all of its tables.
+func checkSchema(t *testing.T, db *sql.DB) {
+	version, err := retrieveSchemaVersion(db)
+	if err != nil {
+		t.Fatalf("retrieveSchemaVersion failed: %v", err)
+	}
+	if version != schemaVersion {
+		t.Errorf("schema version %d, expected %d", version, schemaVersion)
+	}
+	for v, tables := range tablesByVersion {
+		for _, table := range tables {
+			exists, err := tableExists(db, table)
+			if err != nil {
+				t.Fatalf("tableExists failed: %v", err)
+			}
+			if !exists {
+				t.Errorf("table %s of schema version %d does not exist", table, v)
+			}
+		}
+	}
+}
+
+func TestUpgradeSchemaStepwise(t *testing.T) {
+	if len(tablesByVersion) != schemaVersion+1 {
+		t.Fatalf("tablesByVersion lists %d versions, expected %d",
+			len(tablesByVersion)-1, schemaVersion)
+	}
+
+	// Upgrade a database from every earlier version.
+	for v := 0; v <= schemaVersion; v++ {
+		db := openMemDB(t)
+		migrateTo(t, db, v)
+
+		version, err := retrieveSchemaVersion(db)
+		if err != nil {
+			t.Fatalf("retrieveSchemaVersion failed: %v", err)
+		}
+		if version != v {
+			t.Fatalf("schema version %d, expected %d", version, v)
+		}
+
+		if err = upgradeSchema(db); err != nil {
+			t.Fatalf("upgradeSchema from version %d failed: %v", v, err)
+		}
+		checkSchema(t, db)
+
+		// Upgrading again does nothing.
+		if err = upgradeSchema(db); err != nil {
+			t.Errorf("upgradeSchema of current version failed: %v", err)
+		}
+		checkSchema(t, db)
+		db.Close()
+	}
+}
+
+func TestUpgradeSchemaLegacy(t *testing.T) {
+	db := openMemDB(t)
+	defer db.Close()
+
+	// A database created before the schema version was stored has only the
+	// block summary and stake info tables, and no meta table.
+	dbtx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = createSummaryTables(dbtx); err != nil {
+		t.Fatalf("createSummaryTables failed: %v", err)
+	}
+	if err = dbtx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(fmt.Sprintf(`INSERT INTO %s(height, size, hash)
+        values(1, 100, 'abc')`, TableNameSummaries))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := retrieveSchemaVersion(db)
+	if err != nil {
+		t.Fatalf("retrieveSchemaVersion failed: %v", err)
+	}
+	if version != 1 {
+		t.Fatalf("legacy database at schema version %d, expected 1", version)
+	}
+
+	if err = upgradeSchema(db); err != nil {
+		t.Fatalf("upgradeSchema failed: %v", err)
+	}
+	checkSchema(t, db)
+
+	// The existing data is kept.
+	var hash string
+	err = db.QueryRow(fmt.Sprintf(`SELECT hash FROM %s WHERE height = 1`,
+		TableNameSummaries)).Scan(&hash)
+	if err != nil || hash != "abc" {
+		t.Errorf("block summary lost in upgrade: %q, %v", hash, err)
+	}
+}
+
+func TestUpgradeSchemaNewer(t *testing.T) {
+	db := openMemDB(t)
+	defer db.Close()
+
+	migrateTo(t, db, schemaVersion)
+	setSchemaVersion(t, db, schemaVersion+1)
+
+	err := upgradeSchema(db)
+	if err == nil {
+		t.Fatalf("upgradeSchema of a newer schema version succeeded")
+	}
+	if !strings.Contains(err.Error(), "newer") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// The stored version is left alone.
+	version, err := retrieveSchemaVersion(db)
+	if err != nil {
+		t.Fatalf("retrieveSchemaVersion failed: %v", err)
+	}
+	if version != schemaVersion+1 {
+		t.Errorf("schema version %d, expected %d", version, schemaVersion+1)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package dcrsqlite
+
+import (
+	"fmt"
+
+	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/dcrdata/dcrdata/stakedb"
+	"github.com/decred/dcrd/dcrutil"
+)
+
+// latestPoolCheckpoint returns the highest of the stake DB's pool info
+// checkpoints at or below maxHeight that is for the block stored in the block
+// summary table at that height, or nil if there is none.
+func (db *wiredDB) latestPoolCheckpoint(maxHeight int64) *stakedb.PoolCheckpoint {
+	if db.sDB == nil || db.sDB.StakeDB == nil {
+		return nil
+	}
+	checkpoints, err := db.sDB.PoolCheckpoints()
+	if err != nil {
+		log.Warnf("Unable to load pool info checkpoints: %v", err)
+		return nil
+	}
+
+	for i := len(checkpoints) - 1; i >= 0; i-- {
+		c := checkpoints[i]
+		if int64(c.Height) > maxHeight {
+			continue
+		}
+		hash, err := db.RetrieveBlockHash(int64(c.Height))
+		if err == nil && hash == c.Hash.String() {
+			return c
+		}
+		log.Debugf("Skipping pool info checkpoint at height %d not on main chain.",
+			c.Height)
+	}
+	return nil
+}
+
+// RebuildPoolInfo recomputes the ticket pool value for the blocks from height
+// ind0 to ind1 without replaying the chain through the stake DB. Starting from
+// the latest stake DB checkpoint below ind0, or from genesis, the pool value is
+// updated with the ticket maturity, spend and expiry events in the
+// transactions table, and the block summary and stake info rows are rewritten
+// in batches. Missed tickets leave the pool at the height they were missed,
+// as recorded in the tickets table. The stored pool size, which comes from the
+// block header, is kept. Missed tickets without a row in the tickets table are
+// counted as live until revoked, so the number of heights where the computed
+// pool size differs from the header is logged as a measure of how approximate
+// the values are.
+func (db *wiredDB) RebuildPoolInfo(ind0, ind1 int64, quit chan struct{}) error {
+	if ind1 < ind0 || ind0 < 0 {
+		return fmt.Errorf("Cannot rebuild pool info range [%d,%d]", ind0, ind1)
+	}
+	if txHeight := db.GetTransactionHeight(); ind1 > txHeight {
+		return fmt.Errorf("Cannot rebuild pool info to height %d, transactions "+
+			"table is at height %d", ind1, txHeight)
+	}
+
+	startHeight := int64(-1)
+	var poolSize, poolValue int64
+	if c := db.latestPoolCheckpoint(ind0 - 1); c != nil {
+		startHeight = int64(c.Height)
+		poolSize, poolValue = int64(c.PoolSize), c.PoolValue
+		log.Infof("Starting from pool info checkpoint at height %d.", startHeight)
+	} else {
+		log.Infof("No pool info checkpoint found. Starting from genesis.")
+	}
+
+	maturity := int64(db.params.TicketMaturity)
+	expiry := int64(db.params.TicketExpiry)
+	var sizeMismatches int64
+	progress := newSyncProgress(ind0)
+
+	for i := startHeight + 1; i <= ind1; {
+		// check for quit signal
+		if quitting(quit) {
+			log.Infof("Pool info rebuild cancelled at height %d.", i)
+			return nil
+		}
+
+		end := i + db.syncBatchSize - 1
+		if end > ind1 {
+			end = ind1
+		}
+		sizes, values, err := db.RetrieveTicketPoolDeltas(i, end, maturity, expiry)
+		if err != nil {
+			return fmt.Errorf("RetrieveTicketPoolDeltas failed: %v", err)
+		}
+
+		blockSummaries := make([]*apitypes.BlockDataBasic, 0, len(sizes))
+		stakeInfos := make([]*apitypes.StakeInfoExtended, 0, len(sizes))
+		for j := range sizes {
+			poolSize += sizes[j]
+			poolValue += values[j]
+
+			height := i + int64(j)
+			if height < ind0 {
+				continue
+			}
+
+			bd, err := db.RetrieveBlockSummary(height)
+			if err != nil {
+				return fmt.Errorf("RetrieveBlockSummary(%d) failed: %v", height, err)
+			}
+			si, err := db.RetrieveStakeInfoExtended(height)
+			if err != nil {
+				return fmt.Errorf("RetrieveStakeInfoExtended(%d) failed: %v", height, err)
+			}
+
+			if int64(bd.PoolInfo.Size) != poolSize {
+				sizeMismatches++
+			}
+
+			poolCoin := dcrutil.Amount(poolValue).ToCoin()
+			tpi := apitypes.TicketPoolInfo{
+				Size:  bd.PoolInfo.Size,
+				Value: poolCoin,
+			}
+			if tpi.Size > 0 {
+				tpi.ValAvg = poolCoin / float64(tpi.Size)
+			}
+			bd.PoolInfo = tpi
+			si.PoolInfo = tpi
+
+			blockSummaries = append(blockSummaries, bd)
+			stakeInfos = append(stakeInfos, si)
+		}
+
+		if err = db.StoreBlockBatch(blockSummaries, stakeInfos, nil); err != nil {
+			return fmt.Errorf("Unable to store block data in database: %v", err)
+		}
+
+		if end >= ind0 {
+			progress.log(end, ind1)
+		}
+		i = end + 1
+	}
+
+	if sizeMismatches > 0 {
+		log.Warnf("Computed pool size differed from the block header at %d of %d "+
+			"heights, mostly due to missed tickets missing from the tickets "+
+			"table that were not yet revoked.",
+			sizeMismatches, ind1-ind0+1)
+	}
+	log.Infof("Rebuilt pool info for blocks %d to %d.", ind0, ind1)
+
+	return nil
+}
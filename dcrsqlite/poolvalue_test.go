@@ -0,0 +1,189 @@
+package dcrsqlite
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/dcrdata/dcrdata/dbtypes"
+	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/dcrutil"
+)
+
+func TestRebuildPoolInfo(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	wdb := newTestWiredDB(db)
+
+	params := chaincfg.SimNetParams
+	params.TicketMaturity = 2
+	params.TicketExpiry = 5
+	wdb.params = &params
+
+	// Tickets a and b are bought at height 1 and c and d at height 2. a votes
+	// at height 5. b is missed at height 4 and revoked at 6. c expires at
+	// height 9. d is missed at height 5 and revoked at 7, but is added to the
+	// tickets table later.
+	tickets := []struct {
+		hash                string
+		purchase, value     int64
+		spend               int64
+		status              string
+		exit                int64
+		inTicketsTableFirst bool
+	}{
+		{"a", 1, 10, 5, "voted", 5, true},
+		{"b", 1, 20, 6, "missed", 4, true},
+		{"c", 2, 30, -1, "expired", 9, true},
+		{"d", 2, 40, 7, "missed", 5, false},
+	}
+	addTicket := func(hash string, purchase int64, status string, exit int64) {
+		_, err := db.Exec(fmt.Sprintf(`INSERT INTO %s(tx_hash, purchase_height,
+            maturity_height, pool_status, pool_exit_height) values(?, ?, ?, ?, ?)`,
+			TableNameTickets), hash, purchase, purchase+2, status, exit)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tk := range tickets {
+		_, err := db.Exec(fmt.Sprintf(`INSERT INTO %s(tx_hash, tx_index, tx_tree,
+            block_height, value, script_class, spending_height)
+            values(?, 0, 1, ?, ?, 'stakesubmission', ?)`, TableNameVouts),
+			tk.hash, tk.purchase, tk.value, tk.spend)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tk.inTicketsTableFirst {
+			addTicket(tk.hash, tk.purchase, tk.status, tk.exit)
+		}
+	}
+
+	// The header pool sizes of heights 0 to 9.
+	sizes := []uint32{0, 0, 0, 2, 3, 1, 1, 1, 1, 0}
+	const bestHeight = 9
+	_, err := db.Exec(fmt.Sprintf(`INSERT INTO %s(tx_hash, block_height)
+        values('x', ?)`, TableNameTransactions), bestHeight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bds := make([]*apitypes.BlockDataBasic, len(sizes))
+	sis := make([]*apitypes.StakeInfoExtended, len(sizes))
+	for i, size := range sizes {
+		bds[i] = &apitypes.BlockDataBasic{
+			Height:   uint32(i),
+			PoolInfo: apitypes.TicketPoolInfo{Size: size},
+		}
+		sis[i] = &apitypes.StakeInfoExtended{
+			PoolInfo: apitypes.TicketPoolInfo{Size: size},
+		}
+		sis[i].Feeinfo.Height = uint32(i)
+	}
+	if err = db.StoreBlockBatch(bds, sis, nil); err != nil {
+		t.Fatalf("StoreBlockBatch failed: %v", err)
+	}
+
+	checkValues := func(values []int64) {
+		for i, v := range values {
+			bd, err := db.RetrieveBlockSummary(int64(i))
+			if err != nil {
+				t.Fatalf("RetrieveBlockSummary(%d) failed: %v", i, err)
+			}
+			si, err := db.RetrieveStakeInfoExtended(int64(i))
+			if err != nil {
+				t.Fatalf("RetrieveStakeInfoExtended(%d) failed: %v", i, err)
+			}
+			expected := apitypes.TicketPoolInfo{
+				Size:  sizes[i],
+				Value: dcrutil.Amount(v).ToCoin(),
+			}
+			if sizes[i] > 0 {
+				expected.ValAvg = expected.Value / float64(sizes[i])
+			}
+			for _, pi := range []apitypes.TicketPoolInfo{bd.PoolInfo, si.PoolInfo} {
+				if !poolInfoMatches(&pi, &expected) {
+					t.Errorf("pool info at height %d is %v, expected %v", i,
+						pi, expected)
+				}
+			}
+		}
+	}
+
+	// Without its row in the tickets table, d is counted until revoked.
+	if err = wdb.RebuildPoolInfo(0, bestHeight, nil); err != nil {
+		t.Fatalf("RebuildPoolInfo failed: %v", err)
+	}
+	checkValues([]int64{0, 0, 0, 30, 80, 70, 70, 30, 30, 0})
+
+	// With every ticket in the tickets table, the values match the pool.
+	addTicket("d", 2, "missed", 5)
+	if err = wdb.RebuildPoolInfo(0, bestHeight, nil); err != nil {
+		t.Fatalf("RebuildPoolInfo failed: %v", err)
+	}
+	checkValues([]int64{0, 0, 0, 30, 80, 30, 30, 30, 30, 0})
+
+	// The computed deltas add up to the header pool sizes.
+	deltas, _, err := db.RetrieveTicketPoolDeltas(0, bestHeight,
+		int64(params.TicketMaturity), int64(params.TicketExpiry))
+	if err != nil {
+		t.Fatalf("RetrieveTicketPoolDeltas failed: %v", err)
+	}
+	var size int64
+	for i, d := range deltas {
+		size += d
+		if size != int64(sizes[i]) {
+			t.Errorf("pool size at height %d is %d, expected %d", i, size, sizes[i])
+		}
+	}
+}
+
+func TestRetrieveTicketExitCounts(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	store := func(height int64, updates ...dbtypes.TicketUpdate) {
+		if err := db.StoreTicketUpdates(height, updates); err != nil {
+			t.Fatalf("StoreTicketUpdates(%d) failed: %v", height, err)
+		}
+	}
+	update := func(hash string, status dbtypes.TicketPoolStatus) dbtypes.TicketUpdate {
+		return dbtypes.TicketUpdate{TicketHash: hash, Status: status}
+	}
+	check := func(ind0, ind1 int64, expectedMissed, expectedExpired []uint32) {
+		missed, expired, err := db.RetrieveTicketExitCounts(ind0, ind1)
+		if err != nil {
+			t.Fatalf("RetrieveTicketExitCounts failed: %v", err)
+		}
+		if !reflect.DeepEqual(missed, expectedMissed) {
+			t.Errorf("missed [%d,%d] %v, expected %v", ind0, ind1, missed, expectedMissed)
+		}
+		if !reflect.DeepEqual(expired, expectedExpired) {
+			t.Errorf("expired [%d,%d] %v, expected %v", ind0, ind1, expired, expectedExpired)
+		}
+	}
+
+	// a, b and c mature at height 1. a is missed at height 2, and at height 4
+	// b expires and c is missed.
+	store(1, update("a", dbtypes.PoolStatusLive), update("b", dbtypes.PoolStatusLive),
+		update("c", dbtypes.PoolStatusLive))
+	store(2, update("a", dbtypes.PoolStatusMissed))
+	store(3)
+	store(4, update("b", dbtypes.PoolStatusExpired), update("c", dbtypes.PoolStatusMissed))
+
+	check(0, 4, []uint32{0, 0, 1, 1, 2}, []uint32{0, 0, 0, 0, 1})
+	// Heights above the tickets table have the totals of its best block.
+	check(3, 6, []uint32{1, 2, 2, 2}, []uint32{0, 1, 1, 1})
+
+	// The cached totals are extended as blocks are stored.
+	store(5, update("d", dbtypes.PoolStatusLive))
+	store(6, update("d", dbtypes.PoolStatusExpired))
+	check(4, 6, []uint32{2, 2, 2}, []uint32{1, 1, 2})
+
+	// And rolled back with the tickets table.
+	if err := db.DeleteTicketsAboveHeight(3); err != nil {
+		t.Fatalf("DeleteTicketsAboveHeight failed: %v", err)
+	}
+	check(0, 5, []uint32{0, 0, 1, 1, 1, 1}, []uint32{0, 0, 0, 0, 0, 0})
+	store(4, update("c", dbtypes.PoolStatusExpired))
+	check(2, 4, []uint32{1, 1, 1}, []uint32{0, 0, 1})
+}
@@ -7,10 +7,15 @@ import (
 	"database/sql"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/btcsuite/btclog"
 	"github.com/dcrdata/dcrdata/blockdata"
+	"github.com/dcrdata/dcrdata/chaindb"
+	"github.com/dcrdata/dcrdata/dbtypes"
 	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/wire"
 	_ "github.com/mattn/go-sqlite3" // register sqlite driver with database/sql
 )
 
@@ -18,6 +23,7 @@ import (
 type StakeInfoDatabaser interface {
 	StoreStakeInfoExtended(bd *apitypes.StakeInfoExtended) error
 	RetrieveStakeInfoExtended(ind int64) (*apitypes.StakeInfoExtended, error)
+	RetrieveStakeInfoExtendedRange(ind0, ind1 int64) ([]*apitypes.StakeInfoExtended, error)
 }
 
 // BlockSummaryDatabaser is the interface for a block data saving database
@@ -26,6 +32,73 @@ type BlockSummaryDatabaser interface {
 	RetrieveBlockSummary(ind int64) (*apitypes.BlockDataBasic, error)
 }
 
+// ChainDataStore is the interface for a database backend that stores and
+// retrieves the block summaries, extended stake info and address index used
+// by a wiredDB. DB satisfies this interface, as does dcrpg.DB.
+type ChainDataStore interface {
+	BlockSummaryDatabaser
+	StakeInfoDatabaser
+	Ping() error
+	Close() error
+
+	StoreBlockBatch(bds []*apitypes.BlockDataBasic, sis []*apitypes.StakeInfoExtended,
+		blocks []*chaindb.BlockIndex) error
+
+	GetBestBlockHash() string
+	GetBestBlockHeight() int64
+	GetBlockSummaryHeight() int64
+	GetStakeInfoHeight() int64
+	RetrieveBlockHash(ind int64) (string, error)
+	RetrieveBlockHeight(hash string) (int64, error)
+	RetrieveBlockSummaryByHash(hash string) (*apitypes.BlockDataBasic, error)
+	RetrieveBlockSizeRange(ind0, ind1 int64) ([]int32, error)
+	RetrieveBlockTimeRange(ind0, ind1 int64) ([]int64, error)
+	RetrievePoolInfo(ind int64) (*apitypes.TicketPoolInfo, error)
+	RetrievePoolInfoByHash(hash string) (*apitypes.TicketPoolInfo, error)
+	RetrievePoolInfoRange(ind0, ind1 int64) ([]apitypes.TicketPoolInfo, error)
+	RetrievePoolValAndSizeRange(ind0, ind1 int64) ([]float64, []float64, error)
+	RetrieveSDiff(ind int64) (float64, error)
+	RetrieveSDiffRange(ind0, ind1 int64) ([]float64, error)
+
+	StoreBlockAddresses(msgBlock *wire.MsgBlock, params *chaincfg.Params) error
+	DeleteAddressesAboveHeight(height int64) error
+	GetAddressHeight() int64
+	RetrieveAddressTxns(address string, N, offset int64) ([]*dbtypes.AddressTxn, error)
+	RetrieveAddressTotals(address string) (*dbtypes.AddressTotals, error)
+	RetrieveOutpointAddresses(txHash string, index uint32) ([]string, int64, error)
+	RetrieveAddressUTXOs(address string) ([]*dbtypes.AddressOutpoint, error)
+
+	StoreBlockTransactions(msgBlock *wire.MsgBlock, params *chaincfg.Params) error
+	DeleteTransactionsAboveHeight(height int64) error
+	GetTransactionHeight() int64
+	RetrieveTransaction(txHash string) (*dbtypes.Tx, error)
+	RetrieveTxVins(txHash string) ([]*dbtypes.Vin, error)
+	RetrieveTxVouts(txHash string) ([]*dbtypes.Vout, error)
+	RetrieveSpendingTx(txHash string, index uint32) (string, uint32, int64, error)
+	RetrieveTicketPoolDeltas(ind0, ind1, maturity, expiry int64) ([]int64, []int64, error)
+
+	StoreTicketUpdates(height int64, updates []dbtypes.TicketUpdate) error
+	DeleteTicketsAboveHeight(height int64) error
+	GetTicketHeight() int64
+	RetrieveTicket(txHash string) (*dbtypes.Ticket, error)
+	RetrieveMissedTickets(height int64) ([]string, error)
+	RetrieveTicketExitCounts(ind0, ind1 int64) ([]uint32, []uint32, error)
+
+	StoreBlockVotes(msgBlock *wire.MsgBlock) error
+	DeleteVotesAboveHeight(height int64) error
+	GetVoteHeight() int64
+	RetrieveVoteBitsCounts(version uint32, mask uint16, ind0, ind1, binSize int64) ([]dbtypes.VoteBitsCount, error)
+
+	StoreBlockVersions(bv *dbtypes.BlockVersions) error
+	DeleteBlockVersionsAboveHeight(height int64) error
+	GetBlockVersionsHeight() int64
+	RetrieveVersionCounts(vt dbtypes.VersionType, ind0, ind1, binSize int64) ([]dbtypes.VersionCount, error)
+
+	StoreMempoolSnapshot(snap *dbtypes.MempoolSnapshot) error
+	RetrieveMempoolHistory(from, to, step int64) ([]dbtypes.MempoolHistoryBin, error)
+	SetMempoolRetention(retention time.Duration)
+}
+
 // DBInfo contains db configuration
 type DBInfo struct {
 	FileName string
@@ -36,6 +109,24 @@ const (
 	TableNameSummaries = "dcrdata_block_summary"
 	// TableNameStakeInfo is name of the table used to store extended stake info
 	TableNameStakeInfo = "dcrdata_stakeinfo_extended"
+	// TableNameAddresses is name of the table used to store address outpoints
+	TableNameAddresses = "dcrdata_addresses"
+	// TableNameTransactions is name of the table used to store transactions
+	TableNameTransactions = "dcrdata_transactions"
+	// TableNameVins is name of the table used to store transaction inputs
+	TableNameVins = "dcrdata_vins"
+	// TableNameVouts is name of the table used to store transaction outputs
+	TableNameVouts = "dcrdata_vouts"
+	// TableNameTickets is name of the table used to store ticket status
+	TableNameTickets = "dcrdata_tickets"
+	// TableNameVotes is name of the table used to store votes
+	TableNameVotes = "dcrdata_votes"
+	// TableNameVersions is name of the table used to store block header
+	// versions
+	TableNameVersions = "dcrdata_block_versions"
+	// TableNameMempoolHistory is name of the table used to store mempool data
+	// collections
+	TableNameMempoolHistory = "dcrdata_mempool_history"
 )
 
 // DB is a wrapper around sql.DB that adds methods for storing and retrieving
@@ -43,6 +134,7 @@ const (
 // future.
 type DB struct {
 	*sql.DB
+	*chaindb.Tables
 	sync.RWMutex
 	dbSummaryHeight                                     int64
 	dbStakeInfoHeight                                   int64
@@ -54,9 +146,11 @@ type DB struct {
 	getBlockByHashSQL                                   string
 	getBlockHashSQL, getBlockHeightSQL                  string
 	getBlockSizeRangeSQL                                string
+	getBlockTimeRangeSQL                                string
 	getBestBlockHashSQL, getBestBlockHeightSQL          string
 	getLatestStakeInfoExtendedSQL                       string
 	getStakeInfoExtendedSQL, insertStakeInfoExtendedSQL string
+	getStakeInfoExtendedRangeSQL                        string
 }
 
 // NewDB creates a new DB instance with pre-generated sql statements from an
@@ -95,6 +189,8 @@ func NewDB(db *sql.DB) *DB {
 
 	d.getBlockSizeRangeSQL = fmt.Sprintf(`select size from %s where height between ? and ?`,
 		TableNameSummaries)
+	d.getBlockTimeRangeSQL = fmt.Sprintf(`select time from %s where height between ? and ?
+        order by height`, TableNameSummaries)
 
 	d.getBestBlockHashSQL = fmt.Sprintf(`select hash from %s ORDER BY height DESC LIMIT 0, 1`, TableNameSummaries)
 	d.getBestBlockHeightSQL = fmt.Sprintf(`select height from %s ORDER BY height DESC LIMIT 0, 1`, TableNameSummaries)
@@ -105,6 +201,8 @@ func NewDB(db *sql.DB) *DB {
 	// Stake info queries
 	d.getStakeInfoExtendedSQL = fmt.Sprintf(`select * from %s where height = ?`,
 		TableNameStakeInfo)
+	d.getStakeInfoExtendedRangeSQL = fmt.Sprintf(`select * from %s
+        where height between ? and ? order by height`, TableNameStakeInfo)
 	d.getLatestStakeInfoExtendedSQL = fmt.Sprintf(
 		`SELECT * FROM %s ORDER BY height DESC LIMIT 0, 1`, TableNameStakeInfo)
 	d.insertStakeInfoExtendedSQL = fmt.Sprintf(`
@@ -114,6 +212,211 @@ func NewDB(db *sql.DB) *DB {
         ) values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
         `, TableNameStakeInfo)
 
+	// Address, transaction, ticket, vote, block version and mempool history
+	// queries, used by the chain data tables.
+	var q chaindb.Queries
+
+	// Address queries
+	q.InsertAddress = fmt.Sprintf(`
+        INSERT OR IGNORE INTO %s(
+            address, tx_hash, tx_index, tx_tree, value, block_height, block_time
+        ) values(?, ?, ?, ?, ?, ?, ?)
+        `, TableNameAddresses)
+	q.SetAddressSpending = fmt.Sprintf(`UPDATE %s SET spending_tx_hash = ?,
+        spending_tx_index = ?, spending_height = ?, spending_time = ?
+        WHERE tx_hash = ? AND tx_index = ?`, TableNameAddresses)
+	q.DeleteAddressesAbove = fmt.Sprintf(`DELETE FROM %s WHERE block_height > ?`,
+		TableNameAddresses)
+	q.UnsetAddressSpendingAbove = fmt.Sprintf(`UPDATE %s SET spending_tx_hash = '',
+        spending_tx_index = -1, spending_height = -1, spending_time = 0
+        WHERE spending_height > ?`, TableNameAddresses)
+	q.GetAddressHeight = fmt.Sprintf(`SELECT COALESCE(MAX(block_height), -1) FROM %s`,
+		TableNameAddresses)
+	// Transactions funding or spending from an address, with the amounts
+	// received and sent by each.
+	addressTxnsSubquery := fmt.Sprintf(`
+            SELECT tx_hash, block_height, block_time, value AS received, 0 AS sent
+            FROM %[1]s WHERE address = ?1
+            UNION ALL
+            SELECT spending_tx_hash, spending_height, spending_time, 0, value
+            FROM %[1]s WHERE address = ?1 AND spending_height >= 0`,
+		TableNameAddresses)
+	q.GetAddressTxns = fmt.Sprintf(`
+        SELECT tx_hash, block_height, block_time, SUM(received), SUM(sent)
+        FROM (%s) AS txns
+        GROUP BY tx_hash, block_height, block_time
+        ORDER BY block_height DESC, tx_hash
+        LIMIT ?2 OFFSET ?3`, addressTxnsSubquery)
+	q.GetAddressTxnCount = fmt.Sprintf(`
+        SELECT COUNT(*) FROM (SELECT DISTINCT tx_hash FROM (%s) AS txns) AS hashes`,
+		addressTxnsSubquery)
+	q.GetAddressTotals = fmt.Sprintf(`SELECT COALESCE(SUM(value), 0),
+        COALESCE(SUM(CASE WHEN spending_height >= 0 THEN value ELSE 0 END), 0)
+        FROM %s WHERE address = ?`, TableNameAddresses)
+	q.GetOutpointAddresses = fmt.Sprintf(`SELECT address, value FROM %s
+        WHERE tx_hash = ? AND tx_index = ?`, TableNameAddresses)
+	q.GetAddressUTXOs = fmt.Sprintf(`SELECT tx_hash, tx_index, tx_tree, value,
+        block_height, block_time FROM %s WHERE address = ? AND spending_height < 0
+        ORDER BY block_height, tx_hash, tx_index`, TableNameAddresses)
+
+	// Transaction queries
+	q.InsertTx = fmt.Sprintf(`
+        INSERT OR REPLACE INTO %s(
+            tx_hash, block_hash, block_height, block_time, block_index, tree,
+            tx_type, version, locktime, expiry, size, num_vin, num_vout
+        ) values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        `, TableNameTransactions)
+	q.InsertVin = fmt.Sprintf(`
+        INSERT OR REPLACE INTO %s(
+            tx_hash, tx_index, tx_tree, block_height, prev_tx_hash,
+            prev_tx_index, prev_tx_tree, sequence, value_in, proof_height,
+            proof_index, sig_script
+        ) values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        `, TableNameVins)
+	q.InsertVout = fmt.Sprintf(`
+        INSERT OR REPLACE INTO %s(
+            tx_hash, tx_index, tx_tree, block_height, value, version, pkscript,
+            script_class, req_sigs, addresses, commit_amt
+        ) values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        `, TableNameVouts)
+	q.SetVoutSpending = fmt.Sprintf(`UPDATE %s SET spending_tx_hash = ?,
+        spending_tx_index = ?, spending_height = ?
+        WHERE tx_hash = ? AND tx_index = ?`, TableNameVouts)
+	q.UnsetVoutSpendingAbove = fmt.Sprintf(`UPDATE %s SET spending_tx_hash = '',
+        spending_tx_index = -1, spending_height = -1
+        WHERE spending_height > ?`, TableNameVouts)
+	q.DeleteTxsAbove = fmt.Sprintf(`DELETE FROM %s WHERE block_height > ?`,
+		TableNameTransactions)
+	q.DeleteVinsAbove = fmt.Sprintf(`DELETE FROM %s WHERE block_height > ?`,
+		TableNameVins)
+	q.DeleteVoutsAbove = fmt.Sprintf(`DELETE FROM %s WHERE block_height > ?`,
+		TableNameVouts)
+	q.GetTxHeight = fmt.Sprintf(`SELECT COALESCE(MAX(block_height), -1) FROM %s`,
+		TableNameTransactions)
+	q.GetTx = fmt.Sprintf(`SELECT tx_hash, block_hash, block_height,
+        block_time, block_index, tree, tx_type, version, locktime, expiry, size,
+        num_vin, num_vout FROM %s WHERE tx_hash = ?`, TableNameTransactions)
+	q.GetTxVins = fmt.Sprintf(`SELECT tx_hash, tx_index, tx_tree,
+        block_height, prev_tx_hash, prev_tx_index, prev_tx_tree, sequence,
+        value_in, proof_height, proof_index, sig_script
+        FROM %s WHERE tx_hash = ? ORDER BY tx_index`, TableNameVins)
+	q.GetTxVouts = fmt.Sprintf(`SELECT tx_hash, tx_index, tx_tree,
+        block_height, value, version, pkscript, script_class, req_sigs,
+        addresses, commit_amt, spending_tx_hash, spending_tx_index,
+        spending_height FROM %s WHERE tx_hash = ? ORDER BY tx_index`,
+		TableNameVouts)
+	q.GetVoutSpender = fmt.Sprintf(`SELECT spending_tx_hash, spending_tx_index,
+        spending_height FROM %s WHERE tx_hash = ? AND tx_index = ?`, TableNameVouts)
+
+	// Ticket pool queries. A ticket (the stakesubmission output of a ticket
+	// purchase) joins the live pool TicketMaturity blocks after it is mined,
+	// and leaves when spent by a vote or revocation, or when it expires.
+	q.GetTicketsMaturing = fmt.Sprintf(`SELECT block_height, COUNT(*), SUM(value)
+        FROM %s WHERE script_class = 'stakesubmission' AND block_height BETWEEN ?1 AND ?2
+        GROUP BY block_height`, TableNameVouts)
+	q.GetTicketsLeaving = fmt.Sprintf(`SELECT leave_height, COUNT(*), SUM(value)
+        FROM (SELECT CASE
+                WHEN t.pool_exit_height >= 0 THEN t.pool_exit_height
+                WHEN v.spending_height >= 0 AND v.spending_height < v.block_height + ?1
+                THEN v.spending_height ELSE v.block_height + ?1 END AS leave_height,
+                v.value
+            FROM %s AS v LEFT JOIN %s AS t ON t.tx_hash = v.tx_hash
+            WHERE v.script_class = 'stakesubmission'
+                AND v.block_height BETWEEN ?2 AND ?3) AS tickets
+        WHERE leave_height BETWEEN ?4 AND ?3
+        GROUP BY leave_height`, TableNameVouts, TableNameTickets)
+
+	// Ticket status queries
+	q.InsertTicket = fmt.Sprintf(`
+        INSERT OR REPLACE INTO %s(
+            tx_hash, purchase_height, maturity_height, pool_status,
+            pool_exit_height, revoked, spend_height
+        ) values(?, ?, ?, 'live', -1, 0, -1)
+        `, TableNameTickets)
+	q.SetTicketStatus = fmt.Sprintf(`UPDATE %s SET pool_status = ?,
+        pool_exit_height = ? WHERE tx_hash = ?`, TableNameTickets)
+	q.SetTicketSpend = fmt.Sprintf(`UPDATE %s SET revoked = ?,
+        spend_height = ? WHERE tx_hash = ?`, TableNameTickets)
+	q.DeleteTicketsAbove = fmt.Sprintf(`DELETE FROM %s WHERE maturity_height > ?`,
+		TableNameTickets)
+	q.UnsetTicketStatusAbove = fmt.Sprintf(`UPDATE %s SET pool_status = 'live',
+        pool_exit_height = -1 WHERE pool_exit_height > ?`, TableNameTickets)
+	q.UnsetTicketSpendAbove = fmt.Sprintf(`UPDATE %s SET revoked = 0,
+        spend_height = -1 WHERE spend_height > ?`, TableNameTickets)
+	q.GetTicketHeight = fmt.Sprintf(`SELECT COALESCE(MAX(MAX(maturity_height,
+        pool_exit_height, spend_height)), -1) FROM %s`, TableNameTickets)
+	q.GetTicket = fmt.Sprintf(`SELECT tx_hash, purchase_height, maturity_height,
+        pool_status, pool_exit_height, revoked, spend_height
+        FROM %s WHERE tx_hash = ?`, TableNameTickets)
+	q.GetMissedTickets = fmt.Sprintf(`SELECT tx_hash FROM %s
+        WHERE pool_status = 'missed' AND pool_exit_height = ?
+        ORDER BY tx_hash`, TableNameTickets)
+	q.GetTicketExitCounts = fmt.Sprintf(`SELECT pool_exit_height,
+        SUM(CASE WHEN pool_status = 'missed' THEN 1 ELSE 0 END),
+        SUM(CASE WHEN pool_status = 'expired' THEN 1 ELSE 0 END)
+        FROM %s WHERE pool_status IN ('missed', 'expired')
+            AND pool_exit_height BETWEEN ? AND ?
+        GROUP BY pool_exit_height ORDER BY pool_exit_height`, TableNameTickets)
+
+	// Vote queries. The vote bits counts are grouped into bins of ?2 blocks
+	// starting at height ?1.
+	q.InsertVote = fmt.Sprintf(`
+        INSERT OR REPLACE INTO %s(
+            tx_hash, block_height, ticket_hash, vote_version, vote_bits
+        ) values(?, ?, ?, ?, ?)
+        `, TableNameVotes)
+	q.DeleteVotesAbove = fmt.Sprintf(`DELETE FROM %s WHERE block_height > ?`,
+		TableNameVotes)
+	q.GetVoteHeight = fmt.Sprintf(`SELECT COALESCE(MAX(block_height), -1) FROM %s`,
+		TableNameVotes)
+	q.GetVoteBitsCounts = fmt.Sprintf(`SELECT (block_height - ?1) / ?2 AS bin,
+        vote_bits & ?3 AS bits, COUNT(*)
+        FROM %s WHERE vote_version = ?4 AND block_height BETWEEN ?1 AND ?5
+        GROUP BY bin, bits ORDER BY bin, bits`, TableNameVotes)
+
+	// Block version queries. The version counts are grouped into bins of ?2
+	// blocks starting at height ?1. Vote versions are counted from the votes
+	// table.
+	q.InsertVersions = fmt.Sprintf(`
+        INSERT OR REPLACE INTO %s(
+            height, hash, block_version, stake_version
+        ) values(?, ?, ?, ?)
+        `, TableNameVersions)
+	q.DeleteVersionsAbove = fmt.Sprintf(`DELETE FROM %s WHERE height > ?`,
+		TableNameVersions)
+	q.GetVersionsHeight = fmt.Sprintf(`SELECT COALESCE(MAX(height), -1) FROM %s`,
+		TableNameVersions)
+	q.GetBlockVersionCounts = fmt.Sprintf(`SELECT (height - ?1) / ?2 AS bin,
+        block_version, COUNT(*)
+        FROM %s WHERE height BETWEEN ?1 AND ?3
+        GROUP BY bin, block_version ORDER BY bin, block_version`, TableNameVersions)
+	q.GetStakeVersionCounts = fmt.Sprintf(`SELECT (height - ?1) / ?2 AS bin,
+        stake_version, COUNT(*)
+        FROM %s WHERE height BETWEEN ?1 AND ?3
+        GROUP BY bin, stake_version ORDER BY bin, stake_version`, TableNameVersions)
+	q.GetVoteVersionCounts = fmt.Sprintf(`SELECT (block_height - ?1) / ?2 AS bin,
+        vote_version, COUNT(*)
+        FROM %s WHERE block_height BETWEEN ?1 AND ?3
+        GROUP BY bin, vote_version ORDER BY bin, vote_version`, TableNameVotes)
+
+	// Mempool history queries. The snapshots are averaged in bins of ?2
+	// seconds starting at time ?1.
+	q.InsertMempoolSnapshot = fmt.Sprintf(`
+        INSERT OR REPLACE INTO %s(
+            time, height, num_tickets, fee_min, fee_mean, fee_median, fee_max,
+            num_regular, regular_size
+        ) values(?, ?, ?, ?, ?, ?, ?, ?, ?)
+        `, TableNameMempoolHistory)
+	q.GetMempoolHistory = fmt.Sprintf(`SELECT (time - ?1) / ?2 AS bin,
+        COUNT(*), MAX(height), AVG(num_tickets), AVG(fee_min), AVG(fee_mean),
+        AVG(fee_median), AVG(fee_max), AVG(num_regular), AVG(regular_size)
+        FROM %s WHERE time BETWEEN ?1 AND ?3
+        GROUP BY bin ORDER BY bin`, TableNameMempoolHistory)
+	q.DeleteMempoolHistoryBefore = fmt.Sprintf(`DELETE FROM %s WHERE time < ?`,
+		TableNameMempoolHistory)
+
+	d.Tables = chaindb.NewTables(db, &q)
+
 	d.dbSummaryHeight = d.GetBlockSummaryHeight()
 	d.dbStakeInfoHeight = d.GetStakeInfoHeight()
 
@@ -128,44 +431,16 @@ func InitDB(dbInfo *DBInfo) (*DB, error) {
 		return nil, err
 	}
 
-	createBlockSummaryStmt := fmt.Sprintf(`
-        PRAGMA cache_size = 32768;
-        pragma synchronous = OFF;
-        create table if not exists %s(
-            height INTEGER PRIMARY KEY,
-            size INTEGER,
-            hash TEXT,
-            diff FLOAT,
-            sdiff FLOAT,
-            time INTEGER,
-            poolsize INTEGER,
-            poolval FLOAT,
-            poolavg FLOAT
-        );
-        `, TableNameSummaries)
-
-	_, err = db.Exec(createBlockSummaryStmt)
+	_, err = db.Exec(`PRAGMA cache_size = 32768;
+        pragma synchronous = OFF;`)
 	if err != nil {
-		log.Errorf("%q: %s\n", err, createBlockSummaryStmt)
+		_ = db.Close()
 		return nil, err
 	}
 
-	createStakeInfoExtendedStmt := fmt.Sprintf(`
-        PRAGMA cache_size = 32768;
-        pragma synchronous = OFF;
-        create table if not exists %s(
-            height INTEGER PRIMARY KEY,
-            num_tickets INTEGER,
-            fee_min FLOAT, fee_max FLOAT, fee_mean FLOAT,
-			fee_med FLOAT, fee_std FLOAT,
-			sdiff FLOAT, window_num INTEGER, window_ind INTEGER,
-            pool_size INTEGER, pool_val FLOAT, pool_valavg FLOAT
-        );
-        `, TableNameStakeInfo)
-
-	_, err = db.Exec(createStakeInfoExtendedStmt)
-	if err != nil {
-		log.Errorf("%q: %s\n", err, createStakeInfoExtendedStmt)
+	// Create the tables of a new database, or upgrade those of an older one.
+	if err = upgradeSchema(db); err != nil {
+		_ = db.Close()
 		return nil, err
 	}
 
@@ -173,16 +448,17 @@ func InitDB(dbInfo *DBInfo) (*DB, error) {
 	return NewDB(db), err
 }
 
-// DBDataSaver models a DB with a channel to communicate new block height to the web interface
+// DBDataSaver models a ChainDataStore with a channel to communicate new block
+// height to the web interface
 type DBDataSaver struct {
-	*DB
+	ChainDataStore
 	updateStatusChan chan uint32
 }
 
 // Store satisfies the blockdata.BlockDataSaver interface
 func (db *DBDataSaver) Store(data *blockdata.BlockData) error {
 	summary := data.ToBlockSummary()
-	err := db.DB.StoreBlockSummary(&summary)
+	err := db.ChainDataStore.StoreBlockSummary(&summary)
 	if err != nil {
 		return err
 	}
@@ -193,7 +469,7 @@ func (db *DBDataSaver) Store(data *blockdata.BlockData) error {
 	}
 
 	stakeInfoExtended := data.ToStakeInfoExtended()
-	return db.DB.StoreStakeInfoExtended(&stakeInfoExtended)
+	return db.ChainDataStore.StoreStakeInfoExtended(&stakeInfoExtended)
 }
 
 // StoreBlockSummary attemps to stores the block data in the database and
@@ -596,6 +872,43 @@ func (db *DB) RetrieveBlockSizeRange(ind0, ind1 int64) ([]int32, error) {
 	return blockSizes, nil
 }
 
+// RetrieveBlockTimeRange returns an array of block timestamps for block range
+// ind0 to ind1
+func (db *DB) RetrieveBlockTimeRange(ind0, ind1 int64) ([]int64, error) {
+	N := ind1 - ind0 + 1
+	if N == 0 {
+		return []int64{}, nil
+	}
+	if N < 0 {
+		return nil, fmt.Errorf("Cannot retrieve block time range (%d<%d)",
+			ind1, ind0)
+	}
+	db.RLock()
+	if ind1 > db.dbSummaryHeight || ind0 < 0 {
+		defer db.RUnlock()
+		return nil, fmt.Errorf("Cannot retrieve block time range [%d,%d], have height %d",
+			ind1, ind0, db.dbSummaryHeight)
+	}
+	db.RUnlock()
+
+	rows, err := db.Query(db.getBlockTimeRangeSQL, ind0, ind1)
+	if err != nil {
+		log.Errorf("Query failed: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	times := make([]int64, 0, N)
+	for rows.Next() {
+		var t int64
+		if err = rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		times = append(times, t)
+	}
+	return times, rows.Err()
+}
+
 // StoreStakeInfoExtended stores the extended stake info in the database
 func (db *DB) StoreStakeInfoExtended(si *apitypes.StakeInfoExtended) error {
 	stmt, err := db.Prepare(db.insertStakeInfoExtendedSQL)
@@ -625,6 +938,85 @@ func (db *DB) StoreStakeInfoExtended(si *apitypes.StakeInfoExtended) error {
 	return err
 }
 
+// StoreBlockBatch stores the block summaries and extended stake info for a
+// batch of blocks, and the blocks in the chain data tables, in a single SQL
+// transaction, which is much faster than storing each row separately during a
+// resync.
+func (db *DB) StoreBlockBatch(bds []*apitypes.BlockDataBasic, sis []*apitypes.StakeInfoExtended,
+	blocks []*chaindb.BlockIndex) error {
+	dbtx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("unable to begin database transaction: %v", err)
+	}
+
+	blockStmt, err := dbtx.Prepare(db.insertBlockSQL)
+	if err != nil {
+		_ = dbtx.Rollback()
+		return err
+	}
+	defer blockStmt.Close()
+
+	var summaryHeight int64 = -1
+	for _, bd := range bds {
+		_, err = blockStmt.Exec(&bd.Height, &bd.Size, &bd.Hash,
+			&bd.Difficulty, &bd.StakeDiff, &bd.Time,
+			&bd.PoolInfo.Size, &bd.PoolInfo.Value, &bd.PoolInfo.ValAvg)
+		if err != nil {
+			_ = dbtx.Rollback()
+			return fmt.Errorf("unable to insert block summary row: %v", err)
+		}
+		if int64(bd.Height) > summaryHeight {
+			summaryHeight = int64(bd.Height)
+		}
+	}
+
+	stakeStmt, err := dbtx.Prepare(db.insertStakeInfoExtendedSQL)
+	if err != nil {
+		_ = dbtx.Rollback()
+		return err
+	}
+	defer stakeStmt.Close()
+
+	var stakeHeight int64 = -1
+	for _, si := range sis {
+		_, err = stakeStmt.Exec(&si.Feeinfo.Height,
+			&si.Feeinfo.Number, &si.Feeinfo.Min, &si.Feeinfo.Max, &si.Feeinfo.Mean,
+			&si.Feeinfo.Median, &si.Feeinfo.StdDev,
+			&si.StakeDiff, // no next or estimates
+			&si.PriceWindowNum, &si.IdxBlockInWindow, &si.PoolInfo.Size,
+			&si.PoolInfo.Value, &si.PoolInfo.ValAvg)
+		if err != nil {
+			_ = dbtx.Rollback()
+			return fmt.Errorf("unable to insert stake info row: %v", err)
+		}
+		if int64(si.Feeinfo.Height) > stakeHeight {
+			stakeHeight = int64(si.Feeinfo.Height)
+		}
+	}
+
+	updateIndexHeights, err := db.StoreIndexBatch(dbtx, blocks)
+	if err != nil {
+		_ = dbtx.Rollback()
+		return err
+	}
+
+	if err = dbtx.Commit(); err != nil {
+		return err
+	}
+
+	updateIndexHeights()
+
+	db.Lock()
+	defer db.Unlock()
+	if summaryHeight > db.dbSummaryHeight {
+		db.dbSummaryHeight = summaryHeight
+	}
+	if stakeHeight > db.dbStakeInfoHeight {
+		db.dbStakeInfoHeight = stakeHeight
+	}
+	return nil
+}
+
 // RetrieveLatestStakeInfoExtended returns the extended stake info for the best block
 func (db *DB) RetrieveLatestStakeInfoExtended() (*apitypes.StakeInfoExtended, error) {
 	si := new(apitypes.StakeInfoExtended)
@@ -660,6 +1052,32 @@ func (db *DB) RetrieveStakeInfoExtended(ind int64) (*apitypes.StakeInfoExtended,
 	return si, nil
 }
 
+// RetrieveStakeInfoExtendedRange returns the extended stake info for the
+// blocks from height ind0 to ind1, in order of height.
+func (db *DB) RetrieveStakeInfoExtendedRange(ind0, ind1 int64) ([]*apitypes.StakeInfoExtended, error) {
+	rows, err := db.Query(db.getStakeInfoExtendedRangeSQL, ind0, ind1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sis []*apitypes.StakeInfoExtended
+	for rows.Next() {
+		si := new(apitypes.StakeInfoExtended)
+		err = rows.Scan(&si.Feeinfo.Height,
+			&si.Feeinfo.Number, &si.Feeinfo.Min, &si.Feeinfo.Max, &si.Feeinfo.Mean,
+			&si.Feeinfo.Median, &si.Feeinfo.StdDev,
+			&si.StakeDiff, // no next or estimates
+			&si.PriceWindowNum, &si.IdxBlockInWindow, &si.PoolInfo.Size,
+			&si.PoolInfo.Value, &si.PoolInfo.ValAvg)
+		if err != nil {
+			return nil, err
+		}
+		sis = append(sis, si)
+	}
+	return sis, rows.Err()
+}
+
 func logDBResult(res sql.Result) error {
 	if log.Level() > btclog.LevelTrace {
 		return nil
@@ -0,0 +1,73 @@
+package dcrsqlite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dcrdata/dcrdata/dbtypes"
+)
+
+func TestMempoolHistory(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	store := func(tm int64, numTickets uint32) {
+		snap := &dbtypes.MempoolSnapshot{
+			Time:       tm,
+			Height:     100,
+			NumTickets: numTickets,
+			FeeMin:     0.01,
+			FeeMean:    0.02,
+			FeeMedian:  0.02,
+			FeeMax:     0.1,
+		}
+		if err := db.StoreMempoolSnapshot(snap); err != nil {
+			t.Fatalf("StoreMempoolSnapshot failed: %v", err)
+		}
+	}
+	// numSamples returns the number of collections in each hour from t0.
+	const t0 = 1500000000
+	numSamples := func(hours int64) []uint32 {
+		bins, err := db.RetrieveMempoolHistory(t0, t0+hours*3600-1, 3600)
+		if err != nil {
+			t.Fatalf("RetrieveMempoolHistory failed: %v", err)
+		}
+		counts := make([]uint32, hours)
+		for _, b := range bins {
+			counts[b.Bin] = b.NumSamples
+		}
+		return counts
+	}
+	checkSamples := func(hours int64, expected ...uint32) {
+		counts := numSamples(hours)
+		for i := range expected {
+			if counts[i] != expected[i] {
+				t.Errorf("collections by hour %v, expected %v", counts, expected)
+				return
+			}
+		}
+	}
+
+	// With a retention of two hours, the collections more than two hours
+	// older than the latest are deleted as it is stored.
+	db.SetMempoolRetention(2 * time.Hour)
+	for i := int64(0); i < 4; i++ {
+		store(t0+i*3600, uint32(i))
+		store(t0+i*3600+1800, uint32(i))
+	}
+	checkSamples(4, 0, 1, 2, 2)
+
+	// The averages are over the remaining collections.
+	bins, err := db.RetrieveMempoolHistory(t0, t0+4*3600-1, 4*3600)
+	if err != nil {
+		t.Fatalf("RetrieveMempoolHistory failed: %v", err)
+	}
+	if len(bins) != 1 || bins[0].NumSamples != 5 || bins[0].NumTickets != 2.2 {
+		t.Errorf("unexpected bins %v", bins)
+	}
+
+	// With no retention limit, nothing more is deleted.
+	db.SetMempoolRetention(0)
+	store(t0+10*3600, 10)
+	checkSamples(11, 0, 1, 2, 2, 0, 0, 0, 0, 0, 0, 1)
+}
@@ -0,0 +1,326 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package dcrsqlite
+
+import (
+	"fmt"
+	"sync"
+
+	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/dcrdata/dcrdata/txhelpers"
+	"github.com/decred/dcrd/dcrjson"
+	"github.com/decred/dcrd/dcrutil"
+)
+
+// StakeDiffWindowCache contains a map of stake difficulty window numbers to
+// the statistics for the window. Only complete windows are cached.
+type StakeDiffWindowCache struct {
+	sync.RWMutex
+	windows map[int64]*apitypes.StakeDiffWindow
+}
+
+// NewStakeDiffWindowCache constructs a new StakeDiffWindowCache, and is needed
+// to initialize the internal map.
+func NewStakeDiffWindowCache() *StakeDiffWindowCache {
+	return &StakeDiffWindowCache{
+		windows: make(map[int64]*apitypes.StakeDiffWindow),
+	}
+}
+
+// Get attempts to fetch the statistics for the given window, returning the
+// statistics, and a bool indicating if the window was found in the map.
+func (c *StakeDiffWindowCache) Get(window int64) (*apitypes.StakeDiffWindow, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	w, ok := c.windows[window]
+	return w, ok
+}
+
+// Set stores the statistics for a window in the cache.
+func (c *StakeDiffWindowCache) Set(w *apitypes.StakeDiffWindow) {
+	c.Lock()
+	defer c.Unlock()
+	c.windows[w.Window] = w
+}
+
+// PurgeFrom removes the given window and all later windows from the cache.
+// This is used when a reorganization may have changed their blocks.
+func (c *StakeDiffWindowCache) PurgeFrom(window int64) {
+	c.Lock()
+	defer c.Unlock()
+	for n := range c.windows {
+		if n >= window {
+			delete(c.windows, n)
+		}
+	}
+}
+
+// purgeStakeDiffWindows removes the cached statistics of the windows including
+// blocks above the given height.
+func (db *wiredDB) purgeStakeDiffWindows(height int64) {
+	db.sdiffWindows.PurgeFrom((height + 1) / db.params.StakeDiffWindowSize)
+}
+
+// makeStakeDiffWindow computes the statistics of a window from the extended
+// stake info of its blocks, given the pool size at the end of the previous
+// window.
+func makeStakeDiffWindow(window, windowSize int64, prevPoolSize uint32,
+	sis []*apitypes.StakeInfoExtended) *apitypes.StakeDiffWindow {
+	w := &apitypes.StakeDiffWindow{
+		Window:      window,
+		StartHeight: window * windowSize,
+		EndHeight:   (window+1)*windowSize - 1,
+		Complete:    int64(len(sis)) == windowSize,
+	}
+	if len(sis) == 0 {
+		return w
+	}
+
+	w.StakeDiff = sis[0].StakeDiff
+	var feeSum float64
+	for _, si := range sis {
+		fi := &si.Feeinfo
+		if fi.Number == 0 {
+			continue
+		}
+		if w.NumTickets == 0 || fi.Min < w.FeeMin {
+			w.FeeMin = fi.Min
+		}
+		if fi.Max > w.FeeMax {
+			w.FeeMax = fi.Max
+		}
+		feeSum += fi.Mean * float64(fi.Number)
+		w.NumTickets += fi.Number
+	}
+	if w.NumTickets > 0 {
+		w.FeeMean = feeSum / float64(w.NumTickets)
+	}
+	w.PoolSizeChange = int64(sis[len(sis)-1].PoolInfo.Size) - int64(prevPoolSize)
+	return w
+}
+
+// GetStakeDiffWindows returns the statistics of the stake difficulty windows
+// from window w0 to w1, inclusive, or nil on error. The window containing the
+// best block is included but not complete. Windows beyond it are omitted.
+func (db *wiredDB) GetStakeDiffWindows(w0, w1 int64) []*apitypes.StakeDiffWindow {
+	windows, err := db.stakeDiffWindows(w0, w1)
+	if err != nil {
+		log.Errorf("Unable to get stake difficulty windows: %v", err)
+		return nil
+	}
+	return windows
+}
+
+// GetLatestStakeDiffWindows returns the statistics of the count most recent
+// stake difficulty windows, starting with the current window, or nil on error.
+func (db *wiredDB) GetLatestStakeDiffWindows(count int64) []*apitypes.StakeDiffWindow {
+	bestWindow := db.GetStakeInfoHeight() / db.params.StakeDiffWindowSize
+	w0 := bestWindow - count + 1
+	if w0 < 0 {
+		w0 = 0
+	}
+	windows := db.GetStakeDiffWindows(w0, bestWindow)
+	// Most recent first
+	for i, j := 0, len(windows)-1; i < j; i, j = i+1, j-1 {
+		windows[i], windows[j] = windows[j], windows[i]
+	}
+	return windows
+}
+
+func (db *wiredDB) stakeDiffWindows(w0, w1 int64) ([]*apitypes.StakeDiffWindow, error) {
+	if w1 < w0 || w0 < 0 {
+		return nil, fmt.Errorf("invalid window range [%d,%d]", w0, w1)
+	}
+	windowSize := db.params.StakeDiffWindowSize
+	bestHeight := db.GetStakeInfoHeight()
+	if bestHeight < 0 {
+		return nil, fmt.Errorf("no stake info")
+	}
+	if bestWindow := bestHeight / windowSize; w1 > bestWindow {
+		w1 = bestWindow
+	}
+	if w0 > w1 {
+		return []*apitypes.StakeDiffWindow{}, nil
+	}
+
+	windows := make([]*apitypes.StakeDiffWindow, 0, w1-w0+1)
+	for n := w0; n <= w1; {
+		if w, ok := db.sdiffWindows.Get(n); ok {
+			windows = append(windows, w)
+			n++
+			continue
+		}
+
+		// Retrieve the stake info for the run of uncached windows, plus the
+		// last block of the previous window for the pool size change.
+		end := n
+		for end < w1 {
+			if _, ok := db.sdiffWindows.Get(end + 1); ok {
+				break
+			}
+			end++
+		}
+		ind0, ind1 := n*windowSize-1, (end+1)*windowSize-1
+		if ind0 < 0 {
+			ind0 = 0
+		}
+		sis, err := db.RetrieveStakeInfoExtendedRange(ind0, ind1)
+		if err != nil {
+			return nil, fmt.Errorf("RetrieveStakeInfoExtendedRange failed: %v", err)
+		}
+
+		var prevPoolSize uint32
+		if n > 0 && len(sis) > 0 && int64(sis[0].Feeinfo.Height) == n*windowSize-1 {
+			prevPoolSize = sis[0].PoolInfo.Size
+			sis = sis[1:]
+		}
+		for ; n <= end; n++ {
+			// Take the blocks of window n from the front of sis.
+			var i int
+			for i < len(sis) && int64(sis[i].Feeinfo.Height) < (n+1)*windowSize {
+				i++
+			}
+			w := makeStakeDiffWindow(n, windowSize, prevPoolSize, sis[:i])
+			if w.Complete {
+				db.sdiffWindows.Set(w)
+			}
+			windows = append(windows, w)
+			if i > 0 {
+				prevPoolSize = sis[i-1].PoolInfo.Size
+			}
+			sis = sis[i:]
+		}
+	}
+
+	return windows, nil
+}
+
+// stakeDiffHistory builds the block data needed by the stake difficulty
+// algorithm from the extended stake info of the blocks from the start of the
+// previous retarget interval's immature tickets to the best block. The pool
+// sizes and best block's ticket price are taken from the block headers, since
+// the stored pool info may be from the stake DB, which includes the tickets
+// maturing in each block.
+func (db *wiredDB) stakeDiffHistory() (txhelpers.StakeDiffHistory, error) {
+	bestHeight := db.GetStakeInfoHeight()
+	if bestHeight < 0 {
+		return nil, fmt.Errorf("no stake info")
+	}
+	start := txhelpers.StakeDiffHistoryStart(db.params, bestHeight)
+	sis, err := db.RetrieveStakeInfoExtendedRange(start, bestHeight)
+	if err != nil {
+		return nil, fmt.Errorf("RetrieveStakeInfoExtendedRange failed: %v", err)
+	}
+	if int64(len(sis)) != bestHeight-start+1 {
+		return nil, fmt.Errorf("stake info missing for blocks in [%d,%d]",
+			start, bestHeight)
+	}
+
+	history := make(txhelpers.StakeDiffHistory, 0, len(sis))
+	for i, si := range sis {
+		height := start + int64(i)
+		if int64(si.Feeinfo.Height) != height {
+			return nil, fmt.Errorf("stake info missing for block %d", height)
+		}
+		history = append(history, txhelpers.StakeDiffBlock{
+			Height:     height,
+			FreshStake: uint8(si.Feeinfo.Number),
+		})
+	}
+
+	for _, height := range txhelpers.StakeDiffPoolSizeHeights(db.params, bestHeight) {
+		hash, err := db.client.GetBlockHash(height)
+		if err != nil {
+			return nil, fmt.Errorf("GetBlockHash(%d) failed: %v", height, err)
+		}
+		header, err := db.client.GetBlockHeader(hash)
+		if err != nil {
+			return nil, fmt.Errorf("GetBlockHeader(%v) failed: %v", hash, err)
+		}
+		b := &history[height-start]
+		b.PoolSize = header.PoolSize
+		b.SBits = header.SBits
+	}
+
+	return history, nil
+}
+
+// EstimateStakeDiff estimates the stake difficulty of the next window. If
+// tickets is not nil, the price assuming that many more purchases in the
+// window is also estimated, as the user estimate. The estimates are made
+// locally by estimateStakeDiff, falling back to dcrd's estimatestakediff if
+// the stored block data is insufficient.
+func (db *wiredDB) EstimateStakeDiff(tickets *uint32) (*dcrjson.EstimateStakeDiffResult, error) {
+	est, err := db.estimateStakeDiff(tickets)
+	if err != nil {
+		log.Debugf("Unable to estimate stake difficulty locally, "+
+			"using dcrd: %v", err)
+		return db.client.EstimateStakeDiff(tickets)
+	}
+	return est, nil
+}
+
+// estimateStakeDiff estimates the stake difficulty of the next window using
+// the stored block data and mempool, without dcrd's estimatestakediff. The
+// expected price assumes tickets continue to be purchased at the average rate
+// so far in the window, but at least as many as are in mempool. If tickets is
+// not nil, the price assuming that many more purchases in the window is also
+// estimated. An error is returned if tickets is more than can still be mined
+// in the window.
+func (db *wiredDB) estimateStakeDiff(tickets *uint32) (*dcrjson.EstimateStakeDiffResult, error) {
+	history, err := db.stakeDiffHistory()
+	if err != nil {
+		return nil, err
+	}
+	bestHeight := history[len(history)-1].Height
+
+	estimate := func(newTickets int64, useMaxTickets bool) (float64, error) {
+		sdiff, err := txhelpers.EstimateNextStakeDiff(db.params, history,
+			newTickets, useMaxTickets)
+		if err != nil {
+			return 0, err
+		}
+		return dcrutil.Amount(sdiff).ToCoin(), nil
+	}
+
+	min, err := estimate(0, false)
+	if err != nil {
+		return nil, err
+	}
+	max, err := estimate(0, true)
+	if err != nil {
+		return nil, err
+	}
+
+	expectedTickets, err := txhelpers.ExpectedNewTickets(db.params, history)
+	if err != nil {
+		return nil, err
+	}
+	mpHeight, mpTickets := db.MPC.GetNumTickets()
+	if int64(mpHeight) == bestHeight && int64(mpTickets) > expectedTickets {
+		expectedTickets = int64(mpTickets)
+	}
+	maxRemaining := txhelpers.MaxRemainingTickets(db.params, bestHeight)
+	if expectedTickets > maxRemaining {
+		expectedTickets = maxRemaining
+	}
+	expected, err := estimate(expectedTickets, false)
+	if err != nil {
+		return nil, err
+	}
+
+	est := &dcrjson.EstimateStakeDiffResult{
+		Min:      min,
+		Max:      max,
+		Expected: expected,
+	}
+	if tickets != nil {
+		user, err := estimate(int64(*tickets), false)
+		if err != nil {
+			return nil, err
+		}
+		est.User = &user
+	}
+	return est, nil
+}
@@ -0,0 +1,246 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package dcrsqlite
+
+import (
+	"fmt"
+
+	"github.com/dcrdata/dcrdata/dbtypes"
+	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/dcrdata/dcrdata/explorer"
+)
+
+// stakeVersionInterval returns the stake version interval containing the block
+// at the given height, and the interval's first block height.
+func (db *wiredDB) stakeVersionInterval(height int64) (int64, int64) {
+	return db.stakeInterval(height, db.params.StakeVersionInterval)
+}
+
+// setVersionPercents sets the percentage of the total for each version count.
+func setVersionPercents(counts []apitypes.VersionCount, total uint32) {
+	if total == 0 {
+		return
+	}
+	for i := range counts {
+		counts[i].Percent = 100 * float64(counts[i].Count) / float64(total)
+	}
+}
+
+// versionWindows counts the block, stake and vote versions in the blocks from
+// height ind0 to ind1, in windows of binSize blocks starting at ind0. The last
+// window ends at ind1, so it may be shorter.
+func (db *wiredDB) versionWindows(ind0, ind1, binSize int64) ([]*apitypes.VersionWindow, error) {
+	if ind1 < ind0 || ind0 < 0 || binSize < 1 {
+		return nil, fmt.Errorf("invalid block range [%d,%d] or bin size %d",
+			ind0, ind1, binSize)
+	}
+
+	numBins := (ind1-ind0)/binSize + 1
+	windows := make([]*apitypes.VersionWindow, 0, numBins)
+	for i := int64(0); i < numBins; i++ {
+		start := ind0 + i*binSize
+		end := start + binSize - 1
+		if end > ind1 {
+			end = ind1
+		}
+		windows = append(windows, &apitypes.VersionWindow{
+			StartHeight: start,
+			EndHeight:   end,
+		})
+	}
+
+	versionTypes := []dbtypes.VersionType{dbtypes.BlockVersion,
+		dbtypes.StakeVersion, dbtypes.VoteVersion}
+	for _, vt := range versionTypes {
+		counts, err := db.RetrieveVersionCounts(vt, ind0, ind1, binSize)
+		if err != nil {
+			return nil, fmt.Errorf("RetrieveVersionCounts failed: %v", err)
+		}
+		for _, c := range counts {
+			if c.Bin < 0 || c.Bin >= numBins {
+				continue
+			}
+			w := windows[c.Bin]
+			vc := apitypes.VersionCount{Version: c.Version, Count: c.Count}
+			switch vt {
+			case dbtypes.BlockVersion:
+				w.BlockVersions = append(w.BlockVersions, vc)
+				w.Blocks += c.Count
+			case dbtypes.StakeVersion:
+				w.StakeVersions = append(w.StakeVersions, vc)
+			case dbtypes.VoteVersion:
+				w.VoteVersions = append(w.VoteVersions, vc)
+				w.Votes += c.Count
+			}
+		}
+	}
+
+	for _, w := range windows {
+		setVersionPercents(w.BlockVersions, w.Blocks)
+		setVersionPercents(w.StakeVersions, w.Blocks)
+		setVersionPercents(w.VoteVersions, w.Votes)
+	}
+	return windows, nil
+}
+
+// GetVersionWindow returns the block, stake and vote versions in the blocks
+// from height idx0 to idx, or nil on error. The range is cut off at the best
+// block in the block versions table.
+func (db *wiredDB) GetVersionWindow(idx0, idx int64) *apitypes.VersionWindow {
+	if height := db.GetBlockVersionsHeight(); idx > height {
+		idx = height
+	}
+	windows, err := db.versionWindows(idx0, idx, idx-idx0+1)
+	if err != nil {
+		log.Errorf("Unable to get versions for blocks %d to %d: %v", idx0, idx, err)
+		return nil
+	}
+	return windows[0]
+}
+
+// GetLatestVersionWindow returns the block, stake and vote versions in the N
+// most recent blocks, or nil on error.
+func (db *wiredDB) GetLatestVersionWindow(N int64) *apitypes.VersionWindow {
+	height := db.GetBlockVersionsHeight()
+	start := height - N + 1
+	if start < 0 {
+		start = 0
+	}
+	return db.GetVersionWindow(start, height)
+}
+
+// GetStakeVersionsSummary returns the block, stake and vote versions in the
+// blocks that dcrd checks for block version upgrades, and in the current and
+// previous stake version intervals, or nil on error.
+func (db *wiredDB) GetStakeVersionsSummary() *apitypes.StakeVersionsSummary {
+	height := db.GetBlockVersionsHeight()
+	if height < 0 {
+		log.Errorf("The block versions table is empty")
+		return nil
+	}
+
+	summary := &apitypes.StakeVersionsSummary{
+		Height:     height,
+		LastBlocks: db.GetLatestVersionWindow(int64(db.params.BlockUpgradeNumToCheck)),
+	}
+	if summary.LastBlocks == nil {
+		return nil
+	}
+
+	n, start := db.stakeVersionInterval(height)
+	if n < 0 {
+		return summary
+	}
+	if summary.CurrentInterval = db.GetVersionWindow(start, height); summary.CurrentInterval == nil {
+		return nil
+	}
+	if n > 0 {
+		prevStart := start - db.params.StakeVersionInterval
+		if summary.PreviousInterval = db.GetVersionWindow(prevStart, start-1); summary.PreviousInterval == nil {
+			return nil
+		}
+	}
+	return summary
+}
+
+// GetStakeVersionIntervals returns the block, stake and vote versions in each
+// stake version interval from stake validation height to the best block, or
+// nil on error. The last interval is incomplete.
+func (db *wiredDB) GetStakeVersionIntervals() []*apitypes.VersionWindow {
+	height := db.GetBlockVersionsHeight()
+	svh := db.params.StakeValidationHeight
+	if height < svh {
+		return []*apitypes.VersionWindow{}
+	}
+
+	windows, err := db.versionWindows(svh, height, db.params.StakeVersionInterval)
+	if err != nil {
+		log.Errorf("Unable to get stake version intervals: %v", err)
+		return nil
+	}
+	return windows
+}
+
+// makeVersionWindowInfo converts a version window for the explorer.
+func makeVersionWindowInfo(title string, w *apitypes.VersionWindow) explorer.VersionWindowInfo {
+	info := explorer.VersionWindowInfo{
+		Title:       title,
+		StartHeight: w.StartHeight,
+		EndHeight:   w.EndHeight,
+		Blocks:      w.Blocks,
+		Votes:       w.Votes,
+	}
+	convert := func(counts []apitypes.VersionCount) []explorer.VersionPercent {
+		percents := make([]explorer.VersionPercent, 0, len(counts))
+		for _, c := range counts {
+			percents = append(percents, explorer.VersionPercent{
+				Version: c.Version,
+				Count:   c.Count,
+				Percent: c.Percent,
+			})
+		}
+		return percents
+	}
+	info.BlockVersions = convert(w.BlockVersions)
+	info.StakeVersions = convert(w.StakeVersions)
+	info.VoteVersions = convert(w.VoteVersions)
+	return info
+}
+
+// versionSeries converts the version counts of each window to a percentage
+// series for each version, with zeros for the windows without the version.
+func versionSeries(windows []*apitypes.VersionWindow,
+	counts func(w *apitypes.VersionWindow) []apitypes.VersionCount) map[uint32][]float64 {
+	series := make(map[uint32][]float64)
+	for i, w := range windows {
+		for _, c := range counts(w) {
+			if _, ok := series[c.Version]; !ok {
+				series[c.Version] = make([]float64, len(windows))
+			}
+			series[c.Version][i] = c.Percent
+		}
+	}
+	return series
+}
+
+// GetExplorerVersions returns the upgrade progress of the block, stake and
+// vote versions for the explorer's versions page, or nil on error.
+func (db *wiredDB) GetExplorerVersions() *explorer.VersionsInfo {
+	summary := db.GetStakeVersionsSummary()
+	if summary == nil {
+		return nil
+	}
+	intervals := db.GetStakeVersionIntervals()
+	if intervals == nil {
+		return nil
+	}
+
+	info := &explorer.VersionsInfo{
+		Height: summary.Height,
+		Windows: []explorer.VersionWindowInfo{
+			makeVersionWindowInfo(fmt.Sprintf("Last %d Blocks",
+				db.params.BlockUpgradeNumToCheck), summary.LastBlocks),
+		},
+	}
+	if summary.CurrentInterval != nil {
+		info.Windows = append(info.Windows, makeVersionWindowInfo(
+			"Current Stake Version Interval", summary.CurrentInterval))
+	}
+	if summary.PreviousInterval != nil {
+		info.Windows = append(info.Windows, makeVersionWindowInfo(
+			"Previous Stake Version Interval", summary.PreviousInterval))
+	}
+
+	info.Chart.Heights = make([]int64, 0, len(intervals))
+	for _, w := range intervals {
+		info.Chart.Heights = append(info.Chart.Heights, w.StartHeight)
+	}
+	info.Chart.BlockVersions = versionSeries(intervals,
+		func(w *apitypes.VersionWindow) []apitypes.VersionCount { return w.BlockVersions })
+	info.Chart.StakeVersions = versionSeries(intervals,
+		func(w *apitypes.VersionWindow) []apitypes.VersionCount { return w.StakeVersions })
+	info.Chart.VoteVersions = versionSeries(intervals,
+		func(w *apitypes.VersionWindow) []apitypes.VersionCount { return w.VoteVersions })
+	return info
+}
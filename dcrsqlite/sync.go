@@ -7,10 +7,13 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/dcrdata/dcrdata/chaindb"
 	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
 	"github.com/dcrdata/dcrdata/txhelpers"
 	"github.com/decred/dcrd/chaincfg/chainhash"
 	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/dcrd/rpcclient"
+	"github.com/decred/dcrd/wire"
 )
 
 const (
@@ -19,8 +22,49 @@ const (
 	dbType = "ffldb"
 	// DefaultStakeDbName is the default database name
 	DefaultStakeDbName = "ffldb_stake"
+	// DefaultSyncBatchSize is the default number of blocks fetched and stored
+	// together in a resync
+	DefaultSyncBatchSize = 500
 )
 
+// syncProgress tracks the progress of a resync for logging the sync rate and
+// estimated time remaining.
+type syncProgress struct {
+	start       time.Time
+	startHeight int64
+}
+
+func newSyncProgress(startHeight int64) *syncProgress {
+	return &syncProgress{
+		start:       time.Now(),
+		startHeight: startHeight,
+	}
+}
+
+// log reports the progress after syncing to height, with the chain at
+// bestHeight.
+func (p *syncProgress) log(height, bestHeight int64) {
+	done := height - p.startHeight + 1
+	elapsed := time.Since(p.start).Seconds()
+	if done < 1 || elapsed <= 0 {
+		return
+	}
+	rate := float64(done) / elapsed
+	eta := time.Duration(float64(bestHeight-height)/rate) * time.Second
+	log.Infof("Synced to height %d of %d (%.1f blocks/s, ETA %v).",
+		height, bestHeight, rate, eta)
+}
+
+// quitting checks the quit channel without blocking.
+func quitting(quit chan struct{}) bool {
+	select {
+	case <-quit:
+		return true
+	default:
+		return false
+	}
+}
+
 func (db *wiredDB) resyncDB(quit chan struct{}) error {
 	// Get chain servers's best block
 	_, height, err := db.client.GetBestBlock()
@@ -31,16 +75,37 @@ func (db *wiredDB) resyncDB(quit chan struct{}) error {
 	// Get DB's best block (for block summary and stake info tables)
 	bestBlockHeight := db.GetBlockSummaryHeight()
 	bestStakeHeight := db.GetStakeInfoHeight()
+	bestAddrHeight := db.GetAddressHeight()
+	bestTxHeight := db.GetTransactionHeight()
+	bestVoteHeight := db.voteIndexHeight()
+	bestVersionsHeight := db.GetBlockVersionsHeight()
 
 	log.Info("Current best block (chain server): ", height)
 	log.Info("Current best block (summary DB):   ", bestBlockHeight)
 	log.Info("Current best block (stakeinfo DB): ", bestStakeHeight)
+	log.Info("Current best block (address DB):   ", bestAddrHeight)
+	log.Info("Current best block (tx DB):        ", bestTxHeight)
+	log.Info("Current best block (votes DB):     ", bestVoteHeight)
+	log.Info("Current best block (versions DB):  ", bestVersionsHeight)
 
-	// Start with the older of summary or stake table heights
+	// Start with the older of summary, stake, address, transaction, vote or
+	// block versions table heights
 	i := bestStakeHeight
 	if bestBlockHeight < bestStakeHeight {
 		i = bestBlockHeight
 	}
+	if bestAddrHeight < i {
+		i = bestAddrHeight
+	}
+	if bestTxHeight < i {
+		i = bestTxHeight
+	}
+	if bestVoteHeight < i {
+		i = bestVoteHeight
+	}
+	if bestVersionsHeight < i {
+		i = bestVersionsHeight
+	}
 	if i < -1 {
 		i = -1
 	}
@@ -54,74 +119,77 @@ func (db *wiredDB) resyncDB(quit chan struct{}) error {
 
 	startHeight := i + 1
 	log.Infof("Resyncing from %v", startHeight)
+	summaryHeight := bestBlockHeight
+	if bestStakeHeight < summaryHeight {
+		summaryHeight = bestStakeHeight
+	}
+	if summaryHeight >= startHeight {
+		log.Infof("Indexing addresses, transactions, votes and versions for blocks %d to %d.",
+			startHeight, summaryHeight)
+	}
 
-	winSize := uint32(db.params.StakeDiffWindowSize)
-
-	// Only save the block summaries we don't have in the DB yet
-	//blockSummaries := make([]apitypes.BlockDataBasic, 0, minBlocksToCheck+1)
+	progress := newSyncProgress(startHeight)
+	fetcher := db.newBlockFetcher(startHeight)
 
-	for i = startHeight; i <= height; i++ {
+	for i = startHeight; i <= height; {
 		// check for quit signal
-		select {
-		case <-quit:
+		if quitting(quit) {
 			return nil
-		default:
 		}
 
-		block, blockhash, err := db.getBlock(i)
+		blocks, err := fetcher.next(height)
 		if err != nil {
-			return fmt.Errorf("GetBlock failed (%s): %v", blockhash, err)
-		}
-
-		if i%rescanLogBlockChunk == 0 {
-			log.Infof("Scanning blocks %d to %d...", i, i+rescanLogBlockChunk)
-		}
-
-		header := block.MsgBlock().Header
-		diffRatio := txhelpers.GetDifficultyRatio(header.Bits, db.params)
-
-		blockSummary := apitypes.BlockDataBasic{
-			Height:     header.Height,
-			Size:       header.Size,
-			Hash:       blockhash.String(),
-			Difficulty: diffRatio,
-			StakeDiff:  dcrutil.Amount(header.SBits).ToCoin(),
-			Time:       header.Timestamp.Unix(),
-			PoolInfo: apitypes.TicketPoolInfo{
-				Size: header.PoolSize,
-			},
+			return err
 		}
+		end := i + int64(len(blocks)) - 1
+
+		// The address, transaction, votes and block versions tables skip the
+		// blocks they already have, but the summary and stake info rows would
+		// be replaced, losing their pool values, so they are only made for
+		// the blocks above those tables' own heights.
+		blockSummaries := make([]*apitypes.BlockDataBasic, 0, len(blocks))
+		stakeInfos := make([]*apitypes.StakeInfoExtended, 0, len(blocks))
+		blockIndexes := make([]*chaindb.BlockIndex, 0, len(blocks))
+		for _, block := range blocks {
+			blockIndexes = append(blockIndexes,
+				chaindb.NewBlockIndex(block.MsgBlock(), db.params))
+
+			blockHeight := block.Height()
+			if blockHeight <= bestBlockHeight && blockHeight <= bestStakeHeight {
+				continue
+			}
 
-		if err = db.StoreBlockSummary(&blockSummary); err != nil {
-			return fmt.Errorf("Unable to store block summary in database: %v", err)
-		}
+			// Ticket pool info (just size in this function)
+			tpi := &apitypes.TicketPoolInfo{
+				Size: block.MsgBlock().Header.PoolSize,
+			}
 
-		// Stake info
-		si := apitypes.StakeInfoExtended{}
+			if blockHeight > bestBlockHeight {
+				blockSummaries = append(blockSummaries, db.makeBlockSummary(block, tpi))
+			}
 
-		// Ticket fee info
-		fib := txhelpers.FeeRateInfoBlock(block)
-		if fib == nil {
-			return fmt.Errorf("FeeRateInfoBlock failed")
+			// Stake info
+			if blockHeight > bestStakeHeight {
+				si, err := db.makeStakeInfo(block, tpi)
+				if err != nil {
+					return err
+				}
+				stakeInfos = append(stakeInfos, si)
+			}
 		}
-		si.Feeinfo = *fib
-
-		// Price window number and block index
-		si.PriceWindowNum = int(i) / int(winSize)
-		si.IdxBlockInWindow = int(i)%int(winSize) + 1
-
-		// Ticket pool info (just size in this function)
-		si.PoolInfo = blockSummary.PoolInfo
 
-		if err = db.StoreStakeInfoExtended(&si); err != nil {
-			return fmt.Errorf("Unable to store stake info in database: %v", err)
+		if err = db.StoreBlockBatch(blockSummaries, stakeInfos, blockIndexes); err != nil {
+			return fmt.Errorf("Unable to store block data in database: %v", err)
 		}
 
-		// update height
+		// update height, the end condition for the loop
 		_, height, err = db.client.GetBestBlock()
 		if err != nil {
 			return fmt.Errorf("GetBestBlock failed: %v", err)
 		}
+
+		progress.log(end, height)
+		i = end + 1
 	}
 
 	log.Info("Resync complete.")
@@ -146,6 +214,11 @@ func (db *wiredDB) resyncDBWithPoolValue(quit chan struct{}) error {
 	// Get DB's best block (for block summary and stake info tables)
 	bestBlockHeight := db.GetBlockSummaryHeight()
 	bestStakeHeight := db.GetStakeInfoHeight()
+	bestAddrHeight := db.GetAddressHeight()
+	bestTxHeight := db.GetTransactionHeight()
+	bestTicketHeight := db.ticketIndexHeight()
+	bestVoteHeight := db.voteIndexHeight()
+	bestVersionsHeight := db.GetBlockVersionsHeight()
 
 	// Create a new database to store the accepted stake node data into.
 	if db.sDB == nil || db.sDB.BestNode == nil {
@@ -157,53 +230,86 @@ func (db *wiredDB) resyncDBWithPoolValue(quit chan struct{}) error {
 	log.Info("Current best block (summary DB):   ", bestBlockHeight)
 	log.Info("Current best block (stakeinfo DB): ", bestStakeHeight)
 	log.Info("Current best block (ticketdb):     ", bestNodeHeight)
-
-	// Start with the older of summary or stake table heights
+	log.Info("Current best block (address DB):   ", bestAddrHeight)
+	log.Info("Current best block (tx DB):        ", bestTxHeight)
+	log.Info("Current best block (tickets DB):   ", bestTicketHeight)
+	log.Info("Current best block (votes DB):     ", bestVoteHeight)
+	log.Info("Current best block (versions DB):  ", bestVersionsHeight)
+
+	// Start with the older of summary, stake or tickets table heights. The
+	// pool info and ticket status changes are only known for the blocks
+	// connected to the stake DB, so it is rewound to that height, or rebuilt
+	// from genesis if rewinding would take longer (e.g. when the tickets table
+	// of an upgraded database is empty).
 	startHeight := bestStakeHeight
-	if bestBlockHeight < bestStakeHeight {
+	if bestBlockHeight < startHeight {
 		startHeight = bestBlockHeight
 	}
+	if bestTicketHeight < startHeight {
+		startHeight = bestTicketHeight
+	}
 	if bestNodeHeight < startHeight {
 		startHeight = bestNodeHeight
 	} else if bestNodeHeight > startHeight && bestNodeHeight > 0 {
 		if startHeight < 0 || bestNodeHeight > 2*startHeight {
-			// log.Debug("Creating new stake db.")
-			// if err = stakeDB.Update(func(dbTx database.Tx) error {
-			// 	var errLocal error
-			// 	bestNode, errLocal = stake.InitDatabaseState(dbTx, db.params)
-			// 	return errLocal
-			// }); err != nil {
-			// 	return err
-			// }
-			return fmt.Errorf("delete stake db (ffldb_stake) and try again")
-		}
-		log.Infof("Rewinding stake node from %d to %d", bestNodeHeight, startHeight)
-		// rewind best node in ticket db
-		for bestNodeHeight > startHeight {
-			// check for quit signal
-			select {
-			case <-quit:
-				log.Infof("Rewind cancelled at height %d.", bestNodeHeight)
-				return nil
-			default:
-			}
-			if err = db.sDB.DisconnectBlock(); err != nil {
-				return err
+			log.Infof("Rebuilding stake db from genesis instead of rewinding "+
+				"from %d to %d.", bestNodeHeight, startHeight)
+			if err = db.sDB.Reset(); err != nil {
+				return fmt.Errorf("Unable to reset stake db: %v", err)
 			}
 			bestNodeHeight = int64(db.sDB.Height())
-			log.Infof("Stake db now at height %d.", bestNodeHeight)
-		}
-		if bestNodeHeight != startHeight {
-			panic("rewind failed")
+			startHeight = bestNodeHeight
+		} else {
+			log.Infof("Rewinding stake node from %d to %d", bestNodeHeight, startHeight)
+			// rewind best node in ticket db
+			for bestNodeHeight > startHeight {
+				// check for quit signal
+				select {
+				case <-quit:
+					log.Infof("Rewind cancelled at height %d.", bestNodeHeight)
+					return nil
+				default:
+				}
+				if err = db.sDB.DisconnectBlock(); err != nil {
+					return err
+				}
+				bestNodeHeight = int64(db.sDB.Height())
+				log.Infof("Stake db now at height %d.", bestNodeHeight)
+			}
+			if bestNodeHeight != startHeight {
+				panic("rewind failed")
+			}
 		}
 	}
 	if startHeight < -1 {
 		startHeight = -1
 	}
 
+	// The address, transaction, votes and block versions tables are indexed
+	// independently of the stake DB, so they may require scanning blocks that
+	// are already in the other tables (e.g. when a table is new). Those blocks
+	// are not connected to the stake DB, which does not need rewinding on
+	// their account.
+	addrStartHeight := startHeight
+	if bestAddrHeight < addrStartHeight {
+		addrStartHeight = bestAddrHeight
+	}
+	if bestTxHeight < addrStartHeight {
+		addrStartHeight = bestTxHeight
+	}
+	if bestVoteHeight < addrStartHeight {
+		addrStartHeight = bestVoteHeight
+	}
+	if bestVersionsHeight < addrStartHeight {
+		addrStartHeight = bestVersionsHeight
+	}
+	if addrStartHeight < -1 {
+		addrStartHeight = -1
+	}
+
 	// At least this many blocks to check (at least because another might come
 	// in during this process).
-	minBlocksToCheck := height - startHeight
+	minBlocksToCheck := height - addrStartHeight
 	if minBlocksToCheck < 1 {
 		if minBlocksToCheck < 0 {
 			log.Warn("Chain server behind DBs!")
@@ -213,117 +319,197 @@ func (db *wiredDB) resyncDBWithPoolValue(quit chan struct{}) error {
 
 	// Start at next block we don't have in every DB
 	startHeight++
+	addrStartHeight++
 
-	// a ticket treap would be nice, but a map will do for a cache
-	//liveTicketCache := make(map[chainhash.Hash]int64)
+	if addrStartHeight < startHeight {
+		log.Infof("Indexing addresses, transactions, votes and versions for blocks %d to %d.",
+			addrStartHeight, startHeight-1)
+	}
+
+	progress := newSyncProgress(addrStartHeight)
+	fetcher := db.newBlockFetcher(addrStartHeight)
 
-	for i := startHeight; i <= height; i++ {
+	for i := addrStartHeight; i <= height; {
 		// check for quit signal
-		select {
-		case <-quit:
+		if quitting(quit) {
 			log.Infof("Rescan cancelled at height %d.", i)
 			return nil
-		default:
 		}
 
-		block, blockhash, err := db.getBlock(i)
+		blocks, err := fetcher.next(height)
 		if err != nil {
-			return fmt.Errorf("GetBlock failed (%s): %v", blockhash, err)
+			return err
 		}
+		end := i + int64(len(blocks)) - 1
+
+		// Rows for all tables are collected for the whole batch and stored in
+		// a single transaction. The stake DB is updated block by block, so a
+		// cancelled batch is stored up to the last block connected to the
+		// stake DB.
+		blockSummaries := make([]*apitypes.BlockDataBasic, 0, len(blocks))
+		stakeInfos := make([]*apitypes.StakeInfoExtended, 0, len(blocks))
+		blockIndexes := make([]*chaindb.BlockIndex, 0, len(blocks))
+		cancelled := false
+		for _, block := range blocks {
+			if quitting(quit) {
+				log.Infof("Rescan cancelled at height %d.", block.Height())
+				cancelled = true
+				break
+			}
 
-		if i > bestNodeHeight {
-			if i != int64(db.sDB.Height()+1) {
-				panic(fmt.Sprintf("about to connect the wrong block: %d, %d", i, db.sDB.Height()))
+			blockHeight := block.Height()
+			if blockHeight < startHeight {
+				blockIndexes = append(blockIndexes,
+					chaindb.NewBlockIndex(block.MsgBlock(), db.params))
+				continue
 			}
-			if err = db.sDB.ConnectBlock(block); err != nil {
-				return err
+
+			if blockHeight > bestNodeHeight {
+				if blockHeight != int64(db.sDB.Height()+1) {
+					panic(fmt.Sprintf("about to connect the wrong block: %d, %d", blockHeight, db.sDB.Height()))
+				}
+				if err = db.sDB.ConnectBlock(block); err != nil {
+					return err
+				}
 			}
-		}
 
-		numLive := db.sDB.BestNode.PoolSize()
-		//liveTickets := db.sDB.BestNode.LiveTickets()
-		// TODO: winning tickets
-		//winningTickets := db.sDB.BestNode.Winners()
+			blockIndexes = append(blockIndexes, db.blockIndex(block.MsgBlock()))
+
+			var tpi *apitypes.TicketPoolInfo
+			var found bool
+			if tpi, found = db.sDB.PoolInfo(*block.Hash()); !found {
+				log.Warnf("Unable to find block (%s) in pool info cache. Resync is malfunctioning!", block.Hash())
+				ticketPoolInfo, sdbHeight := db.sDB.PoolInfoBest()
+				if int64(sdbHeight) != blockHeight {
+					log.Errorf("Collected block height %d != stake db height %d. Pool info "+
+						"will not match the rest of this block's data.", blockHeight, sdbHeight)
+				}
+				tpi = &ticketPoolInfo
+			}
 
-		if (i-1)%rescanLogBlockChunk == 0 || i == startHeight {
-			endRangeBlock := rescanLogBlockChunk * (1 + (i-1)/rescanLogBlockChunk)
-			if endRangeBlock > height {
-				endRangeBlock = height
+			if blockHeight > bestBlockHeight {
+				blockSummaries = append(blockSummaries, db.makeBlockSummary(block, tpi))
 			}
-			log.Infof("Scanning blocks %d to %d (%d live)...",
-				i, endRangeBlock, numLive)
-		}
 
-		var tpi *apitypes.TicketPoolInfo
-		var found bool
-		if tpi, found = db.sDB.PoolInfo(*blockhash); !found {
-			log.Warnf("Unable to find block (%s) in pool info cache. Resync is malfunctioning!", blockhash.String())
-			ticketPoolInfo, sdbHeight := db.sDB.PoolInfoBest()
-			if int64(sdbHeight) != i {
-				log.Errorf("Collected block height %d != stake db height %d. Pool info "+
-					"will not match the rest of this block's data.", height, i)
+			if blockHeight > bestStakeHeight {
+				si, err := db.makeStakeInfo(block, tpi)
+				if err != nil {
+					return err
+				}
+				stakeInfos = append(stakeInfos, si)
 			}
-			tpi = &ticketPoolInfo
 		}
 
-		header := block.MsgBlock().Header
-		diffRatio := txhelpers.GetDifficultyRatio(header.Bits, db.params)
-
-		blockSummary := apitypes.BlockDataBasic{
-			Height:     header.Height,
-			Size:       header.Size,
-			Hash:       blockhash.String(),
-			Difficulty: diffRatio,
-			StakeDiff:  dcrutil.Amount(header.SBits).ToCoin(),
-			Time:       header.Timestamp.Unix(),
-			PoolInfo:   *tpi,
+		if err = db.StoreBlockBatch(blockSummaries, stakeInfos, blockIndexes); err != nil {
+			return fmt.Errorf("Unable to store block data in database: %v", err)
 		}
-
-		if i > bestBlockHeight {
-			if err = db.StoreBlockSummary(&blockSummary); err != nil {
-				return fmt.Errorf("Unable to store block summary in database: %v", err)
-			}
+		if cancelled {
+			return nil
 		}
 
-		if i <= bestStakeHeight {
-			// update height, the end condition for the loop
-			if _, height, err = db.client.GetBestBlock(); err != nil {
-				return fmt.Errorf("GetBestBlock failed: %v", err)
-			}
-			continue
+		// update height, the end condition for the loop
+		if _, height, err = db.client.GetBestBlock(); err != nil {
+			return fmt.Errorf("GetBestBlock failed: %v", err)
 		}
 
-		// Stake info
-		si := apitypes.StakeInfoExtended{}
+		progress.log(end, height)
+		i = end + 1
+	}
 
-		// Ticket fee info
-		fib := txhelpers.FeeRateInfoBlock(block)
-		if fib == nil {
-			return fmt.Errorf("FeeRateInfoBlock failed")
-		}
-		si.Feeinfo = *fib
+	log.Infof("Rescan finished successfully at height %d.", height)
 
-		// Price window number and block index
-		winSize := uint32(db.params.StakeDiffWindowSize)
-		si.PriceWindowNum = int(i) / int(winSize)
-		si.IdxBlockInWindow = int(i)%int(winSize) + 1
+	return nil
+}
 
-		// Ticket pool info
-		si.PoolInfo = blockSummary.PoolInfo
+// getBlockRange fetches the blocks from height start to end, inclusive. The
+// requests are pipelined with asynchronous RPCs, with each block requested as
+// soon as its hash is received.
+func (db *wiredDB) getBlockRange(start, end int64) ([]*dcrutil.Block, error) {
+	hashFutures := make([]rpcclient.FutureGetBlockHashResult, 0, end-start+1)
+	for i := start; i <= end; i++ {
+		hashFutures = append(hashFutures, db.client.GetBlockHashAsync(i))
+	}
 
-		if err = db.StoreStakeInfoExtended(&si); err != nil {
-			return fmt.Errorf("Unable to store stake info in database: %v", err)
+	blockFutures := make([]rpcclient.FutureGetBlockResult, 0, len(hashFutures))
+	for i, f := range hashFutures {
+		blockhash, err := f.Receive()
+		if err != nil {
+			return nil, fmt.Errorf("GetBlockHash(%d) failed: %v", start+int64(i), err)
 		}
+		blockFutures = append(blockFutures, db.client.GetBlockAsync(blockhash))
+	}
 
-		// update height, the end condition for the loop
-		if _, height, err = db.client.GetBestBlock(); err != nil {
-			return fmt.Errorf("GetBestBlock failed: %v", err)
+	blocks := make([]*dcrutil.Block, 0, len(blockFutures))
+	for i, f := range blockFutures {
+		msgBlock, err := f.Receive()
+		if err != nil {
+			return nil, fmt.Errorf("GetBlock(%d) failed: %v", start+int64(i), err)
 		}
+		blocks = append(blocks, dcrutil.NewBlock(msgBlock))
 	}
 
-	log.Infof("Rescan finished successfully at height %d.", height)
+	return blocks, nil
+}
 
-	return nil
+// blockFetch is the result of fetching a range of blocks.
+type blockFetch struct {
+	blocks []*dcrutil.Block
+	err    error
+}
+
+// blockFetcher fetches consecutive batches of blocks of up to syncBatchSize,
+// fetching each batch in the background while the previous one is processed.
+type blockFetcher struct {
+	db         *wiredDB
+	start, end int64
+	result     chan blockFetch
+}
+
+// newBlockFetcher creates a blockFetcher for the batches from height start.
+func (db *wiredDB) newBlockFetcher(start int64) *blockFetcher {
+	return &blockFetcher{
+		db:    db,
+		start: start,
+	}
+}
+
+// prefetch starts fetching the next batch, up to bestHeight, unless it is
+// already being fetched or there are no more blocks.
+func (f *blockFetcher) prefetch(bestHeight int64) {
+	if f.result != nil || f.start > bestHeight {
+		return
+	}
+	f.end = f.start + f.db.syncBatchSize - 1
+	if f.end > bestHeight {
+		f.end = bestHeight
+	}
+
+	// The channel is buffered so the fetch completes if the batch is never
+	// received, e.g. when the sync is cancelled.
+	result := make(chan blockFetch, 1)
+	go func(start, end int64) {
+		blocks, err := f.db.getBlockRange(start, end)
+		result <- blockFetch{blocks, err}
+	}(f.start, f.end)
+	f.result = result
+}
+
+// next returns the next batch of blocks, up to bestHeight, and starts fetching
+// the batch after it.
+func (f *blockFetcher) next(bestHeight int64) ([]*dcrutil.Block, error) {
+	f.prefetch(bestHeight)
+	if f.result == nil {
+		return nil, fmt.Errorf("no blocks to fetch after height %d", f.start-1)
+	}
+	res := <-f.result
+	f.result = nil
+	if res.err != nil {
+		return nil, res.err
+	}
+
+	f.start = f.end + 1
+	f.prefetch(bestHeight)
+	return res.blocks, nil
 }
 
 func (db *wiredDB) getBlock(ind int64) (*dcrutil.Block, *chainhash.Hash, error) {
@@ -341,3 +527,141 @@ func (db *wiredDB) getBlock(ind int64) (*dcrutil.Block, *chainhash.Hash, error)
 
 	return block, blockhash, nil
 }
+
+// makeBlockSummary builds the block summary table row for the block, with the
+// given ticket pool info.
+func (db *wiredDB) makeBlockSummary(block *dcrutil.Block, tpi *apitypes.TicketPoolInfo) *apitypes.BlockDataBasic {
+	header := block.MsgBlock().Header
+	diffRatio := txhelpers.GetDifficultyRatio(header.Bits, db.params)
+
+	return &apitypes.BlockDataBasic{
+		Height:     header.Height,
+		Size:       header.Size,
+		Hash:       block.Hash().String(),
+		Difficulty: diffRatio,
+		StakeDiff:  dcrutil.Amount(header.SBits).ToCoin(),
+		Time:       header.Timestamp.Unix(),
+		PoolInfo:   *tpi,
+	}
+}
+
+// makeStakeInfo builds the extended stake info table row for the block, with
+// the given ticket pool info.
+func (db *wiredDB) makeStakeInfo(block *dcrutil.Block, tpi *apitypes.TicketPoolInfo) (*apitypes.StakeInfoExtended, error) {
+	si := apitypes.StakeInfoExtended{}
+
+	// Ticket fee info
+	fib := txhelpers.FeeRateInfoBlock(block)
+	if fib == nil {
+		return nil, fmt.Errorf("FeeRateInfoBlock failed")
+	}
+	si.Feeinfo = *fib
+
+	// Stake difficulty
+	header := block.MsgBlock().Header
+	si.StakeDiff = dcrutil.Amount(header.SBits).ToCoin()
+
+	// Price window number and block index
+	height := int(header.Height)
+	winSize := int(db.params.StakeDiffWindowSize)
+	si.PriceWindowNum = height / winSize
+	si.IdxBlockInWindow = height%winSize + 1
+
+	// Ticket pool info
+	si.PoolInfo = *tpi
+
+	return &si, nil
+}
+
+// voteIndexHeight returns the height to which the votes table is indexed.
+// There are no votes before stake validation height, so an empty votes table
+// is up to date until then.
+func (db *wiredDB) voteIndexHeight() int64 {
+	height := db.GetVoteHeight()
+	if svh := db.params.StakeValidationHeight - 1; height < svh {
+		height = svh
+	}
+	return height
+}
+
+// ticketIndexHeight returns the height to which the tickets table is updated.
+// No ticket matures until TicketMaturity blocks after the first purchase, so an
+// empty tickets table is up to date until then.
+func (db *wiredDB) ticketIndexHeight() int64 {
+	height := db.GetTicketHeight()
+	if maturity := int64(db.params.TicketMaturity); height < maturity {
+		height = maturity
+	}
+	return height
+}
+
+// blockIndex extracts the chain data table rows of the block, with the ticket
+// status changes recorded by the stake DB when it connected the block, unless
+// the tickets table already has the block.
+func (db *wiredDB) blockIndex(msgBlock *wire.MsgBlock) *chaindb.BlockIndex {
+	b := chaindb.NewBlockIndex(msgBlock, db.params)
+	height := b.Height()
+	if height <= db.GetTicketHeight() || db.sDB == nil {
+		return b
+	}
+
+	hash := msgBlock.BlockHash()
+	updates, found := db.sDB.TicketUpdates(hash)
+	if !found {
+		log.Warnf("Unable to find block (%s) in ticket updates cache. Ticket "+
+			"status will not be updated for height %d.", hash, height)
+		return b
+	}
+	b.SetTicketUpdates(updates)
+	return b
+}
+
+// indexBlockByHash stores the block with the given hash in the address,
+// transaction, votes, block versions and tickets tables. If any table is
+// behind the block's parent, the missing blocks are stored first, in batches,
+// so that spends are always recorded against existing outputs.
+func (db *wiredDB) indexBlockByHash(hash *chainhash.Hash) error {
+	msgBlock, err := db.client.GetBlock(hash)
+	if err != nil {
+		return fmt.Errorf("GetBlock failed (%s): %v", hash, err)
+	}
+
+	height := int64(msgBlock.Header.Height)
+	indexHeight := db.GetAddressHeight()
+	if txHeight := db.GetTransactionHeight(); txHeight < indexHeight {
+		indexHeight = txHeight
+	}
+	if voteHeight := db.voteIndexHeight(); voteHeight < indexHeight {
+		indexHeight = voteHeight
+	}
+	if versionsHeight := db.GetBlockVersionsHeight(); versionsHeight < indexHeight {
+		indexHeight = versionsHeight
+	}
+
+	for i := indexHeight + 1; i < height; i += db.syncBatchSize {
+		end := i + db.syncBatchSize - 1
+		if end >= height {
+			end = height - 1
+		}
+		log.Infof("Indexing addresses, transactions, votes and versions for "+
+			"missed blocks %d to %d.", i, end)
+		blocks, err := db.getBlockRange(i, end)
+		if err != nil {
+			return err
+		}
+		blockIndexes := make([]*chaindb.BlockIndex, 0, len(blocks))
+		for _, block := range blocks {
+			blockIndexes = append(blockIndexes,
+				chaindb.NewBlockIndex(block.MsgBlock(), db.params))
+		}
+		if err = db.StoreBlockBatch(nil, nil, blockIndexes); err != nil {
+			return fmt.Errorf("Unable to store block data in database: %v", err)
+		}
+	}
+
+	blockIndexes := []*chaindb.BlockIndex{db.blockIndex(msgBlock)}
+	if err = db.StoreBlockBatch(nil, nil, blockIndexes); err != nil {
+		return fmt.Errorf("Unable to store block data in database: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package dcrsqlite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dcrdata/dcrdata/chaindb"
+	"github.com/dcrdata/dcrdata/dbtypes"
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/chaincfg/chainec"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/dcrd/txscript"
+	"github.com/decred/dcrd/wire"
+)
+
+func testAddress(t *testing.T, b byte) (string, []byte) {
+	pkHash := make([]byte, 20)
+	pkHash[0] = b
+	addr, err := dcrutil.NewAddressPubKeyHash(pkHash, &chaincfg.SimNetParams,
+		chainec.ECTypeSecp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr.EncodeAddress(), script
+}
+
+func TestStoreBlockBatchIndex(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	addr1, script1 := testAddress(t, 1)
+	addr2, script2 := testAddress(t, 2)
+
+	// Block 1 pays 10 atoms to addr1.
+	fund := wire.NewMsgTx()
+	fund.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+		wire.MaxPrevOutIndex, wire.TxTreeRegular), nil))
+	fund.AddTxOut(wire.NewTxOut(10, script1))
+	block1 := &wire.MsgBlock{
+		Header:       wire.BlockHeader{Height: 1, Timestamp: time.Unix(1500000000, 0)},
+		Transactions: []*wire.MsgTx{fund},
+	}
+
+	// Block 2 spends it, paying 7 atoms to addr2.
+	fundHash := fund.TxHash()
+	spend := wire.NewMsgTx()
+	spend.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&fundHash, 0, wire.TxTreeRegular), nil))
+	spend.AddTxOut(wire.NewTxOut(7, script2))
+	block2 := &wire.MsgBlock{
+		Header:       wire.BlockHeader{Height: 2, Timestamp: time.Unix(1500000300, 0)},
+		Transactions: []*wire.MsgTx{spend},
+	}
+
+	// Both blocks are stored in one SQL transaction, so the spend in block 2
+	// must find the output created in block 1 within it.
+	store := func() {
+		idx2 := chaindb.NewBlockIndex(block2, &chaincfg.SimNetParams)
+		idx2.SetTicketUpdates(nil)
+		blocks := []*chaindb.BlockIndex{
+			chaindb.NewBlockIndex(block1, &chaincfg.SimNetParams),
+			idx2,
+		}
+		if err := db.StoreBlockBatch(nil, nil, blocks); err != nil {
+			t.Fatalf("StoreBlockBatch failed: %v", err)
+		}
+	}
+	store()
+
+	heights := []struct {
+		table  string
+		height int64
+	}{
+		{"address", db.GetAddressHeight()},
+		{"transaction", db.GetTransactionHeight()},
+		{"block versions", db.GetBlockVersionsHeight()},
+		{"ticket", db.GetTicketHeight()},
+	}
+	for _, h := range heights {
+		if h.height != 2 {
+			t.Errorf("%s table height %d, expected 2", h.table, h.height)
+		}
+	}
+
+	checkTotals := func(addr string, expected dbtypes.AddressTotals) {
+		totals, err := db.RetrieveAddressTotals(addr)
+		if err != nil {
+			t.Fatalf("RetrieveAddressTotals failed: %v", err)
+		}
+		if *totals != expected {
+			t.Errorf("RetrieveAddressTotals(%s): got %v, expected %v", addr,
+				*totals, expected)
+		}
+	}
+	checkTotals(addr1, dbtypes.AddressTotals{NumTxns: 2, Received: 10, Sent: 10})
+	checkTotals(addr2, dbtypes.AddressTotals{NumTxns: 1, Received: 7, Unspent: 7})
+
+	spender, _, height, err := db.RetrieveSpendingTx(fundHash.String(), 0)
+	if err != nil {
+		t.Fatalf("RetrieveSpendingTx failed: %v", err)
+	}
+	if spender != spend.TxHash().String() || height != 2 {
+		t.Errorf("RetrieveSpendingTx: got %s at height %d", spender, height)
+	}
+
+	// Blocks the tables already have are skipped.
+	store()
+	checkTotals(addr1, dbtypes.AddressTotals{NumTxns: 2, Received: 10, Sent: 10})
+	checkTotals(addr2, dbtypes.AddressTotals{NumTxns: 1, Received: 7, Unspent: 7})
+}
+
+func TestTicketIndexHeight(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	wdb := newTestWiredDB(db)
+
+	// An empty tickets table is up to date until the first tickets mature.
+	maturity := int64(wdb.params.TicketMaturity)
+	if height := wdb.ticketIndexHeight(); height != maturity {
+		t.Errorf("Empty tickets table indexed to %d, expected %d", height, maturity)
+	}
+
+	hash := chainhash.HashH([]byte("ticket")).String()
+	updates := []dbtypes.TicketUpdate{{
+		TicketHash:     hash,
+		PurchaseHeight: 1000,
+		Status:         dbtypes.PoolStatusLive,
+	}}
+	if err := db.StoreTicketUpdates(1000+maturity, updates); err != nil {
+		t.Fatalf("StoreTicketUpdates failed: %v", err)
+	}
+	if height := wdb.ticketIndexHeight(); height != 1000+maturity {
+		t.Errorf("Tickets table indexed to %d, expected %d", height, 1000+maturity)
+	}
+}
@@ -27,6 +27,8 @@ const (
 	blockTemplateIndex
 	txTemplateIndex
 	addressTemplateIndex
+	agendasTemplateIndex
+	versionsTemplateIndex
 	maxExplorerRows = 2000
 	minExplorerRows = 20
 	AddressRows     = 500
@@ -39,7 +41,9 @@ type explorerDataSource interface {
 	GetBlockHeight(hash string) (int64, error)
 	GetBlockHash(idx int64) (string, error)
 	GetExplorerTx(txid string) *TxInfo
-	GetExplorerAddress(address string, count int) *AddressInfo
+	GetExplorerAddress(address string, count, offset int) *AddressInfo
+	GetExplorerAgendas() []*AgendaInfo
+	GetExplorerVersions() *VersionsInfo
 	GetHeight() int
 }
 
@@ -141,7 +145,19 @@ func (exp *explorerUI) addressPage(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/error/"+address, http.StatusTemporaryRedirect)
 		return
 	}
-	data := exp.blockData.GetExplorerAddress(address, AddressRows)
+
+	// Number of transactions per page, and the number of most recent
+	// transactions to skip
+	limit, err := strconv.Atoi(r.URL.Query().Get("n"))
+	if err != nil || limit > AddressRows || limit < 1 {
+		limit = AddressRows
+	}
+	offset, err := strconv.Atoi(r.URL.Query().Get("start"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	data := exp.blockData.GetExplorerAddress(address, limit, offset)
 	if data == nil {
 		log.Errorf("Unable to get address %s", address)
 		http.Redirect(w, r, "/error/"+address, http.StatusTemporaryRedirect)
@@ -158,6 +174,42 @@ func (exp *explorerUI) addressPage(w http.ResponseWriter, r *http.Request) {
 	io.WriteString(w, str)
 }
 
+func (exp *explorerUI) agendasPage(w http.ResponseWriter, r *http.Request) {
+	agendas := exp.blockData.GetExplorerAgendas()
+	if agendas == nil {
+		log.Errorf("Unable to get agendas")
+		http.Redirect(w, r, "/error", http.StatusTemporaryRedirect)
+		return
+	}
+	str, err := templateExecToString(exp.templates[agendasTemplateIndex], "agendas", agendas)
+	if err != nil {
+		log.Errorf("Template execute failure: %v", err)
+		http.Redirect(w, r, "/error", http.StatusTemporaryRedirect)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, str)
+}
+
+func (exp *explorerUI) versionsPage(w http.ResponseWriter, r *http.Request) {
+	versions := exp.blockData.GetExplorerVersions()
+	if versions == nil {
+		log.Errorf("Unable to get versions")
+		http.Redirect(w, r, "/error", http.StatusTemporaryRedirect)
+		return
+	}
+	str, err := templateExecToString(exp.templates[versionsTemplateIndex], "versions", versions)
+	if err != nil {
+		log.Errorf("Template execute failure: %v", err)
+		http.Redirect(w, r, "/error", http.StatusTemporaryRedirect)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, str)
+}
+
 // search implements a primitive search algorithm by checking if the value in
 // question is a block index, block hash, address hash or transaction hash and
 // redirects to the appropriate page or displays an error
@@ -182,7 +234,7 @@ func (exp *explorerUI) search(w http.ResponseWriter, r *http.Request) {
 
 	// Call GetExplorerAddress to see if the value is an address hash and
 	// then redirect to the address page if it is
-	address := exp.blockData.GetExplorerAddress(searchStr, 1)
+	address := exp.blockData.GetExplorerAddress(searchStr, 1, 0)
 	if address != nil {
 		http.Redirect(w, r, "/explorer/address/"+searchStr, http.StatusPermanentRedirect)
 		return
@@ -247,10 +299,28 @@ func (exp *explorerUI) reloadTemplates() error {
 		return err
 	}
 
+	agendasTemplate, err := template.New("agendas").Funcs(exp.templateHelpers).ParseFiles(
+		exp.templateFiles["agendas"],
+		exp.templateFiles["extras"],
+	)
+	if err != nil {
+		return err
+	}
+
+	versionsTemplate, err := template.New("versions").Funcs(exp.templateHelpers).ParseFiles(
+		exp.templateFiles["versions"],
+		exp.templateFiles["extras"],
+	)
+	if err != nil {
+		return err
+	}
+
 	exp.templates[rootTemplateIndex] = explorerTemplate
 	exp.templates[blockTemplateIndex] = blockTemplate
 	exp.templates[txTemplateIndex] = txTemplate
 	exp.templates[addressTemplateIndex] = addressTemplate
+	exp.templates[agendasTemplateIndex] = agendasTemplate
+	exp.templates[versionsTemplateIndex] = versionsTemplate
 
 	return nil
 }
@@ -291,7 +361,15 @@ func New(dataSource explorerDataSource, userRealIP bool) *explorerUI {
 	exp.templateFiles["tx"] = filepath.Join("views", "tx.tmpl")
 	exp.templateFiles["extras"] = filepath.Join("views", "extras.tmpl")
 	exp.templateFiles["address"] = filepath.Join("views", "address.tmpl")
+	exp.templateFiles["agendas"] = filepath.Join("views", "agendas.tmpl")
+	exp.templateFiles["versions"] = filepath.Join("views", "versions.tmpl")
 	exp.templateHelpers = template.FuncMap{
+		"add": func(a, b int) int {
+			return a + b
+		},
+		"subtract": func(a, b int) int {
+			return a - b
+		},
 		"timezone": func() string {
 			t, _ := time.Now().Zone()
 			return t
@@ -349,7 +427,7 @@ func New(dataSource explorerDataSource, userRealIP bool) *explorerUI {
 		},
 	}
 
-	exp.templates = make([]*template.Template, 0, 4)
+	exp.templates = make([]*template.Template, 0, 5)
 
 	explorerTemplate, err := template.New("explorer").Funcs(exp.templateHelpers).ParseFiles(
 		exp.templateFiles["explorer"],
@@ -386,6 +464,24 @@ func New(dataSource explorerDataSource, userRealIP bool) *explorerUI {
 	}
 	exp.templates = append(exp.templates, addrTemplate)
 
+	agendasTemplate, err := template.New("agendas").Funcs(exp.templateHelpers).ParseFiles(
+		exp.templateFiles["agendas"],
+		exp.templateFiles["extras"],
+	)
+	if err != nil {
+		log.Errorf("Unable to create new html template: %v", err)
+	}
+	exp.templates = append(exp.templates, agendasTemplate)
+
+	versionsTemplate, err := template.New("versions").Funcs(exp.templateHelpers).ParseFiles(
+		exp.templateFiles["versions"],
+		exp.templateFiles["extras"],
+	)
+	if err != nil {
+		log.Errorf("Unable to create new html template: %v", err)
+	}
+	exp.templates = append(exp.templates, versionsTemplate)
+
 	exp.addRoutes()
 
 	return exp
@@ -419,5 +515,8 @@ func (exp *explorerUI) addRoutes() {
 		})
 	})
 
+	exp.Mux.Get("/agendas", exp.agendasPage)
+	exp.Mux.Get("/versions", exp.versionsPage)
+
 	exp.Mux.With(searchPathCtx).Get("/search/{search}", exp.search)
 }
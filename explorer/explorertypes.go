@@ -57,6 +57,81 @@ type TxInfo struct {
 	FormattedTime   string
 	Mature          string
 	VoteFundsLocked string
+	Ticket          *TicketInfo
+}
+
+// TicketInfo models the lifecycle of a ticket for the explorer's transaction
+// page. Heights are zero if they do not apply yet.
+type TicketInfo struct {
+	Status           string
+	MaturityHeight   int64
+	ExpirationHeight int64
+	PoolExitHeight   int64
+	SpendHeight      int64
+	SpendingTxID     string
+}
+
+// AgendaInfo models a consensus rule change agenda and the votes on it in the
+// current rule change interval, for the explorer's agendas page. The
+// percentages are of the quorum, and of the non-abstaining votes for yes.
+type AgendaInfo struct {
+	ID               string
+	Description      string
+	VoteVersion      uint32
+	Choices          []string
+	StartTime        string
+	ExpireTime       string
+	Interval         int64
+	IntervalStart    int64
+	IntervalEnd      int64
+	Yes              uint32
+	No               uint32
+	Abstain          uint32
+	QuorumPercent    float64
+	YesPercent       float64
+	ThresholdPercent float64
+	QuorumMet        bool
+	ThresholdMet     bool
+}
+
+// VersionsInfo models the upgrade progress of the block, stake and vote
+// versions for the explorer's versions page, with the versions in recent
+// windows of blocks and the chart data for every stake version interval.
+type VersionsInfo struct {
+	Height  int64
+	Windows []VersionWindowInfo
+	Chart   VersionsChartData
+}
+
+// VersionWindowInfo models the versions of the blocks from StartHeight to
+// EndHeight, and of the votes in them.
+type VersionWindowInfo struct {
+	Title         string
+	StartHeight   int64
+	EndHeight     int64
+	Blocks        uint32
+	Votes         uint32
+	BlockVersions []VersionPercent
+	StakeVersions []VersionPercent
+	VoteVersions  []VersionPercent
+}
+
+// VersionPercent models the number and percentage of blocks or votes with a
+// version.
+type VersionPercent struct {
+	Version uint32
+	Count   uint32
+	Percent float64
+}
+
+// VersionsChartData is the data for the versions chart. Heights are the first
+// blocks of the stake version intervals, and the series of each version are
+// the percentages of the blocks or votes in each interval with that version.
+type VersionsChartData struct {
+	Heights       []int64              `json:"heights"`
+	BlockVersions map[uint32][]float64 `json:"block_versions"`
+	StakeVersions map[uint32][]float64 `json:"stake_versions"`
+	VoteVersions  map[uint32][]float64 `json:"vote_versions"`
 }
 
 // VoteInfo models data about a SSGen transaction (vote)
@@ -88,6 +163,8 @@ type Vout struct {
 	FormattedAmount string
 	Type            string
 	Spent           bool
+	SpendingTxID    string
+	SpendingVin     uint32
 	OP_RETURN       string
 }
 
@@ -128,4 +205,6 @@ type AddressInfo struct {
 	AddressRow       int
 	TotalSent        dcrutil.Amount
 	UnSpent          dcrutil.Amount
+	Limit            int
+	Offset           int
 }
@@ -11,6 +11,8 @@ import (
 
 	"github.com/btcsuite/btclog"
 	"github.com/dcrdata/dcrdata/blockdata"
+	"github.com/dcrdata/dcrdata/chaindb"
+	"github.com/dcrdata/dcrdata/dcrpg"
 	"github.com/dcrdata/dcrdata/dcrsqlite"
 	"github.com/dcrdata/dcrdata/explorer"
 	"github.com/dcrdata/dcrdata/mempool"
@@ -49,6 +51,8 @@ var (
 	logRotator *rotator.Rotator
 
 	sqliteLog    = backendLog.Logger("DSQL")
+	postgresLog  = backendLog.Logger("PSQL")
+	chaindbLog   = backendLog.Logger("CHDB")
 	stakedbLog   = backendLog.Logger("SKDB")
 	blockdataLog = backendLog.Logger("BLKD")
 	clientLog    = backendLog.Logger("RPCC")
@@ -61,6 +65,8 @@ var (
 // Initialize package-global logger variables.
 func init() {
 	dcrsqlite.UseLogger(sqliteLog)
+	dcrpg.UseLogger(postgresLog)
+	chaindb.UseLogger(chaindbLog)
 	stakedb.UseLogger(stakedbLog)
 	blockdata.UseLogger(blockdataLog)
 	rpcclient.UseLogger(clientLog)
@@ -72,6 +78,8 @@ func init() {
 // subsystemLoggers maps each subsystem identifier to its associated logger.
 var subsystemLoggers = map[string]btclog.Logger{
 	"DSQL": sqliteLog,
+	"PSQL": postgresLog,
+	"CHDB": chaindbLog,
 	"SKDB": stakedbLog,
 	"BLKD": blockdataLog,
 	"RPCC": clientLog,
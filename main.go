@@ -5,6 +5,7 @@ package main
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,12 +14,12 @@ import (
 	"time"
 
 	"github.com/dcrdata/dcrdata/blockdata"
+	"github.com/dcrdata/dcrdata/dcrpg"
 	"github.com/dcrdata/dcrdata/dcrsqlite"
 	"github.com/dcrdata/dcrdata/explorer"
 	"github.com/dcrdata/dcrdata/mempool"
 	"github.com/dcrdata/dcrdata/rpcutils"
 	"github.com/dcrdata/dcrdata/semver"
-	"github.com/dcrdata/dcrdata/txhelpers"
 	"github.com/decred/dcrd/chaincfg/chainhash"
 	"github.com/decred/dcrd/rpcclient"
 	"github.com/go-chi/chi"
@@ -102,18 +103,45 @@ func mainCore() int {
 	// blockDataMapSaver := NewBlockDataToMemdb()
 	// blockDataSavers = append(blockDataSavers, blockDataMapSaver)
 
-	// Sqlite output
-	dbInfo := dcrsqlite.DBInfo{FileName: cfg.DBFileName}
-	//sqliteDB, err := dcrsqlite.InitDB(&dbInfo)
-	sqliteDB, cleanupDB, err := dcrsqlite.InitWiredDB(&dbInfo,
+	// Database output, to either SQLite or PostgreSQL
+	var store dcrsqlite.ChainDataStore
+	switch cfg.DBBackend {
+	case "postgresql":
+		host, port, err := net.SplitHostPort(cfg.PGHost)
+		if err != nil {
+			log.Errorf("Invalid pghost %s: %v", cfg.PGHost, err)
+			return 16
+		}
+		pgDB, err := dcrpg.InitDB(&dcrpg.DBInfo{
+			Host:   host,
+			Port:   port,
+			User:   cfg.PGUser,
+			Pass:   cfg.PGPass,
+			DBName: cfg.PGDBName,
+		})
+		if err != nil {
+			log.Errorf("Unable to initialize PostgreSQL database: %v", err)
+			return 16
+		}
+		log.Infof("PostgreSQL DB successfully opened: %s on %s", cfg.PGDBName,
+			cfg.PGHost)
+		store = pgDB
+	default:
+		dbInfo := dcrsqlite.DBInfo{FileName: cfg.DBFileName}
+		sqliteDB, err := dcrsqlite.InitDB(&dbInfo)
+		if err != nil {
+			log.Errorf("Unable to initialize SQLite database: %v", err)
+			return 16
+		}
+		log.Infof("SQLite DB successfully opened: %s", cfg.DBFileName)
+		store = sqliteDB
+	}
+	wiredDB, cleanupDB := dcrsqlite.NewWiredDBWithStore(store,
 		ntfnChans.updateStatusDBHeight, dcrdClient, activeChain)
 	defer cleanupDB()
-	if err != nil {
-		log.Errorf("Unable to initialize SQLite database: %v", err)
-		return 16
-	}
-	log.Infof("SQLite DB successfully opened: %s", cfg.DBFileName)
-	defer sqliteDB.Close()
+	defer wiredDB.Close()
+	wiredDB.SetSyncBatchSize(cfg.SyncBatchSize)
+	wiredDB.SetMempoolRetention(time.Duration(cfg.MPHistoryDays) * 24 * time.Hour)
 
 	// Ctrl-C to shut down.
 	// Nothing should be sent the quit channel.  It should only be closed.
@@ -136,7 +164,7 @@ func mainCore() int {
 	waitSync.Add(1)
 	// start as goroutine to let chain monitor start, but the sync will keep up
 	// with current height, it is not likely to matter.
-	if err = sqliteDB.SyncDBWithPoolValue(&waitSync, quit); err != nil {
+	if err = wiredDB.SyncDBWithPoolValue(&waitSync, quit); err != nil {
 		log.Error("Resync failed: ", err)
 		return 15
 	}
@@ -151,7 +179,7 @@ func mainCore() int {
 	}
 
 	// Block data collector
-	collector := blockdata.NewCollector(dcrdClient, activeChain, sqliteDB.GetStakeDB())
+	collector := blockdata.NewCollector(dcrdClient, activeChain, wiredDB.GetStakeDB())
 	if collector == nil {
 		log.Errorf("Failed to create block data collector")
 		return 9
@@ -168,11 +196,11 @@ func mainCore() int {
 		mempoolSavers = append(mempoolSavers, mempoolFeeDumper)
 	}
 
-	blockDataSavers = append(blockDataSavers, &sqliteDB)
-	mempoolSavers = append(mempoolSavers, sqliteDB.MPC)
+	blockDataSavers = append(blockDataSavers, &wiredDB)
+	mempoolSavers = append(mempoolSavers, &wiredDB)
 
 	// Web template data. WebUI implements BlockDataSaver interface
-	webUI := NewWebUI(&sqliteDB)
+	webUI := NewWebUI(&wiredDB, &wiredDB, dcrdClient)
 	if webUI == nil {
 		log.Info("Failed to start WebUI. Missing HTML resources?")
 		return 17
@@ -199,14 +227,13 @@ func mainCore() int {
 	var wg sync.WaitGroup
 
 	// Blockchain monitor for the collector
-	addrMap := make(map[string]txhelpers.TxAction) // for support of watched addresses
 	// On reorg, only update web UI since dcrsqlite's own reorg handler will
 	// deal with patching up the block info database.
 	reorgBlockDataSavers := []blockdata.BlockDataSaver{webUI}
 	wsChainMonitor := blockdata.NewChainMonitor(collector, blockDataSavers,
-		reorgBlockDataSavers, quit, &wg, addrMap,
+		reorgBlockDataSavers, quit, &wg, webUI.WatchedAddresses, &wiredDB,
 		ntfnChans.connectChan, ntfnChans.recvTxBlockChan,
-		ntfnChans.reorgChanBlockData)
+		ntfnChans.spendTxBlockChan, ntfnChans.reorgChanBlockData)
 	wg.Add(2)
 	go wsChainMonitor.BlockConnectedHandler()
 	// The blockdata reorg handler disables collection during reorg, leaving
@@ -214,18 +241,25 @@ func mainCore() int {
 	// collected and stored via reorgBlockDataSavers.
 	go wsChainMonitor.ReorgHandler()
 
+	// Transactions on the addresses watched by websocket clients, as they
+	// enter mempool and as they are mined
+	wg.Add(1)
+	go webUI.AddressTxHandler(ntfnChans.relevantTxMempoolChan,
+		ntfnChans.recvTxBlockChan, ntfnChans.spendTxBlockChan,
+		ntfnChans.reconnectChan, quit, &wg)
+
 	// Blockchain monitor for the stake DB
-	sdbChainMonitor := sqliteDB.NewStakeDBChainMonitor(quit, &wg,
+	sdbChainMonitor := wiredDB.NewStakeDBChainMonitor(quit, &wg,
 		ntfnChans.connectChanStakeDB, ntfnChans.reorgChanStakeDB)
 	wg.Add(2)
 	go sdbChainMonitor.BlockConnectedHandler()
 	go sdbChainMonitor.ReorgHandler()
 
 	// Blockchain monitor for the wired sqlite DB
-	wiredDBChainMonitor := sqliteDB.NewChainMonitor(collector, quit, &wg,
+	wiredDBChainMonitor := wiredDB.NewChainMonitor(collector, quit, &wg,
 		ntfnChans.connectChanWiredDB, ntfnChans.reorgChanWiredDB)
 	wg.Add(2)
-	// dcrsqlite does not handle new blocks except during reorg
+	// dcrsqlite only indexes addresses and transactions for new blocks, except during reorg
 	go wiredDBChainMonitor.BlockConnectedHandler()
 	go wiredDBChainMonitor.ReorgHandler()
 
@@ -234,7 +268,7 @@ func mainCore() int {
 	collectionQueue.SetSynchronousHandlers([]func(*chainhash.Hash){
 		sdbChainMonitor.BlockConnectedSync,     // 1. Stake DB for pool info
 		wsChainMonitor.BlockConnectedSync,      // 2. blockdata for regular block data collection and storage
-		wiredDBChainMonitor.BlockConnectedSync, // 3. dcrsqlite for sqlite DB reorg handling
+		wiredDBChainMonitor.BlockConnectedSync, // 3. dcrsqlite for address and tx index, and DB reorg handling
 	})
 
 	if cfg.MonitorMempool {
@@ -251,8 +285,40 @@ func mainCore() int {
 			return 14
 		}
 
+		// Track the transactions already in mempool
+		if err = wiredDB.MPT.Sync(dcrdClient); err != nil {
+			log.Errorf("Failed to get initial mempool transactions: %v", err)
+			return 14
+		}
+
 		// Store initial MP data
-		sqliteDB.MPC.StoreMPData(mpData, time.Now())
+		if err = wiredDB.StoreMPData(mpData, time.Now()); err != nil {
+			log.Errorf("Failed to store initial mempool data: %v", err)
+			return 14
+		}
+
+		// Track the votes on the best block in the stake DB until the next
+		// winning tickets notification.
+		tipHeight, tipHash, winners, err := wiredDB.GetStakeDB().BestNodeWinners()
+		if err != nil {
+			log.Errorf("Failed to get the winning tickets of the best block: %v", err)
+			return 14
+		}
+		tipWinners := &mempool.WinningTickets{
+			BlockHash:   *tipHash,
+			BlockHeight: int64(tipHeight),
+			Tickets:     make([]*chainhash.Hash, 0, len(winners)),
+		}
+		for i := range winners {
+			tipWinners.Tickets = append(tipWinners.Tickets, &winners[i])
+		}
+		wiredDB.MPT.SetWinningTickets(tipWinners)
+		if tipVotes := wiredDB.MPT.GetTipVotes(); tipVotes != nil {
+			if err = webUI.StoreMPVotes(tipVotes); err != nil {
+				log.Errorf("Failed to store initial mempool votes: %v", err)
+				return 19
+			}
+		}
 
 		// Store initial MP data to webUI
 		if err = webUI.StoreMPData(mpData, time.Now()); err != nil {
@@ -273,8 +339,12 @@ func mainCore() int {
 		mini := time.Duration(cfg.MempoolMinInterval) * time.Second
 		maxi := time.Duration(cfg.MempoolMaxInterval) * time.Second
 
-		mpm := mempool.NewMempoolMonitor(mpoolCollector, mempoolSavers,
-			ntfnChans.newTxChan, quit, &wg, newTicketLimit, mini, maxi, mpi)
+		mpm := mempool.NewMempoolMonitor(mpoolCollector, wiredDB.MPT, mempoolSavers,
+			[]mempool.MempoolVoteSaver{webUI},
+			[]mempool.MempoolConflictSaver{webUI},
+			[]mempool.MempoolTxSaver{webUI}, ntfnChans.newTxChan,
+			ntfnChans.winningTicketsChanMempool, quit, &wg, newTicketLimit,
+			mini, maxi, mpi)
 		wg.Add(1)
 		go mpm.TxHandler(dcrdClient)
 	}
@@ -293,22 +363,23 @@ func mainCore() int {
 	}
 
 	// Start web API
-	app := newContext(dcrdClient, &sqliteDB, cfg.IndentJSON)
+	app := newContext(dcrdClient, &wiredDB, cfg.IndentJSON)
 	// Start notification hander to keep /status up-to-date
 	wg.Add(1)
 	go app.StatusNtfnHandler(&wg, quit)
 	// Initial setting of db_height. Subsequently, Store() will send this.
-	ntfnChans.updateStatusDBHeight <- uint32(sqliteDB.GetHeight())
+	ntfnChans.updateStatusDBHeight <- uint32(wiredDB.GetHeight())
 
 	apiMux := newAPIRouter(app, cfg.UseRealIP)
 
 	// Start the explorer system
-	explore := explorer.New(&sqliteDB, cfg.UseRealIP)
+	explore := explorer.New(&wiredDB, cfg.UseRealIP)
 	explore.UseSIGToReloadTemplates()
 
 	webMux := chi.NewRouter()
 	webMux.Get("/", webUI.RootPage)
 	webMux.Get("/ws", webUI.WSBlockUpdater)
+	webMux.Get("/ws/v1", webUI.WSSubscriptionUpdater)
 	webMux.Get("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "./public/images/favicon.ico")
 	})
@@ -0,0 +1,243 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package mempool
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/dcrd/wire"
+)
+
+// MaxInvalidatedTxs is the number of the most recently invalidated
+// transactions that are kept.
+const MaxInvalidatedTxs = 100
+
+// Reasons for the invalidation of a mempool transaction by a block.
+const (
+	invalidDoubleSpend  = "doublespend"
+	invalidInvalidInput = "invalidinput"
+)
+
+// isNullOutPoint checks if the outpoint is the null outpoint of a coinbase or
+// stakebase input, which spends nothing.
+func isNullOutPoint(op *wire.OutPoint) bool {
+	return op.Index == wire.MaxPrevOutIndex && op.Hash == chainhash.Hash{}
+}
+
+// outPointString formats the outpoint as hash:index.
+func outPointString(op *wire.OutPoint) string {
+	return fmt.Sprintf("%v:%d", op.Hash, op.Index)
+}
+
+// add starts tracking the transaction and indexes the outpoints it spends,
+// noting when another tracked transaction spends the same outpoint, and the
+// addresses it pays to. The caller must hold the lock.
+func (t *MempoolTxTracker) add(mtx *MempoolTx) {
+	t.txs[mtx.Hash] = mtx
+	for _, addr := range mtx.payees() {
+		t.addrs[addr] = append(t.addrs[addr], mtx.Hash)
+	}
+	for _, op := range mtx.spends {
+		spenders := t.spends[op]
+		if len(spenders) > 0 {
+			log.Infof("Mempool transaction %v conflicts with %v, spending %s.",
+				mtx.Hash, spenders[0], outPointString(&op))
+			t.conflictsUpdated = time.Now()
+		}
+		t.spends[op] = append(spenders, mtx.Hash)
+	}
+}
+
+// remove stops tracking the transaction and removes it from the indexes of
+// spent outpoints and addresses. The caller must hold the lock.
+func (t *MempoolTxTracker) remove(mtx *MempoolTx) {
+	delete(t.txs, mtx.Hash)
+	for _, addr := range mtx.payees() {
+		t.addrs[addr] = removeHash(t.addrs[addr], mtx.Hash)
+		if len(t.addrs[addr]) == 0 {
+			delete(t.addrs, addr)
+		}
+	}
+	for _, op := range mtx.spends {
+		spenders := removeHash(t.spends[op], mtx.Hash)
+		if len(spenders) == 0 {
+			delete(t.spends, op)
+		} else {
+			t.spends[op] = spenders
+		}
+	}
+}
+
+// removeHash removes the first occurrence of hash from hashes.
+func removeHash(hashes []chainhash.Hash, hash chainhash.Hash) []chainhash.Hash {
+	for i := range hashes {
+		if hashes[i] == hash {
+			return append(hashes[:i], hashes[i+1:]...)
+		}
+	}
+	return hashes
+}
+
+// blockSpend is a transaction in a block that spends an outpoint.
+type blockSpend struct {
+	tx    chainhash.Hash
+	block *dcrutil.Block
+}
+
+// minedTxs maps the hashes of the transactions in the blocks to the block
+// containing them.
+func minedTxs(blocks []*dcrutil.Block) map[chainhash.Hash]*dcrutil.Block {
+	mined := make(map[chainhash.Hash]*dcrutil.Block)
+	for _, block := range blocks {
+		for _, txs := range [][]*dcrutil.Tx{block.Transactions(), block.STransactions()} {
+			for _, tx := range txs {
+				mined[*tx.Hash()] = block
+			}
+		}
+	}
+	return mined
+}
+
+// recordInvalidated finds the evicted transactions that were invalidated by
+// the blocks, either by a block transaction spending the same outpoint, or by
+// spending an output of another invalidated transaction, and records them.
+// mined holds the transactions in the blocks, as given by minedTxs. The number
+// of invalidated transactions is returned. The caller must hold the lock.
+func (t *MempoolTxTracker) recordInvalidated(evicted []*MempoolTx, blocks []*dcrutil.Block,
+	mined map[chainhash.Hash]*dcrutil.Block) int {
+	if len(evicted) == 0 || len(blocks) == 0 {
+		return 0
+	}
+
+	spentBy := make(map[wire.OutPoint]blockSpend)
+	for _, block := range blocks {
+		for _, txs := range [][]*dcrutil.Tx{block.Transactions(), block.STransactions()} {
+			for _, tx := range txs {
+				for _, txIn := range tx.MsgTx().TxIn {
+					spentBy[txIn.PreviousOutPoint] = blockSpend{*tx.Hash(), block}
+				}
+			}
+		}
+	}
+
+	invalidated := make(map[chainhash.Hash]*apitypes.MempoolInvalidatedTx)
+	now := time.Now().Unix()
+	for _, mtx := range evicted {
+		if _, ok := mined[mtx.Hash]; ok {
+			continue
+		}
+		for i := range mtx.spends {
+			op := &mtx.spends[i]
+			if bs, ok := spentBy[*op]; ok {
+				invalidated[mtx.Hash] = &apitypes.MempoolInvalidatedTx{
+					MempoolTx:     mtx.apiTx(),
+					Reason:        invalidDoubleSpend,
+					ConflictingTx: bs.tx.String(),
+					Outpoint:      outPointString(op),
+					BlockHash:     bs.block.Hash().String(),
+					BlockHeight:   bs.block.Height(),
+					EvictedTime:   now,
+				}
+				break
+			}
+		}
+	}
+
+	// Transactions spending the outputs of invalidated transactions are
+	// invalid too, however deep the chain of unconfirmed transactions.
+	for found := len(invalidated) > 0; found; {
+		found = false
+		for _, mtx := range evicted {
+			if _, ok := invalidated[mtx.Hash]; ok {
+				continue
+			}
+			if _, ok := mined[mtx.Hash]; ok {
+				continue
+			}
+			for i := range mtx.spends {
+				op := &mtx.spends[i]
+				if parent, ok := invalidated[op.Hash]; ok {
+					invalidated[mtx.Hash] = &apitypes.MempoolInvalidatedTx{
+						MempoolTx:     mtx.apiTx(),
+						Reason:        invalidInvalidInput,
+						ConflictingTx: op.Hash.String(),
+						Outpoint:      outPointString(op),
+						BlockHash:     parent.BlockHash,
+						BlockHeight:   parent.BlockHeight,
+						EvictedTime:   now,
+					}
+					found = true
+					break
+				}
+			}
+		}
+	}
+	if len(invalidated) == 0 {
+		return 0
+	}
+
+	newInvalidated := make([]*apitypes.MempoolInvalidatedTx, 0, len(invalidated))
+	for _, itx := range invalidated {
+		log.Infof("Mempool transaction %v invalidated by block %v (%s).",
+			itx.Hash, itx.BlockHash, itx.Reason)
+		newInvalidated = append(newInvalidated, itx)
+	}
+	sort.Slice(newInvalidated, func(i, j int) bool {
+		return newInvalidated[i].Time < newInvalidated[j].Time
+	})
+	t.invalidated = append(t.invalidated, newInvalidated...)
+	if len(t.invalidated) > MaxInvalidatedTxs {
+		t.invalidated = t.invalidated[len(t.invalidated)-MaxInvalidatedTxs:]
+	}
+	t.conflictsUpdated = time.Now()
+	return len(invalidated)
+}
+
+// ConflictsUpdated returns the time a conflict or an invalidated transaction
+// was last found.
+func (t *MempoolTxTracker) ConflictsUpdated() time.Time {
+	t.RLock()
+	defer t.RUnlock()
+	return t.conflictsUpdated
+}
+
+// GetConflicts returns the tracked transactions that spend the same outpoint
+// as another, and the transactions most recently invalidated by blocks, newest
+// first.
+func (t *MempoolTxTracker) GetConflicts() *apitypes.MempoolConflicts {
+	t.RLock()
+	defer t.RUnlock()
+
+	conflicts := &apitypes.MempoolConflicts{
+		Height:      t.height,
+		Time:        t.lastUpdate.Unix(),
+		Conflicts:   []*apitypes.MempoolConflict{},
+		Invalidated: make([]*apitypes.MempoolInvalidatedTx, 0, len(t.invalidated)),
+	}
+	for op, spenders := range t.spends {
+		if len(spenders) < 2 {
+			continue
+		}
+		conflict := &apitypes.MempoolConflict{
+			Outpoint: outPointString(&op),
+			Txs:      make([]*apitypes.MempoolTx, 0, len(spenders)),
+		}
+		for _, hash := range spenders {
+			conflict.Txs = append(conflict.Txs, t.txs[hash].apiTx())
+		}
+		conflicts.Conflicts = append(conflicts.Conflicts, conflict)
+	}
+	sort.Slice(conflicts.Conflicts, func(i, j int) bool {
+		return conflicts.Conflicts[i].Outpoint < conflicts.Conflicts[j].Outpoint
+	})
+	for i := len(t.invalidated) - 1; i >= 0; i-- {
+		conflicts.Invalidated = append(conflicts.Invalidated, t.invalidated[i])
+	}
+	return conflicts
+}
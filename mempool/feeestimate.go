@@ -0,0 +1,190 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package mempool
+
+import (
+	"fmt"
+	"sort"
+
+	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/dcrdata/dcrdata/txhelpers"
+	"github.com/decred/dcrd/dcrjson"
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/dcrd/rpcclient"
+)
+
+// MaxFeeEstimateBlocks is the largest confirmation target of a fee estimate,
+// and the number of recent blocks whose fee rates are kept for estimation.
+const MaxFeeEstimateBlocks = 24
+
+// DefaultRelayFeeRate is dcrd's default minimum fee rate in DCR/kB for a
+// transaction to be relayed, used until the node's relay fee is known.
+const DefaultRelayFeeRate = 0.001
+
+// blockFees is the fee rate data of the regular transactions in a block, and
+// the sizes used to estimate the block space available to them.
+type blockFees struct {
+	height      int64
+	feeInfo     *dcrjson.FeeInfoBlock
+	size        int
+	regularSize int
+}
+
+// newBlockFees computes the fee rate info and sizes of a block.
+func newBlockFees(block *dcrutil.Block) blockFees {
+	msgBlock := block.MsgBlock()
+	bf := blockFees{
+		height:  block.Height(),
+		feeInfo: txhelpers.FeeRateInfoBlockRegular(block),
+		size:    msgBlock.SerializeSize(),
+	}
+	for _, msgTx := range msgBlock.Transactions[1:] {
+		bf.regularSize += msgTx.SerializeSize()
+	}
+	return bf
+}
+
+// fetchBlocks gets the blocks from height ind0 to ind1 from the node.
+func fetchBlocks(client *rpcclient.Client, ind0, ind1 int64) ([]*dcrutil.Block, error) {
+	blocks := make([]*dcrutil.Block, 0, ind1-ind0+1)
+	for i := ind0; i <= ind1; i++ {
+		hash, err := client.GetBlockHash(i)
+		if err != nil {
+			return nil, fmt.Errorf("GetBlockHash(%d) failed: %v", i, err)
+		}
+		msgBlock, err := client.GetBlock(hash)
+		if err != nil {
+			return nil, fmt.Errorf("GetBlock(%v) failed: %v", hash, err)
+		}
+		blocks = append(blocks, dcrutil.NewBlock(msgBlock))
+	}
+	return blocks, nil
+}
+
+// updateBlockFees gets the fee rate data of the blocks connected since the
+// last update, up to the block at height, keeping the MaxFeeEstimateBlocks
+// most recent. The block at height is always fetched again in case it was
+// replaced by a reorganization. The fetched blocks are returned.
+func (t *MempoolTxTracker) updateBlockFees(client *rpcclient.Client, height int64) ([]*dcrutil.Block, error) {
+	t.RLock()
+	start := height - MaxFeeEstimateBlocks + 1
+	if n := len(t.blockFees); n > 0 && t.blockFees[n-1].height >= start {
+		start = t.blockFees[n-1].height + 1
+	}
+	t.RUnlock()
+	if start > height {
+		start = height
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	blocks, err := fetchBlocks(client, start, height)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	var fees []blockFees
+	for _, bf := range t.blockFees {
+		if bf.height < start {
+			fees = append(fees, bf)
+		}
+	}
+	for _, block := range blocks {
+		fees = append(fees, newBlockFees(block))
+	}
+	if len(fees) > MaxFeeEstimateBlocks {
+		fees = fees[len(fees)-MaxFeeEstimateBlocks:]
+	}
+	t.blockFees = fees
+	return blocks, nil
+}
+
+// updateRelayFee gets the node's minimum relay fee rate. The previous rate is
+// kept if it cannot be determined.
+func (t *MempoolTxTracker) updateRelayFee(client *rpcclient.Client) error {
+	info, err := client.GetInfo()
+	if err != nil {
+		return fmt.Errorf("GetInfo failed: %v", err)
+	}
+	if info.RelayFee > 0 {
+		t.Lock()
+		t.relayFee = info.RelayFee
+		t.Unlock()
+	}
+	return nil
+}
+
+// EstimateFee estimates the fee rate in DCR/kB for a regular transaction to be
+// mined within targetBlocks blocks. The regular transactions in mempool are
+// ranked by fee rate, and the estimate is the rate of the one at the depth that
+// fills targetBlocks blocks, given the block space left by the stake
+// transactions in recent blocks. It is at least the median of the lowest fee
+// rates included in the recent blocks, and never below the node's minimum
+// relay fee rate, since a transaction paying less would not enter mempool.
+func (t *MempoolTxTracker) EstimateFee(targetBlocks int) (*apitypes.MempoolFeeEstimate, error) {
+	if targetBlocks < 1 || targetBlocks > MaxFeeEstimateBlocks {
+		return nil, fmt.Errorf("target of %d blocks not in [1,%d]",
+			targetBlocks, MaxFeeEstimateBlocks)
+	}
+
+	t.RLock()
+	defer t.RUnlock()
+
+	estimate := &apitypes.MempoolFeeEstimate{
+		Height:       t.height,
+		Time:         t.lastUpdate.Unix(),
+		Blocks:       targetBlocks,
+		NumBlocks:    len(t.blockFees),
+		RelayFeeRate: t.relayFee,
+	}
+
+	// Block space for regular transactions, and the lowest fee rates of the
+	// recent blocks.
+	var stakeSize int
+	minRates := make([]float64, 0, len(t.blockFees))
+	for _, bf := range t.blockFees {
+		stakeSize += bf.size - bf.regularSize
+		if bf.feeInfo.Number > 0 {
+			minRates = append(minRates, bf.feeInfo.Min)
+		}
+	}
+	estimate.BlockCapacity = int64(t.params.MaximumBlockSizes[0])
+	if len(t.blockFees) > 0 {
+		estimate.BlockCapacity -= int64(stakeSize / len(t.blockFees))
+	}
+	estimate.RecentMinFeeRate = txhelpers.MedianCoin(minRates)
+
+	// The regular transactions in mempool, highest fee rate first.
+	var regular []*MempoolTx
+	for _, mtx := range t.txs {
+		if mtx.Type == txTypeRegular {
+			regular = append(regular, mtx)
+		}
+	}
+	sort.Slice(regular, func(i, j int) bool {
+		return regular[i].FeeRate > regular[j].FeeRate
+	})
+
+	var depthRate float64
+	targetSize := int64(targetBlocks) * estimate.BlockCapacity
+	for _, mtx := range regular {
+		estimate.MempoolDepth += int64(mtx.Size)
+		if estimate.MempoolDepth > targetSize {
+			depthRate = mtx.FeeRate.ToCoin()
+			break
+		}
+	}
+
+	estimate.FeeRate = estimate.RelayFeeRate
+	if estimate.RecentMinFeeRate > estimate.FeeRate {
+		estimate.FeeRate = estimate.RecentMinFeeRate
+	}
+	if depthRate > estimate.FeeRate {
+		estimate.FeeRate = depthRate
+	}
+	return estimate, nil
+}
@@ -0,0 +1,101 @@
+package mempool
+
+import (
+	"testing"
+
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrjson"
+	"github.com/decred/dcrd/dcrutil"
+)
+
+func TestEstimateFee(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	capacity := int64(params.MaximumBlockSizes[0])
+
+	// Recent blocks with only regular transactions, the lowest paying 0.01
+	// DCR/kB.
+	recentBlocks := make([]blockFees, 3)
+	for i := range recentBlocks {
+		recentBlocks[i] = blockFees{
+			height:      int64(100 + i),
+			feeInfo:     &dcrjson.FeeInfoBlock{Number: 10, Min: 0.01},
+			size:        5000,
+			regularSize: 5000,
+		}
+	}
+
+	// A mempool of 100 transactions of 10 kB, the i-th paying 0.1-0.001*i
+	// DCR/kB, fills one block at i = 39 and two at i = 78.
+	const txSize = 10000
+	fullMempool := make(map[chainhash.Hash]*MempoolTx)
+	for i := 0; i < 100; i++ {
+		hash := chainhash.HashH([]byte{byte(i)})
+		fullMempool[hash] = &MempoolTx{
+			Hash:    hash,
+			Type:    txTypeRegular,
+			Size:    txSize,
+			FeeRate: dcrutil.Amount((100 - i) * 1e5),
+		}
+	}
+
+	tests := []struct {
+		name         string
+		blocks       []blockFees
+		txs          map[chainhash.Hash]*MempoolTx
+		relayFee     float64
+		targetBlocks int
+		feeRate      float64
+		depth        int64
+	}{
+		{"empty mempool, no blocks", nil, nil, DefaultRelayFeeRate, 1,
+			DefaultRelayFeeRate, 0},
+		{"empty mempool", recentBlocks, nil, DefaultRelayFeeRate, 1, 0.01, 0},
+		{"empty mempool, high relay fee", recentBlocks, nil, 0.05, 1, 0.05, 0},
+		{"full mempool", recentBlocks, fullMempool, DefaultRelayFeeRate, 1,
+			0.061, 40 * txSize},
+		{"full mempool, 2 blocks", recentBlocks, fullMempool, DefaultRelayFeeRate,
+			2, 0.022, 79 * txSize},
+		{"full mempool, 24 blocks", recentBlocks, fullMempool, DefaultRelayFeeRate,
+			MaxFeeEstimateBlocks, 0.01, 100 * txSize},
+		{"full mempool, high relay fee", recentBlocks, fullMempool, 0.05, 2,
+			0.05, 79 * txSize},
+	}
+
+	for _, tt := range tests {
+		tracker := NewMempoolTxTracker(params)
+		tracker.blockFees = tt.blocks
+		tracker.relayFee = tt.relayFee
+		if tt.txs != nil {
+			tracker.txs = tt.txs
+		}
+
+		estimate, err := tracker.EstimateFee(tt.targetBlocks)
+		if err != nil {
+			t.Fatalf("%s: EstimateFee failed: %v", tt.name, err)
+		}
+		if estimate.FeeRate != tt.feeRate {
+			t.Errorf("%s: fee rate %v, expected %v", tt.name, estimate.FeeRate,
+				tt.feeRate)
+		}
+		if estimate.RelayFeeRate != tt.relayFee {
+			t.Errorf("%s: relay fee rate %v, expected %v", tt.name,
+				estimate.RelayFeeRate, tt.relayFee)
+		}
+		if estimate.MempoolDepth != tt.depth {
+			t.Errorf("%s: mempool depth %d, expected %d", tt.name,
+				estimate.MempoolDepth, tt.depth)
+		}
+		if estimate.BlockCapacity != capacity {
+			t.Errorf("%s: block capacity %d, expected %d", tt.name,
+				estimate.BlockCapacity, capacity)
+		}
+	}
+
+	tracker := NewMempoolTxTracker(params)
+	for _, target := range []int{0, MaxFeeEstimateBlocks + 1} {
+		if _, err := tracker.EstimateFee(target); err == nil {
+			t.Errorf("no error for target of %d blocks", target)
+		}
+	}
+}
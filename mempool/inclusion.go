@@ -0,0 +1,137 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package mempool
+
+import (
+	"sort"
+	"time"
+
+	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/dcrdata/dcrdata/txhelpers"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil"
+)
+
+// MaxInclusionWindows is the number of the most recent stake difficulty
+// windows for which the inclusion of tickets is kept. The inclusions are kept
+// in memory only, and are lost on restart.
+const MaxInclusionWindows = 8
+
+// inclusionFeeRateBins is the number of fee rate bins, each with the same
+// number of tickets, in the ticket inclusion statistics of a window.
+const inclusionFeeRateBins = 4
+
+// ticketInclusion records how long a ticket was in mempool before being mined.
+type ticketInclusion struct {
+	feeRate dcrutil.Amount
+	delay   time.Duration
+	blocks  int64
+}
+
+// recordInclusions records the time to inclusion of the evicted tickets that
+// were mined, given the mined transactions from minedTxs. Only the most recent
+// MaxInclusionWindows windows are kept. The caller must hold the lock.
+func (t *MempoolTxTracker) recordInclusions(evicted []*MempoolTx,
+	mined map[chainhash.Hash]*dcrutil.Block) {
+	winSize := t.params.StakeDiffWindowSize
+	var newest int64 = -1
+	for _, mtx := range evicted {
+		if mtx.Type != txTypeTicket {
+			continue
+		}
+		block, ok := mined[mtx.Hash]
+		if !ok {
+			continue
+		}
+
+		delay := block.MsgBlock().Header.Timestamp.Sub(mtx.Time)
+		if delay < 0 {
+			delay = 0
+		}
+		window := block.Height() / winSize
+		t.inclusions[window] = append(t.inclusions[window], ticketInclusion{
+			feeRate: mtx.FeeRate,
+			delay:   delay,
+			blocks:  block.Height() - int64(mtx.Height),
+		})
+		if window > newest {
+			newest = window
+		}
+	}
+
+	for window := range t.inclusions {
+		if window <= newest-MaxInclusionWindows {
+			delete(t.inclusions, window)
+		}
+	}
+}
+
+// makeInclusionStats computes the statistics of the ticket inclusions, which
+// must be sorted by fee rate.
+func makeInclusionStats(inclusions []ticketInclusion) apitypes.TicketInclusionStats {
+	stats := apitypes.TicketInclusionStats{
+		Count: uint32(len(inclusions)),
+	}
+	if len(inclusions) == 0 {
+		return stats
+	}
+
+	delays := make([]float64, 0, len(inclusions))
+	var totalBlocks int64
+	for _, ti := range inclusions {
+		if ti.blocks <= 1 {
+			stats.FirstBlock++
+		}
+		delays = append(delays, ti.delay.Seconds())
+		stats.MeanDelay += ti.delay.Seconds()
+		totalBlocks += ti.blocks
+	}
+	n := float64(len(inclusions))
+	stats.FirstBlockRatio = float64(stats.FirstBlock) / n
+	stats.MeanDelay /= n
+	stats.MedianDelay = txhelpers.MedianCoin(delays)
+	stats.MeanBlocks = float64(totalBlocks) / n
+	stats.MinFeeRate = inclusions[0].feeRate.ToCoin()
+	stats.MaxFeeRate = inclusions[len(inclusions)-1].feeRate.ToCoin()
+	return stats
+}
+
+// GetTicketInclusion returns the statistics of the time the tickets mined in
+// each of the recent stake difficulty windows spent in mempool, overall and by
+// fee rate, newest window first. Only tickets that were tracked in mempool
+// before being mined are counted.
+func (t *MempoolTxTracker) GetTicketInclusion() []*apitypes.TicketInclusionWindow {
+	t.RLock()
+	defer t.RUnlock()
+
+	winSize := t.params.StakeDiffWindowSize
+	windows := make([]*apitypes.TicketInclusionWindow, 0, len(t.inclusions))
+	for window, inclusions := range t.inclusions {
+		sorted := make([]ticketInclusion, len(inclusions))
+		copy(sorted, inclusions)
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i].feeRate < sorted[j].feeRate
+		})
+
+		w := &apitypes.TicketInclusionWindow{
+			Window:      window,
+			StartHeight: window * winSize,
+			EndHeight:   (window+1)*winSize - 1,
+			Tickets:     makeInclusionStats(sorted),
+			ByFeeRate:   make([]apitypes.TicketInclusionStats, 0, inclusionFeeRateBins),
+		}
+		for i := 0; i < inclusionFeeRateBins; i++ {
+			lo := i * len(sorted) / inclusionFeeRateBins
+			hi := (i + 1) * len(sorted) / inclusionFeeRateBins
+			if hi > lo {
+				w.ByFeeRate = append(w.ByFeeRate, makeInclusionStats(sorted[lo:hi]))
+			}
+		}
+		windows = append(windows, w)
+	}
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].Window > windows[j].Window
+	})
+	return windows
+}
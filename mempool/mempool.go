@@ -43,15 +43,31 @@ type mempoolMonitor struct {
 	minInterval    time.Duration
 	maxInterval    time.Duration
 	collector      *mempoolDataCollector
+	txTracker      *MempoolTxTracker
 	dataSavers     []MempoolDataSaver
+	voteSavers     []MempoolVoteSaver
+	conflictSavers []MempoolConflictSaver
+	txSavers       []MempoolTxSaver
+	lastConflicts  time.Time
+	votesMtx       sync.Mutex
+	votes          chan *apitypes.MempoolTipVotes
 	newTxHash      chan *NewTx
+	winners        chan *WinningTickets
 	quit           chan struct{}
 	wg             *sync.WaitGroup
 }
 
-// NewMempoolMonitor creates a new mempoolMonitor
-func NewMempoolMonitor(collector *mempoolDataCollector,
-	savers []MempoolDataSaver, newTxChan chan *NewTx,
+// NewMempoolMonitor creates a new mempoolMonitor. Every transaction accepted
+// into mempool is tracked with txTracker, and the votes on the best block are
+// sent to the voteSavers as they arrive. Conflicting and invalidated
+// transactions are sent to the conflictSavers when found, and each accepted
+// transaction is sent to the txSavers. The winning tickets of each new block
+// are received on winnersChan. The votes are stored in order by a single
+// goroutine started by TxHandler.
+func NewMempoolMonitor(collector *mempoolDataCollector, txTracker *MempoolTxTracker,
+	savers []MempoolDataSaver, voteSavers []MempoolVoteSaver,
+	conflictSavers []MempoolConflictSaver, txSavers []MempoolTxSaver,
+	newTxChan chan *NewTx, winnersChan chan *WinningTickets,
 	quit chan struct{}, wg *sync.WaitGroup, newTicketLimit int32,
 	mini time.Duration, maxi time.Duration, mpi *MempoolInfo) *mempoolMonitor {
 	return &mempoolMonitor{
@@ -60,8 +76,14 @@ func NewMempoolMonitor(collector *mempoolDataCollector,
 		minInterval:    mini,
 		maxInterval:    maxi,
 		collector:      collector,
+		txTracker:      txTracker,
 		dataSavers:     savers,
+		voteSavers:     voteSavers,
+		conflictSavers: conflictSavers,
+		txSavers:       txSavers,
+		votes:          make(chan *apitypes.MempoolTipVotes, 1),
 		newTxHash:      newTxChan,
+		winners:        winnersChan,
 		quit:           quit,
 		wg:             wg,
 	}
@@ -112,6 +134,11 @@ func (tix ByAbsoluteFee) Less(i, j int) bool {
 // from a Ticker or manually triggered.
 func (p *mempoolMonitor) TxHandler(client *rpcclient.Client) {
 	defer p.wg.Done()
+
+	done := make(chan struct{})
+	defer close(done)
+	go p.voteSaver(done)
+
 	for {
 		select {
 		case s, ok := <-p.newTxHash:
@@ -120,8 +147,14 @@ func (p *mempoolMonitor) TxHandler(client *rpcclient.Client) {
 				return
 			}
 
-			// A nil hash is our signal of a new block
+			// A nil hash is our signal of a new block. Evict the
+			// transactions that were mined from the tracker.
 			if s.Hash == nil {
+				if err := p.txTracker.Sync(client); err != nil {
+					log.Errorf("Failed to update mempool transactions: %v", err)
+				}
+				p.StoreVotes()
+				p.StoreConflicts()
 				_ = p.CollectAndStore()
 				continue
 			}
@@ -134,6 +167,16 @@ func (p *mempoolMonitor) TxHandler(client *rpcclient.Client) {
 				continue
 			}
 
+			// Track every transaction, whatever its type.
+			mtx := p.txTracker.Add(tx, s.T)
+			p.StoreConflicts()
+			apiTx := mtx.apiTx()
+			for _, saver := range p.txSavers {
+				if saver != nil {
+					go saver.StoreMPTx(apiTx)
+				}
+			}
+
 			// See if the transaction is a ticket purchase.  If not, just
 			// make a note of it and go back to the loop.
 			txType := stake.DetermineTxType(tx.MsgTx())
@@ -157,6 +200,7 @@ func (p *mempoolMonitor) TxHandler(client *rpcclient.Client) {
 				voteHash := &tx.MsgTx().TxIn[1].PreviousOutPoint.Hash
 				log.Tracef("Received vote %v for ticket %v", tx.Hash(), voteHash)
 				// TODO: Show subsidy for this vote (Vout[2] - Vin[1] ?)
+				p.StoreVotes()
 				continue
 			case stake.TxTypeSSRtx:
 				// Revoke
@@ -217,6 +261,17 @@ func (p *mempoolMonitor) TxHandler(client *rpcclient.Client) {
 				}
 			}
 
+		case winners, ok := <-p.winners:
+			if !ok {
+				log.Infof("Winning tickets channel closed")
+				return
+			}
+
+			// The votes on the new best block are tallied against its
+			// winning tickets.
+			p.txTracker.SetWinningTickets(winners)
+			p.StoreVotes()
+
 		case <-p.quit:
 			log.Debugf("Quitting OnTxAccepted (new tx in mempool) handler.")
 			return
@@ -224,6 +279,64 @@ func (p *mempoolMonitor) TxHandler(client *rpcclient.Client) {
 	}
 }
 
+// StoreVotes queues the votes in mempool on the best block for the vote
+// savers. Nothing is queued until the winning tickets of the best block are
+// known. A queued snapshot not yet taken by voteSaver is replaced, so the
+// savers never receive an older snapshot after a newer one.
+func (p *mempoolMonitor) StoreVotes() {
+	p.votesMtx.Lock()
+	defer p.votesMtx.Unlock()
+
+	tipVotes := p.txTracker.GetTipVotes()
+	if tipVotes == nil {
+		return
+	}
+	select {
+	case <-p.votes:
+	default:
+	}
+	p.votes <- tipVotes
+}
+
+// voteSaver sends the snapshots queued by StoreVotes to the vote savers, one at
+// a time, until done or quit is closed.
+func (p *mempoolMonitor) voteSaver(done chan struct{}) {
+	for {
+		select {
+		case tipVotes := <-p.votes:
+			for _, s := range p.voteSavers {
+				if s == nil {
+					continue
+				}
+				if err := s.StoreMPVotes(tipVotes); err != nil {
+					log.Errorf("Failed to store mempool votes: %v", err)
+				}
+			}
+		case <-done:
+			return
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// StoreConflicts sends the conflicting and invalidated transactions to the
+// conflict savers if any were found since they were last sent.
+func (p *mempoolMonitor) StoreConflicts() {
+	updated := p.txTracker.ConflictsUpdated()
+	if !updated.After(p.lastConflicts) {
+		return
+	}
+	p.lastConflicts = updated
+
+	conflicts := p.txTracker.GetConflicts()
+	for _, s := range p.conflictSavers {
+		if s != nil {
+			go s.StoreMPConflicts(conflicts)
+		}
+	}
+}
+
 // CollectAndStore collects mempool data, resets counters ticket counters and
 // the timer, and dispatches the storers.
 func (p *mempoolMonitor) CollectAndStore() error {
@@ -437,6 +550,24 @@ type MempoolDataSaver interface {
 	StoreMPData(data *MempoolData, timestamp time.Time) error
 }
 
+// MempoolVoteSaver is an interface for saving/storing the votes in mempool on
+// the best block
+type MempoolVoteSaver interface {
+	StoreMPVotes(votes *apitypes.MempoolTipVotes) error
+}
+
+// MempoolConflictSaver is an interface for saving/storing the conflicting
+// transactions in mempool and those invalidated by blocks
+type MempoolConflictSaver interface {
+	StoreMPConflicts(conflicts *apitypes.MempoolConflicts) error
+}
+
+// MempoolTxSaver is an interface for saving/storing the transactions accepted
+// into mempool
+type MempoolTxSaver interface {
+	StoreMPTx(tx *apitypes.MempoolTx) error
+}
+
 // MempoolDataToJSONStdOut implements MempoolDataSaver interface for JSON output to
 // stdout
 type MempoolDataToJSONStdOut struct {
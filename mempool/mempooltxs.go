@@ -0,0 +1,404 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package mempool
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/dcrdata/dcrdata/txhelpers"
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrjson"
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/dcrd/rpcclient"
+	"github.com/decred/dcrd/txscript"
+	"github.com/decred/dcrd/wire"
+)
+
+// Transaction type strings, as given by txhelpers.DetermineTxTypeString.
+const (
+	txTypeRegular    = "Regular"
+	txTypeTicket     = "Ticket"
+	txTypeVote       = "Vote"
+	txTypeRevocation = "Revocation"
+)
+
+// MempoolTx models a transaction tracked in mempool
+type MempoolTx struct {
+	Hash    chainhash.Hash
+	Type    string
+	Size    int32
+	Fee     dcrutil.Amount
+	FeeRate dcrutil.Amount
+	Time    time.Time
+	Height  uint32
+
+	// vote is set for votes, and describes the block voted on.
+	vote *mempoolVote
+
+	// spends are the outpoints spent by the transaction's inputs, excluding
+	// stakebase and coinbase inputs.
+	spends []wire.OutPoint
+
+	// outputs are the values and addresses of the transaction's outputs.
+	outputs []mempoolOutput
+}
+
+// mempoolOutput is the value of a transaction output and the addresses it
+// pays to.
+type mempoolOutput struct {
+	value int64
+	addrs []string
+}
+
+// tree returns the tree of the transaction's outputs, which is the stake tree
+// for all but regular transactions.
+func (tx *MempoolTx) tree() int8 {
+	if tx.Type == txTypeRegular {
+		return wire.TxTreeRegular
+	}
+	return wire.TxTreeStake
+}
+
+// payees returns the distinct addresses paid to by the transaction's outputs.
+func (tx *MempoolTx) payees() []string {
+	var addrs []string
+	seen := make(map[string]struct{})
+	for i := range tx.outputs {
+		for _, addr := range tx.outputs[i].addrs {
+			if _, ok := seen[addr]; !ok {
+				seen[addr] = struct{}{}
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs
+}
+
+// paysTo checks if the output pays to the address.
+func (out *mempoolOutput) paysTo(address string) bool {
+	for _, addr := range out.addrs {
+		if addr == address {
+			return true
+		}
+	}
+	return false
+}
+
+// apiTx converts the transaction to its API type.
+func (tx *MempoolTx) apiTx() *apitypes.MempoolTx {
+	return &apitypes.MempoolTx{
+		Hash:    tx.Hash.String(),
+		Type:    tx.Type,
+		Size:    tx.Size,
+		Fee:     tx.Fee.ToCoin(),
+		FeeRate: tx.FeeRate.ToCoin(),
+		Time:    tx.Time.Unix(),
+		Height:  int64(tx.Height),
+	}
+}
+
+// MempoolTxTracker tracks every transaction accepted into mempool, removing
+// them when they leave mempool, e.g. when mined in a block. Use
+// NewMempoolTxTracker to create a new instance.
+type MempoolTxTracker struct {
+	sync.RWMutex
+	params     *chaincfg.Params
+	height     uint32
+	bestHash   chainhash.Hash
+	lastUpdate time.Time
+	txs        map[chainhash.Hash]*MempoolTx
+	blockFees  []blockFees
+	relayFee   float64
+	tip        WinningTickets
+
+	// spends indexes the tracked transactions by the outpoints they spend,
+	// and addrs by the addresses their outputs pay to.
+	spends           map[wire.OutPoint][]chainhash.Hash
+	addrs            map[string][]chainhash.Hash
+	invalidated      []*apitypes.MempoolInvalidatedTx
+	conflictsUpdated time.Time
+
+	// inclusions holds the ticket inclusions by stake difficulty window.
+	inclusions map[int64][]ticketInclusion
+}
+
+// NewMempoolTxTracker constructs a new MempoolTxTracker, and is needed to
+// initialize the internal maps.
+func NewMempoolTxTracker(params *chaincfg.Params) *MempoolTxTracker {
+	return &MempoolTxTracker{
+		params:     params,
+		relayFee:   DefaultRelayFeeRate,
+		txs:        make(map[chainhash.Hash]*MempoolTx),
+		spends:     make(map[wire.OutPoint][]chainhash.Hash),
+		addrs:      make(map[string][]chainhash.Hash),
+		inclusions: make(map[int64][]ticketInclusion),
+	}
+}
+
+// newMempoolTx computes the type, size and fee of the transaction. The fee is
+// from the input amounts in the transaction. For votes, the block voted on is
+// determined too, and the addresses paid to by the outputs are decoded for the
+// given network.
+func newMempoolTx(tx *dcrutil.Tx, seen time.Time, height uint32,
+	params *chaincfg.Params) *MempoolTx {
+	msgTx := tx.MsgTx()
+	size := msgTx.SerializeSize()
+	fee := txhelpers.TxFee(msgTx)
+	mtx := &MempoolTx{
+		Hash:    *tx.Hash(),
+		Type:    txhelpers.DetermineTxTypeString(msgTx),
+		Size:    int32(size),
+		Fee:     fee,
+		FeeRate: 1000 * fee / dcrutil.Amount(size),
+		Time:    seen,
+		Height:  height,
+	}
+	if mtx.Type == txTypeVote {
+		mtx.vote = newMempoolVote(msgTx)
+	}
+	for _, txIn := range msgTx.TxIn {
+		if !isNullOutPoint(&txIn.PreviousOutPoint) {
+			mtx.spends = append(mtx.spends, txIn.PreviousOutPoint)
+		}
+	}
+	mtx.outputs = make([]mempoolOutput, len(msgTx.TxOut))
+	for i, txOut := range msgTx.TxOut {
+		mtx.outputs[i].value = txOut.Value
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(txOut.Version,
+			txOut.PkScript, params)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			mtx.outputs[i].addrs = append(mtx.outputs[i].addrs, addr.EncodeAddress())
+		}
+	}
+	return mtx
+}
+
+// Add starts tracking a transaction that was accepted into mempool at the
+// given time, returning the tracked transaction. A transaction that is already
+// tracked is not changed.
+func (t *MempoolTxTracker) Add(tx *dcrutil.Tx, seen time.Time) *MempoolTx {
+	t.Lock()
+	defer t.Unlock()
+	if mtx, ok := t.txs[*tx.Hash()]; ok {
+		return mtx
+	}
+	mtx := newMempoolTx(tx, seen, t.height, t.params)
+	t.add(mtx)
+	t.lastUpdate = time.Now()
+	return mtx
+}
+
+// Sync updates the tracked transactions to match the node's mempool, evicting
+// those that have left it, such as those mined in a newly connected block, and
+// adding any that were missed. Evicted transactions that were invalidated by
+// the new blocks are recorded. The best block and the fee rates of the recent
+// blocks are updated too.
+func (t *MempoolTxTracker) Sync(client *rpcclient.Client) error {
+	bestHash, height, err := client.GetBestBlock()
+	if err != nil {
+		return fmt.Errorf("GetBestBlock failed: %v", err)
+	}
+	blocks, err := t.updateBlockFees(client, height)
+	if err != nil {
+		log.Warnf("Unable to update the fee rates of recent blocks: %v", err)
+	}
+	if err = t.updateRelayFee(client); err != nil {
+		log.Warnf("Unable to update the relay fee rate: %v", err)
+	}
+	mempoolTxs, err := client.GetRawMempoolVerbose(dcrjson.GRMAll)
+	if err != nil {
+		return fmt.Errorf("GetRawMempoolVerbose failed: %v", err)
+	}
+
+	// Find the transactions that are not yet tracked without holding the
+	// lock while fetching them.
+	t.RLock()
+	var missing []string
+	for hashStr := range mempoolTxs {
+		hash, err := chainhash.NewHashFromStr(hashStr)
+		if err != nil {
+			t.RUnlock()
+			return err
+		}
+		if _, ok := t.txs[*hash]; !ok {
+			missing = append(missing, hashStr)
+		}
+	}
+	t.RUnlock()
+
+	newTxs := make([]*MempoolTx, 0, len(missing))
+	for _, hashStr := range missing {
+		hash, _ := chainhash.NewHashFromStr(hashStr)
+		tx, err := client.GetRawTransaction(hash)
+		if err != nil {
+			// The transaction may have left mempool since the listing.
+			log.Debugf("GetRawTransaction(%s) failed: %v", hashStr, err)
+			continue
+		}
+		info := mempoolTxs[hashStr]
+		newTxs = append(newTxs, newMempoolTx(tx, time.Unix(info.Time, 0),
+			uint32(info.Height), t.params))
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	var evicted []*MempoolTx
+	for hash, mtx := range t.txs {
+		if _, ok := mempoolTxs[hash.String()]; !ok {
+			evicted = append(evicted, mtx)
+		}
+	}
+	for _, mtx := range evicted {
+		t.remove(mtx)
+	}
+	mined := minedTxs(blocks)
+	numInvalidated := t.recordInvalidated(evicted, blocks, mined)
+	t.recordInclusions(evicted, mined)
+	for _, mtx := range newTxs {
+		if _, ok := t.txs[mtx.Hash]; !ok {
+			t.add(mtx)
+		}
+	}
+	t.height = uint32(height)
+	t.bestHash = *bestHash
+	t.lastUpdate = time.Now()
+
+	log.Debugf("Mempool at height %d: %d transactions (%d evicted, %d "+
+		"invalidated, %d added).", height, len(t.txs), len(evicted),
+		numInvalidated, len(newTxs))
+	return nil
+}
+
+// GetHeight returns the best block height as of the last Sync.
+func (t *MempoolTxTracker) GetHeight() uint32 {
+	t.RLock()
+	defer t.RUnlock()
+	return t.height
+}
+
+// AddressActivity returns the number of tracked transactions paying to or
+// spending from the address, and the net change in the address's balance in
+// atoms that they make. utxos holds the address's confirmed unspent outputs
+// and their values, which may be spent by the tracked transactions. An output
+// spent by more than one conflicting transaction is only deducted once.
+func (t *MempoolTxTracker) AddressActivity(address string, utxos map[wire.OutPoint]int64) (int, int64) {
+	t.RLock()
+	defer t.RUnlock()
+
+	var net int64
+	involved := make(map[chainhash.Hash]struct{})
+	unspent := make(map[wire.OutPoint]int64, len(utxos))
+	for op, value := range utxos {
+		unspent[op] = value
+	}
+
+	// Outputs paying to the address, which may be spent by other tracked
+	// transactions.
+	for _, hash := range t.addrs[address] {
+		mtx := t.txs[hash]
+		involved[hash] = struct{}{}
+		for i := range mtx.outputs {
+			if mtx.outputs[i].paysTo(address) {
+				net += mtx.outputs[i].value
+				op := wire.OutPoint{Hash: hash, Index: uint32(i), Tree: mtx.tree()}
+				unspent[op] = mtx.outputs[i].value
+			}
+		}
+	}
+
+	// Inputs spending outputs that paid to the address.
+	for op, value := range unspent {
+		spenders := t.spends[op]
+		if len(spenders) == 0 {
+			continue
+		}
+		net -= value
+		for _, hash := range spenders {
+			involved[hash] = struct{}{}
+		}
+	}
+
+	return len(involved), net
+}
+
+// GetSummary returns the number, total size and total fees of the tracked
+// transactions of each type.
+func (t *MempoolTxTracker) GetSummary() *apitypes.MempoolSummary {
+	t.RLock()
+	defer t.RUnlock()
+
+	summary := &apitypes.MempoolSummary{
+		Height: t.height,
+		Time:   t.lastUpdate.Unix(),
+	}
+	for _, mtx := range t.txs {
+		var totals *apitypes.MempoolTypeTotals
+		switch mtx.Type {
+		case txTypeTicket:
+			totals = &summary.Tickets
+		case txTypeVote:
+			totals = &summary.Votes
+		case txTypeRevocation:
+			totals = &summary.Revocations
+		default:
+			totals = &summary.Regular
+		}
+		for _, tt := range []*apitypes.MempoolTypeTotals{totals, &summary.Total} {
+			tt.Count++
+			tt.Size += int64(mtx.Size)
+			tt.Fees += mtx.Fee.ToCoin()
+		}
+	}
+	return summary
+}
+
+// GetTxs returns the tracked transactions of the given type, or of all types
+// if txType is empty, newest first. The type is matched without regard to
+// case.
+func (t *MempoolTxTracker) GetTxs(txType string) *apitypes.MempoolTxs {
+	t.RLock()
+	defer t.RUnlock()
+
+	txs := make([]*MempoolTx, 0, len(t.txs))
+	for _, mtx := range t.txs {
+		if txType == "" || strings.EqualFold(mtx.Type, txType) {
+			txs = append(txs, mtx)
+		}
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		return txs[i].Time.After(txs[j].Time)
+	})
+
+	mpTxs := &apitypes.MempoolTxs{
+		Height: t.height,
+		Time:   t.lastUpdate.Unix(),
+		Count:  uint32(len(txs)),
+		Txs:    make([]*apitypes.MempoolTx, 0, len(txs)),
+	}
+	for _, mtx := range txs {
+		mpTxs.Txs = append(mpTxs.Txs, mtx.apiTx())
+	}
+	return mpTxs
+}
+
+// ValidTxType checks that the string names a transaction type, as used by
+// GetTxs.
+func ValidTxType(txType string) bool {
+	for _, tt := range []string{txTypeRegular, txTypeTicket, txTypeVote,
+		txTypeRevocation} {
+		if strings.EqualFold(tt, txType) {
+			return true
+		}
+	}
+	return false
+}
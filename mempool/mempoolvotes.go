@@ -0,0 +1,162 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package mempool
+
+import (
+	"sort"
+
+	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/dcrdata/dcrdata/txhelpers"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/wire"
+)
+
+// WinningTickets models the tickets selected to vote on a block, as given by a
+// winningtickets notification.
+type WinningTickets struct {
+	BlockHash   chainhash.Hash
+	BlockHeight int64
+	Tickets     []*chainhash.Hash
+}
+
+// mempoolVote describes the block voted on by a vote in mempool, and the ticket
+// that cast it.
+type mempoolVote struct {
+	block    txhelpers.BlockValidation
+	voteBits uint16
+	ticket   chainhash.Hash
+}
+
+// newMempoolVote determines the block voted on by the vote transaction, or
+// returns nil if it is not a valid vote.
+func newMempoolVote(msgTx *wire.MsgTx) *mempoolVote {
+	validation, voteBits, err := txhelpers.SSGenVoteBlockValid(msgTx)
+	if err != nil {
+		log.Warnf("Unable to get the block voted on by %v: %v", msgTx.TxHash(), err)
+		return nil
+	}
+	return &mempoolVote{
+		block:    validation,
+		voteBits: voteBits,
+		ticket:   msgTx.TxIn[1].PreviousOutPoint.Hash,
+	}
+}
+
+// apiVote converts the vote to its API type.
+func (tx *MempoolTx) apiVote() *apitypes.MempoolVote {
+	return &apitypes.MempoolVote{
+		Hash:        tx.Hash.String(),
+		Ticket:      tx.vote.ticket.String(),
+		BlockHash:   tx.vote.block.Hash.String(),
+		BlockHeight: tx.vote.block.Height,
+		Approve:     tx.vote.block.Validity,
+		VoteBits:    tx.vote.voteBits,
+		Time:        tx.Time.Unix(),
+	}
+}
+
+// SetWinningTickets sets the best block and the tickets selected to vote on it,
+// which are reported by GetTipVotes. The winning tickets and the mempool sync
+// for a new block may arrive in either order, so the tickets are tagged with
+// their block hash and only reported once it matches the best block.
+func (t *MempoolTxTracker) SetWinningTickets(winners *WinningTickets) {
+	t.Lock()
+	defer t.Unlock()
+	t.tip = *winners
+}
+
+// blockVotes groups the tracked votes by the block they vote on, newest votes
+// first. The caller must hold the lock.
+func (t *MempoolTxTracker) blockVotes() map[chainhash.Hash]*apitypes.MempoolBlockVotes {
+	var votes []*MempoolTx
+	for _, mtx := range t.txs {
+		if mtx.vote != nil {
+			votes = append(votes, mtx)
+		}
+	}
+	sort.Slice(votes, func(i, j int) bool {
+		return votes[i].Time.After(votes[j].Time)
+	})
+
+	blocks := make(map[chainhash.Hash]*apitypes.MempoolBlockVotes)
+	for _, mtx := range votes {
+		bv, ok := blocks[mtx.vote.block.Hash]
+		if !ok {
+			bv = &apitypes.MempoolBlockVotes{
+				BlockHash:   mtx.vote.block.Hash.String(),
+				BlockHeight: mtx.vote.block.Height,
+				Votes:       []*apitypes.MempoolVote{},
+			}
+			blocks[mtx.vote.block.Hash] = bv
+		}
+		bv.Count++
+		if mtx.vote.block.Validity {
+			bv.Approve++
+		} else {
+			bv.Reject++
+		}
+		bv.Votes = append(bv.Votes, mtx.apiVote())
+	}
+	return blocks
+}
+
+// GetBlockVotes returns the votes in mempool grouped by the block they vote
+// on, highest block first.
+func (t *MempoolTxTracker) GetBlockVotes() []*apitypes.MempoolBlockVotes {
+	t.RLock()
+	defer t.RUnlock()
+
+	blocks := t.blockVotes()
+	blockVotes := make([]*apitypes.MempoolBlockVotes, 0, len(blocks))
+	for _, bv := range blocks {
+		blockVotes = append(blockVotes, bv)
+	}
+	sort.Slice(blockVotes, func(i, j int) bool {
+		return blockVotes[i].BlockHeight > blockVotes[j].BlockHeight
+	})
+	return blockVotes
+}
+
+// GetTipVotes returns the votes in mempool on the best block, and which of the
+// tickets selected to vote on it have voted. It returns nil if the winning
+// tickets of the best block as of the last Sync are not yet known.
+func (t *MempoolTxTracker) GetTipVotes() *apitypes.MempoolTipVotes {
+	t.RLock()
+	defer t.RUnlock()
+
+	if len(t.tip.Tickets) == 0 || t.tip.BlockHash != t.bestHash {
+		return nil
+	}
+
+	tipVotes := &apitypes.MempoolTipVotes{
+		Height:   t.height,
+		Time:     t.lastUpdate.Unix(),
+		Required: t.params.TicketsPerBlock,
+		Winners:  make([]apitypes.MempoolWinner, 0, len(t.tip.Tickets)),
+		MempoolBlockVotes: apitypes.MempoolBlockVotes{
+			BlockHash:   t.tip.BlockHash.String(),
+			BlockHeight: t.tip.BlockHeight,
+			Votes:       []*apitypes.MempoolVote{},
+		},
+	}
+	if bv, ok := t.blockVotes()[t.tip.BlockHash]; ok {
+		tipVotes.MempoolBlockVotes = *bv
+	}
+
+	votesByTicket := make(map[string]*apitypes.MempoolVote, len(tipVotes.Votes))
+	for _, v := range tipVotes.Votes {
+		votesByTicket[v.Ticket] = v
+	}
+	for _, ticket := range t.tip.Tickets {
+		winner := apitypes.MempoolWinner{Ticket: ticket.String()}
+		if v, ok := votesByTicket[winner.Ticket]; ok {
+			winner.Voted = true
+			winner.Vote = v.Hash
+			winner.Approve = v.Approve
+		}
+		tipVotes.Winners = append(tipVotes.Winners, winner)
+	}
+	tipVotes.Approved = 2*tipVotes.Approve > tipVotes.Count
+	return tipVotes
+}
@@ -0,0 +1,64 @@
+package mempool
+
+import (
+	"testing"
+
+	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+)
+
+func TestStoreVotes(t *testing.T) {
+	tracker := NewMempoolTxTracker(&chaincfg.MainNetParams)
+	p := &mempoolMonitor{
+		txTracker: tracker,
+		votes:     make(chan *apitypes.MempoolTipVotes, 1),
+	}
+
+	winners := func(b byte) *WinningTickets {
+		ticket := chainhash.HashH([]byte{b, 0})
+		return &WinningTickets{
+			BlockHash:   chainhash.HashH([]byte{b}),
+			BlockHeight: int64(b),
+			Tickets:     []*chainhash.Hash{&ticket},
+		}
+	}
+	// setBest sets the best block as a Sync finding it would.
+	setBest := func(b byte) {
+		tracker.Lock()
+		tracker.height = uint32(b)
+		tracker.bestHash = chainhash.HashH([]byte{b})
+		tracker.Unlock()
+	}
+
+	// The winners of block 2 arrive before mempool is synced to it, so
+	// nothing is queued until it is.
+	setBest(1)
+	tracker.SetWinningTickets(winners(2))
+	p.StoreVotes()
+	select {
+	case v := <-p.votes:
+		t.Fatalf("votes on block %s queued with best block 1", v.BlockHash)
+	default:
+	}
+
+	// Once synced, only the latest snapshot is queued.
+	setBest(2)
+	p.StoreVotes()
+	setBest(3)
+	tracker.SetWinningTickets(winners(3))
+	p.StoreVotes()
+	v := <-p.votes
+	if v.BlockHeight != 3 || v.BlockHash != winners(3).BlockHash.String() {
+		t.Errorf("votes on block %d (%s) queued, expected block 3",
+			v.BlockHeight, v.BlockHash)
+	}
+	if len(v.Winners) != 1 || v.Winners[0].Voted {
+		t.Errorf("unexpected winners %v", v.Winners)
+	}
+	select {
+	case v = <-p.votes:
+		t.Errorf("votes on block %d queued twice", v.BlockHeight)
+	default:
+	}
+}
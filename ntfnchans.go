@@ -25,7 +25,7 @@ const (
 
 	// relevantMempoolTxChanBuffer is the size of the new transaction channel
 	// buffer, for relevant transactions that are added into mempool.
-	//relevantMempoolTxChanBuffer = 2048
+	relevantMempoolTxChanBuffer = 2048
 )
 
 // Channels are package-level variables for simplicity
@@ -40,7 +40,9 @@ var ntfnChans struct {
 	updateStatusDBHeight              chan uint32
 	spendTxBlockChan, recvTxBlockChan chan *txhelpers.BlockWatchedTx
 	relevantTxMempoolChan             chan *dcrutil.Tx
+	reconnectChan                     chan struct{}
 	newTxChan                         chan *mempool.NewTx
+	winningTicketsChanMempool         chan *mempool.WinningTickets
 }
 
 func makeNtfnChans(cfg *config) {
@@ -66,16 +68,19 @@ func makeNtfnChans(cfg *config) {
 	ntfnChans.updateStatusNodeHeight = make(chan uint32, blockConnChanBuffer)
 	ntfnChans.updateStatusDBHeight = make(chan uint32, blockConnChanBuffer)
 
-	// watchaddress
-	// if len(cfg.WatchAddresses) > 0 {
-	// // recv/spendTxBlockChan come with connected blocks
-	// 	ntfnChans.recvTxBlockChan = make(chan *txhelpers.BlockWatchedTx, blockConnChanBuffer)
-	// 	ntfnChans.spendTxBlockChan = make(chan *txhelpers.BlockWatchedTx, blockConnChanBuffer)
-	// 	ntfnChans.relevantTxMempoolChan = make(chan *dcrutil.Tx, relevantMempoolTxChanBuffer)
-	// }
+	// Addresses watched by websocket clients. recv/spendTxBlockChan come with
+	// connected blocks, and relevantTxMempoolChan with OnRelevantTxAccepted.
+	ntfnChans.recvTxBlockChan = make(chan *txhelpers.BlockWatchedTx, blockConnChanBuffer)
+	ntfnChans.spendTxBlockChan = make(chan *txhelpers.BlockWatchedTx, blockConnChanBuffer)
+	ntfnChans.relevantTxMempoolChan = make(chan *dcrutil.Tx, relevantMempoolTxChanBuffer)
+	// The node's transaction filter is lost on reconnect, so the watched
+	// addresses are reloaded on a signal from OnClientConnected.
+	ntfnChans.reconnectChan = make(chan struct{}, 1)
 
 	if cfg.MonitorMempool {
 		ntfnChans.newTxChan = make(chan *mempool.NewTx, newTxChanBuffer)
+		ntfnChans.winningTicketsChanMempool = make(chan *mempool.WinningTickets,
+			blockConnChanBuffer)
 	}
 }
 
@@ -110,6 +115,9 @@ func closeNtfnChans() {
 	if ntfnChans.newTxChan != nil {
 		close(ntfnChans.newTxChan)
 	}
+	if ntfnChans.winningTicketsChanMempool != nil {
+		close(ntfnChans.winningTicketsChanMempool)
+	}
 	if ntfnChans.relevantTxMempoolChan != nil {
 		close(ntfnChans.relevantTxMempoolChan)
 	}
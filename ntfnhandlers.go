@@ -48,14 +48,9 @@ func registerNodeNtfnHandlers(dcrdClient *rpcclient.Client) *ContextualError {
 			"notification registration failed", err)
 	}
 
-	// Register a Tx filter for addresses (receiving).  The filter applies to
-	// OnRelevantTxAccepted.
-	// TODO: register outpoints (third argument).
-	// if len(addresses) > 0 {
-	// 	if err = dcrdClient.LoadTxFilter(true, addresses, nil); err != nil {
-	// 		return newContextualError("load tx filter failed", err)
-	// 	}
-	// }
+	// The Tx filter for addresses, which applies to OnRelevantTxAccepted, is
+	// loaded as websocket clients subscribe to addresses, and reloaded after
+	// reconnecting. See addressWatcher.
 
 	return nil
 }
@@ -133,6 +128,15 @@ func makeNodeNtfnHandlers(cfg *config) (*rpcclient.NotificationHandlers, *collec
 	blockQueue := NewCollectionQueue()
 	go blockQueue.ProcessBlocks()
 	return &rpcclient.NotificationHandlers{
+		// OnClientConnected is invoked when the client connects or reconnects
+		// to the node. The watched addresses must be reloaded into the
+		// node's transaction filter after a reconnect.
+		OnClientConnected: func() {
+			select {
+			case ntfnChans.reconnectChan <- struct{}{}:
+			default:
+			}
+		},
 		OnBlockConnected: func(blockHeaderSerialized []byte, transactions [][]byte) {
 			blockHeader := new(wire.BlockHeader)
 			err := blockHeader.FromBytes(blockHeaderSerialized)
@@ -189,7 +193,9 @@ func makeNodeNtfnHandlers(cfg *config) (*rpcclient.NotificationHandlers, *collec
 		// 	default:
 		// 	}
 		// },
-		// TODO
+		// OnWinningTickets is invoked when a block is connected and provides
+		// the tickets selected to vote on it. The mempool monitor uses these
+		// to track the votes on the new block.
 		OnWinningTickets: func(blockHash *chainhash.Hash, blockHeight int64,
 			tickets []*chainhash.Hash) {
 			var txstr []string
@@ -197,6 +203,17 @@ func makeNodeNtfnHandlers(cfg *config) (*rpcclient.NotificationHandlers, *collec
 				txstr = append(txstr, t.String())
 			}
 			log.Debugf("Winning tickets: %v", strings.Join(txstr, ", "))
+			if ntfnChans.winningTicketsChanMempool != nil {
+				select {
+				case ntfnChans.winningTicketsChanMempool <- &mempool.WinningTickets{
+					BlockHash:   *blockHash,
+					BlockHeight: blockHeight,
+					Tickets:     tickets,
+				}:
+				default:
+					log.Warn("winningTicketsChanMempool buffer full!")
+				}
+			}
 		},
 		// maturing tickets. Thanks for fixing the tickets type bug, jolan!
 		OnNewTickets: func(hash *chainhash.Hash, height int64, stakeDiff int64,
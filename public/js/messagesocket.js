@@ -1,15 +1,22 @@
-// MessageSocket is a WebSocket manager with an assumed JSON message format.
+// MessageSocket is a WebSocket manager for the dcrdata websocket protocol.
 //
-// JSON message format:
+// JSON message format (version 1):
 // {
-//   event: name,
-//   message: your message data
+//   version: 1,
+//   type: "subscribe" | "unsubscribe" | "ping" | "response" | "event",
+//   id: request id, echoed in the response,
+//   topic: "blocks" | "mempool" | "ticketpool" | "address:<addr>" | "tx:<txid>",
+//   event: name of an event,
+//   error: reason a request failed,
+//   payload: event or response data
 // }
 //
 // Functions for external use:
-// register(id, handler_function) -- register a function to handle events of
-//     the given type
-// send(id, data) -- create a JSON message in the above format and send it
+// registerEvtHandler(id, handler_function) -- register a function to handle
+//     events of the given type, with the event payload
+// subscribe(topic) -- subscribe to the events on the topic
+// unsubscribe(topic) -- unsubscribe from the events on the topic
+// send(type, topic) -- create a JSON request in the above format and send it
 //
 // Copyright (c) 2017, Jonathan Chappelow
 // See LICENSE for details.
@@ -19,6 +26,8 @@
 var MessageSocket = function(uri) {
   // create a new WebSocket connection
   var ws = new WebSocket(uri);
+  var version = 1;
+  var nextID = 1;
 
   // register an event handler
   var handlers = {};
@@ -28,20 +37,42 @@ var MessageSocket = function(uri) {
     return this;
   };
 
-  // send a message back to the server
-  this.send = function(eventID, message) {
-    var payload = JSON.stringify({
-      event: eventID,
-      message: message
+  // send a request to the server
+  this.send = function(type, topic) {
+    var request = JSON.stringify({
+      version: version,
+      type: type,
+      id: nextID++,
+      topic: topic
     });
-    ws.send(payload);
+    ws.send(request);
     return this;
   };
 
-  // unmarshall message, and forward the message to registered handlers
+  this.subscribe = function(topic) {
+    return this.send("subscribe", topic);
+  };
+
+  this.unsubscribe = function(topic) {
+    return this.send("unsubscribe", topic);
+  };
+
+  // unmarshall message, and forward the payload to registered handlers
   ws.onmessage = function(evt) {
     var json = JSON.parse(evt.data);
-    forward(json.event, json.message);
+    switch (json.type) {
+    case "event":
+      forward(json.event, json.payload);
+      break;
+    case "response":
+      if (json.error) {
+        console.warn("Request " + json.id + " failed:", json.error);
+      }
+      forward("response", json);
+      break;
+    default:
+      forward(json.type, json.payload);
+    }
   };
 
   // forward a message for the named event to the right handlers
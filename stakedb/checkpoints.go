@@ -0,0 +1,139 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package stakedb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/database"
+	"github.com/decred/dcrd/dcrutil"
+)
+
+// PoolCheckpointInterval is the number of blocks between the ticket pool info
+// checkpoints saved in the stake database.
+const PoolCheckpointInterval = 1440
+
+// poolCheckpointBucketName is the name of the stake database metadata bucket
+// holding the ticket pool info checkpoints, keyed by big-endian height.
+var poolCheckpointBucketName = []byte("poolinfocheckpoints")
+
+// serializedCheckpointSize is the size of a serialized PoolCheckpoint: block
+// hash, pool size and pool value.
+const serializedCheckpointSize = chainhash.HashSize + 4 + 8
+
+// PoolCheckpoint is the ticket pool size and value after connecting a block,
+// as persisted in the stake database at every PoolCheckpointInterval blocks.
+// The block hash allows checking that the checkpoint is on the main chain.
+type PoolCheckpoint struct {
+	Height    uint32
+	Hash      chainhash.Hash
+	PoolSize  uint32
+	PoolValue int64
+}
+
+// PoolInfo returns the checkpoint as a TicketPoolInfo.
+func (c *PoolCheckpoint) PoolInfo() *apitypes.TicketPoolInfo {
+	poolCoin := dcrutil.Amount(c.PoolValue).ToCoin()
+	valAvg := 0.0
+	if c.PoolSize > 0 {
+		valAvg = poolCoin / float64(c.PoolSize)
+	}
+	return &apitypes.TicketPoolInfo{
+		Size:   c.PoolSize,
+		Value:  poolCoin,
+		ValAvg: valAvg,
+	}
+}
+
+func checkpointKey(height uint32) []byte {
+	var key [4]byte
+	binary.BigEndian.PutUint32(key[:], height)
+	return key[:]
+}
+
+func serializeCheckpoint(c *PoolCheckpoint) []byte {
+	b := make([]byte, serializedCheckpointSize)
+	copy(b, c.Hash[:])
+	offset := chainhash.HashSize
+	binary.LittleEndian.PutUint32(b[offset:], c.PoolSize)
+	binary.LittleEndian.PutUint64(b[offset+4:], uint64(c.PoolValue))
+	return b
+}
+
+func deserializeCheckpoint(key, b []byte) (*PoolCheckpoint, error) {
+	if len(key) != 4 || len(b) != serializedCheckpointSize {
+		return nil, fmt.Errorf("malformed pool info checkpoint")
+	}
+	c := &PoolCheckpoint{
+		Height: binary.BigEndian.Uint32(key),
+	}
+	copy(c.Hash[:], b[:chainhash.HashSize])
+	offset := chainhash.HashSize
+	c.PoolSize = binary.LittleEndian.Uint32(b[offset:])
+	c.PoolValue = int64(binary.LittleEndian.Uint64(b[offset+4:]))
+	return c, nil
+}
+
+// storePoolCheckpoint saves a checkpoint of the ticket pool info at the
+// given block, replacing any existing checkpoint at the same height.
+func (db *StakeDatabase) storePoolCheckpoint(height uint32, hash chainhash.Hash,
+	tpi *apitypes.TicketPoolInfo) error {
+	poolValue, err := dcrutil.NewAmount(tpi.Value)
+	if err != nil {
+		return err
+	}
+	c := &PoolCheckpoint{
+		Height:    height,
+		Hash:      hash,
+		PoolSize:  tpi.Size,
+		PoolValue: int64(poolValue),
+	}
+	return db.StakeDB.Update(func(dbTx database.Tx) error {
+		bucket, err := dbTx.Metadata().CreateBucketIfNotExists(poolCheckpointBucketName)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(checkpointKey(height), serializeCheckpoint(c))
+	})
+}
+
+// deletePoolCheckpoint removes the checkpoint at the given height, if any.
+func (db *StakeDatabase) deletePoolCheckpoint(height uint32) error {
+	return db.StakeDB.Update(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(poolCheckpointBucketName)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete(checkpointKey(height))
+	})
+}
+
+// PoolCheckpoints returns all of the ticket pool info checkpoints in the stake
+// database, in order of increasing height. A checkpoint's block may no longer
+// be on the main chain, so the hash should be checked before use.
+func (db *StakeDatabase) PoolCheckpoints() ([]*PoolCheckpoint, error) {
+	var checkpoints []*PoolCheckpoint
+	err := db.StakeDB.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(poolCheckpointBucketName)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			c, err := deserializeCheckpoint(k, v)
+			if err != nil {
+				return err
+			}
+			checkpoints = append(checkpoints, c)
+			return nil
+		})
+	})
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].Height < checkpoints[j].Height
+	})
+	return checkpoints, err
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package stakedb
+
+import (
+	"fmt"
+	"sort"
+
+	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/dcrd/rpcclient"
+)
+
+// ticketInfoFunc gets the value and purchase height of each of the tickets,
+// and whether they could be determined.
+type ticketInfoFunc func(tickets []chainhash.Hash) (values, heights []int64, ok []bool)
+
+// liveTicketInfo gets the value and purchase height of each of the live
+// tickets from the live ticket caches, requesting any that are missing from
+// the node with pipelined RPCs. Tickets that could not be fetched are not ok.
+func (db *StakeDatabase) liveTicketInfo(liveTickets []chainhash.Hash) ([]int64, []int64, []bool) {
+	values := make([]int64, len(liveTickets))
+	heights := make([]int64, len(liveTickets))
+	ok := make([]bool, len(liveTickets))
+
+	type promiseGetRawTransactionVerbose struct {
+		result rpcclient.FutureGetRawTransactionVerboseResult
+		index  int
+	}
+	var promises []promiseGetRawTransactionVerbose
+
+	db.liveTicketMtx.Lock()
+	for i := range liveTickets {
+		val, okVal := db.liveTicketCache[liveTickets[i]]
+		height, okHeight := db.liveTicketHts[liveTickets[i]]
+		if okVal && okHeight {
+			values[i], heights[i], ok[i] = val, height, true
+			continue
+		}
+		promises = append(promises, promiseGetRawTransactionVerbose{
+			result: db.NodeClient.GetRawTransactionVerboseAsync(&liveTickets[i]),
+			index:  i,
+		})
+	}
+	db.liveTicketMtx.Unlock()
+
+	if len(promises) > 0 {
+		log.Debugf("Fetching %d live tickets missing from cache.", len(promises))
+	}
+
+	for _, p := range promises {
+		txRaw, err := p.result.Receive()
+		if err != nil {
+			log.Errorf("Unable to get transaction %v: %v", liveTickets[p.index], err)
+			continue
+		}
+		// As in PoolInfoBest, the value is that of the stake submission
+		// output.
+		var val int64
+		if len(txRaw.Vout) > 0 {
+			amt, err := dcrutil.NewAmount(txRaw.Vout[0].Value)
+			if err == nil {
+				val = int64(amt)
+			}
+		}
+		values[p.index], heights[p.index] = val, txRaw.BlockHeight
+		ok[p.index] = true
+
+		db.liveTicketMtx.Lock()
+		db.liveTicketCache[liveTickets[p.index]] = val
+		db.liveTicketHts[liveTickets[p.index]] = txRaw.BlockHeight
+		db.liveTicketMtx.Unlock()
+	}
+
+	return values, heights, ok
+}
+
+// PoolDistribution computes the distribution of the live tickets at the best
+// stake node by purchase price, in buckets of priceWidth DCR, and by age in
+// blocks since maturity, in buckets of ageWidth blocks. Only non-empty buckets
+// are included, in order of increasing price and age. Tickets whose value
+// could not be fetched from the node are counted as unavailable, and are not
+// included in either distribution.
+func (db *StakeDatabase) PoolDistribution(priceWidth float64, ageWidth int64) (*apitypes.TicketPoolDistribution, error) {
+	db.nodeMtx.RLock()
+	liveTickets := db.BestNode.LiveTickets()
+	height := db.BestNode.Height()
+	db.nodeMtx.RUnlock()
+
+	return poolDistribution(liveTickets, height, int64(db.params.TicketMaturity),
+		db.liveTicketInfo, priceWidth, ageWidth)
+}
+
+// poolDistribution computes the distribution of the live tickets at the given
+// height, getting their values and purchase heights from ticketInfo.
+func poolDistribution(liveTickets []chainhash.Hash, height uint32, maturity int64,
+	ticketInfo ticketInfoFunc, priceWidth float64, ageWidth int64) (*apitypes.TicketPoolDistribution, error) {
+	if priceWidth <= 0 || ageWidth <= 0 {
+		return nil, fmt.Errorf("bucket widths must be positive")
+	}
+	priceWidthAtoms, err := dcrutil.NewAmount(priceWidth)
+	if err != nil || priceWidthAtoms <= 0 {
+		return nil, fmt.Errorf("invalid price bucket width %v", priceWidth)
+	}
+
+	values, heights, ok := ticketInfo(liveTickets)
+
+	// Bucket values are summed in atoms, and converted to coins at the end.
+	type bucket struct {
+		lower float64
+		count uint32
+		value int64
+	}
+	priceBuckets := make(map[int64]*bucket)
+	ageBuckets := make(map[int64]*bucket)
+	addTo := func(buckets map[int64]*bucket, i int64, lower float64, value int64) {
+		b, ok := buckets[i]
+		if !ok {
+			b = &bucket{lower: lower}
+			buckets[i] = b
+		}
+		b.count++
+		b.value += value
+	}
+
+	var unavailable, unknownAge uint32
+	for i := range liveTickets {
+		if !ok[i] {
+			unavailable++
+			continue
+		}
+
+		priceIdx := values[i] / int64(priceWidthAtoms)
+		addTo(priceBuckets, priceIdx,
+			dcrutil.Amount(priceIdx*int64(priceWidthAtoms)).ToCoin(), values[i])
+
+		if heights[i] < 0 {
+			unknownAge++
+			continue
+		}
+		age := int64(height) - heights[i] - maturity
+		if age < 0 {
+			age = 0
+		}
+		ageIdx := age / ageWidth
+		addTo(ageBuckets, ageIdx, float64(ageIdx*ageWidth), values[i])
+	}
+
+	sortedBuckets := func(buckets map[int64]*bucket) []apitypes.TicketPoolBucket {
+		list := make([]apitypes.TicketPoolBucket, 0, len(buckets))
+		for _, b := range buckets {
+			list = append(list, apitypes.TicketPoolBucket{
+				Lower: b.lower,
+				Count: b.count,
+				Value: dcrutil.Amount(b.value).ToCoin(),
+			})
+		}
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Lower < list[j].Lower
+		})
+		return list
+	}
+
+	return &apitypes.TicketPoolDistribution{
+		Height:           height,
+		PoolSize:         uint32(len(liveTickets)),
+		PriceBucketWidth: priceWidth,
+		AgeBucketWidth:   ageWidth,
+		Price:            sortedBuckets(priceBuckets),
+		Age:              sortedBuckets(ageBuckets),
+		UnknownAge:       unknownAge,
+		Unavailable:      unavailable,
+	}, nil
+}
@@ -0,0 +1,76 @@
+package stakedb
+
+import (
+	"reflect"
+	"testing"
+
+	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+)
+
+func TestPoolDistribution(t *testing.T) {
+	type ticket struct {
+		value, height int64
+		ok            bool
+	}
+	// At height 100 with maturity 10, tickets bought at height 90 have age 0.
+	tickets := []ticket{
+		{1.5e8, 90, true},
+		{2.5e8, 80, true},
+		{2.0e8, 60, true},
+		{4.0e8, -1, true},
+		{0, -1, false},
+	}
+	liveTickets := make([]chainhash.Hash, len(tickets))
+	byHash := make(map[chainhash.Hash]ticket, len(tickets))
+	for i := range tickets {
+		liveTickets[i] = chainhash.HashH([]byte{byte(i)})
+		byHash[liveTickets[i]] = tickets[i]
+	}
+	ticketInfo := func(hashes []chainhash.Hash) ([]int64, []int64, []bool) {
+		values := make([]int64, len(hashes))
+		heights := make([]int64, len(hashes))
+		ok := make([]bool, len(hashes))
+		for i := range hashes {
+			tk := byHash[hashes[i]]
+			values[i], heights[i], ok[i] = tk.value, tk.height, tk.ok
+		}
+		return values, heights, ok
+	}
+
+	dist, err := poolDistribution(liveTickets, 100, 10, ticketInfo, 1, 15)
+	if err != nil {
+		t.Fatalf("poolDistribution failed: %v", err)
+	}
+
+	expected := &apitypes.TicketPoolDistribution{
+		Height:           100,
+		PoolSize:         5,
+		PriceBucketWidth: 1,
+		AgeBucketWidth:   15,
+		Price: []apitypes.TicketPoolBucket{
+			{Lower: 1, Count: 1, Value: 1.5},
+			{Lower: 2, Count: 2, Value: 4.5},
+			{Lower: 4, Count: 1, Value: 4},
+		},
+		Age: []apitypes.TicketPoolBucket{
+			{Lower: 0, Count: 2, Value: 4},
+			{Lower: 30, Count: 1, Value: 2},
+		},
+		UnknownAge:  1,
+		Unavailable: 1,
+	}
+	if !reflect.DeepEqual(dist, expected) {
+		t.Errorf("poolDistribution:\n got %+v\nwant %+v", dist, expected)
+	}
+
+	for _, widths := range []struct {
+		price float64
+		age   int64
+	}{{0, 15}, {1, 0}, {1e-9, 15}} {
+		if _, err = poolDistribution(liveTickets, 100, 10, ticketInfo,
+			widths.price, widths.age); err == nil {
+			t.Errorf("no error for bucket widths %v and %d", widths.price, widths.age)
+		}
+	}
+}
@@ -65,7 +65,9 @@ type StakeDatabase struct {
 	blockCache      map[int64]*dcrutil.Block
 	liveTicketMtx   sync.Mutex
 	liveTicketCache map[chainhash.Hash]int64
+	liveTicketHts   map[chainhash.Hash]int64
 	poolInfo        *PoolInfoCache
+	ticketUpdates   *TicketUpdateCache
 }
 
 const (
@@ -83,7 +85,9 @@ func NewStakeDatabase(client *rpcclient.Client, params *chaincfg.Params) (*Stake
 		NodeClient:      client,
 		blockCache:      make(map[int64]*dcrutil.Block),
 		liveTicketCache: make(map[chainhash.Hash]int64),
+		liveTicketHts:   make(map[chainhash.Hash]int64),
 		poolInfo:        NewPoolInfoCache(),
+		ticketUpdates:   NewTicketUpdateCache(TicketUpdateCacheHeights),
 	}
 	if err := sDB.Open(); err != nil {
 		return nil, err
@@ -216,7 +220,17 @@ func (db *StakeDatabase) ConnectBlock(block *dcrutil.Block) error {
 		if wasCached {
 			db.ForgetBlock(maturingHeight)
 		}
-		maturingTickets, _ = txhelpers.TicketsInBlock(maturingBlock)
+		var maturingTxs []*wire.MsgTx
+		maturingTickets, maturingTxs = txhelpers.TicketsInBlock(maturingBlock)
+
+		// The value and purchase height of the maturing tickets are known, so
+		// cache them now rather than fetching them when needed.
+		db.liveTicketMtx.Lock()
+		for i := range maturingTickets {
+			db.liveTicketCache[maturingTickets[i]] = maturingTxs[i].TxOut[0].Value
+			db.liveTicketHts[maturingTickets[i]] = maturingHeight
+		}
+		db.liveTicketMtx.Unlock()
 	}
 
 	db.blkMtx.Lock()
@@ -244,9 +258,11 @@ func (db *StakeDatabase) connectBlock(block *dcrutil.Block, spent []chainhash.Ha
 		db.liveTicketMtx.Lock()
 		for i := range spent {
 			delete(db.liveTicketCache, spent[i])
+			delete(db.liveTicketHts, spent[i])
 		}
 		for i := range revoked {
 			delete(db.liveTicketCache, revoked[i])
+			delete(db.liveTicketHts, revoked[i])
 		}
 		db.liveTicketMtx.Unlock()
 	}
@@ -265,6 +281,10 @@ func (db *StakeDatabase) connectBlock(block *dcrutil.Block, spent []chainhash.Ha
 		return err
 	}
 
+	// The undo data of the new best node records the ticket status changes.
+	db.ticketUpdates.Set(*block.Hash(), block.Height(),
+		ticketUpdatesFromUndoData(db.BestNode.UndoData()))
+
 	db.nodeMtx.Unlock()
 
 	// Get ticket pool info at current best (just connected in stakedb) block,
@@ -272,7 +292,17 @@ func (db *StakeDatabase) connectBlock(block *dcrutil.Block, spent []chainhash.Ha
 	tpi, _ := db.PoolInfoBest()
 	db.poolInfo.Set(*block.Hash(), &tpi)
 
-	return err
+	// Periodically checkpoint the pool info so that pool value history can be
+	// reconstructed without replaying the whole chain.
+	height := uint32(block.Height())
+	if height%PoolCheckpointInterval == 0 {
+		if err = db.storePoolCheckpoint(height, *block.Hash(), &tpi); err != nil {
+			log.Errorf("Unable to store pool info checkpoint at height %d: %v",
+				height, err)
+		}
+	}
+
+	return nil
 }
 
 // DisconnectBlock attempts to disconnect the current best block from the stake
@@ -312,10 +342,19 @@ func (db *StakeDatabase) disconnectBlock() error {
 	}
 	db.BestNode = parentStakeNode
 
-	return db.StakeDB.Update(func(dbTx database.Tx) error {
+	err = db.StakeDB.Update(func(dbTx database.Tx) error {
 		return stake.WriteDisconnectedBestNode(dbTx, parentStakeNode,
 			*parentBlock.Hash(), childUndoData)
 	})
+	if err != nil {
+		return err
+	}
+
+	// The checkpoint for the disconnected block, if any, is no longer valid.
+	if childHeight%PoolCheckpointInterval == 0 {
+		return db.deletePoolCheckpoint(childHeight)
+	}
+	return nil
 }
 
 // DisconnectBlocks disconnects N blocks from the head of the chain.
@@ -332,6 +371,38 @@ func (db *StakeDatabase) DisconnectBlocks(count int64) error {
 	return nil
 }
 
+// Reset deletes the stake database and creates a new one with the best node at
+// genesis, clearing the caches. The blocks must then be connected again from
+// height 1, which records the ticket status changes of every block.
+func (db *StakeDatabase) Reset() error {
+	db.nodeMtx.Lock()
+	err := db.StakeDB.Close()
+	if err != nil {
+		db.nodeMtx.Unlock()
+		return err
+	}
+	db.BestNode = nil
+	err = os.RemoveAll(DefaultStakeDbName)
+	db.nodeMtx.Unlock()
+	if err != nil {
+		return err
+	}
+
+	db.blkMtx.Lock()
+	db.blockCache = make(map[int64]*dcrutil.Block)
+	db.blkMtx.Unlock()
+
+	db.liveTicketMtx.Lock()
+	db.liveTicketCache = make(map[chainhash.Hash]int64)
+	db.liveTicketHts = make(map[chainhash.Hash]int64)
+	db.liveTicketMtx.Unlock()
+
+	db.poolInfo = NewPoolInfoCache()
+	db.ticketUpdates = NewTicketUpdateCache(TicketUpdateCacheHeights)
+
+	return db.Open()
+}
+
 // Open attempts to open an existing stake database, and will create a new one
 // if one does not exist.
 func (db *StakeDatabase) Open() error {
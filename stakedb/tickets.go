@@ -0,0 +1,120 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package stakedb
+
+import (
+	"sync"
+
+	"github.com/dcrdata/dcrdata/dbtypes"
+	"github.com/decred/dcrd/blockchain/stake"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+)
+
+// TicketUpdateCacheHeights is the number of heights below the best block for
+// which a TicketUpdateCache keeps the ticket updates, which is enough for the
+// blocks of any reorganization to be indexed.
+const TicketUpdateCacheHeights = 256
+
+// TicketUpdateCache contains a map of block hashes to the ticket status changes
+// made by connecting the block to the stake database. Only the blocks within
+// numHeights of the highest block set are kept.
+type TicketUpdateCache struct {
+	sync.RWMutex
+	updates    map[chainhash.Hash]*blockTicketUpdates
+	numHeights int64
+	bestHeight int64
+}
+
+// blockTicketUpdates are the ticket updates of the block at height.
+type blockTicketUpdates struct {
+	height  int64
+	updates []dbtypes.TicketUpdate
+}
+
+// NewTicketUpdateCache constructs a new TicketUpdateCache keeping the updates
+// of numHeights heights, and is needed to initialize the internal map.
+func NewTicketUpdateCache(numHeights int64) *TicketUpdateCache {
+	return &TicketUpdateCache{
+		updates:    make(map[chainhash.Hash]*blockTicketUpdates),
+		numHeights: numHeights,
+		bestHeight: -1,
+	}
+}
+
+// Get attempts to fetch the ticket updates for a given block hash, returning
+// the updates, and a bool indicating if the hash was found in the map.
+func (c *TicketUpdateCache) Get(hash chainhash.Hash) ([]dbtypes.TicketUpdate, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	b, ok := c.updates[hash]
+	if !ok {
+		return nil, false
+	}
+	return b.updates, true
+}
+
+// Set stores the ticket updates for the block with the given hash and height
+// in the cache, evicting the blocks that are now too far below the best.
+func (c *TicketUpdateCache) Set(hash chainhash.Hash, height int64, updates []dbtypes.TicketUpdate) {
+	c.Lock()
+	defer c.Unlock()
+	c.updates[hash] = &blockTicketUpdates{height, updates}
+	if height <= c.bestHeight {
+		return
+	}
+	c.bestHeight = height
+	for h, b := range c.updates {
+		if b.height <= c.bestHeight-c.numHeights {
+			delete(c.updates, h)
+		}
+	}
+}
+
+// ticketUpdatesFromUndoData converts the undo data of a stake node, which
+// records the new state of each ticket affected by connecting its block, into
+// ticket updates.
+func ticketUpdatesFromUndoData(undoData stake.UndoTicketDataSlice) []dbtypes.TicketUpdate {
+	updates := make([]dbtypes.TicketUpdate, 0, len(undoData))
+	for _, u := range undoData {
+		update := dbtypes.TicketUpdate{
+			TicketHash:     u.TicketHash.String(),
+			PurchaseHeight: int64(u.TicketHeight),
+		}
+		switch {
+		case u.Revoked:
+			update.Revoked = true
+		case u.Spent:
+			update.Status = dbtypes.PoolStatusVoted
+		case u.Expired:
+			update.Status = dbtypes.PoolStatusExpired
+		case u.Missed:
+			update.Status = dbtypes.PoolStatusMissed
+		default:
+			// A ticket with no flags set has just matured.
+			update.Status = dbtypes.PoolStatusLive
+		}
+		updates = append(updates, update)
+	}
+	return updates
+}
+
+// TicketUpdates attempts to fetch the ticket status changes made by the block
+// with the specified hash from an internal cache, which holds the updates for
+// the blocks connected since the stake database was opened that are within
+// TicketUpdateCacheHeights of the best block.
+func (db *StakeDatabase) TicketUpdates(hash chainhash.Hash) ([]dbtypes.TicketUpdate, bool) {
+	return db.ticketUpdates.Get(hash)
+}
+
+// BestNodeWinners returns the height and hash of the best block in the stake
+// database, and the tickets selected to vote on it.
+func (db *StakeDatabase) BestNodeWinners() (uint32, *chainhash.Hash, []chainhash.Hash, error) {
+	db.nodeMtx.RLock()
+	defer db.nodeMtx.RUnlock()
+	height, hash, err := db.dbState()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return height, hash, db.BestNode.Winners(), nil
+}
@@ -0,0 +1,44 @@
+package stakedb
+
+import (
+	"testing"
+
+	"github.com/dcrdata/dcrdata/dbtypes"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+)
+
+func TestTicketUpdateCache(t *testing.T) {
+	const numHeights = 4
+	c := NewTicketUpdateCache(numHeights)
+
+	hashes := make([]chainhash.Hash, 10)
+	for i := range hashes {
+		hashes[i] = chainhash.HashH([]byte{byte(i)})
+		c.Set(hashes[i], int64(i), []dbtypes.TicketUpdate{{PurchaseHeight: int64(i)}})
+	}
+
+	// Only the last numHeights heights are kept.
+	for i := range hashes {
+		updates, found := c.Get(hashes[i])
+		if expected := i >= len(hashes)-numHeights; found != expected {
+			t.Errorf("height %d found = %v, expected %v", i, found, expected)
+			continue
+		}
+		if found && (len(updates) != 1 || updates[0].PurchaseHeight != int64(i)) {
+			t.Errorf("height %d has wrong updates %v", i, updates)
+		}
+	}
+
+	// A side chain block at a kept height is added without evicting others.
+	side := chainhash.HashH([]byte("side"))
+	c.Set(side, 8, nil)
+	if _, found := c.Get(side); !found {
+		t.Errorf("side chain block not found")
+	}
+	if _, found := c.Get(hashes[6]); !found {
+		t.Errorf("height 6 evicted by a block at a lower height than the best")
+	}
+	if len(c.updates) != numHeights+1 {
+		t.Errorf("cache holds %d blocks, expected %d", len(c.updates), numHeights+1)
+	}
+}
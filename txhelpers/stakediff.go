@@ -0,0 +1,314 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package txhelpers
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/decred/dcrd/chaincfg"
+)
+
+// The functions in this file implement the DCP0001 stake difficulty algorithm
+// used by dcrd, so that the next ticket price can be estimated from block
+// header data without dcrd's estimatestakediff RPC.
+
+// StakeDiffBlock is the block header data used by the stake difficulty
+// algorithm. PoolSize is the pool size in the block header, which does not
+// include the tickets maturing in the block.
+type StakeDiffBlock struct {
+	Height     int64
+	SBits      int64
+	PoolSize   uint32
+	FreshStake uint8
+}
+
+// StakeDiffHistory is the StakeDiffBlock data for a run of consecutive blocks
+// ending at the best block. It must start at or below the height given by
+// StakeDiffHistoryStart for the best block.
+type StakeDiffHistory []StakeDiffBlock
+
+// StakeDiffHistoryStart returns the lowest block height needed in the
+// StakeDiffHistory used to estimate the next stake difficulty at bestHeight.
+func StakeDiffHistoryStart(params *chaincfg.Params, bestHeight int64) int64 {
+	intervalSize := params.StakeDiffWindowSize
+	nextRetargetHeight := bestHeight + intervalSize - bestHeight%intervalSize
+	// The tickets purchased in the TicketMaturity blocks up to the block
+	// before the previous retarget are needed.
+	start := nextRetargetHeight - intervalSize - int64(params.TicketMaturity)
+	if start < 0 {
+		start = 0
+	}
+	return start
+}
+
+// StakeDiffPoolSizeHeights returns the heights of the blocks whose header pool
+// sizes are used to estimate the next stake difficulty at bestHeight: the block
+// before the previous retarget, unless that is below genesis, and the best
+// block. The pool sizes of the other blocks in the StakeDiffHistory are not
+// used.
+func StakeDiffPoolSizeHeights(params *chaincfg.Params, bestHeight int64) []int64 {
+	prevRetargetHeight := bestHeight - bestHeight%params.StakeDiffWindowSize - 1
+	if prevRetargetHeight < 0 {
+		return []int64{bestHeight}
+	}
+	return []int64{prevRetargetHeight, bestHeight}
+}
+
+// best returns the last block in the history.
+func (h StakeDiffHistory) best() *StakeDiffBlock {
+	return &h[len(h)-1]
+}
+
+// block returns the block at the given height, or nil if the height is
+// negative.
+func (h StakeDiffHistory) block(height int64) (*StakeDiffBlock, error) {
+	if height < 0 {
+		return nil, nil
+	}
+	i := height - h[0].Height
+	if i < 0 || i >= int64(len(h)) {
+		return nil, fmt.Errorf("block %d not in stake difficulty history [%d,%d]",
+			height, h[0].Height, h.best().Height)
+	}
+	return &h[i], nil
+}
+
+// sumPurchasedTickets returns the number of tickets purchased in the n blocks
+// ending at the given height.
+func (h StakeDiffHistory) sumPurchasedTickets(height, n int64) (int64, error) {
+	var total int64
+	for i := height; i > height-n && i >= 0; i-- {
+		b, err := h.block(i)
+		if err != nil {
+			return 0, err
+		}
+		total += int64(b.FreshStake)
+	}
+	return total, nil
+}
+
+// EstimateSupply returns an estimate of the coin supply in atoms at the given
+// block height, as used to limit the stake difficulty.
+func EstimateSupply(params *chaincfg.Params, height int64) int64 {
+	if height <= 0 {
+		return 0
+	}
+
+	// Estimate the supply by calculating the full block subsidy for each
+	// reduction interval and multiplying it by the number of blocks in the
+	// interval, then adding the subsidy produced by the number of blocks in
+	// the current interval.
+	supply := params.BlockOneSubsidy()
+	reductions := height / params.SubsidyReductionInterval
+	subsidy := params.BaseSubsidy
+	for i := int64(0); i < reductions; i++ {
+		supply += params.SubsidyReductionInterval * subsidy
+
+		subsidy *= params.MulSubsidy
+		subsidy /= params.DivSubsidy
+	}
+	supply += (1 + height%params.SubsidyReductionInterval) * subsidy
+
+	// Blocks 0 and 1 have special subsidy amounts that have already been
+	// added above, so remove what their subsidies would have normally been.
+	supply -= params.BaseSubsidy * 2
+
+	return supply
+}
+
+// CalcNextStakeDiff returns the stake difficulty in atoms for the retarget at
+// nextHeight, given the current difficulty and the sizes of the ticket pool,
+// including immature tickets, at the previous and next retargets. Integer
+// math is used, as in consensus code.
+func CalcNextStakeDiff(params *chaincfg.Params, nextHeight, curDiff,
+	prevPoolSizeAll, curPoolSizeAll int64) int64 {
+	votesPerBlock := int64(params.TicketsPerBlock)
+	ticketPoolSize := int64(params.TicketPoolSize)
+	ticketMaturity := int64(params.TicketMaturity)
+
+	//                   curDiff * curPoolSizeAll^2
+	//   nextDiff = -----------------------------------
+	//              prevPoolSizeAll * targetPoolSizeAll
+	targetPoolSizeAll := votesPerBlock * (ticketPoolSize + ticketMaturity)
+	curPoolSizeAllBig := big.NewInt(curPoolSizeAll)
+	nextDiffBig := big.NewInt(curDiff)
+	nextDiffBig.Mul(nextDiffBig, curPoolSizeAllBig)
+	nextDiffBig.Mul(nextDiffBig, curPoolSizeAllBig)
+	nextDiffBig.Div(nextDiffBig, big.NewInt(prevPoolSizeAll))
+	nextDiffBig.Div(nextDiffBig, big.NewInt(targetPoolSizeAll))
+
+	// Limit the new stake difficulty between the minimum allowed stake
+	// difficulty and a maximum value that is relative to the total supply.
+	nextDiff := nextDiffBig.Int64()
+	maximumStakeDiff := EstimateSupply(params, nextHeight) / ticketPoolSize
+	if nextDiff > maximumStakeDiff {
+		nextDiff = maximumStakeDiff
+	}
+	if nextDiff < params.MinimumStakeDiff {
+		nextDiff = params.MinimumStakeDiff
+	}
+	return nextDiff
+}
+
+// MaxRemainingTickets returns the largest number of tickets that may still be
+// purchased in the stake difficulty window of the best block of the history.
+func MaxRemainingTickets(params *chaincfg.Params, bestHeight int64) int64 {
+	intervalSize := params.StakeDiffWindowSize
+	blocksUntilRetarget := intervalSize - bestHeight%intervalSize
+	return (blocksUntilRetarget - 1) * int64(params.MaxFreshStakePerBlock)
+}
+
+// EstimateNextStakeDiff estimates the stake difficulty in atoms of the next
+// window, assuming newTickets more tickets are purchased in the remainder of
+// the current window after the best block of the history. If useMaxTickets is
+// true, the maximum possible number of tickets is assumed instead.
+func EstimateNextStakeDiff(params *chaincfg.Params, history StakeDiffHistory,
+	newTickets int64, useMaxTickets bool) (int64, error) {
+	if len(history) == 0 {
+		return 0, fmt.Errorf("empty stake difficulty history")
+	}
+	curNode := history.best()
+	curHeight := curNode.Height
+
+	ticketMaturity := int64(params.TicketMaturity)
+	intervalSize := params.StakeDiffWindowSize
+	blocksUntilRetarget := intervalSize - curHeight%intervalSize
+	nextRetargetHeight := curHeight + blocksUntilRetarget
+
+	maxTicketsPerBlock := int64(params.MaxFreshStakePerBlock)
+	maxRemainingTickets := MaxRemainingTickets(params, curHeight)
+	if useMaxTickets {
+		newTickets = maxRemainingTickets
+	}
+	if newTickets > maxRemainingTickets {
+		return 0, fmt.Errorf("unable to create an estimated stake difficulty "+
+			"with %d tickets since it is more than the maximum remaining of %d",
+			newTickets, maxRemainingTickets)
+	}
+	if newTickets < 0 {
+		return 0, fmt.Errorf("negative number of new tickets")
+	}
+
+	// Stake difficulty before any tickets could possibly be purchased is
+	// the minimum value.
+	stakeDiffStartHeight := int64(params.CoinbaseMaturity) + 1
+	if nextRetargetHeight < stakeDiffStartHeight {
+		return params.MinimumStakeDiff, nil
+	}
+
+	// Get the pool size and number of tickets that were immature at the
+	// previous retarget interval, relative to the block before it.
+	var prevPoolSize int64
+	prevRetargetHeight := nextRetargetHeight - intervalSize - 1
+	prevRetargetNode, err := history.block(prevRetargetHeight)
+	if err != nil {
+		return 0, err
+	}
+	if prevRetargetNode != nil {
+		prevPoolSize = int64(prevRetargetNode.PoolSize)
+	}
+	prevImmatureTickets, err := history.sumPurchasedTickets(prevRetargetHeight,
+		ticketMaturity)
+	if err != nil {
+		return 0, err
+	}
+
+	// Return the existing ticket price for the first few intervals to avoid
+	// division by zero and encourage initial pool population.
+	curDiff := curNode.SBits
+	prevPoolSizeAll := prevPoolSize + prevImmatureTickets
+	if prevPoolSizeAll == 0 {
+		return curDiff, nil
+	}
+
+	// Calculate the number of tickets that will still be immature at the
+	// next retarget based on the known data.
+	var remainingImmatureTickets int64
+	nextMaturityFloor := nextRetargetHeight - ticketMaturity - 1
+	if curHeight > nextMaturityFloor {
+		remainingImmatureTickets, err = history.sumPurchasedTickets(curHeight,
+			curHeight-nextMaturityFloor)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Add the number of tickets that will still be immature at the next
+	// retarget based on the estimated data.
+	maxImmatureTickets := ticketMaturity * maxTicketsPerBlock
+	if newTickets > maxImmatureTickets {
+		remainingImmatureTickets += maxImmatureTickets
+	} else {
+		remainingImmatureTickets += newTickets
+	}
+
+	// Calculate the number of tickets that will mature in the remainder of
+	// the interval based on the known data. The pool size in the block
+	// headers does not include the tickets maturing at that height, so start
+	// one block before the next maturity floor.
+	finalMaturingHeight := nextMaturityFloor - 1
+	if finalMaturingHeight > curHeight {
+		finalMaturingHeight = curHeight
+	}
+	firstMaturingHeight := curHeight - ticketMaturity
+	maturingTickets, err := history.sumPurchasedTickets(finalMaturingHeight,
+		finalMaturingHeight-firstMaturingHeight+1)
+	if err != nil {
+		return 0, err
+	}
+
+	// Add the number of tickets that will mature based on the estimated
+	// data.
+	if curHeight < nextMaturityFloor {
+		maturingEstimateNodes := nextMaturityFloor - curHeight - 1
+		maturingEstimatedTickets := maxTicketsPerBlock * maturingEstimateNodes
+		if maturingEstimatedTickets > newTickets {
+			maturingEstimatedTickets = newTickets
+		}
+		maturingTickets += maturingEstimatedTickets
+	}
+
+	// Calculate the number of votes that will occur during the remainder of
+	// the interval.
+	stakeValidationHeight := params.StakeValidationHeight
+	var pendingVotes int64
+	if nextRetargetHeight > stakeValidationHeight {
+		votingBlocks := blocksUntilRetarget - 1
+		if curHeight < stakeValidationHeight {
+			votingBlocks = nextRetargetHeight - stakeValidationHeight
+		}
+		pendingVotes = votingBlocks * int64(params.TicketsPerBlock)
+	}
+
+	// Calculate what the pool size would be as of the next interval.
+	estimatedPoolSize := int64(curNode.PoolSize) + maturingTickets - pendingVotes
+	estimatedPoolSizeAll := estimatedPoolSize + remainingImmatureTickets
+
+	return CalcNextStakeDiff(params, nextRetargetHeight, curDiff,
+		prevPoolSizeAll, estimatedPoolSizeAll), nil
+}
+
+// ExpectedNewTickets projects the number of tickets to be purchased in the
+// remainder of the current stake difficulty window from the average number
+// per block so far in the window.
+func ExpectedNewTickets(params *chaincfg.Params, history StakeDiffHistory) (int64, error) {
+	if len(history) == 0 {
+		return 0, fmt.Errorf("empty stake difficulty history")
+	}
+	bestHeight := history.best().Height
+	intervalSize := params.StakeDiffWindowSize
+	lastAdjustment := (bestHeight / intervalSize) * intervalSize
+	nextAdjustment := lastAdjustment + intervalSize
+
+	blocksSince := bestHeight - lastAdjustment + 1
+	totalTickets, err := history.sumPurchasedTickets(bestHeight, blocksSince)
+	if err != nil {
+		return 0, err
+	}
+	remaining := float64(nextAdjustment - bestHeight - 1)
+	averagePerBlock := float64(totalTickets) / float64(blocksSince)
+	return int64(math.Floor(averagePerBlock * remaining)), nil
+}
@@ -29,9 +29,17 @@ type RawTransactionGetter interface {
 	GetRawTransaction(txHash *chainhash.Hash) (*dcrutil.Tx, error)
 }
 
+// OutpointAddressGetter is an interface satisfied by the databases with an
+// address table, such as dcrsqlite.DB, which give the addresses paid to by an
+// indexed transaction output.
+type OutpointAddressGetter interface {
+	RetrieveOutpointAddresses(txHash string, index uint32) ([]string, int64, error)
+}
+
 // BlockWatchedTx contains, for a certain block, the transactions for certain
 // watched addresses
 type BlockWatchedTx struct {
+	BlockHash     chainhash.Hash
 	BlockHeight   int64
 	TxsForAddress map[string][]*dcrutil.Tx
 }
@@ -95,55 +103,100 @@ func IncludesTx(txHash *chainhash.Hash, block *dcrutil.Block) (int, int8) {
 
 // BlockConsumesOutpointWithAddresses checks the specified block to see if it
 // includes transactions that spend from outputs created using any of the
-// addresses in addrs. The TxAction for each address is not important, but it
-// would logically be TxMined. Both regular and stake transactions are checked.
-// The RPC client is used to get the PreviousOutPoint for each TxIn of each
-// transaction in the block, from which the address is obtained from the
-// PkScript of that output. chaincfg Params is requried to decode the script.
+// addresses in addrs, and creates a map of addresses to a slice of the
+// dcrutil.Tx spending from the address. The TxAction for each address is not
+// important, but it would logically be TxMined. Both regular and stake
+// transactions are checked. The addresses of the outputs created in the block
+// itself are decoded from their PkScript, which requires the chaincfg Params,
+// and those of earlier outputs are looked up in the address table with g, so
+// the table must be current up to the previous block.
 func BlockConsumesOutpointWithAddresses(block *dcrutil.Block, addrs map[string]TxAction,
-	c RawTransactionGetter, params *chaincfg.Params) map[string][]*dcrutil.Tx {
-	addrMap := make(map[string][]*dcrutil.Tx)
-
-	checkForOutpointAddr := func(blockTxs []*dcrutil.Tx) {
+	g OutpointAddressGetter, params *chaincfg.Params) (map[string][]*dcrutil.Tx, error) {
+	// The outputs created in the block are not yet in the address table.
+	blockOuts := make(map[wire.OutPoint][]string)
+	for _, blockTxs := range [][]*dcrutil.Tx{block.Transactions(), block.STransactions()} {
 		for _, tx := range blockTxs {
-			for _, txIn := range tx.MsgTx().TxIn {
-				prevOut := &txIn.PreviousOutPoint
-				// For each TxIn, check the indicated vout index in the txid of the
-				// previous outpoint.
-				// txrr, err := c.GetRawTransactionVerbose(&prevOut.Hash)
-				prevTx, err := c.GetRawTransaction(&prevOut.Hash)
-				if err != nil {
-					fmt.Printf("Unable to get raw transaction for %s\n", prevOut.Hash.String())
+			for i, txOut := range tx.MsgTx().TxOut {
+				_, txAddrs, _, err := txscript.ExtractPkScriptAddrs(
+					txOut.Version, txOut.PkScript, params)
+				if err != nil || len(txAddrs) == 0 {
 					continue
 				}
-
-				// prevOut.Index should tell us which one, but check all anyway
-				for _, txOut := range prevTx.MsgTx().TxOut {
-					_, txAddrs, _, err := txscript.ExtractPkScriptAddrs(
-						txOut.Version, txOut.PkScript, params)
-					if err != nil {
-						fmt.Printf("ExtractPkScriptAddrs: %v\n", err.Error())
-						continue
-					}
-
-					for _, txAddr := range txAddrs {
-						addrstr := txAddr.EncodeAddress()
-						if _, ok := addrs[addrstr]; ok {
-							if addrMap[addrstr] == nil {
-								addrMap[addrstr] = make([]*dcrutil.Tx, 0)
-							}
-							addrMap[addrstr] = append(addrMap[addrstr], prevTx)
-						}
-					}
+				outAddrs := make([]string, 0, len(txAddrs))
+				for _, txAddr := range txAddrs {
+					outAddrs = append(outAddrs, txAddr.EncodeAddress())
 				}
+				op := wire.OutPoint{Hash: *tx.Hash(), Index: uint32(i), Tree: tx.Tree()}
+				blockOuts[op] = outAddrs
 			}
 		}
 	}
+	prevOutAddrs := func(prevOut *wire.OutPoint) ([]string, error) {
+		if outAddrs, ok := blockOuts[*prevOut]; ok {
+			return outAddrs, nil
+		}
+		outAddrs, _, err := g.RetrieveOutpointAddresses(prevOut.Hash.String(),
+			prevOut.Index)
+		return outAddrs, err
+	}
 
-	checkForOutpointAddr(block.Transactions())
-	checkForOutpointAddr(block.STransactions())
+	addrMap := make(map[string][]*dcrutil.Tx)
+	for _, blockTxs := range [][]*dcrutil.Tx{block.Transactions(), block.STransactions()} {
+		for _, tx := range blockTxs {
+			found, err := consumedAddresses(tx, addrs, prevOutAddrs)
+			if err != nil {
+				return nil, err
+			}
+			for _, addrstr := range found {
+				addrMap[addrstr] = append(addrMap[addrstr], tx)
+			}
+		}
+	}
+	return addrMap, nil
+}
 
-	return addrMap
+// TxConsumesOutpointWithAddresses checks the previous outpoints spent by the
+// inputs of the transaction, and returns the addresses in addrs paid to by any
+// of them, each listed once. Coinbase and stakebase inputs, which spend no
+// previous outpoint, are skipped. The addresses of the previous outpoints are
+// looked up in the address table with g, so the outputs that are not yet
+// indexed, such as those of other mempool transactions, are not found.
+func TxConsumesOutpointWithAddresses(tx *dcrutil.Tx, addrs map[string]TxAction,
+	g OutpointAddressGetter) ([]string, error) {
+	return consumedAddresses(tx, addrs, func(prevOut *wire.OutPoint) ([]string, error) {
+		outAddrs, _, err := g.RetrieveOutpointAddresses(prevOut.Hash.String(),
+			prevOut.Index)
+		return outAddrs, err
+	})
+}
+
+// consumedAddresses returns the addresses in addrs paid to by the previous
+// outpoints spent by the inputs of the transaction, each listed once, given by
+// prevOutAddrs. Coinbase and stakebase inputs are skipped.
+func consumedAddresses(tx *dcrutil.Tx, addrs map[string]TxAction,
+	prevOutAddrs func(prevOut *wire.OutPoint) ([]string, error)) ([]string, error) {
+	var found []string
+	seen := make(map[string]struct{})
+	for _, txIn := range tx.MsgTx().TxIn {
+		prevOut := &txIn.PreviousOutPoint
+		if prevOut.Index == wire.MaxPrevOutIndex && prevOut.Hash == (chainhash.Hash{}) {
+			continue
+		}
+		outAddrs, err := prevOutAddrs(prevOut)
+		if err != nil {
+			return nil, err
+		}
+		for _, addrstr := range outAddrs {
+			if _, ok := addrs[addrstr]; !ok {
+				continue
+			}
+			if _, ok := seen[addrstr]; !ok {
+				seen[addrstr] = struct{}{}
+				found = append(found, addrstr)
+			}
+		}
+	}
+	return found, nil
 }
 
 // BlockReceivesToAddresses checks a block for transactions paying to the
@@ -155,25 +208,8 @@ func BlockReceivesToAddresses(block *dcrutil.Block, addrs map[string]TxAction,
 
 	checkForAddrOut := func(blockTxs []*dcrutil.Tx) {
 		for _, tx := range blockTxs {
-			// Check the addresses associated with the PkScript of each TxOut
-			for _, txOut := range tx.MsgTx().TxOut {
-				_, txOutAddrs, _, err := txscript.ExtractPkScriptAddrs(txOut.Version,
-					txOut.PkScript, params)
-				if err != nil {
-					fmt.Printf("ExtractPkScriptAddrs: %v", err.Error())
-					continue
-				}
-
-				// Check if we are watching any address for this TxOut
-				for _, txAddr := range txOutAddrs {
-					addrstr := txAddr.EncodeAddress()
-					if _, ok := addrs[addrstr]; ok {
-						if _, gotSlice := addrMap[addrstr]; !gotSlice {
-							addrMap[addrstr] = make([]*dcrutil.Tx, 0) // nil
-						}
-						addrMap[addrstr] = append(addrMap[addrstr], tx)
-					}
-				}
+			for _, addrstr := range TxReceivesToAddresses(tx, addrs, params) {
+				addrMap[addrstr] = append(addrMap[addrstr], tx)
 			}
 		}
 	}
@@ -184,6 +220,36 @@ func BlockReceivesToAddresses(block *dcrutil.Block, addrs map[string]TxAction,
 	return addrMap
 }
 
+// TxReceivesToAddresses checks the outputs of the transaction, and returns the
+// addresses in addrs paid to by any of them, each listed once.
+func TxReceivesToAddresses(tx *dcrutil.Tx, addrs map[string]TxAction,
+	params *chaincfg.Params) []string {
+	var found []string
+	seen := make(map[string]struct{})
+	// Check the addresses associated with the PkScript of each TxOut
+	for _, txOut := range tx.MsgTx().TxOut {
+		_, txOutAddrs, _, err := txscript.ExtractPkScriptAddrs(txOut.Version,
+			txOut.PkScript, params)
+		if err != nil {
+			fmt.Printf("ExtractPkScriptAddrs: %v", err.Error())
+			continue
+		}
+
+		// Check if we are watching any address for this TxOut
+		for _, txAddr := range txOutAddrs {
+			addrstr := txAddr.EncodeAddress()
+			if _, ok := addrs[addrstr]; !ok {
+				continue
+			}
+			if _, ok := seen[addrstr]; !ok {
+				seen[addrstr] = struct{}{}
+				found = append(found, addrstr)
+			}
+		}
+	}
+	return found
+}
+
 // OutPointAddresses gets the addresses payed to by a transaction output.
 func OutPointAddresses(outPoint *wire.OutPoint, c RawTransactionGetter,
 	params *chaincfg.Params) ([]string, error) {
@@ -480,16 +546,32 @@ func FeeInfoBlock(block *dcrutil.Block) *dcrjson.FeeInfoBlock {
 // FeeRateInfoBlock computes ticket fee rate statistics for the tickets included
 // in the specified block.
 func FeeRateInfoBlock(block *dcrutil.Block) *dcrjson.FeeInfoBlock {
-	feeInfo := new(dcrjson.FeeInfoBlock)
 	_, sstxMsgTxns := TicketsInBlock(block)
+	return feeRateInfo(block.Height(), sstxMsgTxns)
+}
 
-	feeInfo.Height = uint32(block.Height())
-	feeInfo.Number = uint32(len(sstxMsgTxns))
+// FeeRateInfoBlockRegular computes fee rate statistics for the regular
+// transactions included in the specified block, excluding the coinbase.
+func FeeRateInfoBlockRegular(block *dcrutil.Block) *dcrjson.FeeInfoBlock {
+	msgTxns := block.MsgBlock().Transactions
+	if len(msgTxns) > 0 {
+		msgTxns = msgTxns[1:]
+	}
+	return feeRateInfo(block.Height(), msgTxns)
+}
+
+// feeRateInfo computes fee rate statistics in DCR/kB for the transactions of
+// the block at the given height.
+func feeRateInfo(height int64, msgTxns []*wire.MsgTx) *dcrjson.FeeInfoBlock {
+	feeInfo := new(dcrjson.FeeInfoBlock)
+
+	feeInfo.Height = uint32(height)
+	feeInfo.Number = uint32(len(msgTxns))
 
 	var minFee, maxFee, meanFee float64
 	minFee = math.MaxFloat64
 	feesRates := make([]float64, feeInfo.Number)
-	for it, msgTx := range sstxMsgTxns {
+	for it, msgTx := range msgTxns {
 		var amtIn, amtOut int64
 		for iv := range msgTx.TxIn {
 			amtIn += msgTx.TxIn[iv].ValueIn
@@ -10,10 +10,13 @@ import (
 	"testing"
 
 	"github.com/dcrdata/dcrdata/semver"
+	"github.com/decred/dcrd/chaincfg"
 	"github.com/decred/dcrd/chaincfg/chainhash"
 	"github.com/decred/dcrd/dcrjson"
 	"github.com/decred/dcrd/dcrutil"
 	"github.com/decred/dcrd/rpcclient"
+	"github.com/decred/dcrd/txscript"
+	"github.com/decred/dcrd/wire"
 )
 
 type TxGetter struct {
@@ -29,6 +32,17 @@ func (t TxGetter) GetRawTransaction(txHash *chainhash.Hash) (*dcrutil.Tx, error)
 	return tx, err
 }
 
+// OutpointAddrGetter gives the addresses of indexed outputs, keyed by
+// "txid:index", or fails if err is set.
+type OutpointAddrGetter struct {
+	addrs map[string][]string
+	err   error
+}
+
+func (g OutpointAddrGetter) RetrieveOutpointAddresses(txHash string, index uint32) ([]string, int64, error) {
+	return g.addrs[fmt.Sprintf("%s:%d", txHash, index)], 0, g.err
+}
+
 func LoadTestBlockAndSSTX(t *testing.T) (*dcrutil.Block, []*dcrutil.Tx) {
 	// Load block data
 	blockTestFileName := "block138883.bin"
@@ -107,6 +121,23 @@ func TestFeeRateInfoBlock(t *testing.T) {
 	}
 }
 
+func TestFeeRateInfoBlockRegular(t *testing.T) {
+	block, _ := LoadTestBlockAndSSTX(t)
+
+	fib := FeeRateInfoBlockRegular(block)
+	t.Log(*fib)
+
+	numRegular := len(block.MsgBlock().Transactions) - 1
+	if fib.Height != 138883 || fib.Number != uint32(numRegular) {
+		t.Fatalf("Expected %d regular transactions at height 138883, got %d at %d.",
+			numRegular, fib.Number, fib.Height)
+	}
+	if fib.Number > 0 && (fib.Min > fib.Median || fib.Median > fib.Max ||
+		fib.Min > fib.Mean || fib.Mean > fib.Max) {
+		t.Errorf("Inconsistent fee rate statistics: %v", *fib)
+	}
+}
+
 func TestFeeInfoBlock(t *testing.T) {
 	block, _ := LoadTestBlockAndSSTX(t)
 
@@ -128,6 +159,271 @@ func TestFeeInfoBlock(t *testing.T) {
 	}
 }
 
+func TestAddressTxs(t *testing.T) {
+	block, _ := LoadTestBlockAndSSTX(t)
+	params := &chaincfg.MainNetParams
+
+	// The first output of the first non-coinbase transaction pays to the
+	// watched address.
+	txs := block.Transactions()
+	if len(txs) < 2 {
+		t.Fatalf("Expected regular transactions in block %d", block.Height())
+	}
+	recvTx := txs[1]
+	txOut := recvTx.MsgTx().TxOut[0]
+	_, txAddrs, _, err := txscript.ExtractPkScriptAddrs(txOut.Version,
+		txOut.PkScript, params)
+	if err != nil || len(txAddrs) == 0 {
+		t.Fatalf("Unable to get the address of the output: %v", err)
+	}
+	addr := txAddrs[0].EncodeAddress()
+	addrs := map[string]TxAction{addr: TxMined | TxInserted}
+
+	found := TxReceivesToAddresses(recvTx, addrs, params)
+	if len(found) != 1 || found[0] != addr {
+		t.Errorf("Expected transaction to pay to %s, got %v", addr, found)
+	}
+	recvTxs := BlockReceivesToAddresses(block, addrs, params)
+	if TxhashInSlice(recvTxs[addr], recvTx.Hash()) == nil {
+		t.Errorf("Expected block to pay to %s in %v", addr, recvTx.Hash())
+	}
+
+	// A transaction spending that output, and a stakebase input that spends
+	// nothing.
+	msgTx := wire.NewMsgTx()
+	msgTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex, wire.TxTreeRegular),
+	})
+	msgTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(recvTx.Hash(), 0, wire.TxTreeRegular),
+	})
+	spendTx := dcrutil.NewTx(msgTx)
+
+	// The spent output is found in the address table.
+	indexed := OutpointAddrGetter{addrs: map[string][]string{
+		recvTx.Hash().String() + ":0": {addr},
+	}}
+	unindexed := OutpointAddrGetter{}
+	found, err = TxConsumesOutpointWithAddresses(spendTx, addrs, indexed)
+	if err != nil {
+		t.Fatalf("TxConsumesOutpointWithAddresses failed: %v", err)
+	}
+	if len(found) != 1 || found[0] != addr {
+		t.Errorf("Expected transaction to spend from %s, got %v", addr, found)
+	}
+	found, err = TxConsumesOutpointWithAddresses(spendTx, addrs, unindexed)
+	if err != nil || len(found) != 0 {
+		t.Errorf("Expected no spend of an unindexed output, got %v (%v)", found, err)
+	}
+	_, err = TxConsumesOutpointWithAddresses(spendTx, addrs,
+		OutpointAddrGetter{err: fmt.Errorf("no database")})
+	if err == nil {
+		t.Errorf("Expected an error from the address table to be returned")
+	}
+	found = TxReceivesToAddresses(spendTx, addrs, params)
+	if len(found) != 0 {
+		t.Errorf("Expected transaction not to pay to %s, got %v", addr, found)
+	}
+
+	// In a block, the spent output is found in the address table, or among
+	// the outputs of the block itself.
+	spendBlock := dcrutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{msgTx},
+	})
+	bothBlock := dcrutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{recvTx.MsgTx(), msgTx},
+	})
+	tests := []struct {
+		name   string
+		block  *dcrutil.Block
+		getter OutpointAddrGetter
+		spends bool
+	}{
+		{"indexed output", spendBlock, indexed, true},
+		{"unindexed output", spendBlock, unindexed, false},
+		{"output in block", bothBlock, unindexed, true},
+	}
+	for _, tt := range tests {
+		spendTxs, err := BlockConsumesOutpointWithAddresses(tt.block, addrs,
+			tt.getter, params)
+		if err != nil {
+			t.Fatalf("%s: BlockConsumesOutpointWithAddresses failed: %v", tt.name, err)
+		}
+		if spends := TxhashInSlice(spendTxs[addr], spendTx.Hash()) != nil; spends != tt.spends {
+			t.Errorf("%s: spend from %s found %v, expected %v", tt.name, addr,
+				spends, tt.spends)
+		}
+	}
+
+	_, err = BlockConsumesOutpointWithAddresses(spendBlock, addrs,
+		OutpointAddrGetter{err: fmt.Errorf("no database")}, params)
+	if err == nil {
+		t.Errorf("Expected an error from the address table to be returned")
+	}
+}
+
+func TestCalcNextStakeDiff(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	target := int64(params.TicketsPerBlock) *
+		int64(params.TicketPoolSize+params.TicketMaturity)
+	curDiff := int64(100 * dcrutil.AtomsPerCoin)
+
+	// A pool at the target size keeps the price unchanged.
+	next := CalcNextStakeDiff(params, 144000, curDiff, target, target)
+	if next != curDiff {
+		t.Errorf("Expected unchanged stake diff %d, got %d", curDiff, next)
+	}
+
+	// The price never goes below the minimum.
+	next = CalcNextStakeDiff(params, 144000, curDiff, target, 1)
+	if next != params.MinimumStakeDiff {
+		t.Errorf("Expected minimum stake diff %d, got %d",
+			params.MinimumStakeDiff, next)
+	}
+
+	// The price is limited by the coin supply.
+	next = CalcNextStakeDiff(params, 144000, curDiff, 1, target)
+	maxDiff := EstimateSupply(params, 144000) / int64(params.TicketPoolSize)
+	if next != maxDiff {
+		t.Errorf("Expected maximum stake diff %d, got %d", maxDiff, next)
+	}
+
+	// Retargets with mainnet parameters, as computed by dcrd's algorithm.
+	tests := []struct {
+		nextHeight, curDiff, prevPoolSizeAll, curPoolSizeAll int64
+		expected                                             int64
+	}{
+		{160704, 9500000000, 42000, 42500, 9672266752},
+		{160704, 9500000000, 42500, 41000, 8895666221},
+		{4320, 10000000000, 20000, 42240, 21120000000},
+		{4320, 10000000000, 19000, 42240, 22152524112}, // supply limit
+		{4320, 200000000, 42240, 30000, 200000000},     // minimum
+	}
+	for _, tt := range tests {
+		next = CalcNextStakeDiff(params, tt.nextHeight, tt.curDiff,
+			tt.prevPoolSizeAll, tt.curPoolSizeAll)
+		if next != tt.expected {
+			t.Errorf("CalcNextStakeDiff(%d, %d, %d, %d): expected %d, got %d",
+				tt.nextHeight, tt.curDiff, tt.prevPoolSizeAll, tt.curPoolSizeAll,
+				tt.expected, next)
+		}
+	}
+}
+
+func TestEstimateNextStakeDiff(t *testing.T) {
+	params := &chaincfg.MainNetParams
+
+	// Before any tickets can be bought, the estimate is the minimum.
+	history := StakeDiffHistory{{Height: 0, SBits: params.MinimumStakeDiff}}
+	sdiff, err := EstimateNextStakeDiff(params, history, 0, true)
+	if err != nil {
+		t.Fatalf("EstimateNextStakeDiff failed: %v", err)
+	}
+	if sdiff != params.MinimumStakeDiff {
+		t.Errorf("Expected minimum stake diff %d, got %d",
+			params.MinimumStakeDiff, sdiff)
+	}
+
+	maxRemaining := MaxRemainingTickets(params, 0)
+	if _, err = EstimateNextStakeDiff(params, history, maxRemaining+1, false); err == nil {
+		t.Errorf("Expected error estimating with more than %d tickets",
+			maxRemaining)
+	}
+
+	// A history that does not reach back far enough is an error.
+	height := 10 * params.StakeDiffWindowSize
+	history = StakeDiffHistory{{Height: height, SBits: params.MinimumStakeDiff,
+		PoolSize: 1000}}
+	if _, err = EstimateNextStakeDiff(params, history, 0, false); err == nil {
+		t.Errorf("Expected error estimating with incomplete history")
+	}
+
+	// With more tickets bought, the estimate is no lower.
+	start := StakeDiffHistoryStart(params, height)
+	history = history[:0]
+	for h := start; h <= height; h++ {
+		history = append(history, StakeDiffBlock{
+			Height:     h,
+			SBits:      int64(50 * dcrutil.AtomsPerCoin),
+			PoolSize:   40000,
+			FreshStake: 5,
+		})
+	}
+	min, err := EstimateNextStakeDiff(params, history, 0, false)
+	if err != nil {
+		t.Fatalf("EstimateNextStakeDiff failed: %v", err)
+	}
+	max, err := EstimateNextStakeDiff(params, history, 0, true)
+	if err != nil {
+		t.Fatalf("EstimateNextStakeDiff failed: %v", err)
+	}
+	if min > max {
+		t.Errorf("Minimum estimate %d above maximum estimate %d", min, max)
+	}
+
+	// Histories with mainnet parameters, as estimated by dcrd's algorithm. The
+	// first is mid-window with a full pool, and the second is in the window
+	// in which voting starts.
+	histories := []struct {
+		best, sbits       int64
+		poolSize          func(h int64) uint32
+		freshStake        func(h int64) uint8
+		newTickets        int64
+		min, max, tickets int64
+	}{
+		{160650, 9500000000,
+			func(h int64) uint32 { return 41000 },
+			func(h int64) uint8 { return uint8(h % 21) },
+			500, 9687888502, 10167773752, 9912804128},
+		{4090, 300000000,
+			func(h int64) uint32 {
+				if h < 512 {
+					return 0
+				}
+				return 30000
+			},
+			func(h int64) uint8 {
+				if h <= 256 {
+					return 0
+				}
+				return 20
+			},
+			1000, 243782356, 268239493, 258027347},
+	}
+	for _, hh := range histories {
+		history = history[:0]
+		for h := StakeDiffHistoryStart(params, hh.best); h <= hh.best; h++ {
+			history = append(history, StakeDiffBlock{
+				Height:     h,
+				SBits:      hh.sbits,
+				PoolSize:   hh.poolSize(h),
+				FreshStake: hh.freshStake(h),
+			})
+		}
+		for _, est := range []struct {
+			newTickets    int64
+			useMaxTickets bool
+			expected      int64
+		}{
+			{0, false, hh.min},
+			{0, true, hh.max},
+			{hh.newTickets, false, hh.tickets},
+		} {
+			sdiff, err = EstimateNextStakeDiff(params, history, est.newTickets,
+				est.useMaxTickets)
+			if err != nil {
+				t.Fatalf("EstimateNextStakeDiff failed: %v", err)
+			}
+			if sdiff != est.expected {
+				t.Errorf("EstimateNextStakeDiff at %d with %d tickets (max %v): "+
+					"expected %d, got %d", hh.best, est.newTickets,
+					est.useMaxTickets, est.expected, sdiff)
+			}
+		}
+	}
+}
+
 // Utilities for creating test data:
 
 func TxToWriter(tx *dcrutil.Tx, w io.Writer) error {
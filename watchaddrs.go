@@ -0,0 +1,280 @@
+// Copyright (c) 2017, Jonathan Chappelow
+// See LICENSE for details.
+
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/dcrdata/dcrdata/dbtypes"
+	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/dcrdata/dcrdata/txhelpers"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/dcrd/wire"
+)
+
+// Actions of a transaction on a watched address, as given in WebAddressTx.
+const (
+	addrTxReceive = "receive"
+	addrTxSpend   = "spend"
+)
+
+// WatchedAddresses returns the addresses websocket clients are subscribed to.
+// The transactions paying to or spending from them are watched both in mempool
+// and in new blocks.
+func (td *WebUI) WatchedAddresses() map[string]txhelpers.TxAction {
+	prefix := apitypes.WSTopicAddress + ":"
+	topics := td.wsHub.SubscribedTopics(apitypes.WSTopicAddress)
+	addrs := make(map[string]txhelpers.TxAction, len(topics))
+	for topic := range topics {
+		addrs[strings.TrimPrefix(topic, prefix)] = txhelpers.TxInserted | txhelpers.TxMined
+	}
+	return addrs
+}
+
+// txFilterLoader is an interface satisfied by rpcclient.Client, and required
+// by addressWatcher to load the node's transaction filter.
+type txFilterLoader interface {
+	LoadTxFilter(reload bool, addresses []dcrutil.Address, outPoints []wire.OutPoint) error
+}
+
+// addressIndex is an interface satisfied by the databases with an address
+// table, such as the wiredDB, which give the unspent outputs paying to an
+// address and the addresses paid to by an output.
+type addressIndex interface {
+	txhelpers.OutpointAddressGetter
+	RetrieveAddressUTXOs(address string) ([]*dbtypes.AddressOutpoint, error)
+}
+
+// watchedAddress is an address in the node's transaction filter, and the
+// number of websocket subscriptions to it.
+type watchedAddress struct {
+	address dcrutil.Address
+	refs    int
+}
+
+// addressWatcher keeps the node's transaction filter loaded with the addresses
+// websocket clients are subscribed to, and the outpoints of their unspent
+// outputs in the address table, so that the transactions paying to them, and
+// those spending from them, are received by OnRelevantTxAccepted as they enter
+// mempool. The node adds the outputs paying to the addresses in later
+// transactions to the filter itself. The subscriptions to each address are
+// counted, and the filter is reloaded without an address once none remain.
+type addressWatcher struct {
+	sync.Mutex
+	client txFilterLoader
+	index  addressIndex
+	addrs  map[string]*watchedAddress
+}
+
+// newAddressWatcher creates an addressWatcher for the node's filter, which
+// gets the unspent outputs of the addresses from the address index.
+func newAddressWatcher(client txFilterLoader, index addressIndex) *addressWatcher {
+	return &addressWatcher{
+		client: client,
+		index:  index,
+		addrs:  make(map[string]*watchedAddress),
+	}
+}
+
+// watch adds a subscription to the address, adding it and its unspent outputs
+// to the node's filter if it is not already watched.
+func (w *addressWatcher) watch(addr string) error {
+	w.Lock()
+	defer w.Unlock()
+	if wa, ok := w.addrs[addr]; ok {
+		wa.refs++
+		return nil
+	}
+
+	address, err := dcrutil.DecodeAddress(addr)
+	if err != nil {
+		return err
+	}
+	outPoints, err := w.unspentOutPoints(addr)
+	if err != nil {
+		return err
+	}
+	err = w.client.LoadTxFilter(false, []dcrutil.Address{address}, outPoints)
+	if err != nil {
+		return err
+	}
+	w.addrs[addr] = &watchedAddress{address: address, refs: 1}
+	return nil
+}
+
+// unwatch removes a subscription to each of the addresses. If any address is
+// left without subscriptions, the node's filter is reloaded with the rest.
+func (w *addressWatcher) unwatch(addrs []string) error {
+	w.Lock()
+	defer w.Unlock()
+	var removed bool
+	for _, addr := range addrs {
+		wa, ok := w.addrs[addr]
+		if !ok {
+			continue
+		}
+		wa.refs--
+		if wa.refs <= 0 {
+			delete(w.addrs, addr)
+			removed = true
+		}
+	}
+	if !removed {
+		return nil
+	}
+	return w.loadFilter()
+}
+
+// reload loads the node's filter with the watched addresses and their unspent
+// outputs, replacing it. The filter must be reloaded after reconnecting to the
+// node, which loses it.
+func (w *addressWatcher) reload() error {
+	w.Lock()
+	defer w.Unlock()
+	return w.loadFilter()
+}
+
+// loadFilter replaces the node's filter with the watched addresses and their
+// unspent outputs. The caller must hold the lock.
+func (w *addressWatcher) loadFilter() error {
+	addrs := make([]dcrutil.Address, 0, len(w.addrs))
+	var outPoints []wire.OutPoint
+	for addr, wa := range w.addrs {
+		addrs = append(addrs, wa.address)
+		addrOutPoints, err := w.unspentOutPoints(addr)
+		if err != nil {
+			return err
+		}
+		outPoints = append(outPoints, addrOutPoints...)
+	}
+	return w.client.LoadTxFilter(true, addrs, outPoints)
+}
+
+// unspentOutPoints returns the outpoints of the unspent outputs paying to the
+// address in the address table.
+func (w *addressWatcher) unspentOutPoints(addr string) ([]wire.OutPoint, error) {
+	utxos, err := w.index.RetrieveAddressUTXOs(addr)
+	if err != nil {
+		return nil, err
+	}
+	outPoints := make([]wire.OutPoint, 0, len(utxos))
+	for _, utxo := range utxos {
+		hash, err := chainhash.NewHashFromStr(utxo.TxHash)
+		if err != nil {
+			return nil, err
+		}
+		outPoints = append(outPoints, *wire.NewOutPoint(hash, utxo.TxIndex,
+			utxo.TxTree))
+	}
+	return outPoints, nil
+}
+
+// addressTopics returns the addresses of the address topics.
+func addressTopics(topics []string) []string {
+	prefix := apitypes.WSTopicAddress + ":"
+	var addrs []string
+	for _, topic := range topics {
+		if strings.HasPrefix(topic, prefix) {
+			addrs = append(addrs, strings.TrimPrefix(topic, prefix))
+		}
+	}
+	return addrs
+}
+
+// AddressTxHandler publishes the transactions paying to or spending from the
+// watched addresses to the websocket clients subscribed to them, as they enter
+// mempool, received on relevantTxMempoolChan, and as they are mined, received
+// on recvTxBlockChan and spendTxBlockChan. The node's transaction filter is
+// reloaded on each signal on reconnectChan. This function should be launched
+// as a goroutine, and stopped by closing the quit channel.
+func (td *WebUI) AddressTxHandler(relevantTxMempoolChan chan *dcrutil.Tx,
+	recvTxBlockChan, spendTxBlockChan chan *txhelpers.BlockWatchedTx,
+	reconnectChan chan struct{}, quit chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for {
+		select {
+		case tx, ok := <-relevantTxMempoolChan:
+			if !ok {
+				log.Infof("Relevant mempool transaction channel closed")
+				return
+			}
+
+			// The node's filter may still hold addresses that are no longer
+			// subscribed to.
+			addrs := td.WatchedAddresses()
+			if len(addrs) == 0 {
+				continue
+			}
+			for _, addr := range txhelpers.TxReceivesToAddresses(tx, addrs, td.params) {
+				td.publishAddressTx(sigTxMempool, addr, tx.Hash().String(),
+					addrTxReceive, nil)
+			}
+			// The spent outputs are looked up in the address table.
+			spendAddrs, err := txhelpers.TxConsumesOutpointWithAddresses(tx,
+				addrs, td.addrIndex)
+			if err != nil {
+				log.Errorf("Failed to get the addresses spent from by %v: %v",
+					tx.Hash(), err)
+			}
+			for _, addr := range spendAddrs {
+				td.publishAddressTx(sigTxMempool, addr, tx.Hash().String(),
+					addrTxSpend, nil)
+			}
+
+		case blockTxs, ok := <-recvTxBlockChan:
+			if !ok {
+				log.Infof("Received transactions block channel closed")
+				return
+			}
+			td.publishBlockAddressTxs(blockTxs, addrTxReceive)
+
+		case blockTxs, ok := <-spendTxBlockChan:
+			if !ok {
+				log.Infof("Spending transactions block channel closed")
+				return
+			}
+			td.publishBlockAddressTxs(blockTxs, addrTxSpend)
+
+		case <-reconnectChan:
+			if err := td.addrWatcher.reload(); err != nil {
+				log.Errorf("Failed to reload the watched addresses: %v", err)
+			}
+
+		case <-quit:
+			log.Debugf("Quitting watched address transaction handler.")
+			return
+		}
+	}
+}
+
+// publishBlockAddressTxs publishes the transactions in a new block that pay to
+// or spend from the watched addresses, as given by action.
+func (td *WebUI) publishBlockAddressTxs(blockTxs *txhelpers.BlockWatchedTx, action string) {
+	for addr, txs := range blockTxs.TxsForAddress {
+		for _, tx := range txs {
+			td.publishAddressTx(sigTxMined, addr, tx.Hash().String(), action,
+				blockTxs)
+		}
+	}
+}
+
+// publishAddressTx publishes a transaction on the address topic. The block is
+// nil for a transaction entering mempool.
+func (td *WebUI) publishAddressTx(sig hubSignal, addr, txid, action string,
+	block *txhelpers.BlockWatchedTx) {
+	log.Debugf("Transaction %s (%s) on watched address %s.", txid,
+		eventIDs[sig], addr)
+	addrTx := WebAddressTx{
+		Address: addr,
+		TxID:    txid,
+		Action:  action,
+	}
+	if block != nil {
+		addrTx.BlockHash = block.BlockHash.String()
+		addrTx.BlockHeight = block.BlockHeight
+	}
+	td.wsHub.Publish(sig, apitypes.WSTopicAddress+":"+addr, addrTx)
+}
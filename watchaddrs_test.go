@@ -0,0 +1,117 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dcrdata/dcrdata/dbtypes"
+	"github.com/dcrdata/dcrdata/txhelpers"
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/dcrd/wire"
+)
+
+// loadedFilter is a transaction filter loaded into a filterLoader.
+type loadedFilter struct {
+	reload    bool
+	addrs     []string
+	outPoints []wire.OutPoint
+}
+
+// filterLoader records the transaction filters loaded into it.
+type filterLoader struct {
+	loads []loadedFilter
+}
+
+func (l *filterLoader) LoadTxFilter(reload bool, addresses []dcrutil.Address, outPoints []wire.OutPoint) error {
+	f := loadedFilter{reload: reload, outPoints: outPoints}
+	for _, addr := range addresses {
+		f.addrs = append(f.addrs, addr.EncodeAddress())
+	}
+	l.loads = append(l.loads, f)
+	return nil
+}
+
+// utxoIndex is an address table holding the unspent outputs of each address.
+type utxoIndex map[string][]*dbtypes.AddressOutpoint
+
+func (idx utxoIndex) RetrieveAddressUTXOs(address string) ([]*dbtypes.AddressOutpoint, error) {
+	return idx[address], nil
+}
+
+func (idx utxoIndex) RetrieveOutpointAddresses(txHash string, index uint32) ([]string, int64, error) {
+	for addr, utxos := range idx {
+		for _, utxo := range utxos {
+			if utxo.TxHash == txHash && utxo.TxIndex == index {
+				return []string{addr}, utxo.Value, nil
+			}
+		}
+	}
+	return nil, 0, nil
+}
+
+func TestWatchAddressSpend(t *testing.T) {
+	address, err := dcrutil.NewAddressScriptHash([]byte{0x51}, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Unable to create address: %v", err)
+	}
+	addr := address.EncodeAddress()
+
+	// An output paying to the address before it is watched.
+	fundingHash := chainhash.HashH([]byte("funding"))
+	utxo := wire.NewOutPoint(&fundingHash, 1, wire.TxTreeRegular)
+	index := utxoIndex{addr: {{
+		Address: addr,
+		TxHash:  fundingHash.String(),
+		TxIndex: utxo.Index,
+		TxTree:  utxo.Tree,
+		Value:   1e8,
+	}}}
+	loader := new(filterLoader)
+	w := newAddressWatcher(loader, index)
+
+	// The unspent output is added to the filter with the address, once.
+	for i := 0; i < 2; i++ {
+		if err = w.watch(addr); err != nil {
+			t.Fatalf("watch failed: %v", err)
+		}
+	}
+	want := []loadedFilter{{false, []string{addr}, []wire.OutPoint{*utxo}}}
+	if !reflect.DeepEqual(loader.loads, want) {
+		t.Fatalf("Expected filter loads %v, got %v", want, loader.loads)
+	}
+
+	// So is it when the filter is reloaded after reconnecting.
+	if err = w.reload(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	want = append(want, loadedFilter{true, []string{addr}, []wire.OutPoint{*utxo}})
+	if !reflect.DeepEqual(loader.loads, want) {
+		t.Fatalf("Expected filter loads %v, got %v", want, loader.loads)
+	}
+
+	// A mempool transaction spending the output spends from the address.
+	msgTx := wire.NewMsgTx()
+	msgTx.AddTxIn(&wire.TxIn{PreviousOutPoint: *utxo})
+	addrs := map[string]txhelpers.TxAction{addr: txhelpers.TxInserted}
+	found, err := txhelpers.TxConsumesOutpointWithAddresses(dcrutil.NewTx(msgTx),
+		addrs, index)
+	if err != nil {
+		t.Fatalf("TxConsumesOutpointWithAddresses failed: %v", err)
+	}
+	if len(found) != 1 || found[0] != addr {
+		t.Errorf("Expected transaction to spend from %s, got %v", addr, found)
+	}
+
+	// The address stays in the filter until its last subscription is removed.
+	for i := 0; i < 2; i++ {
+		if err = w.unwatch([]string{addr}); err != nil {
+			t.Fatalf("unwatch failed: %v", err)
+		}
+	}
+	want = append(want, loadedFilter{reload: true})
+	if !reflect.DeepEqual(loader.loads, want) {
+		t.Errorf("Expected filter loads %v, got %v", want, loader.loads)
+	}
+}
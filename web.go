@@ -25,6 +25,7 @@ import (
 	"github.com/dcrdata/dcrdata/mempool"
 	"github.com/decred/dcrd/chaincfg"
 	"github.com/decred/dcrd/dcrjson"
+	"github.com/decred/dcrd/rpcclient"
 	humanize "github.com/dustin/go-humanize"
 	"github.com/go-chi/chi"
 )
@@ -47,10 +48,12 @@ func TemplateExecToString(t *template.Template, name string, data interface{}) (
 
 // WebTemplateData holds all of the data structures used to update the web page.
 type WebTemplateData struct {
-	BlockSummary   apitypes.BlockExplorerBasic
-	StakeSummary   apitypes.StakeInfoExtendedEstimates
-	MempoolFeeInfo apitypes.MempoolTicketFeeInfo
-	MempoolFees    apitypes.MempoolTicketFees
+	BlockSummary     apitypes.BlockExplorerBasic
+	StakeSummary     apitypes.StakeInfoExtendedEstimates
+	MempoolFeeInfo   apitypes.MempoolTicketFeeInfo
+	MempoolFees      apitypes.MempoolTicketFees
+	MempoolVotes     apitypes.MempoolTipVotes
+	MempoolConflicts apitypes.MempoolConflicts
 }
 
 // WebUI models data for the web page and websocket
@@ -65,11 +68,15 @@ type WebUI struct {
 	params          *chaincfg.Params
 	ExplorerSource  APIDataSource
 	tmpHelpers      template.FuncMap
+	addrIndex       addressIndex
+	addrWatcher     *addressWatcher
 }
 
 // NewWebUI constructs a new WebUI by loading and parsing the html templates
-// then launching the WebSocket event handler
-func NewWebUI(expSource APIDataSource) *WebUI {
+// then launching the WebSocket event handler. The dcrd client and the address
+// index are used to watch the addresses websocket clients subscribe to.
+func NewWebUI(expSource APIDataSource, addrIndex addressIndex,
+	dcrdClient *rpcclient.Client) *WebUI {
 	fp := filepath.Join("views", "root.tmpl")
 	efp := filepath.Join("views", "extras.tmpl")
 	errorfp := filepath.Join("views", "error.tmpl")
@@ -174,6 +181,8 @@ func NewWebUI(expSource APIDataSource) *WebUI {
 		params:         activeChain,
 		ExplorerSource: expSource,
 		tmpHelpers:     helpers,
+		addrIndex:      addrIndex,
+		addrWatcher:    newAddressWatcher(dcrdClient, addrIndex),
 	}
 }
 
@@ -214,19 +223,60 @@ func (td *WebUI) reloadTemplatesSig(sig os.Signal) {
 }
 
 // Store extracts the block and stake data from the input BlockData and stores
-// it in the HTML template data. Store also signals the WebsocketHub of the
-// updated data.
+// it in the HTML template data. Store also publishes the new block, the ticket
+// pool, and the subscribed transactions mined in the block to the
+// WebsocketHub.
 func (td *WebUI) Store(blockData *blockdata.BlockData) error {
+	blockSummary := blockData.ToBlockExplorerSummary()
+	stakeSummary := blockData.ToStakeInfoExtendedEstimates()
+
 	td.templateDataMtx.Lock()
-	td.TemplateData.BlockSummary = blockData.ToBlockExplorerSummary()
-	td.TemplateData.StakeSummary = blockData.ToStakeInfoExtendedEstimates()
+	td.TemplateData.BlockSummary = blockSummary
+	td.TemplateData.StakeSummary = stakeSummary
 	td.templateDataMtx.Unlock()
 
-	td.wsHub.HubRelay <- sigNewBlock
+	td.wsHub.Publish(sigNewBlock, apitypes.WSTopicBlocks, WebBlockInfo{
+		BlockDataBasic: &blockSummary,
+		StakeInfoExt:   &stakeSummary,
+	})
+	td.wsHub.Publish(sigTicketPool, apitypes.WSTopicTicketPool, WebTicketPoolInfo{
+		Height:    blockSummary.Height,
+		PoolInfo:  stakeSummary.PoolInfo,
+		StakeDiff: stakeSummary.StakeDiff,
+	})
+	td.publishMinedTxs(int(blockSummary.Height))
 
 	return nil
 }
 
+// publishMinedTxs publishes the transactions in the block at the given height
+// that websocket clients are subscribed to. The block is only fetched if there
+// are any such subscriptions.
+func (td *WebUI) publishMinedTxs(height int) {
+	topics := td.wsHub.SubscribedTopics(apitypes.WSTopicTx)
+	if len(topics) == 0 {
+		return
+	}
+	block := td.ExplorerSource.GetBlockVerbose(height, false)
+	if block == nil {
+		log.Errorf("Unable to get block %d for subscribed transactions.", height)
+		return
+	}
+	for _, txids := range [][]string{block.Tx, block.STx} {
+		for _, txid := range txids {
+			topic := apitypes.WSTopicTx + ":" + txid
+			if _, ok := topics[topic]; !ok {
+				continue
+			}
+			td.wsHub.Publish(sigTxMined, topic, WebTxMined{
+				TxID:        txid,
+				BlockHash:   block.Hash,
+				BlockHeight: block.Height,
+			})
+		}
+	}
+}
+
 // StoreMPData stores mempool data in the mempool cache and update the webui via websocket
 func (td *WebUI) StoreMPData(data *mempool.MempoolData, timestamp time.Time) error {
 	td.MPC.StoreMPData(data, timestamp)
@@ -249,13 +299,48 @@ func (td *WebUI) StoreMPData(data *mempool.MempoolData, timestamp time.Time) err
 	mpf := &td.TemplateData.MempoolFees
 	mpf.Height, mpf.Time, _, mpf.FeeRates = td.MPC.GetFeeRates(25)
 	mpf.Length = uint32(len(mpf.FeeRates))
+	feeInfo := td.TemplateData.MempoolFeeInfo
+	td.templateDataMtx.Unlock()
+
+	td.wsHub.Publish(sigMempoolFeeInfoUpdate, apitypes.WSTopicMempool, feeInfo)
+
+	return nil
+}
+
+// StoreMPVotes stores the votes in mempool on the best block, and updates the
+// webui via websocket
+func (td *WebUI) StoreMPVotes(votes *apitypes.MempoolTipVotes) error {
+	td.templateDataMtx.Lock()
+	td.TemplateData.MempoolVotes = *votes
+	td.templateDataMtx.Unlock()
+
+	td.wsHub.Publish(sigMempoolVotes, apitypes.WSTopicMempool, votes)
+
+	return nil
+}
+
+// StoreMPConflicts stores the conflicting and invalidated mempool
+// transactions, and updates the webui via websocket
+func (td *WebUI) StoreMPConflicts(conflicts *apitypes.MempoolConflicts) error {
+	td.templateDataMtx.Lock()
+	td.TemplateData.MempoolConflicts = *conflicts
 	td.templateDataMtx.Unlock()
 
-	td.wsHub.HubRelay <- sigMempoolFeeInfoUpdate
+	td.wsHub.Publish(sigMempoolConflicts, apitypes.WSTopicMempool, conflicts)
 
 	return nil
 }
 
+// StoreMPTx publishes a transaction accepted into mempool to the websocket
+// clients subscribed to it.
+func (td *WebUI) StoreMPTx(tx *apitypes.MempoolTx) error {
+	topic := apitypes.WSTopicTx + ":" + tx.Hash
+	if td.wsHub.IsSubscribed(topic) {
+		td.wsHub.Publish(sigTxMempool, topic, tx)
+	}
+	return nil
+}
+
 // RootPage is the http.HandlerFunc for the "/" http path
 func (td *WebUI) RootPage(w http.ResponseWriter, r *http.Request) {
 	td.templateDataMtx.RLock()
@@ -314,87 +399,163 @@ func (td *WebUI) ErrorPage(w http.ResponseWriter, r *http.Request) {
 	io.WriteString(w, str)
 }
 
-// WSBlockUpdater handles requests on the websocket path (e.g. /ws). The wrapped
-// websocket.Handler registers the connection with the WebsocketHub, which
-// provides an update channel, and starts an update loop. The loop writes the
-// current block data when it receives a signal on the update channel. The run()
-// loop must be running to receive signals on the update channel. The update
-// loop quits in the following situations: when the quitWSHandler channel is
-// closed, when the update channel is closed, or when a write on the
-// websocket.Conn fails.
+// WSBlockUpdater handles requests on the websocket path of the original
+// protocol (/ws), for clients that do not subscribe to topics. The wrapped
+// websocket.Handler registers the connection with the WebsocketHub, subscribed
+// to the legacyTopics, and starts an update loop. The loop writes each event
+// received on the event channel in a WebSocketMessage, and a regular ping with
+// the number of clients. Anything the client sends is ignored. The update loop
+// quits in the same situations as that of WSSubscriptionUpdater.
 func (td *WebUI) WSBlockUpdater(w http.ResponseWriter, r *http.Request) {
 	wsHandler := websocket.Handler(func(ws *websocket.Conn) {
-		// Create channel to signal updated data availability
-		updateSig := make(hubSpoke)
-		// register websocket client with our signal channel
-		td.wsHub.RegisterClient(&updateSig)
-		// unregister (and close signal channel) before return
-		defer td.wsHub.UnregisterClient(&updateSig)
-
-		// Ticker for a regular ping
-		ticker := time.NewTicker(pingInterval)
-		defer ticker.Stop()
+		// Create the client, subscribed to the legacy topics
+		client := newHubSpoke()
+		for _, topic := range legacyTopics {
+			client.subscribe(topic)
+		}
+		// register websocket client with the hub
+		td.wsHub.RegisterClient(client)
+		// unregister (and close event channel) before return
+		defer td.wsHub.UnregisterClient(client)
 
+		// Discard anything the client sends until the connection is closed
+		closed := make(chan struct{})
 		go func() {
-			for range ticker.C {
-				td.wsHub.HubRelay <- sigPingAndUserCount
+			defer close(closed)
+			var discard []byte
+			for websocket.Message.Receive(ws, &discard) == nil {
 			}
 		}()
 
-	loop:
+		// Ticker for a regular ping
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+
 		for {
-			// Wait for signal from the hub to update
+			var msg WebSocketMessage
 			select {
-			case sig, ok := <-updateSig:
-				// Check if the update channel was closed. Either the websocket
-				// hub will do it after unregistering the client, or forcibly in
-				// response to (http.CloseNotifier).CloseNotify() and only then if
-				// the hub has somehow lost track of the client.
+			case ev, ok := <-client.events:
+				// Check if the event channel was closed. The websocket hub
+				// will do it after unregistering the client.
 				if !ok {
-					//ws.WriteClose(1)
-					td.wsHub.UnregisterClient(&updateSig)
-					break loop
+					return
 				}
+				log.Tracef("signaling client: %p", client)
+				msg.EventId = eventIDs[ev.sig]
+				msg.Messsage = string(ev.payload)
+			case <-closed:
+				return
+			case <-ticker.C:
+				// ping and send user count
+				msg.EventId = legacyPingEvent
+				msg.Messsage = strconv.Itoa(td.wsHub.NumClients())
+			case <-td.wsHub.quitWSHandler:
+				return
+			}
 
-				if _, ok = eventIDs[sig]; !ok {
-					break loop
-				}
+			ws.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := websocket.JSON.Send(ws, msg); err != nil {
+				log.Debugf("Failed to send websocket %s message: %v", msg.EventId, err)
+				// If the send failed, the client is probably gone, so close
+				// the connection and quit.
+				return
+			}
+		}
+	})
 
-				log.Tracef("signaling client: %p", &updateSig)
-				ws.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	wsHandler.ServeHTTP(w, r)
+}
 
-				// Write block data to websocket client
-				td.templateDataMtx.RLock()
-				webData := WebSocketMessage{
-					EventId: eventIDs[sig],
+// WSSubscriptionUpdater handles requests on the websocket path of the
+// subscription protocol (/ws/v1). The wrapped websocket.Handler registers the
+// connection with the WebsocketHub, which provides an event channel, and
+// starts an update loop. Clients subscribe to
+// and unsubscribe from topics with requests in an apitypes.WebSocketEnvelope,
+// which are read in a separate goroutine and answered by the update loop. The
+// loop writes each event on a subscribed topic received on the event channel,
+// and a regular ping with the number of clients. The run() loop must be running
+// to relay events. The update loop quits in the following situations: when the
+// quitWSHandler channel is closed, when the event channel is closed, when the
+// client closes the connection, or when a write on the websocket.Conn fails.
+func (td *WebUI) WSSubscriptionUpdater(w http.ResponseWriter, r *http.Request) {
+	wsHandler := websocket.Handler(func(ws *websocket.Conn) {
+		// Create the client with a channel for events on its topics
+		client := newHubSpoke()
+		// register websocket client with the hub
+		td.wsHub.RegisterClient(client)
+		// unregister (and close event channel) before return
+		defer td.wsHub.UnregisterClient(client)
+		// stop watching the client's addresses before return
+		defer func() {
+			addrs := addressTopics(client.subscriptions())
+			if err := td.addrWatcher.unwatch(addrs); err != nil {
+				log.Errorf("Failed to unwatch %v: %v", addrs, err)
+			}
+		}()
+
+		// Read the client's requests until the connection is closed
+		requests := make(chan *apitypes.WebSocketEnvelope)
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			defer close(requests)
+			for {
+				req := new(apitypes.WebSocketEnvelope)
+				if err := websocket.JSON.Receive(ws, req); err != nil {
+					if err != io.EOF {
+						log.Debugf("Failed to receive websocket request: %v", err)
+					}
+					return
 				}
-				buff := new(bytes.Buffer)
-				enc := json.NewEncoder(buff)
-				switch sig {
-				case sigNewBlock:
-					enc.Encode(WebBlockInfo{
-						BlockDataBasic: &td.TemplateData.BlockSummary,
-						StakeInfoExt:   &td.TemplateData.StakeSummary,
-					})
-					webData.Messsage = buff.String()
-				case sigMempoolFeeInfoUpdate:
-					enc.Encode(td.TemplateData.MempoolFeeInfo)
-					webData.Messsage = buff.String()
-				case sigPingAndUserCount:
-					// ping and send user count
-					webData.Messsage = strconv.Itoa(td.wsHub.NumClients())
+				select {
+				case requests <- req:
+				case <-done:
+					return
 				}
+			}
+		}()
 
-				err := websocket.JSON.Send(ws, webData)
-				td.templateDataMtx.RUnlock()
-				if err != nil {
-					log.Debugf("Failed to encode WebSocketMessage %v: %v", sig, err)
-					// If the send failed, the client is probably gone, so close
-					// the connection and quit.
+		// Ticker for a regular ping
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+
+		for {
+			msg := &apitypes.WebSocketEnvelope{
+				Version: apitypes.WebSocketVersion,
+			}
+			select {
+			case ev, ok := <-client.events:
+				// Check if the event channel was closed. The websocket hub
+				// will do it after unregistering the client.
+				if !ok {
 					return
 				}
+				log.Tracef("signaling client: %p", client)
+				msg.Type = apitypes.WSTypeEvent
+				msg.Topic = ev.topic
+				msg.Event = eventIDs[ev.sig]
+				msg.Payload = ev.payload
+			case req, ok := <-requests:
+				if !ok {
+					return
+				}
+				msg = td.handleWSRequest(client, req)
+			case <-ticker.C:
+				// ping and send user count
+				msg.Type = apitypes.WSTypePing
+				msg.Payload, _ = json.Marshal(apitypes.WebSocketPing{
+					Clients: td.wsHub.NumClients(),
+				})
 			case <-td.wsHub.quitWSHandler:
-				break loop
+				return
+			}
+
+			ws.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := websocket.JSON.Send(ws, msg); err != nil {
+				log.Debugf("Failed to send websocket %s message: %v", msg.Type, err)
+				// If the send failed, the client is probably gone, so close
+				// the connection and quit.
+				return
 			}
 		}
 	})
@@ -402,6 +563,70 @@ func (td *WebUI) WSBlockUpdater(w http.ResponseWriter, r *http.Request) {
 	wsHandler.ServeHTTP(w, r)
 }
 
+// handleWSRequest applies a subscribe, unsubscribe or ping request from the
+// websocket client, and returns the response. The response to a subscription
+// request lists the client's topics, or gives the reason the request failed.
+func (td *WebUI) handleWSRequest(client *hubSpoke, req *apitypes.WebSocketEnvelope) *apitypes.WebSocketEnvelope {
+	resp := &apitypes.WebSocketEnvelope{
+		Version: apitypes.WebSocketVersion,
+		Type:    apitypes.WSTypeResponse,
+		ID:      req.ID,
+		Topic:   req.Topic,
+	}
+	if req.Version != apitypes.WebSocketVersion {
+		resp.Error = fmt.Sprintf("unsupported version %d", req.Version)
+		return resp
+	}
+
+	var err error
+	switch req.Type {
+	case apitypes.WSTypeSubscribe, apitypes.WSTypeUnsubscribe:
+		var topic string
+		if topic, err = parseTopic(req.Topic); err != nil {
+			break
+		}
+		resp.Topic = topic
+		// The subscriptions to an address keep it in the node's transaction
+		// filter.
+		addrs := addressTopics([]string{topic})
+		if req.Type == apitypes.WSTypeSubscribe {
+			watch := len(addrs) > 0 && !client.isSubscribed(topic)
+			if err = client.subscribe(topic); err != nil {
+				break
+			}
+			if watch {
+				if err = td.addrWatcher.watch(addrs[0]); err != nil {
+					log.Errorf("Failed to watch %s: %v", addrs[0], err)
+					client.unsubscribe(topic)
+					err = fmt.Errorf("unable to watch address")
+					break
+				}
+			}
+		} else {
+			if err = client.unsubscribe(topic); err != nil {
+				break
+			}
+			if err = td.addrWatcher.unwatch(addrs); err != nil {
+				log.Errorf("Failed to unwatch %v: %v", addrs, err)
+				err = nil
+			}
+		}
+		if err != nil {
+			break
+		}
+		resp.Payload, err = json.Marshal(apitypes.WebSocketSubscriptions{
+			Topics: client.subscriptions(),
+		})
+	case apitypes.WSTypePing:
+	default:
+		err = fmt.Errorf("unknown request type %q", req.Type)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	return resp
+}
+
 // FileServer conveniently sets up a http.FileServer handler to serve
 // static files from a http.FileSystem.
 func FileServer(r chi.Router, path string, root http.FileSystem, CacheControlMaxAge int64) {
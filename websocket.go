@@ -4,13 +4,37 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 
 	apitypes "github.com/dcrdata/dcrdata/dcrdataapi"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil"
 )
 
-// WebSocketMessage represents the JSON object used to send and received typed
-// messages to the web client.
+// maxSubscriptions is the maximum number of topics a websocket client may
+// subscribe to.
+const maxSubscriptions = 100
+
+// maxAddressSubscriptions is the maximum number of addresses a websocket
+// client may subscribe to, each of which is added to the node's transaction
+// filter.
+const maxAddressSubscriptions = 20
+
+// legacyTopics are the topics of the clients of the original websocket
+// protocol at /ws, which receive these events without subscribing.
+var legacyTopics = []string{apitypes.WSTopicBlocks, apitypes.WSTopicMempool,
+	apitypes.WSTopicTicketPool}
+
+// legacyPingEvent is the event ID of the ping sent to legacy clients.
+const legacyPingEvent = "ping"
+
+// WebSocketMessage represents the JSON object used to send typed messages to
+// the clients of the original websocket protocol. Messsage is the JSON encoded
+// event data, or the number of clients for a ping.
 type WebSocketMessage struct {
 	EventId  string `json:"event"`
 	Messsage string `json:"message"`
@@ -20,7 +44,11 @@ type WebSocketMessage struct {
 var eventIDs = map[hubSignal]string{
 	sigNewBlock:             "newblock",
 	sigMempoolFeeInfoUpdate: "mempoolsstxfeeinfo",
-	sigPingAndUserCount:     "ping",
+	sigMempoolVotes:         "mempoolvotes",
+	sigMempoolConflicts:     "mempoolconflicts",
+	sigTicketPool:           "ticketpool",
+	sigTxMempool:            "txmempool",
+	sigTxMined:              "txmined",
 }
 
 // WebBlockInfo represents the JSON object used to send block data and stake
@@ -30,15 +58,44 @@ type WebBlockInfo struct {
 	StakeInfoExt   *apitypes.StakeInfoExtendedEstimates `json:"stake"`
 }
 
+// WebTicketPoolInfo represents the JSON object used to send the ticket pool
+// and ticket price after a new block to the web client
+type WebTicketPoolInfo struct {
+	Height    uint32                  `json:"height"`
+	PoolInfo  apitypes.TicketPoolInfo `json:"ticket_pool"`
+	StakeDiff apitypes.StakeDiff      `json:"stakediff"`
+}
+
+// WebTxMined represents the JSON object used to send the block that mined a
+// transaction to the web client
+type WebTxMined struct {
+	TxID        string `json:"txid"`
+	BlockHash   string `json:"block_hash"`
+	BlockHeight int64  `json:"block_height"`
+}
+
+// WebAddressTx represents the JSON object used to send a transaction paying to
+// (receive) or spending from (spend) a watched address to the web client. The
+// block is omitted for a transaction entering mempool.
+type WebAddressTx struct {
+	Address     string `json:"address"`
+	TxID        string `json:"txid"`
+	Action      string `json:"action"`
+	BlockHash   string `json:"block_hash,omitempty"`
+	BlockHeight int64  `json:"block_height,omitempty"`
+}
+
 // WebsocketHub and its event loop manage all websocket client connections.
 // WebsocketHub is responsible for closing all connections registered with it.
 // If the event loop is running, calling (*WebsocketHub).Stop() will handle it.
+// The embedded RWMutex protects the clients map, which is only modified by the
+// event loop.
 type WebsocketHub struct {
 	sync.RWMutex
 	clients         map[*hubSpoke]struct{}
 	Register        chan *hubSpoke
 	Unregister      chan *hubSpoke
-	HubRelay        chan hubSignal
+	HubRelay        chan *hubEvent
 	NewBlockInfo    chan WebBlockInfo
 	NewBlockSummary chan apitypes.BlockDataBasic
 	NewStakeSummary chan apitypes.StakeInfoExtendedEstimates
@@ -46,21 +103,141 @@ type WebsocketHub struct {
 }
 
 type hubSignal int
-type hubSpoke chan hubSignal
 
 const (
 	sigNewBlock hubSignal = iota
 	sigMempoolFeeInfoUpdate
-	sigPingAndUserCount
+	sigMempoolVotes
+	sigMempoolConflicts
+	sigTicketPool
+	sigTxMempool
+	sigTxMined
 )
 
+// hubEvent is an event on a topic, with its payload already encoded, that the
+// hub relays to the clients subscribed to the topic.
+type hubEvent struct {
+	sig     hubSignal
+	topic   string
+	payload json.RawMessage
+}
+
+// hubSpoke is a websocket client registered with the hub. Events on the topics
+// it subscribes to are sent on its events channel.
+type hubSpoke struct {
+	sync.RWMutex
+	topics map[string]struct{}
+	events chan *hubEvent
+}
+
+// newHubSpoke creates a hubSpoke without subscriptions.
+func newHubSpoke() *hubSpoke {
+	return &hubSpoke{
+		topics: make(map[string]struct{}),
+		events: make(chan *hubEvent, 16),
+	}
+}
+
+// subscribe adds the topic to the client's subscriptions, failing if the
+// client already has too many.
+func (c *hubSpoke) subscribe(topic string) error {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.topics[topic]; ok {
+		return nil
+	}
+	if len(c.topics) >= maxSubscriptions {
+		return fmt.Errorf("too many subscriptions (max %d)", maxSubscriptions)
+	}
+	if strings.HasPrefix(topic, apitypes.WSTopicAddress+":") {
+		var numAddrs int
+		for t := range c.topics {
+			if strings.HasPrefix(t, apitypes.WSTopicAddress+":") {
+				numAddrs++
+			}
+		}
+		if numAddrs >= maxAddressSubscriptions {
+			return fmt.Errorf("too many address subscriptions (max %d)",
+				maxAddressSubscriptions)
+		}
+	}
+	c.topics[topic] = struct{}{}
+	return nil
+}
+
+// unsubscribe removes the topic from the client's subscriptions.
+func (c *hubSpoke) unsubscribe(topic string) error {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.topics[topic]; !ok {
+		return fmt.Errorf("not subscribed to %s", topic)
+	}
+	delete(c.topics, topic)
+	return nil
+}
+
+// isSubscribed checks if the client is subscribed to the topic.
+func (c *hubSpoke) isSubscribed(topic string) bool {
+	c.RLock()
+	defer c.RUnlock()
+	_, ok := c.topics[topic]
+	return ok
+}
+
+// subscriptions lists the client's topics in order.
+func (c *hubSpoke) subscriptions() []string {
+	c.RLock()
+	defer c.RUnlock()
+	topics := make([]string, 0, len(c.topics))
+	for topic := range c.topics {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// parseTopic checks that the subscription topic is known, and that the address
+// or transaction hash of an address or tx topic is valid. The address must be
+// for the active network. The topic is returned in its canonical form.
+func parseTopic(topic string) (string, error) {
+	switch topic {
+	case apitypes.WSTopicBlocks, apitypes.WSTopicMempool, apitypes.WSTopicTicketPool:
+		return topic, nil
+	}
+
+	parts := strings.SplitN(topic, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", fmt.Errorf("unknown topic %q", topic)
+	}
+	switch parts[0] {
+	case apitypes.WSTopicAddress:
+		addr, err := dcrutil.DecodeAddress(parts[1])
+		if err != nil {
+			return "", fmt.Errorf("invalid address %q", parts[1])
+		}
+		if !addr.IsForNet(activeChain) {
+			return "", fmt.Errorf("address %q is not for %s", parts[1],
+				activeChain.Name)
+		}
+		return apitypes.WSTopicAddress + ":" + addr.EncodeAddress(), nil
+	case apitypes.WSTopicTx:
+		hash, err := chainhash.NewHashFromStr(parts[1])
+		if err != nil {
+			return "", fmt.Errorf("invalid transaction hash %q", parts[1])
+		}
+		return apitypes.WSTopicTx + ":" + hash.String(), nil
+	default:
+		return "", fmt.Errorf("unknown topic %q", topic)
+	}
+}
+
 // NewWebsocketHub creates a new WebsocketHub
 func NewWebsocketHub() *WebsocketHub {
 	return &WebsocketHub{
 		clients:       make(map[*hubSpoke]struct{}),
 		Register:      make(chan *hubSpoke),
 		Unregister:    make(chan *hubSpoke),
-		HubRelay:      make(chan hubSignal),
+		HubRelay:      make(chan *hubEvent),
 		NewBlockInfo:  make(chan WebBlockInfo),
 		quitWSHandler: make(chan struct{}),
 	}
@@ -68,9 +245,55 @@ func NewWebsocketHub() *WebsocketHub {
 
 // NumClients returns the number of clients connected to the websocket hub
 func (wsh *WebsocketHub) NumClients() int {
+	wsh.RLock()
+	defer wsh.RUnlock()
 	return len(wsh.clients)
 }
 
+// Publish encodes the payload of the event on the topic, and relays it via the
+// main run() loop to the clients subscribed to the topic.
+func (wsh *WebsocketHub) Publish(sig hubSignal, topic string, payload interface{}) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		log.Errorf("Failed to encode %s event: %v", eventIDs[sig], err)
+		return
+	}
+	wsh.HubRelay <- &hubEvent{
+		sig:     sig,
+		topic:   topic,
+		payload: b,
+	}
+}
+
+// IsSubscribed checks if any client is subscribed to the topic.
+func (wsh *WebsocketHub) IsSubscribed(topic string) bool {
+	wsh.RLock()
+	defer wsh.RUnlock()
+	for client := range wsh.clients {
+		if client.isSubscribed(topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// SubscribedTopics returns the topics of the given kind, such as
+// apitypes.WSTopicTx, that any client is subscribed to.
+func (wsh *WebsocketHub) SubscribedTopics(kind string) map[string]struct{} {
+	prefix := kind + ":"
+	topics := make(map[string]struct{})
+	wsh.RLock()
+	defer wsh.RUnlock()
+	for client := range wsh.clients {
+		for _, topic := range client.subscriptions() {
+			if strings.HasPrefix(topic, prefix) {
+				topics[topic] = struct{}{}
+			}
+		}
+	}
+	return topics
+}
+
 // RegisterClient registers a websocket connection with the hub.
 func (wsh *WebsocketHub) RegisterClient(c *hubSpoke) {
 	log.Debug("Registering new websocket client")
@@ -79,6 +302,8 @@ func (wsh *WebsocketHub) RegisterClient(c *hubSpoke) {
 
 // registerClient should only be called from the run loop
 func (wsh *WebsocketHub) registerClient(c *hubSpoke) {
+	wsh.Lock()
+	defer wsh.Unlock()
 	wsh.clients[c] = struct{}{}
 }
 
@@ -90,18 +315,20 @@ func (wsh *WebsocketHub) UnregisterClient(c *hubSpoke) {
 
 // unregisterClient should only be called from the loop in run().
 func (wsh *WebsocketHub) unregisterClient(c *hubSpoke) {
+	wsh.Lock()
+	defer wsh.Unlock()
 	if _, ok := wsh.clients[c]; !ok {
 		// unknown client, do not close channel
-		log.Warnf("unknown client")
+		log.Debugf("unknown client")
 		return
 	}
 	delete(wsh.clients, c)
 
 	// Close the channel, but make sure the client didn't do it
-	safeClose(*c)
+	safeClose(c.events)
 }
 
-func safeClose(cc hubSpoke) {
+func safeClose(cc chan *hubEvent) {
 	select {
 	case _, ok := <-cc:
 		if !ok {
@@ -118,7 +345,13 @@ func (wsh *WebsocketHub) Stop() {
 	// end the run() loop, allowing in progress operations to complete
 	wsh.quitWSHandler <- struct{}{}
 	// unregister all clients
+	wsh.RLock()
+	clients := make([]*hubSpoke, 0, len(wsh.clients))
 	for client := range wsh.clients {
+		clients = append(clients, client)
+	}
+	wsh.RUnlock()
+	for _, client := range clients {
 		wsh.unregisterClient(client)
 	}
 }
@@ -128,26 +361,37 @@ func (wsh *WebsocketHub) run() {
 	for {
 	events:
 		select {
-		case hubSignal := <-wsh.HubRelay:
-			switch hubSignal {
+		case ev := <-wsh.HubRelay:
+			switch ev.sig {
 			case sigNewBlock:
-				log.Infof("Signaling new block to %d clients.", len(wsh.clients))
+				log.Infof("Signaling new block to %d clients.", wsh.NumClients())
 			case sigMempoolFeeInfoUpdate:
-				log.Infof("Signaling mempool info update to %d clients.", len(wsh.clients))
-			case sigPingAndUserCount:
-				log.Tracef("Signaling ping/user count to %d clients.", len(wsh.clients))
+				log.Infof("Signaling mempool info update to %d clients.", wsh.NumClients())
+			case sigMempoolVotes:
+				log.Debugf("Signaling mempool votes to %d clients.", wsh.NumClients())
+			case sigMempoolConflicts:
+				log.Infof("Signaling mempool conflicts to %d clients.", wsh.NumClients())
+			case sigTicketPool:
+				log.Debugf("Signaling ticket pool to %d clients.", wsh.NumClients())
+			case sigTxMempool, sigTxMined:
+				log.Debugf("Signaling %s for %s.", eventIDs[ev.sig], ev.topic)
 			default:
-				log.Errorf("Unknown hub signal: %v", hubSignal)
+				log.Errorf("Unknown hub signal: %v", ev.sig)
 				break events
 			}
+			wsh.RLock()
 			for client := range wsh.clients {
+				if !client.isSubscribed(ev.topic) {
+					continue
+				}
 				// signal or unregister the client
 				select {
-				case *client <- hubSignal:
+				case client.events <- ev:
 				default:
-					go wsh.unregisterClient(client)
+					go wsh.UnregisterClient(client)
 				}
 			}
+			wsh.RUnlock()
 		case c := <-wsh.Register:
 			wsh.registerClient(c)
 		case c := <-wsh.Unregister: